@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is the time allowed to write a message.
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from the client.
+	pongWait = 60 * time.Second
+	// pingPeriod is how often clients are pinged. It must be less than
+	// pongWait.
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageSize is the maximum size of a message from a client.
+	maxMessageSize = 4096
+	// sendQueueSize is the number of messages queued for a client before it
+	// is disconnected for being too slow.
+	sendQueueSize = 64
+)
+
+// A client is a connection to a room.
+type client struct {
+	hub  *hub
+	conn *websocket.Conn
+	// send is the queue of messages to write to the connection. The hub
+	// closes it when the client leaves.
+	send chan []byte
+
+	id   string
+	room string
+	name string
+}
+
+// readPump broadcasts the messages read from the connection to the room,
+// until the connection is closed or stops answering pings. Then the client
+// leaves the room.
+func (c *client) readPump(ctx context.Context) {
+	defer func() {
+		c.hub.leave(ctx, c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, p, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("ReadMessage: %v", err)
+			}
+			return
+		}
+		c.hub.broadcast(ctx, &message{Type: typeMessage, Room: c.room, From: c.name, Text: string(p)})
+	}
+}
+
+// writePump writes the queued messages to the connection, and pings it every
+// pingPeriod, until the hub closes the queue or a write fails.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The client left the room, possibly for being too slow.
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
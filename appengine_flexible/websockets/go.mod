@@ -2,4 +2,7 @@ module github.com/GoogleCloudPlatform/golang-samples/appengine_flexible/websocke
 
 go 1.23.0
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gomodule/redigo v2.0.0+incompatible
+	github.com/gorilla/websocket v1.5.3
+)
@@ -0,0 +1,261 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Types of messages sent to clients.
+const (
+	typeMessage  = "message"
+	typeJoin     = "join"
+	typeLeave    = "leave"
+	typePresence = "presence"
+)
+
+// message is a message sent to the clients of a room, as JSON.
+type message struct {
+	Type string `json:"type"`
+	Room string `json:"room"`
+	From string `json:"from,omitempty"`
+	Text string `json:"text,omitempty"`
+	// Members are the names of the clients in the room, for presence
+	// messages.
+	Members []string  `json:"members,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// A broker shares the messages and members of rooms between the instances of
+// the app.
+type broker interface {
+	// publish sends m to all instances, including this one.
+	publish(ctx context.Context, m *message) error
+	// subscribe calls deliver with the messages published by all instances,
+	// until ctx is done.
+	subscribe(ctx context.Context, deliver func(*message))
+	// join records that the client id named name is in room, until
+	// memberTTL has passed. Instances call it again every refreshPeriod.
+	join(ctx context.Context, room, id, name string) error
+	// leave records that the client id left room.
+	leave(ctx context.Context, room, id string) error
+	// members returns the names of the clients in room.
+	members(ctx context.Context, room string) ([]string, error)
+}
+
+const (
+	// refreshPeriod is how often the members of rooms are recorded again
+	// with the broker.
+	refreshPeriod = 30 * time.Second
+	// memberTTL is how long members are kept by the broker without being
+	// recorded again, in case their instance stopped.
+	memberTTL = 3 * refreshPeriod
+)
+
+// A hub keeps track of the clients in each room, and sends them the
+// messages of their room. Create one with newHub.
+type hub struct {
+	mu    sync.Mutex
+	rooms map[string]map[*client]bool
+
+	// broker shares rooms between instances, or is nil if there is a
+	// single instance.
+	broker broker
+}
+
+// newHub returns a hub using the broker b, which may be nil. The hub runs
+// until ctx is done.
+func newHub(ctx context.Context, b broker) *hub {
+	h := &hub{
+		rooms:  map[string]map[*client]bool{},
+		broker: b,
+	}
+	if b != nil {
+		go b.subscribe(ctx, h.deliver)
+		go h.refresh(ctx)
+	}
+	return h
+}
+
+// join adds c to its room, and tells the room.
+func (h *hub) join(ctx context.Context, c *client) {
+	h.mu.Lock()
+	if h.rooms[c.room] == nil {
+		h.rooms[c.room] = map[*client]bool{}
+	}
+	h.rooms[c.room][c] = true
+	h.mu.Unlock()
+
+	if h.broker != nil {
+		if err := h.broker.join(ctx, c.room, c.id, c.name); err != nil {
+			log.Printf("broker.join: %v", err)
+		}
+	}
+	h.broadcast(ctx, &message{Type: typeJoin, Room: c.room, From: c.name})
+	h.sendPresence(ctx, c)
+}
+
+// leave removes c from its room, closes its send queue, and tells the room.
+// It does nothing if c already left.
+func (h *hub) leave(ctx context.Context, c *client) {
+	h.mu.Lock()
+	if !h.rooms[c.room][c] {
+		h.mu.Unlock()
+		return
+	}
+	h.remove(c)
+	h.mu.Unlock()
+
+	if h.broker != nil {
+		if err := h.broker.leave(ctx, c.room, c.id); err != nil {
+			log.Printf("broker.leave: %v", err)
+		}
+	}
+	h.broadcast(ctx, &message{Type: typeLeave, Room: c.room, From: c.name})
+}
+
+// remove removes c from its room and closes its send queue. h.mu must be
+// held.
+func (h *hub) remove(c *client) {
+	delete(h.rooms[c.room], c)
+	if len(h.rooms[c.room]) == 0 {
+		delete(h.rooms, c.room)
+	}
+	close(c.send)
+}
+
+// broadcast sends m to the clients in its room, on all instances.
+func (h *hub) broadcast(ctx context.Context, m *message) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	if h.broker == nil {
+		h.deliver(m)
+		return
+	}
+	if err := h.broker.publish(ctx, m); err != nil {
+		log.Printf("broker.publish: %v", err)
+	}
+}
+
+// deliver sends m to the clients in its room on this instance. Clients whose
+// send queue is full are too slow to keep up: they are disconnected, so that
+// they don't hold up the room.
+func (h *hub) deliver(m *message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+		return
+	}
+
+	var slow []*client
+	h.mu.Lock()
+	for c := range h.rooms[m.Room] {
+		select {
+		case c.send <- data:
+		default:
+			h.remove(c)
+			slow = append(slow, c)
+		}
+	}
+	h.mu.Unlock()
+
+	ctx := context.Background()
+	for _, c := range slow {
+		log.Printf("Disconnecting %s from %s: send queue full", c.name, c.room)
+		if h.broker != nil {
+			if err := h.broker.leave(ctx, c.room, c.id); err != nil {
+				log.Printf("broker.leave: %v", err)
+			}
+		}
+		h.broadcast(ctx, &message{Type: typeLeave, Room: c.room, From: c.name})
+	}
+}
+
+// sendPresence sends c the members of its room.
+func (h *hub) sendPresence(ctx context.Context, c *client) {
+	members, err := h.members(ctx, c.room)
+	if err != nil {
+		log.Printf("members: %v", err)
+		return
+	}
+	data, err := json.Marshal(&message{Type: typePresence, Room: c.room, Members: members, Time: time.Now()})
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.rooms[c.room][c] {
+		select {
+		case c.send <- data:
+		default:
+		}
+	}
+}
+
+// members returns the sorted names of the clients in room.
+func (h *hub) members(ctx context.Context, room string) ([]string, error) {
+	var names []string
+	if h.broker != nil {
+		var err error
+		if names, err = h.broker.members(ctx, room); err != nil {
+			return nil, err
+		}
+	} else {
+		h.mu.Lock()
+		for c := range h.rooms[room] {
+			names = append(names, c.name)
+		}
+		h.mu.Unlock()
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// refresh records the clients of this instance with the broker every
+// refreshPeriod, until ctx is done.
+func (h *hub) refresh(ctx context.Context) {
+	ticker := time.NewTicker(refreshPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		h.mu.Lock()
+		var clients []*client
+		for _, room := range h.rooms {
+			for c := range room {
+				clients = append(clients, c)
+			}
+		}
+		h.mu.Unlock()
+
+		for _, c := range clients {
+			if err := h.broker.join(ctx, c.room, c.id, c.name); err != nil {
+				log.Printf("broker.join: %v", err)
+			}
+		}
+	}
+}
@@ -14,20 +14,40 @@
 
 // [START gae_flex_websockets_app]
 
-// Sample websockets demonstrates an App Engine Flexible app.
+// Sample websockets demonstrates a chat app with rooms on App Engine Flexible.
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
 
 func main() {
+	// Share rooms between instances with Redis, such as Memorystore for
+	// Redis, if it's configured.
+	var b broker
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		b = newRedisBroker(addr, os.Getenv("REDIS_PASSWORD"))
+	}
+	h := newHub(context.Background(), b)
+
+	var origins []string
+	if env := os.Getenv("ALLOWED_ORIGINS"); env != "" {
+		origins = strings.Split(env, ",")
+	}
+
 	http.Handle("/", http.FileServer(http.Dir("static")))
-	http.HandleFunc("/ws", socketHandler)
+	http.Handle("/ws", socketHandler(h, newUpgrader(origins)))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -39,32 +59,93 @@ func main() {
 	}
 }
 
-// upgrader holds the websocket connection.
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+// newUpgrader returns an upgrader accepting websocket connections from pages
+// of the app itself, or of the given origins, such as
+// "https://example.com".
+func newUpgrader(origins []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// Not a browser.
+				return true
+			}
+			u, err := url.Parse(origin)
+			if err != nil {
+				return false
+			}
+			if strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+			for _, o := range origins {
+				if strings.EqualFold(strings.TrimSpace(o), origin) {
+					return true
+				}
+			}
+			return false
+		},
+	}
 }
 
-// socketHandler echos websocket messages back to the client.
-func socketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	defer conn.Close()
+const (
+	// defaultRoom is the room of connections which don't choose one.
+	defaultRoom = "lobby"
+	// maxNameLength is the maximum length of names, in characters.
+	maxNameLength = 32
+)
 
-	if err != nil {
-		log.Printf("upgrader.Upgrade: %v", err)
-		return
-	}
+// validRoom matches the names of rooms.
+var validRoom = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
 
-	for {
-		messageType, p, err := conn.ReadMessage()
-		if err != nil {
-			log.Printf("conn.ReadMessage: %v", err)
+// socketHandler returns a handler connecting websockets to a room of h. The
+// room and the name of the user are set with the room and name query
+// parameters.
+func socketHandler(h *hub, upgrader *websocket.Upgrader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		room := r.FormValue("room")
+		if room == "" {
+			room = defaultRoom
+		}
+		if !validRoom.MatchString(room) {
+			http.Error(w, "Invalid room: use up to 64 letters, digits, - and _", http.StatusBadRequest)
 			return
 		}
-		if err := conn.WriteMessage(messageType, p); err != nil {
-			log.Printf("conn.WriteMessage: %v", err)
+		name := strings.TrimSpace(r.FormValue("name"))
+		if name == "" {
+			name = "anonymous"
+		}
+		if !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxNameLength {
+			http.Error(w, "Invalid name", http.StatusBadRequest)
 			return
 		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			log.Printf("rand.Read: %v", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		// Upgrade replies to the client if it fails.
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("upgrader.Upgrade: %v", err)
+			return
+		}
+
+		c := &client{
+			hub:  h,
+			conn: conn,
+			send: make(chan []byte, sendQueueSize),
+			id:   hex.EncodeToString(id),
+			room: room,
+			name: name,
+		}
+		go c.writePump()
+		h.join(r.Context(), c)
+		c.readPump(r.Context())
 	}
 }
 
@@ -15,39 +15,264 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-func TestSocketHandler(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(socketHandler))
-	defer server.Close()
-	dialer := websocket.Dialer{}
-
-	conn, resp, err := dialer.Dial("ws://"+server.Listener.Addr().String()+"/ws", nil)
+// dial connects to the room of the chat server, and reads the join and
+// presence messages.
+func dial(t *testing.T, server *httptest.Server, room, name string) (*websocket.Conn, *message) {
+	t.Helper()
+	u := "ws://" + server.Listener.Addr().String() + "/ws?" + url.Values{"room": {room}, "name": {name}}.Encode()
+	conn, resp, err := websocket.DefaultDialer.Dial(u, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	t.Cleanup(func() { conn.Close() })
 
 	want := http.StatusSwitchingProtocols
 	if got := resp.StatusCode; got != want {
-		t.Errorf("resp.StatusCode = %q, want %q", got, want)
+		t.Errorf("resp.StatusCode = %d, want %d", got, want)
+	}
+	if m := read(t, conn); m.Type != typeJoin || m.From != name {
+		t.Errorf("got %+v, want %s joining", m, name)
+	}
+	presence := read(t, conn)
+	if presence.Type != typePresence {
+		t.Errorf("got %+v, want presence", presence)
 	}
+	return conn, presence
+}
 
-	message := []byte("echo test")
-	if err = conn.WriteMessage(websocket.TextMessage, message); err != nil {
+// read reads a message from conn.
+func read(t *testing.T, conn *websocket.Conn) *message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var m message
+	if err := conn.ReadJSON(&m); err != nil {
 		t.Fatal(err)
 	}
+	return &m
+}
 
-	_, got, err := conn.ReadMessage()
-	if err != nil {
+func TestSocketHandler(t *testing.T) {
+	h := newHub(context.Background(), nil)
+	server := httptest.NewServer(socketHandler(h, newUpgrader(nil)))
+	defer server.Close()
+
+	conn, _ := dial(t, server, "", "gopher")
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("echo test")); err != nil {
+		t.Fatal(err)
+	}
+	got := read(t, conn)
+	if got.Type != typeMessage || got.Room != defaultRoom || got.From != "gopher" || got.Text != "echo test" {
+		t.Errorf("got %+v, want echo test from gopher in %s", got, defaultRoom)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	h := newHub(context.Background(), nil)
+	server := httptest.NewServer(socketHandler(h, newUpgrader(nil)))
+	defer server.Close()
+
+	alice, _ := dial(t, server, "gophers", "alice")
+	other, _ := dial(t, server, "other", "carol")
+	bob, presence := dial(t, server, "gophers", "bob")
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(presence.Members, want) {
+		t.Errorf("presence.Members = %q, want %q", presence.Members, want)
+	}
+	if m := read(t, alice); m.Type != typeJoin || m.From != "bob" {
+		t.Errorf("alice got %+v, want bob joining", m)
+	}
+
+	if err := bob.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if m := read(t, conn); m.Type != typeMessage || m.From != "bob" || m.Text != "hi" {
+			t.Errorf("got %+v, want hi from bob", m)
+		}
+	}
+
+	bob.Close()
+	if m := read(t, alice); m.Type != typeLeave || m.From != "bob" {
+		t.Errorf("alice got %+v, want bob leaving", m)
+	}
+
+	// Messages of other rooms aren't received.
+	if err := other.WriteMessage(websocket.TextMessage, []byte("anyone?")); err != nil {
+		t.Fatal(err)
+	}
+	if m := read(t, other); m.Text != "anyone?" {
+		t.Errorf("got %+v, want anyone?", m)
+	}
+	alice.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, p, err := alice.ReadMessage(); err == nil {
+		t.Errorf("alice got %s from another room", p)
+	}
+}
+
+func TestSocketHandlerErrors(t *testing.T) {
+	h := newHub(context.Background(), nil)
+	server := httptest.NewServer(socketHandler(h, newUpgrader([]string{"https://example.com"})))
+	defer server.Close()
+
+	tests := []struct {
+		query  string
+		origin string
+		want   int
+	}{
+		{query: "room=bad/room", want: http.StatusBadRequest},
+		{query: "name=" + strings.Repeat("x", maxNameLength+1), want: http.StatusBadRequest},
+		{origin: "https://evil.example.com", want: http.StatusForbidden},
+		{origin: "https://example.com", want: http.StatusSwitchingProtocols},
+		{origin: "http://" + server.Listener.Addr().String(), want: http.StatusSwitchingProtocols},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.origin != "" {
+			header.Set("Origin", test.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial("ws://"+server.Listener.Addr().String()+"/ws?"+test.query, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if resp == nil {
+			t.Errorf("Dial(%q, %q): %v", test.query, test.origin, err)
+			continue
+		}
+		if resp.StatusCode != test.want {
+			t.Errorf("Dial(%q, %q) status = %d, want %d", test.query, test.origin, resp.StatusCode, test.want)
+		}
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	h := newHub(context.Background(), nil)
+	// Nothing reads the queues of the clients. slow's queue is full with its
+	// join and presence messages, so it's disconnected when fast joins.
+	slow := &client{hub: h, send: make(chan []byte, 2), id: "slow", room: "r", name: "slow"}
+	fast := &client{hub: h, send: make(chan []byte, sendQueueSize), id: "fast", room: "r", name: "fast"}
+	h.join(context.Background(), slow)
+	h.join(context.Background(), fast)
+	h.broadcast(context.Background(), &message{Type: typeMessage, Room: "r", Text: "hi"})
+	if members, _ := h.members(context.Background(), "r"); !reflect.DeepEqual(members, []string{"fast"}) {
+		t.Errorf("members = %q, want [fast]", members)
+	}
+	var got []string
+	for len(fast.send) > 0 {
+		var m message
+		if err := json.Unmarshal(<-fast.send, &m); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m.Type)
+	}
+	want := []string{typeJoin, typeLeave, typePresence, typeMessage}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fast got %q, want %q", got, want)
+	}
+	for range slow.send {
+		// Drain; the loop ends because the hub closed the queue.
+	}
+}
+
+// fakeBroker is a broker shared by hubs in memory.
+type fakeBroker struct {
+	mu       sync.Mutex
+	hubs     []func(*message)
+	presence map[string]map[string]string
+}
+
+func (b *fakeBroker) publish(ctx context.Context, m *message) error {
+	b.mu.Lock()
+	hubs := b.hubs
+	b.mu.Unlock()
+	for _, deliver := range hubs {
+		deliver(m)
+	}
+	return nil
+}
+
+func (b *fakeBroker) subscribe(ctx context.Context, deliver func(*message)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.hubs = append(b.hubs, deliver)
+}
+
+func (b *fakeBroker) join(ctx context.Context, room, id, name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.presence[room] == nil {
+		b.presence[room] = map[string]string{}
+	}
+	b.presence[room][id] = name
+	return nil
+}
+
+func (b *fakeBroker) leave(ctx context.Context, room, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.presence[room], id)
+	return nil
+}
+
+func (b *fakeBroker) members(ctx context.Context, room string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var names []string
+	for _, name := range b.presence[room] {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func TestBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &fakeBroker{presence: map[string]map[string]string{}}
+	// Two instances of the app.
+	servers := make([]*httptest.Server, 2)
+	for i := range servers {
+		servers[i] = httptest.NewServer(socketHandler(newHub(ctx, b), newUpgrader(nil)))
+		defer servers[i].Close()
+	}
+	// Wait for the hubs to subscribe.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		b.mu.Lock()
+		n := len(b.hubs)
+		b.mu.Unlock()
+		if n == len(servers) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hubs didn't subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	alice, _ := dial(t, servers[0], "gophers", "alice")
+	bob, presence := dial(t, servers[1], "gophers", "bob")
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(presence.Members, want) {
+		t.Errorf("presence.Members = %q, want %q", presence.Members, want)
+	}
+	if m := read(t, alice); m.Type != typeJoin || m.From != "bob" {
+		t.Errorf("alice got %+v, want bob joining", m)
+	}
+
+	if err := alice.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
 		t.Fatal(err)
 	}
-	if !bytes.Equal(got, message) {
-		t.Errorf("got %q, want %q", got, message)
+	if m := read(t, bob); m.Type != typeMessage || m.From != "alice" || m.Text != "hi" {
+		t.Errorf("bob got %+v, want hi from alice", m)
 	}
 }
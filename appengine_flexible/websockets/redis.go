@@ -0,0 +1,203 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// redisChannel is the Redis pub/sub channel of the messages of all
+	// rooms.
+	redisChannel = "chat:messages"
+	// redisPresencePrefix is the prefix of the Redis sorted sets of the
+	// client IDs in each room, scored by when they expire.
+	redisPresencePrefix = "chat:presence:"
+	// redisNamesPrefix is the prefix of the Redis hashes of the names of the
+	// clients in each room, by ID.
+	redisNamesPrefix = "chat:names:"
+)
+
+// redisBroker is a broker using Redis, such as Memorystore for Redis, so
+// that all instances of the app share the rooms.
+type redisBroker struct {
+	pool *redis.Pool
+}
+
+// newRedisBroker returns a broker using the Redis server at addr.
+func newRedisBroker(addr, password string) *redisBroker {
+	return &redisBroker{
+		pool: &redis.Pool{
+			MaxIdle:     10,
+			IdleTimeout: 5 * time.Minute,
+			Dial: func() (redis.Conn, error) {
+				conn, err := redis.Dial("tcp", addr)
+				if password == "" {
+					return conn, err
+				}
+				if err != nil {
+					return nil, err
+				}
+				if _, err := conn.Do("AUTH", password); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return conn, nil
+			},
+		},
+	}
+}
+
+func (b *redisBroker) publish(ctx context.Context, m *message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	conn := b.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", redisChannel, data); err != nil {
+		return fmt.Errorf("PUBLISH: %w", err)
+	}
+	return nil
+}
+
+// subscribe delivers the messages of redisChannel until ctx is done. If the
+// connection to Redis fails, it reconnects, and the messages published in
+// the meantime are lost.
+func (b *redisBroker) subscribe(ctx context.Context, deliver func(*message)) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := b.receive(ctx, deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("Redis subscription failed, retrying in %v: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// receive subscribes to redisChannel and delivers its messages, until ctx is
+// done or the connection fails.
+func (b *redisBroker) receive(ctx context.Context, deliver func(*message)) error {
+	psc := redis.PubSubConn{Conn: b.pool.Get()}
+	defer psc.Close()
+	if err := psc.Subscribe(redisChannel); err != nil {
+		return fmt.Errorf("SUBSCRIBE: %w", err)
+	}
+
+	// Receive blocks, so close the connection to stop it when ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			psc.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var m message
+			if err := json.Unmarshal(v.Data, &m); err != nil {
+				log.Printf("json.Unmarshal: %v", err)
+				continue
+			}
+			deliver(&m)
+		case error:
+			return v
+		}
+	}
+}
+
+func (b *redisBroker) join(ctx context.Context, room, id, name string) error {
+	conn := b.pool.Get()
+	defer conn.Close()
+	expires := time.Now().Add(memberTTL).UnixMilli()
+	if _, err := conn.Do("ZADD", redisPresencePrefix+room, expires, id); err != nil {
+		return fmt.Errorf("ZADD: %w", err)
+	}
+	if _, err := conn.Do("HSET", redisNamesPrefix+room, id, name); err != nil {
+		return fmt.Errorf("HSET: %w", err)
+	}
+	// Keep the room as long as its latest member.
+	for _, key := range []string{redisPresencePrefix + room, redisNamesPrefix + room} {
+		if _, err := conn.Do("PEXPIRE", key, memberTTL.Milliseconds()); err != nil {
+			return fmt.Errorf("PEXPIRE: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *redisBroker) leave(ctx context.Context, room, id string) error {
+	conn := b.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("ZREM", redisPresencePrefix+room, id); err != nil {
+		return fmt.Errorf("ZREM: %w", err)
+	}
+	if _, err := conn.Do("HDEL", redisNamesPrefix+room, id); err != nil {
+		return fmt.Errorf("HDEL: %w", err)
+	}
+	return nil
+}
+
+func (b *redisBroker) members(ctx context.Context, room string) ([]string, error) {
+	conn := b.pool.Get()
+	defer conn.Close()
+
+	// Remove the members of instances which stopped without them leaving.
+	now := time.Now().UnixMilli()
+	expired, err := redis.Strings(conn.Do("ZRANGEBYSCORE", redisPresencePrefix+room, "-inf", now))
+	if err != nil {
+		return nil, fmt.Errorf("ZRANGEBYSCORE: %w", err)
+	}
+	for _, id := range expired {
+		if err := b.leave(ctx, room, id); err != nil {
+			return nil, err
+		}
+	}
+
+	ids, err := redis.Strings(conn.Do("ZRANGEBYSCORE", redisPresencePrefix+room, now, "+inf"))
+	if err != nil {
+		return nil, fmt.Errorf("ZRANGEBYSCORE: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := redis.Args{}.Add(redisNamesPrefix + room).AddFlat(ids)
+	names, err := redis.Strings(conn.Do("HMGET", args...))
+	if err != nil {
+		return nil, fmt.Errorf("HMGET: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,398 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// copyCheckpointPrefix is the prefix of the rows of the checkpoints of
+	// copies, followed by the source table. A checkpoint row has the
+	// boundaries of the ranges of the copy in its boundsColumn, and a column
+	// for each range copied, with the number of rows copied.
+	copyCheckpointPrefix = "#copy#"
+	boundsColumn         = "bounds"
+	// maxConcurrentCopies is the maximum number of ranges copied
+	// concurrently.
+	maxConcurrentCopies = 8
+	// copyBatchSize is the number of rows written by each ApplyBulk of a
+	// copy.
+	copyBatchSize = 100
+)
+
+// copyStatus is the status of a copy, reported by /copy/status.
+type copyStatus struct {
+	Source string `json:"source"`
+	// State is running, done or failed.
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+	// Ranges is the number of ranges of row keys to copy, and RangesDone
+	// the number copied, including RangesResumed, the ones copied by an
+	// earlier copy which was interrupted.
+	Ranges        int        `json:"ranges"`
+	RangesDone    int        `json:"rangesDone"`
+	RangesResumed int        `json:"rangesResumed"`
+	RowsCopied    int64      `json:"rowsCopied"`
+	Started       time.Time  `json:"started"`
+	Finished      *time.Time `json:"finished,omitempty"`
+}
+
+// copyProgress tracks the status of a copy.
+type copyProgress struct {
+	mu     sync.Mutex
+	status copyStatus
+}
+
+// update changes the status with f.
+func (p *copyProgress) update(f func(s *copyStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	f(&p.status)
+}
+
+// get returns the status.
+func (p *copyProgress) get() copyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// copyTable copies the documents and index of the table src to the table dst,
+// and then rebuilds the statistics of dst.
+//
+// The row keys are split into ranges with SampleRowKeys, and the ranges are
+// copied concurrently. The ranges copied are saved in a checkpoint row of dst,
+// so that copying the same table again after a failure resumes the copy.
+func copyTable(ctx context.Context, src, dst string, client *bigtable.Client, p *copyProgress) error {
+	srcTable := client.Open(src)
+	dstTable := client.Open(dst)
+	checkpoint := copyCheckpointPrefix + src
+
+	bounds, done, err := readCheckpoint(ctx, dstTable, checkpoint)
+	if err != nil {
+		return err
+	}
+	if bounds == nil {
+		if bounds, err = splitKeys(ctx, srcTable); err != nil {
+			return err
+		}
+		data, err := json.Marshal(bounds)
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %w", err)
+		}
+		mut := bigtable.NewMutation()
+		mut.Set(contentColumnFamily, boundsColumn, bigtable.Now(), data)
+		if err := dstTable.Apply(ctx, checkpoint, mut); err != nil {
+			return fmt.Errorf("Apply: %w", err)
+		}
+	}
+	p.update(func(s *copyStatus) {
+		s.Ranges = len(bounds)
+		s.RangesDone = len(done)
+		s.RangesResumed = len(done)
+		for _, rows := range done {
+			s.RowsCopied += rows
+		}
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		copyErr error          // Set if any range fails.
+		mu      sync.Mutex     // Protects copyErr
+		wg      sync.WaitGroup // Used to wait for all copies to finish.
+		sem     = make(chan struct{}, maxConcurrentCopies)
+	)
+	for i := range bounds {
+		if _, ok := done[i]; ok {
+			continue
+		}
+		end := ""
+		if i+1 < len(bounds) {
+			end = bounds[i+1]
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			rows, err := copyRange(ctx, srcTable, dstTable, bigtable.NewRange(bounds[i], end), func(rows int) {
+				p.update(func(s *copyStatus) { s.RowsCopied += int64(rows) })
+			})
+			if err == nil {
+				err = markCopied(ctx, dstTable, checkpoint, i, rows)
+			}
+			if err == nil {
+				p.update(func(s *copyStatus) { s.RangesDone++ })
+			}
+			if err != nil {
+				mu.Lock()
+				if copyErr == nil {
+					copyErr = fmt.Errorf("error copying range %d: %w", i, err)
+				}
+				mu.Unlock()
+				// Stop the other ranges: the copy resumes from the
+				// checkpoint.
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if copyErr != nil {
+		return copyErr
+	}
+
+	if err := rebuildStats(ctx, dstTable); err != nil {
+		return err
+	}
+	mut := bigtable.NewMutation()
+	mut.DeleteRow()
+	if err := dstTable.Apply(ctx, checkpoint, mut); err != nil {
+		return fmt.Errorf("Apply: %w", err)
+	}
+	return nil
+}
+
+// splitKeys returns the boundaries of ranges of row keys of about the same
+// size: range i has the keys from bounds[i] to bounds[i+1], excluded, and the
+// last range has the keys from the last bound.
+func splitKeys(ctx context.Context, table *bigtable.Table) ([]string, error) {
+	keys, err := table.SampleRowKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SampleRowKeys: %w", err)
+	}
+	bounds := []string{""}
+	for _, key := range keys {
+		if key > bounds[len(bounds)-1] {
+			bounds = append(bounds, key)
+		}
+	}
+	return bounds, nil
+}
+
+// readCheckpoint returns the boundaries of the ranges of the copy with the
+// checkpoint row, and the number of rows of each range copied, by range.
+// bounds is nil if there's no checkpoint.
+func readCheckpoint(ctx context.Context, table *bigtable.Table, checkpoint string) (bounds []string, done map[int]int64, err error) {
+	row, err := table.ReadRow(ctx, checkpoint, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ReadRow: %w", err)
+	}
+	done = make(map[int]int64)
+	for _, item := range row[contentColumnFamily] {
+		column := strings.TrimPrefix(item.Column, contentColumnFamily+":")
+		if column == boundsColumn {
+			if err := json.Unmarshal(item.Value, &bounds); err != nil {
+				return nil, nil, fmt.Errorf("invalid checkpoint: %w", err)
+			}
+			continue
+		}
+		i, err := strconv.Atoi(column)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid checkpoint column %q", column)
+		}
+		rows, err := strconv.ParseInt(string(item.Value), 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid checkpoint column %q: %w", column, err)
+		}
+		done[i] = rows
+	}
+	if bounds == nil && len(done) > 0 {
+		return nil, nil, errors.New("invalid checkpoint: no bounds")
+	}
+	return bounds, done, nil
+}
+
+// markCopied saves that range i was copied, with its number of rows, in the
+// checkpoint row.
+func markCopied(ctx context.Context, table *bigtable.Table, checkpoint string, i int, rows int64) error {
+	mut := bigtable.NewMutation()
+	mut.Set(contentColumnFamily, strconv.Itoa(i), bigtable.Now(), []byte(strconv.FormatInt(rows, 10)))
+	if err := table.Apply(ctx, checkpoint, mut); err != nil {
+		return fmt.Errorf("Apply: %w", err)
+	}
+	return nil
+}
+
+// copyRange copies the rows of rr from src to dst, in batches written with
+// ApplyBulk, and returns the number of rows copied. It calls copied with the
+// number of rows of each batch.
+func copyRange(ctx context.Context, src, dst *bigtable.Table, rr bigtable.RowRange, copied func(rows int)) (int64, error) {
+	var (
+		keys []string
+		muts []*bigtable.Mutation
+		n    int64
+	)
+	flush := func() error {
+		errs, err := dst.ApplyBulk(ctx, keys, muts)
+		if err != nil {
+			return fmt.Errorf("ApplyBulk: %w", err)
+		}
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("ApplyBulk: row %q: %w", keys[i], err)
+			}
+		}
+		copied(len(keys))
+		n += int64(len(keys))
+		keys, muts = keys[:0], muts[:0]
+		return nil
+	}
+
+	var flushErr error
+	copyRow := func(row bigtable.Row) bool {
+		// The statistics and checkpoints are specific to each table.
+		if strings.HasPrefix(row.Key(), "#") {
+			return true
+		}
+		mut := bigtable.NewMutation()
+		for family, items := range row {
+			for _, item := range items {
+				// Get the column name, excluding the column family name and ':' character.
+				columnWithoutFamily := item.Column[len(family)+1:]
+				mut.Set(family, columnWithoutFamily, bigtable.Now(), item.Value)
+			}
+		}
+		keys = append(keys, row.Key())
+		muts = append(muts, mut)
+		if len(keys) == copyBatchSize {
+			flushErr = flush()
+		}
+		return flushErr == nil
+	}
+
+	// Create a filter that only accepts the column families we're interested in.
+	filter := bigtable.ChainFilters(
+		bigtable.FamilyFilter(indexColumnFamily+"|"+contentColumnFamily),
+		bigtable.LatestNFilter(1),
+	)
+	if err := src.ReadRows(ctx, rr, copyRow, bigtable.RowFilter(filter)); err != nil {
+		return n, fmt.Errorf("ReadRows: %w", err)
+	}
+	if flushErr != nil {
+		return n, flushErr
+	}
+	if len(keys) > 0 {
+		if err := flush(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// copier runs the copies to a table, one at a time.
+type copier struct {
+	client *bigtable.Client
+	dst    string
+
+	mu sync.Mutex
+	// progress is the progress of the last copy, or nil.
+	progress *copyProgress
+}
+
+// start starts copying the table src in the background. It returns false if
+// a copy is already running.
+func (c *copier) start(src string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.progress != nil && c.progress.get().State == "running" {
+		return false
+	}
+	p := &copyProgress{status: copyStatus{Source: src, State: "running", Started: time.Now()}}
+	c.progress = p
+	go func() {
+		err := copyTable(context.Background(), src, c.dst, c.client, p)
+		if err != nil {
+			log.Printf("copyTable(%q): %v", src, err)
+		}
+		p.update(func(s *copyStatus) {
+			now := time.Now()
+			s.Finished = &now
+			s.State = "done"
+			if err != nil {
+				s.State = "failed"
+				s.Error = err.Error()
+			}
+		})
+	}()
+	return true
+}
+
+// status returns the status of the last copy, if any.
+func (c *copier) status() (copyStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.progress == nil {
+		return copyStatus{}, false
+	}
+	return c.progress.get(), true
+}
+
+// handleCopy starts copying data from another table to this one.
+func handleCopy(w http.ResponseWriter, r *http.Request, c *copier, adminClient *bigtable.AdminClient) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
+	}
+	src := r.FormValue("name")
+	if src == "" {
+		http.Error(w, "No source table specified.", http.StatusBadRequest)
+		return
+	}
+	if src == c.dst {
+		http.Error(w, "Can't copy a table to itself.", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := adminClient.TableInfo(ctx, src); err != nil {
+		http.Error(w, "Error reading source table: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !c.start(src) {
+		http.Error(w, "A copy is already running, see /copy/status.", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprint(w, "Copying table, see /copy/status for progress.\n")
+}
+
+// handleCopyStatus responds with the status of the last copy, as JSON.
+func handleCopyStatus(w http.ResponseWriter, r *http.Request, c *copier) {
+	status, ok := c.status()
+	if !ok {
+		http.Error(w, "No copy started.", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("json.Encode: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/google/go-cmp/cmp"
+)
+
+// saveTestDocuments saves documents, by name, to table.
+func saveTestDocuments(t *testing.T, table *bigtable.Table, docs map[string]string) {
+	t.Helper()
+	for name, content := range docs {
+		if err := saveDocument(context.Background(), table, name, &content, false); err != nil {
+			t.Fatalf("saveDocument(%q): %v", name, err)
+		}
+	}
+}
+
+func TestCopyTable(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, "source", "docindex")
+	src := map[string]string{}
+	for i := range 30 {
+		src[fmt.Sprintf("doc%02d", i)] = fmt.Sprintf("Copied document %d.", i)
+	}
+	saveTestDocuments(t, client.Open("source"), src)
+	dst := client.Open("docindex")
+	saveTestDocuments(t, dst, map[string]string{"own": "A copied word, in the destination."})
+
+	p := &copyProgress{}
+	if err := copyTable(ctx, "source", "docindex", client, p); err != nil {
+		t.Fatalf("copyTable: %v", err)
+	}
+	status := p.get()
+	if status.Ranges == 0 || status.RangesDone != status.Ranges || status.RangesResumed != 0 {
+		t.Errorf("copyTable got status %+v, want all ranges done", status)
+	}
+
+	if got := len(searchNames(t, dst, "copied")); got != 31 {
+		t.Errorf("search after copy got %d results, want 31", got)
+	}
+	stats, err := readStats(ctx, dst)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if stats.docs != 31 {
+		t.Errorf("readStats after copy got %d documents, want 31", stats.docs)
+	}
+	bounds, _, err := readCheckpoint(ctx, dst, copyCheckpointPrefix+"source")
+	if err != nil || bounds != nil {
+		t.Errorf("readCheckpoint after copy got %v, %v, want no checkpoint", bounds, err)
+	}
+}
+
+func TestCopyTableResume(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, "source", "docindex")
+	saveTestDocuments(t, client.Open("source"), map[string]string{
+		"apple": "An apple.",
+		"zebra": "A zebra.",
+	})
+
+	// An interrupted copy split the rows at "m", and copied the first range.
+	dst := client.Open("docindex")
+	mut := bigtable.NewMutation()
+	mut.Set(contentColumnFamily, boundsColumn, bigtable.Now(), []byte(`["", "m"]`))
+	mut.Set(contentColumnFamily, "0", bigtable.Now(), []byte("5"))
+	if err := dst.Apply(ctx, copyCheckpointPrefix+"source", mut); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	p := &copyProgress{}
+	if err := copyTable(ctx, "source", "docindex", client, p); err != nil {
+		t.Fatalf("copyTable: %v", err)
+	}
+	want := copyStatus{Ranges: 2, RangesDone: 2, RangesResumed: 1, RowsCopied: 6}
+	if diff := cmp.Diff(want, p.get()); diff != "" {
+		t.Errorf("copyTable status mismatch (-want +got):\n%s", diff)
+	}
+	// Only the second range was copied.
+	if diff := cmp.Diff([]string{"zebra"}, searchNames(t, dst, "zebra")); diff != "" {
+		t.Errorf("search mismatch (-want +got):\n%s", diff)
+	}
+	if got := searchNames(t, dst, "apple"); got != nil {
+		t.Errorf("search for a document of the copied range got %v, want no results", got)
+	}
+}
+
+func TestCopyHandlers(t *testing.T) {
+	client, adminClient := newTestClient(t, "source", "docindex")
+	saveTestDocuments(t, client.Open("source"), map[string]string{"doc": "A document."})
+	c := &copier{client: client, dst: "docindex"}
+
+	rr := httptest.NewRecorder()
+	handleCopyStatus(rr, httptest.NewRequest("GET", "/copy/status", nil), c)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET /copy/status before a copy got status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	for _, test := range []struct {
+		src        string
+		wantStatus int
+	}{
+		{src: "docindex", wantStatus: http.StatusBadRequest},
+		{src: "missing", wantStatus: http.StatusBadRequest},
+		{src: "source", wantStatus: http.StatusAccepted},
+	} {
+		r := httptest.NewRequest("POST", "/copy", strings.NewReader(url.Values{"name": {test.src}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		handleCopy(rr, r, c, adminClient)
+		if rr.Code != test.wantStatus {
+			t.Errorf("POST /copy of %q got status %d, want %d: %s", test.src, rr.Code, test.wantStatus, rr.Body)
+		}
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		rr := httptest.NewRecorder()
+		handleCopyStatus(rr, httptest.NewRequest("GET", "/copy/status", nil), c)
+		var status copyStatus
+		if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+			t.Fatalf("json.Decode: %v", err)
+		}
+		if status.State == "done" {
+			if status.Source != "source" || status.Finished == nil {
+				t.Errorf("GET /copy/status got %+v, want a finished copy of source", status)
+			}
+			break
+		}
+		if status.State != "running" || time.Now().After(deadline) {
+			t.Fatalf("GET /copy/status got %+v, want a copy running, then done", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// hashColumn is the SHA-256 hash of the content of a document, in its
+	// content row. Updates check that it hasn't changed since they read the
+	// document.
+	hashColumn = "hash"
+	// maxConflictRetries is the number of times an update is retried when
+	// the document changes concurrently.
+	maxConflictRetries = 3
+	// maxConcurrentWrites is the maximum number of concurrent writes of the
+	// postings of a document.
+	maxConcurrentWrites = 32
+	// bulkBatchSize is the number of documents written by each ApplyBulk of
+	// a bulk ingestion.
+	bulkBatchSize = 100
+)
+
+var (
+	errNotFound = errors.New("document not found")
+	errConflict = errors.New("document changed concurrently")
+	// errInvalidDocument is the error of a bulk ingestion with an invalid
+	// document.
+	errInvalidDocument = errors.New("invalid document")
+)
+
+// A document is the version of a document read from its content row.
+type document struct {
+	content string
+	// hash is the hash of the content, or "" for documents added by earlier
+	// versions of this sample.
+	hash   string
+	exists bool
+}
+
+// parseDocument returns the document in row.
+func parseDocument(row bigtable.Row) document {
+	var d document
+	for _, item := range row[contentColumnFamily] {
+		switch item.Column {
+		case contentColumnFamily + ":":
+			d.content, d.exists = string(item.Value), true
+		case contentColumnFamily + ":" + hashColumn:
+			d.hash = string(item.Value)
+		}
+	}
+	return d
+}
+
+// readDocument reads the current version of a document.
+func readDocument(ctx context.Context, table *bigtable.Table, name string) (document, error) {
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(contentColumnFamily), bigtable.LatestNFilter(1))
+	row, err := table.ReadRow(ctx, name, bigtable.RowFilter(filter))
+	if err != nil {
+		return document{}, fmt.Errorf("ReadRow: %w", err)
+	}
+	return parseDocument(row), nil
+}
+
+// contentHash returns the hash of the content of a document.
+func contentHash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+// checkName returns an error if name can't be the name of a document.
+func checkName(name string) error {
+	if name == "" {
+		return errors.New("empty document name")
+	}
+	// Rows starting with # have the statistics and the checkpoints of
+	// copies.
+	if strings.HasPrefix(name, "#") {
+		return errors.New("names starting with # are reserved")
+	}
+	return nil
+}
+
+// saveDocument stores the content of a document, replacing its previous
+// version, or deletes the document if content is nil. If mustExist is set,
+// or the document is deleted, it fails with errNotFound if the document
+// doesn't exist. Updates conflicting with concurrent ones are retried.
+//
+// Saving a document again with the same content rewrites its postings, which
+// repairs them if a previous update failed midway.
+func saveDocument(ctx context.Context, table *bigtable.Table, name string, content *string, mustExist bool) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		old, err := readDocument(ctx, table, name)
+		if err != nil {
+			return err
+		}
+		if (mustExist || content == nil) && !old.exists {
+			return errNotFound
+		}
+		err = replaceDocument(ctx, table, name, old, content)
+		if err != errConflict || attempt == maxConflictRetries {
+			return err
+		}
+	}
+}
+
+// replaceDocument replaces the version old of a document with content, or
+// deletes the document if content is nil. It fails with errConflict if the
+// document isn't at version old anymore, without changing it.
+//
+// Bigtable only changes single rows atomically, so the content row is changed
+// first with a conditional mutation, then the postings. The postings are
+// written with the timestamp of the new version, and only if there's no
+// newer posting, so that the postings of concurrent updates finishing out of
+// order don't replace newer ones.
+func replaceDocument(ctx context.Context, table *bigtable.Table, name string, old document, content *string) error {
+	ts := bigtable.Now().TruncateToMilliseconds()
+	var (
+		newTokens []token
+		newHash   string
+	)
+	mut := bigtable.NewMutation()
+	if content != nil {
+		newTokens = tokenize(*content)
+		newHash = contentHash(*content)
+		mut.Set(contentColumnFamily, "", ts, []byte(*content))
+		mut.Set(contentColumnFamily, hashColumn, ts, []byte(newHash))
+	} else {
+		// The row may also be the index row of a term: only delete the
+		// content.
+		mut.DeleteCellsInFamily(contentColumnFamily)
+	}
+
+	// Only change the content row if its hash is still the one read.
+	hashFilter := bigtable.ChainFilters(
+		bigtable.FamilyFilter(contentColumnFamily),
+		bigtable.ColumnFilter(hashColumn),
+		bigtable.LatestNFilter(1),
+	)
+	var cond *bigtable.Mutation
+	if old.hash != "" {
+		cond = bigtable.NewCondMutation(bigtable.ChainFilters(hashFilter, bigtable.ValueFilter(old.hash)), mut, nil)
+	} else {
+		cond = bigtable.NewCondMutation(hashFilter, nil, mut)
+	}
+	var matched bool
+	if err := table.Apply(ctx, name, cond, bigtable.GetCondMutationResult(&matched)); err != nil {
+		return fmt.Errorf("Apply: %w", err)
+	}
+	if matched != (old.hash != "") {
+		return errConflict
+	}
+
+	var oldTokens []token
+	if old.exists {
+		oldTokens = tokenize(old.content)
+	}
+	if err := writePostings(ctx, table, name, ts, postings(oldTokens), postings(newTokens), old.hash == newHash); err != nil {
+		return err
+	}
+
+	var docs int64
+	if !old.exists {
+		docs++
+	}
+	if content == nil {
+		docs--
+	}
+	return updateStats(ctx, table, docs, int64(len(newTokens)-len(oldTokens)))
+}
+
+// writePostings changes the postings of a document from old to new, at
+// timestamp ts. Unchanged postings are only written if rewrite is set.
+func writePostings(ctx context.Context, table *bigtable.Table, name string, ts bigtable.Timestamp, old, new map[string]*posting, rewrite bool) error {
+	newer := bigtable.ChainFilters(
+		bigtable.FamilyFilter(indexColumnFamily),
+		bigtable.ColumnFilter(regexp.QuoteMeta(name)),
+		bigtable.TimestampRangeFilterMicros(ts+1, 0),
+	)
+	muts := make(map[string]*bigtable.Mutation)
+	for term, p := range new {
+		value := p.encode()
+		if !rewrite && old[term] != nil && bytes.Equal(old[term].encode(), value) {
+			continue
+		}
+		set := bigtable.NewMutation()
+		set.Set(indexColumnFamily, name, ts, value)
+		muts[term] = bigtable.NewCondMutation(newer, nil, set)
+	}
+	for term := range old {
+		if new[term] == nil {
+			del := bigtable.NewMutation()
+			del.DeleteTimestampRange(indexColumnFamily, name, 0, ts)
+			muts[term] = del
+		}
+	}
+
+	var (
+		writeErr error          // Set if any write fails.
+		mu       sync.Mutex     // Protects writeErr
+		wg       sync.WaitGroup // Used to wait for all writes to finish.
+		sem      = make(chan struct{}, maxConcurrentWrites)
+	)
+	for row, mut := range muts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := table.Apply(ctx, row, mut); err != nil {
+				mu.Lock()
+				writeErr = fmt.Errorf("Apply: %w", err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return writeErr
+}
+
+// A bulkDocument is a document of a bulk ingestion.
+type bulkDocument struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// ingestDocuments stores the documents read from r, a stream of JSON objects
+// with name and content fields, in batches of bulkBatchSize documents. Each
+// batch is written with one ApplyBulk. It returns the number of documents
+// stored.
+//
+// Unlike saveDocument, ingestDocuments doesn't check whether the documents
+// are changed concurrently: the last write of a document wins.
+func ingestDocuments(ctx context.Context, table *bigtable.Table, r io.Reader) (int, error) {
+	dec := json.NewDecoder(r)
+	var (
+		batch []bulkDocument
+		names = make(map[string]bool)
+		n     int
+	)
+	flush := func() error {
+		if err := ingestBatch(ctx, table, batch); err != nil {
+			return err
+		}
+		n += len(batch)
+		batch = batch[:0]
+		clear(names)
+		return nil
+	}
+	for {
+		var d bulkDocument
+		err := dec.Decode(&d)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("%w %d: %v", errInvalidDocument, n+len(batch)+1, err)
+		}
+		if err := checkName(d.Name); err != nil {
+			return n, fmt.Errorf("%w %d: %v", errInvalidDocument, n+len(batch)+1, err)
+		}
+		if d.Content == "" {
+			return n, fmt.Errorf("%w %d: empty content", errInvalidDocument, n+len(batch)+1)
+		}
+		// A batch has each document once, so that it's diffed with its
+		// previous version.
+		if len(batch) == bulkBatchSize || names[d.Name] {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+		batch = append(batch, d)
+		names[d.Name] = true
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ingestBatch stores a batch of distinct documents with one ApplyBulk.
+func ingestBatch(ctx context.Context, table *bigtable.Table, batch []bulkDocument) error {
+	names := make(bigtable.RowList, len(batch))
+	for i, d := range batch {
+		names[i] = d.Name
+	}
+	old := make(map[string]document)
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(contentColumnFamily), bigtable.LatestNFilter(1))
+	err := table.ReadRows(ctx, names, func(row bigtable.Row) bool {
+		old[row.Key()] = parseDocument(row)
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return fmt.Errorf("ReadRows: %w", err)
+	}
+
+	// Build one mutation per row, for the content rows and the index rows.
+	ts := bigtable.Now().TruncateToMilliseconds()
+	var (
+		keys  []string
+		muts  []*bigtable.Mutation
+		byKey = make(map[string]*bigtable.Mutation)
+	)
+	mutation := func(row string) *bigtable.Mutation {
+		if m, ok := byKey[row]; ok {
+			return m
+		}
+		m := bigtable.NewMutation()
+		byKey[row] = m
+		keys = append(keys, row)
+		muts = append(muts, m)
+		return m
+	}
+	var docs, length int64
+	for _, d := range batch {
+		var oldPostings map[string]*posting
+		if old := old[d.Name]; old.exists {
+			oldTokens := tokenize(old.content)
+			oldPostings = postings(oldTokens)
+			length -= int64(len(oldTokens))
+		} else {
+			docs++
+		}
+		newTokens := tokenize(d.Content)
+		newPostings := postings(newTokens)
+		length += int64(len(newTokens))
+
+		m := mutation(d.Name)
+		m.Set(contentColumnFamily, "", ts, []byte(d.Content))
+		m.Set(contentColumnFamily, hashColumn, ts, []byte(contentHash(d.Content)))
+		for term, p := range newPostings {
+			mutation(term).Set(indexColumnFamily, d.Name, ts, p.encode())
+		}
+		for term := range oldPostings {
+			if newPostings[term] == nil {
+				mutation(term).DeleteTimestampRange(indexColumnFamily, d.Name, 0, ts)
+			}
+		}
+	}
+
+	errs, err := table.ApplyBulk(ctx, keys, muts)
+	if err != nil {
+		return fmt.Errorf("ApplyBulk: %w", err)
+	}
+	var failed []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Errorf("row %q: %w", keys[i], err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("ApplyBulk failed for %d rows: %w", len(failed), errors.Join(failed...))
+	}
+	return updateStats(ctx, table, docs, length)
+}
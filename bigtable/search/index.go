@@ -0,0 +1,246 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// statsRow is the row of the statistics of all the documents, used for
+	// scoring. Its columns are in the content column family.
+	statsRow = "#stats"
+	// docsColumn is the number of documents in statsRow.
+	docsColumn = "docs"
+	// lengthColumn is the total number of terms of the documents in
+	// statsRow.
+	lengthColumn = "length"
+)
+
+// stopwords are common words which aren't indexed. They still count for the
+// positions of the other words, so phrases containing them match.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true,
+	"their": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "to": true, "was": true, "will": true, "with": true,
+}
+
+// A token is a term of a text, and the position of its word in the text.
+type token struct {
+	term string
+	pos  int
+}
+
+// words splits s into lowercase words.
+func words(s string) []string {
+	f := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+	for i, word := range f {
+		f[i] = strings.ToLower(word)
+	}
+	return f
+}
+
+// tokenize returns the terms of s to index: the stems of its words, except
+// the stopwords.
+func tokenize(s string) []token {
+	var tokens []token
+	for pos, word := range words(s) {
+		if stopwords[word] {
+			continue
+		}
+		tokens = append(tokens, token{term: stem(word), pos: pos})
+	}
+	return tokens
+}
+
+// stem removes common English inflections from word, so that "runs" and
+// "running" are both indexed as "run".
+// This is very simple, see the Porter stemmer for a good stemming function.
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 5 && strings.HasSuffix(word, "ing"):
+		return undouble(word[:len(word)-3])
+	case len(word) > 4 && strings.HasSuffix(word, "ed"):
+		return undouble(word[:len(word)-2])
+	case len(word) > 3 && strings.HasSuffix(word, "s") &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// undouble removes the doubled final consonant of a stem, such as in
+// "stopp" from "stopped".
+func undouble(s string) string {
+	n := len(s)
+	if n < 4 || s[n-1] != s[n-2] || strings.IndexByte("aeioulsz", s[n-1]) >= 0 {
+		return s
+	}
+	return s[:n-1]
+}
+
+// A posting is the entry of a document in the index row of a term.
+type posting struct {
+	// length is the number of terms of the document.
+	length int
+	// positions are the positions of the term in the document, in
+	// increasing order.
+	positions []int
+}
+
+// postings returns the posting of each term of tokens, for a document of
+// len(tokens) terms.
+func postings(tokens []token) map[string]*posting {
+	p := make(map[string]*posting)
+	for _, t := range tokens {
+		if p[t.term] == nil {
+			p[t.term] = &posting{length: len(tokens)}
+		}
+		p[t.term].positions = append(p[t.term].positions, t.pos)
+	}
+	return p
+}
+
+// encode returns the value of the posting in the index: the document length,
+// the number of positions and the delta-encoded positions, as varints.
+func (p *posting) encode() []byte {
+	b := binary.AppendUvarint(nil, uint64(p.length))
+	b = binary.AppendUvarint(b, uint64(len(p.positions)))
+	last := 0
+	for _, pos := range p.positions {
+		b = binary.AppendUvarint(b, uint64(pos-last))
+		last = pos
+	}
+	return b
+}
+
+// decodePosting decodes a posting encoded with encode.
+func decodePosting(b []byte) (*posting, error) {
+	next := func() (int, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 || v > 1<<31 {
+			return 0, errors.New("invalid posting")
+		}
+		b = b[n:]
+		return int(v), nil
+	}
+	length, err := next()
+	if err != nil {
+		return nil, err
+	}
+	count, err := next()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > length {
+		return nil, errors.New("invalid posting")
+	}
+	p := &posting{length: length, positions: make([]int, count)}
+	last := 0
+	for i := range p.positions {
+		delta, err := next()
+		if err != nil {
+			return nil, err
+		}
+		last += delta
+		p.positions[i] = last
+	}
+	return p, nil
+}
+
+// updateStats adds docs documents of length terms to the statistics.
+func updateStats(ctx context.Context, table *bigtable.Table, docs, length int64) error {
+	if docs == 0 && length == 0 {
+		return nil
+	}
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(contentColumnFamily, docsColumn, docs)
+	rmw.Increment(contentColumnFamily, lengthColumn, length)
+	if _, err := table.ApplyReadModifyWrite(ctx, statsRow, rmw); err != nil {
+		return fmt.Errorf("ApplyReadModifyWrite: %w", err)
+	}
+	return nil
+}
+
+// rebuildStats computes the statistics of the documents of table again, by
+// reading all of them. Documents saved while it runs may be missed.
+func rebuildStats(ctx context.Context, table *bigtable.Table) error {
+	var docs, length int64
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(contentColumnFamily), bigtable.LatestNFilter(1))
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		if doc := parseDocument(row); doc.exists && !strings.HasPrefix(row.Key(), "#") {
+			docs++
+			length += int64(len(tokenize(doc.content)))
+		}
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return fmt.Errorf("ReadRows: %w", err)
+	}
+
+	ts := bigtable.Now()
+	mut := bigtable.NewMutation()
+	mut.Set(contentColumnFamily, docsColumn, ts, binary.BigEndian.AppendUint64(nil, uint64(docs)))
+	mut.Set(contentColumnFamily, lengthColumn, ts, binary.BigEndian.AppendUint64(nil, uint64(length)))
+	if err := table.Apply(ctx, statsRow, mut); err != nil {
+		return fmt.Errorf("Apply: %w", err)
+	}
+	return nil
+}
+
+// corpusStats are the statistics of all the documents.
+type corpusStats struct {
+	docs int64
+	// avgLength is the average number of terms of the documents.
+	avgLength float64
+}
+
+// readStats reads the statistics of the documents.
+func readStats(ctx context.Context, table *bigtable.Table) (corpusStats, error) {
+	row, err := table.ReadRow(ctx, statsRow, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return corpusStats{}, fmt.Errorf("ReadRow: %w", err)
+	}
+	var docs, length int64
+	for _, item := range row[contentColumnFamily] {
+		if len(item.Value) != 8 {
+			continue
+		}
+		v := int64(binary.BigEndian.Uint64(item.Value))
+		switch item.Column {
+		case contentColumnFamily + ":" + docsColumn:
+			docs = v
+		case contentColumnFamily + ":" + lengthColumn:
+			length = v
+		}
+	}
+	s := corpusStats{docs: docs}
+	if docs > 0 {
+		s.avgLength = float64(length) / float64(docs)
+	}
+	return s, nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/bigtable"
+)
+
+const (
+	// k1 and b are the parameters of the Okapi BM25 scoring function.
+	k1 = 1.2
+	b  = 0.75
+	// minPrefixLength is the minimum length of the prefix of a prefix query.
+	minPrefixLength = 2
+	// maxPrefixTerms is the maximum number of terms matched by a prefix
+	// query.
+	maxPrefixTerms = 100
+)
+
+// A clause is a part of a query that every result matches: a term, a phrase
+// or a prefix.
+type clause struct {
+	// tokens are the terms of a term or phrase clause, with their positions
+	// relative to the first term.
+	tokens []token
+	// prefix is the prefix of the terms matched by a prefix clause, such as
+	// "comp" for comp*.
+	prefix string
+}
+
+// parseQuery splits a query into clauses. Quoted text, such as "cat in the
+// hat", is a phrase, and words ending with *, such as comp*, are prefixes.
+// The other words are terms.
+func parseQuery(q string) []clause {
+	var clauses []clause
+	seen := make(map[string]bool)
+	add := func(c clause) {
+		key := "*" + c.prefix
+		if c.prefix == "" {
+			key = fmt.Sprint(c.tokens)
+		}
+		if !seen[key] {
+			seen[key] = true
+			clauses = append(clauses, c)
+		}
+	}
+
+	for i, part := range strings.Split(q, `"`) {
+		if i%2 == 1 {
+			tokens := tokenize(part)
+			if len(tokens) == 0 {
+				continue
+			}
+			base := tokens[0].pos
+			for j := range tokens {
+				tokens[j].pos -= base
+			}
+			add(clause{tokens: tokens})
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			if prefix, ok := strings.CutSuffix(field, "*"); ok {
+				if w := words(prefix); len(w) == 1 && len(w[0]) >= minPrefixLength {
+					add(clause{prefix: w[0]})
+					continue
+				}
+			}
+			for _, t := range tokenize(field) {
+				add(clause{tokens: []token{{term: t.term}}})
+			}
+		}
+	}
+	return clauses
+}
+
+// A match is a document matching a query.
+type match struct {
+	name  string
+	score float64
+}
+
+// search returns the documents matching every clause, highest score first.
+func search(ctx context.Context, table *bigtable.Table, clauses []clause) ([]match, error) {
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the index rows of the terms of the term and phrase clauses.
+	var terms []string
+	for _, c := range clauses {
+		for _, t := range c.tokens {
+			if !slices.Contains(terms, t.term) {
+				terms = append(terms, t.term)
+			}
+		}
+	}
+	rows, err := readRows(ctx, table, terms)
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %w", err)
+	}
+	index := make(map[string]map[string]*posting)
+	for i, row := range rows {
+		index[terms[i]] = decodeRow(row)
+	}
+
+	// Score the documents matching each clause, keeping those matching
+	// every clause so far.
+	var scores map[string]float64
+	for _, c := range clauses {
+		var s map[string]float64
+		if c.prefix != "" {
+			expanded, err := readPrefix(ctx, table, c.prefix)
+			if err != nil {
+				return nil, fmt.Errorf("error reading index: %w", err)
+			}
+			s = stats.scorePrefix(expanded)
+		} else {
+			s = stats.scoreTerms(c.tokens, index)
+		}
+		if scores == nil {
+			scores = s
+		} else {
+			for doc, score := range scores {
+				if _, ok := s[doc]; ok {
+					scores[doc] = score + s[doc]
+				} else {
+					delete(scores, doc)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			return nil, nil
+		}
+	}
+
+	matches := make([]match, 0, len(scores))
+	for doc, score := range scores {
+		matches = append(matches, match{doc, score})
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return matches, nil
+}
+
+// readRows reads from many rows concurrently.
+func readRows(ctx context.Context, table *bigtable.Table, rows []string) ([]bigtable.Row, error) {
+	results := make([]bigtable.Row, len(rows))
+	errors := make([]error, len(rows))
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		wg.Add(1)
+		go func(i int, row string) {
+			defer wg.Done()
+			results[i], errors[i] = table.ReadRow(ctx, row, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+		}(i, row)
+	}
+	wg.Wait()
+	for _, err := range errors {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// readPrefix reads the index rows of the terms starting with prefix, with a
+// scan of the range of row keys starting with prefix.
+func readPrefix(ctx context.Context, table *bigtable.Table, prefix string) (map[string]map[string]*posting, error) {
+	index := make(map[string]map[string]*posting)
+	// The range also has the content rows of the documents whose name starts
+	// with prefix: the filter skips them.
+	filter := bigtable.ChainFilters(bigtable.FamilyFilter(indexColumnFamily), bigtable.LatestNFilter(1))
+	err := table.ReadRows(ctx, bigtable.PrefixRange(prefix), func(row bigtable.Row) bool {
+		index[row.Key()] = decodeRow(row)
+		return true
+	}, bigtable.RowFilter(filter), bigtable.LimitRows(maxPrefixTerms))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRows: %w", err)
+	}
+	return index, nil
+}
+
+// decodeRow returns the postings of an index row, by document name.
+// Postings which can't be decoded, such as the ones written by earlier
+// versions of this sample, are skipped.
+func decodeRow(row bigtable.Row) map[string]*posting {
+	postings := make(map[string]*posting)
+	for _, item := range row[indexColumnFamily] {
+		p, err := decodePosting(item.Value)
+		if err != nil {
+			continue
+		}
+		postings[strings.TrimPrefix(item.Column, indexColumnFamily+":")] = p
+	}
+	return postings
+}
+
+// bm25 returns the Okapi BM25 score of a term occurring freq times in a
+// document of length terms, if df documents contain the term.
+func (s corpusStats) bm25(freq, length, df int) float64 {
+	docs := float64(max(s.docs, int64(df)))
+	idf := math.Log(1 + (docs-float64(df)+0.5)/(float64(df)+0.5))
+	avgLength := s.avgLength
+	if avgLength == 0 {
+		avgLength = float64(length)
+	}
+	f := float64(freq)
+	return idf * f * (k1 + 1) / (f + k1*(1-b+b*float64(length)/max(avgLength, 1)))
+}
+
+// scoreTerms scores the documents containing the terms of tokens at their
+// relative positions. A phrase scores as its terms would if they only
+// occurred in the phrase.
+func (s corpusStats) scoreTerms(tokens []token, index map[string]map[string]*posting) map[string]float64 {
+	scores := make(map[string]float64)
+	for doc, p := range index[tokens[0].term] {
+		freq := 0
+		for _, pos := range p.positions {
+			if occursAt(doc, tokens, index, pos) {
+				freq++
+			}
+		}
+		if freq == 0 {
+			continue
+		}
+		for _, t := range tokens {
+			scores[doc] += s.bm25(freq, p.length, len(index[t.term]))
+		}
+	}
+	return scores
+}
+
+// occursAt reports whether the terms of tokens occur in doc at their
+// positions relative to pos.
+func occursAt(doc string, tokens []token, index map[string]map[string]*posting, pos int) bool {
+	for _, t := range tokens[1:] {
+		p := index[t.term][doc]
+		if p == nil {
+			return false
+		}
+		if _, ok := slices.BinarySearch(p.positions, pos+t.pos); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// scorePrefix scores the documents containing any of the terms of index,
+// summing the scores of the terms.
+func (s corpusStats) scorePrefix(index map[string]map[string]*posting) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, postings := range index {
+		for doc, p := range postings {
+			scores[doc] += s.bm25(len(p.positions), p.length, len(postings))
+		}
+	}
+	return scores
+}
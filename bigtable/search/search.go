@@ -14,20 +14,29 @@
 
 // Search is a sample web server that uses Cloud Bigtable as the storage layer
 // for a simple document-storage and full-text-search service.
-// It has four functions:
+// It has these functions:
 //   - Initialize and clear the table.
 //   - Add a document.  This adds the content of a user-supplied document to the
 //     Bigtable, and adds references to the document to an index in the Bigtable.
-//     The document is indexed under each unique word in the document.
-//   - Search the index.  This returns documents containing each word in a user
-//     query, with snippets and links to view the whole document.
+//     The document is indexed under the stem of each word in the document,
+//     except common words such as "the", with the positions of the word.
+//   - Update or delete a document.  This changes the references to the document
+//     in the index for the words added or removed.
+//   - Add documents in bulk.  This adds the documents of a stream of JSON
+//     objects, such as {"name": "...", "content": "..."}, posted to /bulk.
+//   - Search the index.  This returns documents containing each word, quoted
+//     phrase and prefix (such as comp*) in a user query, ranked with BM25, with
+//     snippets and links to view the whole document.
 //   - Copy table.  This copies the documents and index from another table and
-//     adds them to the current one.
+//     adds them to the current one, in the background. The progress of the
+//     copy is at /copy/status, and copying the same table again after a
+//     failure resumes the copy.
 package main
 
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
@@ -35,17 +44,14 @@ import (
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
-	"sync"
 	"time"
-	"unicode"
 
 	"cloud.google.com/go/bigtable"
 )
 
 var (
-	addTemplate = template.Must(template.New("").Parse(`<html><body>
-Added {{.Title}}
+	docTemplate = template.Must(template.New("").Parse(`<html><body>
+{{.Action}} {{.Title}}
 </body></html>`))
 
 	contentTemplate = template.Must(template.New("").Parse(`<html><body>
@@ -54,18 +60,22 @@ Added {{.Title}}
 </body></html>`))
 
 	searchTemplate = template.Must(template.New("").Parse(`<html><body>
-Results for <b>{{.Query}}</b>:<br><br>
+{{if .Results}}Results {{.First}}-{{.Last}} of {{.Total}}{{else}}No results{{end}} for <b>{{.Query}}</b>:<br><br>
 {{range .Results}}
-<a href="/content?name={{.Title}}">{{.Title}}</a><br>
+<a href="/content?name={{.Title}}">{{.Title}}</a> ({{printf "%.2f" .Score}})<br>
 <i>{{.Snippet}}</i><br><br>
 {{end}}
+{{if .Prev}}<a href="/search?q={{.Query}}&page={{.Prev}}">Previous</a>{{end}}
+{{if .Next}}<a href="/search?q={{.Query}}&page={{.Next}}">Next</a>{{end}}
 </body></html>`))
 )
 
 const (
 	indexColumnFamily   = "i"
 	contentColumnFamily = "c"
-	mainPage            = `
+	// pageSize is the number of search results per page.
+	pageSize = 10
+	mainPage = `
 	<html>
 		<head>
 			<title>Document Search</title>
@@ -76,7 +86,7 @@ const (
 				<div><input type="submit" value="Init"></div>
 			</form>
 
-			Search for documents:
+			Search for documents, with "quoted phrases" and prefix* queries:
 			<form action="/search" method="post">
 				<div><input type="text" name="q" size=80></div>
 				<div><input type="submit" value="Search"></div>
@@ -88,7 +98,14 @@ const (
 				<div><textarea name="name" rows="1" cols="80"></textarea></div>
 				Document text:
 				<div><textarea name="content" rows="20" cols="80"></textarea></div>
-				<div><input type="submit" value="Submit"></div>
+				<div><input type="submit" value="Submit"> <input type="submit" value="Update" formaction="/update"></div>
+			</form>
+
+			Delete a document:
+			<form action="/delete" method="post">
+				Document name:
+				<div><input type="text" name="name" size=80></div>
+				<div><input type="submit" value="Delete"></div>
 			</form>
 
 			Copy data from another table:
@@ -130,8 +147,13 @@ func main() {
 	http.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) { handleSearch(w, r, table) })
 	http.HandleFunc("/content", func(w http.ResponseWriter, r *http.Request) { handleContent(w, r, table) })
 	http.HandleFunc("/add", func(w http.ResponseWriter, r *http.Request) { handleAddDoc(w, r, table) })
+	http.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) { handleUpdateDoc(w, r, table) })
+	http.HandleFunc("/delete", func(w http.ResponseWriter, r *http.Request) { handleDeleteDoc(w, r, table) })
+	http.HandleFunc("/bulk", func(w http.ResponseWriter, r *http.Request) { handleBulk(w, r, table) })
 	http.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) { handleReset(w, r, *tableName, adminClient) })
-	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, *tableName, client, adminClient) })
+	copier := &copier{client: client, dst: *tableName}
+	http.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) { handleCopy(w, r, copier, adminClient) })
+	http.HandleFunc("/copy/status", func(w http.ResponseWriter, r *http.Request) { handleCopyStatus(w, r, copier) })
 	http.HandleFunc("/", handleMain)
 	if err := http.ListenAndServe(":"+strconv.Itoa(*port), nil); err != nil {
 		log.Fatal(err)
@@ -143,22 +165,6 @@ func handleMain(w http.ResponseWriter, r *http.Request) {
 	io.WriteString(w, mainPage)
 }
 
-// tokenize splits a string into tokens.
-// This is very simple, it's not a good tokenization function.
-func tokenize(s string) []string {
-	wordMap := make(map[string]bool)
-	f := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
-	for _, word := range f {
-		word = strings.ToLower(word)
-		wordMap[word] = true
-	}
-	words := make([]string, 0, len(wordMap))
-	for word := range wordMap {
-		words = append(words, word)
-	}
-	return words
-}
-
 // handleContent fetches the content of a document from the Bigtable and returns it.
 func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -174,99 +180,85 @@ func handleContent(w http.ResponseWriter, r *http.Request, table *bigtable.Table
 		http.Error(w, "Error reading content: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	content := row[contentColumnFamily]
-	if len(content) == 0 {
+	doc := parseDocument(row)
+	if !doc.exists {
 		http.Error(w, "Document not found.", http.StatusNotFound)
 		return
 	}
 	var buf bytes.Buffer
-	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, string(content[0].Value)}); err != nil {
+	if err := contentTemplate.ExecuteTemplate(&buf, "", struct{ Title, Content string }{name, doc.content}); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	io.Copy(w, &buf)
 }
 
-// handleSearch responds to search queries, returning links and snippets for matching documents.
+// handleSearch responds to search queries, returning links and snippets for
+// a page of the matching documents, highest score first.
 func handleSearch(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	query := r.FormValue("q")
-	// Split the query into words.
-	words := tokenize(query)
-	if len(words) == 0 {
+	// Split the query into terms, phrases and prefixes.
+	clauses := parseQuery(query)
+	if len(clauses) == 0 {
 		http.Error(w, "Empty query.", http.StatusBadRequest)
 		return
 	}
-
-	// readRows reads from many rows concurrently.
-	readRows := func(rows []string) ([]bigtable.Row, error) {
-		results := make([]bigtable.Row, len(rows))
-		errors := make([]error, len(rows))
-		var wg sync.WaitGroup
-		for i, row := range rows {
-			wg.Add(1)
-			go func(i int, row string) {
-				defer wg.Done()
-				results[i], errors[i] = table.ReadRow(ctx, row, bigtable.RowFilter(bigtable.LatestNFilter(1)))
-			}(i, row)
-		}
-		wg.Wait()
-		for _, err := range errors {
-			if err != nil {
-				return nil, err
-			}
+	page := 1
+	if p := r.FormValue("page"); p != "" {
+		var err error
+		if page, err = strconv.Atoi(p); err != nil || page < 1 {
+			http.Error(w, "Invalid page.", http.StatusBadRequest)
+			return
 		}
-		return results, nil
 	}
 
-	// For each query word, get the list of documents containing it.
-	results, err := readRows(words)
+	matches, err := search(ctx, table, clauses)
 	if err != nil {
-		http.Error(w, "Error reading index: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Error searching: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	total := len(matches)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+	matches = matches[start:end]
 
-	// Count how many of the query words each result contained.
-	hits := make(map[string]int)
-	for _, r := range results {
-		for _, r := range r[indexColumnFamily] {
-			hits[r.Column]++
-		}
-	}
-
-	// Build a slice of all the documents that matched every query word.
-	var matches []string
-	for doc, count := range hits {
-		if count == len(words) {
-			matches = append(matches, doc[len(indexColumnFamily+":"):])
-		}
+	// Fetch the content of the documents of the page from the Bigtable.
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
 	}
-
-	// Fetch the content of those documents from the Bigtable.
-	content, err := readRows(matches)
+	content, err := readRows(ctx, table, names)
 	if err != nil {
 		http.Error(w, "Error reading results: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	type result struct{ Title, Snippet string }
+	type result struct {
+		Title, Snippet string
+		Score          float64
+	}
 	data := struct {
-		Query   string
-		Results []result
-	}{query, nil}
+		Query              string
+		Results            []result
+		First, Last, Total int
+		Prev, Next         int
+	}{Query: query, First: start + 1, Last: end, Total: total}
+	if page > 1 {
+		data.Prev = page - 1
+	}
+	if end < total {
+		data.Next = page + 1
+	}
 
 	// Output links and snippets.
-	for i, doc := range matches {
-		var text string
-		c := content[i][contentColumnFamily]
-		if len(c) > 0 {
-			text = string(c[0].Value)
-		}
+	for i, m := range matches {
+		text := parseDocument(content[i]).content
 		if len(text) > 100 {
 			text = text[:100] + "..."
 		}
-		data.Results = append(data.Results, result{doc, text})
+		data.Results = append(data.Results, result{m.name, text, m.score})
 	}
 	var buf bytes.Buffer
 	if err := searchTemplate.ExecuteTemplate(&buf, "", data); err != nil {
@@ -276,8 +268,19 @@ func handleSearch(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	io.Copy(w, &buf)
 }
 
-// handleAddDoc adds a document to the index.
+// handleAddDoc adds a document to the index, or replaces it.
 func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+	saveDoc(w, r, table, false, "Added")
+}
+
+// handleUpdateDoc replaces a document in the index.
+func handleUpdateDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+	saveDoc(w, r, table, true, "Updated")
+}
+
+// saveDoc saves the document of a form, and responds with action if it
+// succeeds. If mustExist is set, the document must already be in the index.
+func saveDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table, mustExist bool, action string) {
 	if r.Method != "POST" {
 		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
 		return
@@ -287,8 +290,8 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 	defer cancel()
 
 	name := r.FormValue("name")
-	if len(name) == 0 {
-		http.Error(w, "Empty document name!", http.StatusBadRequest)
+	if err := checkName(name); err != nil {
+		http.Error(w, "Invalid document name: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -298,62 +301,82 @@ func handleAddDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table)
 		return
 	}
 
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
+	if err := saveDocument(ctx, table, name, &content, mustExist); err != nil {
+		writeSaveError(w, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := docTemplate.ExecuteTemplate(&buf, "", struct{ Action, Title string }{action, name}); err != nil {
+		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	io.Copy(w, &buf)
+}
 
-	// writeOneColumn writes one column in one row, updates err if there is an error,
-	// and signals wg that one operation has finished.
-	writeOneColumn := func(row, family, column, value string, ts bigtable.Timestamp) {
-		mut := bigtable.NewMutation()
-		mut.Set(family, column, ts, []byte(value))
-		err := table.Apply(ctx, row, mut)
-		if err != nil {
-			mu.Lock()
-			writeErr = err
-			mu.Unlock()
-		}
+// handleDeleteDoc deletes a document, and removes it from the index.
+func handleDeleteDoc(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
 	}
 
-	// Start a write to store the document content.
-	wg.Add(1)
-	go func() {
-		writeOneColumn(name, contentColumnFamily, "", content, bigtable.Now())
-		wg.Done()
-	}()
-
-	// Start writes to store the document name in the index for each word in the document.
-	words := tokenize(content)
-	for _, word := range words {
-		var (
-			row    = word
-			family = indexColumnFamily
-			column = name
-			value  = ""
-			ts     = bigtable.Now()
-		)
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			writeOneColumn(row, family, column, value, ts)
-			wg.Done()
-		}()
-	}
-	wg.Wait()
-	if writeErr != nil {
-		http.Error(w, "Error writing to Bigtable: "+writeErr.Error(), http.StatusInternalServerError)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	name := r.FormValue("name")
+	if err := checkName(name); err != nil {
+		http.Error(w, "Invalid document name: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveDocument(ctx, table, name, nil, true); err != nil {
+		writeSaveError(w, err)
 		return
 	}
 	var buf bytes.Buffer
-	if err := addTemplate.ExecuteTemplate(&buf, "", struct{ Title string }{name}); err != nil {
+	if err := docTemplate.ExecuteTemplate(&buf, "", struct{ Action, Title string }{"Deleted", name}); err != nil {
 		http.Error(w, "Error executing HTML template: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	io.Copy(w, &buf)
 }
 
+// writeSaveError responds with the error of saving a document.
+func writeSaveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNotFound):
+		http.Error(w, "Document not found.", http.StatusNotFound)
+	case errors.Is(err, errConflict):
+		http.Error(w, "Document changed concurrently, try again.", http.StatusConflict)
+	default:
+		http.Error(w, "Error writing to Bigtable: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleBulk adds the documents of the request body, a stream of JSON objects
+// with name and content fields, replacing existing ones. For example:
+//
+//	curl --data-binary @docs.json http://localhost:8080/bulk
+func handleBulk(w http.ResponseWriter, r *http.Request, table *bigtable.Table) {
+	if r.Method != "POST" {
+		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	n, err := ingestDocuments(ctx, table, r.Body)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errInvalidDocument) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf("Added %d documents, then failed: %v", n, err), status)
+		return
+	}
+	fmt.Fprintf(w, "Added %d documents.\n", n)
+}
+
 // handleReset deletes the table if it exists, creates it again, and creates its column families.
 func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClient *bigtable.AdminClient) {
 	if r.Method != "POST" {
@@ -383,77 +406,3 @@ func handleReset(w http.ResponseWriter, r *http.Request, table string, adminClie
 	w.Write([]byte("<html><body>Done.</body></html>"))
 	return
 }
-
-// copyTable copies data from one table to another.
-func copyTable(src, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) error {
-	if src == "" || src == dst {
-		return nil
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
-	// Open the source and destination tables.
-	srcTable := client.Open(src)
-	dstTable := client.Open(dst)
-
-	var (
-		writeErr error          // Set if any write fails.
-		mu       sync.Mutex     // Protects writeErr
-		wg       sync.WaitGroup // Used to wait for all writes to finish.
-	)
-	copyRowToTable := func(row bigtable.Row) bool {
-		mu.Lock()
-		failed := writeErr != nil
-		mu.Unlock()
-		if failed {
-			return false
-		}
-		mut := bigtable.NewMutation()
-		for family, items := range row {
-			for _, item := range items {
-				// Get the column name, excluding the column family name and ':' character.
-				columnWithoutFamily := item.Column[len(family)+1:]
-				mut.Set(family, columnWithoutFamily, bigtable.Now(), item.Value)
-			}
-		}
-		wg.Add(1)
-		go func() {
-			// TODO: should use a semaphore to limit the number of concurrent writes.
-			if err := dstTable.Apply(ctx, row.Key(), mut); err != nil {
-				mu.Lock()
-				writeErr = err
-				mu.Unlock()
-			}
-			wg.Done()
-		}()
-		return true
-	}
-
-	// Create a filter that only accepts the column families we're interested in.
-	filter := bigtable.FamilyFilter(indexColumnFamily + "|" + contentColumnFamily)
-	// Read every row from srcTable, and call copyRowToTable to copy it to our table.
-	err := srcTable.ReadRows(ctx, bigtable.InfiniteRange(""), copyRowToTable, bigtable.RowFilter(filter))
-	wg.Wait()
-	if err != nil {
-		return err
-	}
-	return writeErr
-}
-
-// handleCopy copies data from one table to another.
-func handleCopy(w http.ResponseWriter, r *http.Request, dst string, client *bigtable.Client, adminClient *bigtable.AdminClient) {
-	if r.Method != "POST" {
-		http.Error(w, "POST requests only", http.StatusMethodNotAllowed)
-		return
-	}
-	src := r.FormValue("name")
-	if src == "" {
-		http.Error(w, "No source table specified.", http.StatusBadRequest)
-		return
-	}
-	if err := copyTable(src, dst, client, adminClient); err != nil {
-		http.Error(w, "Failed to rebuild index: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	fmt.Fprint(w, "Copied table.\n")
-}
@@ -0,0 +1,386 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestClient returns clients of an in-memory Bigtable emulator, with the
+// tables of names, which have the column families of the sample.
+func newTestClient(t *testing.T, names ...string) (*bigtable.Client, *bigtable.AdminClient) {
+	t.Helper()
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient: %v", err)
+	}
+	for _, name := range names {
+		if err := adminClient.CreateTableFromConf(ctx, &bigtable.TableConf{
+			TableID: name,
+			Families: map[string]bigtable.GCPolicy{
+				indexColumnFamily:   bigtable.MaxVersionsPolicy(1),
+				contentColumnFamily: bigtable.MaxVersionsPolicy(1),
+			},
+		}); err != nil {
+			t.Fatalf("CreateTableFromConf: %v", err)
+		}
+	}
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client, adminClient
+}
+
+// newTestTable returns a table of an in-memory Bigtable emulator.
+func newTestTable(t *testing.T) *bigtable.Table {
+	t.Helper()
+	client, _ := newTestClient(t, "docindex")
+	return client.Open("docindex")
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("The cats were running, and the dog stopped in the studies.")
+	want := []token{
+		{"cat", 1}, {"were", 2}, {"run", 3}, {"dog", 6}, {"stop", 7}, {"study", 10},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(token{})); diff != "" {
+		t.Errorf("tokenize mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPosting(t *testing.T) {
+	p := &posting{length: 300, positions: []int{3, 4, 150, 299}}
+	got, err := decodePosting(p.encode())
+	if err != nil {
+		t.Fatalf("decodePosting: %v", err)
+	}
+	if diff := cmp.Diff(p, got, cmp.AllowUnexported(posting{})); diff != "" {
+		t.Errorf("decodePosting mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, b := range [][]byte{nil, {}, {2}, {2, 3, 0, 0, 0}, {0xff}} {
+		if _, err := decodePosting(b); err == nil {
+			t.Errorf("decodePosting(%v) succeeded, want an error", b)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	got := parseQuery(`Cats "the cat in the hat" comp* c* cats`)
+	want := []clause{
+		{tokens: []token{{"cat", 0}}},
+		{tokens: []token{{"cat", 0}, {"hat", 3}}},
+		{prefix: "comp"},
+		{tokens: []token{{"c", 0}}},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(clause{}, token{})); diff != "" {
+		t.Errorf("parseQuery mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	docs := map[string]string{
+		"hat":      "The cat in the hat sat on the mat.",
+		"hats":     "A hat for a cat. Hats, and more hats, for cats.",
+		"computer": "Computers compute computations.",
+	}
+	for name, content := range docs {
+		if err := saveDocument(ctx, table, name, &content, false); err != nil {
+			t.Fatalf("saveDocument(%q): %v", name, err)
+		}
+	}
+	// Adding a document again replaces it in the statistics.
+	computer := docs["computer"]
+	if err := saveDocument(ctx, table, "computer", &computer, false); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if stats.docs != 3 || stats.avgLength != 13.0/3 {
+		t.Errorf("readStats got %+v, want 3 documents of 13/3 terms", stats)
+	}
+
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		{query: "cat", want: []string{"hats", "hat"}},
+		{query: "hat cat", want: []string{"hats", "hat"}},
+		{query: `"cat in the hat"`, want: []string{"hat"}},
+		{query: `"hat cat"`, want: nil},
+		{query: "comp*", want: []string{"computer"}},
+		{query: "comp* cat", want: nil},
+		{query: "dog", want: nil},
+	} {
+		matches, err := search(ctx, table, parseQuery(test.query))
+		if err != nil {
+			t.Fatalf("search(%q): %v", test.query, err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.name)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("search(%q) mismatch (-want +got):\n%s", test.query, diff)
+		}
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	content := "A common word."
+	for i := range 15 {
+		if err := saveDocument(ctx, table, fmt.Sprintf("doc%02d", i), &content, false); err != nil {
+			t.Fatalf("saveDocument: %v", err)
+		}
+	}
+
+	for _, test := range []struct {
+		query      string
+		wantStatus int
+		want       []string
+	}{
+		{query: "q=common", wantStatus: http.StatusOK, want: []string{"Results 1-10 of 15", "doc09", "page=2"}},
+		{query: "q=common&page=2", wantStatus: http.StatusOK, want: []string{"Results 11-15 of 15", "doc14", "page=1"}},
+		{query: "q=common&page=3", wantStatus: http.StatusOK, want: []string{"No results"}},
+		{query: "q=the", wantStatus: http.StatusBadRequest},
+		{query: "q=common&page=0", wantStatus: http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		handleSearch(rr, httptest.NewRequest("GET", "/search?"+test.query, nil), table)
+		if rr.Code != test.wantStatus {
+			t.Errorf("GET /search?%s got status %d, want %d", test.query, rr.Code, test.wantStatus)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(rr.Body.String(), want) {
+				t.Errorf("GET /search?%s got %q, want it to contain %q", test.query, rr.Body.String(), want)
+			}
+		}
+	}
+}
+
+// searchNames returns the names of the documents matching query.
+func searchNames(t *testing.T, table *bigtable.Table, query string) []string {
+	t.Helper()
+	matches, err := search(context.Background(), table, parseQuery(query))
+	if err != nil {
+		t.Fatalf("search(%q): %v", query, err)
+	}
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func TestUpdateDocument(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	first, second := "The quick brown fox.", "The quick red fox jumps."
+	if err := saveDocument(ctx, table, "fox", &first, true); err != errNotFound {
+		t.Errorf("saveDocument of a new document with mustExist got %v, want errNotFound", err)
+	}
+	if err := saveDocument(ctx, table, "fox", &first, false); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+
+	// A stale update conflicts, and doesn't change the document.
+	old, err := readDocument(ctx, table, "fox")
+	if err != nil {
+		t.Fatalf("readDocument: %v", err)
+	}
+	if err := saveDocument(ctx, table, "fox", &second, true); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+	stale := "A stale fox."
+	if err := replaceDocument(ctx, table, "fox", old, &stale); err != errConflict {
+		t.Errorf("replaceDocument of a stale version got %v, want errConflict", err)
+	}
+
+	for query, want := range map[string][]string{
+		"quick": {"fox"},
+		"brown": nil,
+		"red":   {"fox"},
+		"jump":  {"fox"},
+		"stale": nil,
+	} {
+		if diff := cmp.Diff(want, searchNames(t, table, query)); diff != "" {
+			t.Errorf("search(%q) after update mismatch (-want +got):\n%s", query, diff)
+		}
+	}
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if stats.docs != 1 || stats.avgLength != 4 {
+		t.Errorf("readStats got %+v, want 1 document of 4 terms", stats)
+	}
+
+	if err := saveDocument(ctx, table, "fox", nil, false); err != nil {
+		t.Fatalf("saveDocument to delete: %v", err)
+	}
+	if got := searchNames(t, table, "fox"); got != nil {
+		t.Errorf("search after delete got %v, want no results", got)
+	}
+	if doc, err := readDocument(ctx, table, "fox"); err != nil || doc.exists {
+		t.Errorf("readDocument after delete got %+v, %v, want no document", doc, err)
+	}
+	if stats, err := readStats(ctx, table); err != nil || stats.docs != 0 {
+		t.Errorf("readStats after delete got %+v, %v, want no documents", stats, err)
+	}
+	if err := saveDocument(ctx, table, "fox", nil, false); err != errNotFound {
+		t.Errorf("saveDocument to delete a missing document got %v, want errNotFound", err)
+	}
+}
+
+func TestDeleteDocumentNamedLikeTerm(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	// The content row of "cat" is also the index row of the term cat.
+	cat, other := "A cat.", "Another cat."
+	if err := saveDocument(ctx, table, "cat", &cat, false); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+	if err := saveDocument(ctx, table, "other", &other, false); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+	if err := saveDocument(ctx, table, "cat", nil, false); err != nil {
+		t.Fatalf("saveDocument to delete: %v", err)
+	}
+	if diff := cmp.Diff([]string{"other"}, searchNames(t, table, "cat")); diff != "" {
+		t.Errorf("search mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIngestDocuments(t *testing.T) {
+	ctx := context.Background()
+	table := newTestTable(t)
+	old := "An old version of the document."
+	if err := saveDocument(ctx, table, "doc000", &old, false); err != nil {
+		t.Fatalf("saveDocument: %v", err)
+	}
+
+	var body strings.Builder
+	for i := range 2*bulkBatchSize + 10 {
+		fmt.Fprintf(&body, "{\"name\": \"doc%03d\", \"content\": \"Bulk document number %d.\"}\n", i, i)
+	}
+	// A document can appear twice: the last version wins.
+	body.WriteString(`{"name": "doc001", "content": "Replaced twice."}`)
+	n, err := ingestDocuments(ctx, table, strings.NewReader(body.String()))
+	if err != nil {
+		t.Fatalf("ingestDocuments: %v", err)
+	}
+	if want := 2*bulkBatchSize + 11; n != want {
+		t.Errorf("ingestDocuments got %d documents, want %d", n, want)
+	}
+
+	if got := searchNames(t, table, "old"); got != nil {
+		t.Errorf("search for a replaced word got %v, want no results", got)
+	}
+	if got, want := len(searchNames(t, table, "bulk")), 2*bulkBatchSize+9; got != want {
+		t.Errorf("search for bulk got %d results, want %d", got, want)
+	}
+	if diff := cmp.Diff([]string{"doc001"}, searchNames(t, table, "twice")); diff != "" {
+		t.Errorf("search mismatch (-want +got):\n%s", diff)
+	}
+	stats, err := readStats(ctx, table)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	if want := int64(2*bulkBatchSize + 10); stats.docs != want {
+		t.Errorf("readStats got %d documents, want %d", stats.docs, want)
+	}
+
+	for _, body := range []string{
+		`{"name": "", "content": "No name."}`,
+		`{"name": "#stats", "content": "Reserved."}`,
+		`{"name": "empty"}`,
+		`not JSON`,
+	} {
+		if _, err := ingestDocuments(ctx, table, strings.NewReader(body)); !errors.Is(err, errInvalidDocument) {
+			t.Errorf("ingestDocuments(%q) got error %v, want errInvalidDocument", body, err)
+		}
+	}
+}
+
+func TestDocumentHandlers(t *testing.T) {
+	table := newTestTable(t)
+	for _, test := range []struct {
+		path       string
+		form       url.Values
+		wantStatus int
+	}{
+		{path: "/update", form: url.Values{"name": {"doc"}, "content": {"Text."}}, wantStatus: http.StatusNotFound},
+		{path: "/add", form: url.Values{"name": {"doc"}, "content": {"Text."}}, wantStatus: http.StatusOK},
+		{path: "/update", form: url.Values{"name": {"doc"}, "content": {"New text."}}, wantStatus: http.StatusOK},
+		{path: "/add", form: url.Values{"name": {"#stats"}, "content": {"Text."}}, wantStatus: http.StatusBadRequest},
+		{path: "/delete", form: url.Values{"name": {"doc"}}, wantStatus: http.StatusOK},
+		{path: "/delete", form: url.Values{"name": {"doc"}}, wantStatus: http.StatusNotFound},
+	} {
+		r := httptest.NewRequest("POST", test.path, strings.NewReader(test.form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		switch test.path {
+		case "/add":
+			handleAddDoc(rr, r, table)
+		case "/update":
+			handleUpdateDoc(rr, r, table)
+		case "/delete":
+			handleDeleteDoc(rr, r, table)
+		}
+		if rr.Code != test.wantStatus {
+			t.Errorf("POST %s %v got status %d, want %d: %s", test.path, test.form, rr.Code, test.wantStatus, rr.Body)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleBulk(rr, httptest.NewRequest("POST", "/bulk", strings.NewReader(`{"name": "a", "content": "A."} {"name": "b"}`)), table)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "Added 0 documents") {
+		t.Errorf("POST /bulk with an invalid document got %d %q, want %d", rr.Code, rr.Body, http.StatusBadRequest)
+	}
+}
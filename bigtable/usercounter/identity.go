@@ -0,0 +1,84 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+// iapHeader is the header with the signed JWT assertion of Identity-Aware
+// Proxy.
+const iapHeader = "X-Goog-IAP-JWT-Assertion"
+
+var errUnauthenticated = errors.New("request has no identity")
+
+// An authenticator identifies the user of a request by the email of a
+// Google-signed ID token: the JWT assertion of Identity-Aware Proxy, or an
+// OpenID Connect token in the Authorization header, such as the ID token of
+// a user or service account calling a Cloud Run service.
+type authenticator struct {
+	// iapAudience is the audience of the IAP assertions, such as
+	// /projects/PROJECT_NUMBER/apps/PROJECT_ID on App Engine, or
+	// /projects/PROJECT_NUMBER/global/backendServices/SERVICE_ID behind a
+	// load balancer. If it's empty, IAP assertions aren't accepted.
+	iapAudience string
+	// oidcAudience is the audience of the OIDC tokens, such as the URL of
+	// the Cloud Run service. If it's empty, OIDC tokens aren't accepted.
+	oidcAudience string
+	// validate validates an ID token and returns its claims. It is
+	// idtoken.Validate, except in tests.
+	validate func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// email returns the email of the user of r, or errUnauthenticated if r has
+// no identity.
+func (a *authenticator) email(r *http.Request) (string, error) {
+	if a.iapAudience != "" {
+		if assertion := r.Header.Get(iapHeader); assertion != "" {
+			return a.tokenEmail(r.Context(), assertion, a.iapAudience)
+		}
+	}
+	if a.oidcAudience != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			return a.tokenEmail(r.Context(), token, a.oidcAudience)
+		}
+	}
+	return "", errUnauthenticated
+}
+
+// tokenEmail validates the ID token for audience, and returns its verified
+// email.
+func (a *authenticator) tokenEmail(ctx context.Context, token, audience string) (string, error) {
+	payload, err := a.validate(ctx, token, audience)
+	if err != nil {
+		return "", fmt.Errorf("idtoken.Validate: %w", err)
+	}
+	email, _ := payload.Claims["email"].(string)
+	if email == "" {
+		return "", errors.New("ID token has no email")
+	}
+	// IAP assertions have no email_verified claim, as IAP only signs in
+	// users with a verified email.
+	if verified, ok := payload.Claims["email_verified"].(bool); ok && !verified {
+		return "", fmt.Errorf("email %s of ID token isn't verified", email)
+	}
+	return email, nil
+}
@@ -13,9 +13,13 @@
 // limitations under the License.
 
 /*
-User counter is a program that tracks how often a user has visited the index page.
+User counter is a program that tracks how often each user has visited the index page.
 
-This program demonstrates usage of the Cloud Bigtable API for App Engine flexible environment and Go.
+This program demonstrates usage of the Cloud Bigtable API for the App Engine standard
+environment or Cloud Run, and Go. Users are identified by Identity-Aware Proxy (IAP) or
+by an OpenID Connect (OIDC) ID token. The visits of each user are counted in total, per
+day and per hour, and the daily and hourly counters are removed by garbage collection
+after some time. The /stats page returns the top users and the visits per day as JSON.
 Instructions for running this program are in the README.md.
 */
 package main
@@ -24,108 +28,170 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"cloud.google.com/go/bigtable"
-	"google.golang.org/appengine"
-	aelog "google.golang.org/appengine/log"
-	"google.golang.org/appengine/user"
+	"google.golang.org/api/idtoken"
 )
 
-// User-provided constants.
 const (
-	project  = "PROJECT_ID"
-	instance = "INSTANCE"
-)
+	tableName = "user-visit-counter"
+	// totalFamily has the number of visits of each user, in a column named
+	// by their email.
+	totalFamily = "emails"
+	// dailyFamily has the number of visits of each user per day, in columns
+	// named by the day, such as 2006-01-02, in UTC.
+	dailyFamily = "daily"
+	// hourlyFamily has the number of visits of each user per hour, in columns
+	// named by the hour, such as 2006-01-02T15, in UTC.
+	hourlyFamily = "hourly"
+
+	dayLayout  = "2006-01-02"
+	hourLayout = "2006-01-02T15"
 
-var (
-	tableName  = "user-visit-counter"
-	familyName = "emails"
+	// dailyRetention and hourlyRetention are how long the daily and hourly
+	// counters are kept.
+	dailyRetention  = 90 * 24 * time.Hour
+	hourlyRetention = 7 * 24 * time.Hour
 
-	// Client is initialized by main.
-	client *bigtable.Client
+	// defaultStatsDays and defaultStatsTop are the number of days and of top
+	// users of the stats, unless set by the days and top parameters.
+	defaultStatsDays = 7
+	defaultStatsTop  = 10
+	maxStatsTop      = 100
 )
 
+// families are the column families of the table, with their garbage
+// collection policies. Each increment of a counter writes a new cell, so
+// only the latest cell is kept.
+var families = map[string]bigtable.GCPolicy{
+	totalFamily:  bigtable.MaxVersionsPolicy(1),
+	dailyFamily:  bigtable.UnionPolicy(bigtable.MaxVersionsPolicy(1), bigtable.MaxAgePolicy(dailyRetention)),
+	hourlyFamily: bigtable.UnionPolicy(bigtable.MaxVersionsPolicy(1), bigtable.MaxAgePolicy(hourlyRetention)),
+}
+
 func main() {
 	ctx := context.Background()
 
+	// GOOGLE_CLOUD_PROJECT is set on App Engine. The other variables are set
+	// in app.yaml, or with gcloud run deploy --set-env-vars.
+	project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+	instance := os.Getenv("BIGTABLE_INSTANCE")
+	if project == "" || instance == "" {
+		log.Fatal("GOOGLE_CLOUD_PROJECT and BIGTABLE_INSTANCE must be set.")
+	}
+	auth := &authenticator{
+		iapAudience:  os.Getenv("IAP_AUDIENCE"),
+		oidcAudience: os.Getenv("OIDC_AUDIENCE"),
+		validate:     idtoken.Validate,
+	}
+	if auth.iapAudience == "" && auth.oidcAudience == "" {
+		log.Fatal("IAP_AUDIENCE or OIDC_AUDIENCE must be set.")
+	}
+
 	// Set up admin client, tables, and column families.
 	// NewAdminClient uses Application Default Credentials to authenticate.
 	adminClient, err := bigtable.NewAdminClient(ctx, project, instance)
 	if err != nil {
 		log.Fatalf("Unable to create a table admin client. %v", err)
 	}
+	if err := setupTable(ctx, adminClient); err != nil {
+		log.Fatalf("Unable to set up table: %v. %v", tableName, err)
+	}
+	adminClient.Close()
+
+	// Set up Bigtable data operations client.
+	// NewClient uses Application Default Credentials to authenticate.
+	client, err := bigtable.NewClient(ctx, project, instance)
+	if err != nil {
+		log.Fatalf("Unable to create data operations client. %v", err)
+	}
+
+	s := &server{table: client.Open(tableName), auth: auth, now: time.Now}
+	http.Handle("/", appHandler(s.mainHandler))
+	http.Handle("/stats", appHandler(s.statsHandler))
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Listening on port %s", port)
+	if err := http.ListenAndServe(":"+port, nil); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// setupTable creates the table and its column families if they don't exist,
+// and sets the garbage collection policies of the column families.
+func setupTable(ctx context.Context, adminClient *bigtable.AdminClient) error {
 	tables, err := adminClient.Tables(ctx)
 	if err != nil {
-		log.Fatalf("Unable to fetch table list. %v", err)
+		return fmt.Errorf("Tables: %w", err)
 	}
-	if !sliceContains(tables, tableName) {
+	if !slices.Contains(tables, tableName) {
 		if err := adminClient.CreateTable(ctx, tableName); err != nil {
-			log.Fatalf("Unable to create table: %v. %v", tableName, err)
+			return fmt.Errorf("CreateTable: %w", err)
 		}
 	}
 	tblInfo, err := adminClient.TableInfo(ctx, tableName)
 	if err != nil {
-		log.Fatalf("Unable to read info for table: %v. %v", tableName, err)
+		return fmt.Errorf("TableInfo: %w", err)
 	}
-	if !sliceContains(tblInfo.Families, familyName) {
-		if err := adminClient.CreateColumnFamily(ctx, tableName, familyName); err != nil {
-			log.Fatalf("Unable to create column family: %v. %v", familyName, err)
+	for family, policy := range families {
+		if !slices.Contains(tblInfo.Families, family) {
+			if err := adminClient.CreateColumnFamily(ctx, tableName, family); err != nil {
+				return fmt.Errorf("CreateColumnFamily(%s): %w", family, err)
+			}
+		}
+		if err := adminClient.SetGCPolicy(ctx, tableName, family, policy); err != nil {
+			return fmt.Errorf("SetGCPolicy(%s): %w", family, err)
 		}
 	}
-	adminClient.Close()
-
-	// Set up Bigtable data operations client.
-	// NewClient uses Application Default Credentials to authenticate.
-	client, err = bigtable.NewClient(ctx, project, instance)
-	if err != nil {
-		log.Fatalf("Unable to create data operations client. %v", err)
-	}
+	return nil
+}
 
-	http.Handle("/", appHandler(mainHandler))
-	appengine.Main() // Never returns.
+// A server serves the pages of the app.
+type server struct {
+	table *bigtable.Table
+	auth  *authenticator
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
 }
 
 // mainHandler tracks how many times each user has visited this page.
-func mainHandler(w http.ResponseWriter, r *http.Request) *appError {
+func (s *server) mainHandler(w http.ResponseWriter, r *http.Request) *appError {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return nil
 	}
 
-	ctx := appengine.NewContext(r)
-	u := user.Current(ctx)
-	if u == nil {
-		login, err := user.LoginURL(ctx, r.URL.String())
-		if err != nil {
-			return &appError{err, "Error finding login URL", http.StatusInternalServerError}
-		}
-		http.Redirect(w, r, login, http.StatusFound)
-		return nil
-	}
-	logoutURL, err := user.LogoutURL(ctx, "/")
+	email, err := s.auth.email(r)
 	if err != nil {
-		return &appError{err, "Error finding logout URL", http.StatusInternalServerError}
+		return &appError{err, "Sign in required", http.StatusUnauthorized}
 	}
 
-	// Increment visit count for user.
-	tbl := client.Open(tableName)
-	rmw := bigtable.NewReadModifyWrite()
-	rmw.Increment(familyName, u.Email, 1)
-	row, err := tbl.ApplyReadModifyWrite(ctx, u.Email, rmw)
+	v, err := recordVisit(r.Context(), s.table, email, s.now())
 	if err != nil {
-		return &appError{err, "Error applying ReadModifyWrite to row: " + u.Email, http.StatusInternalServerError}
+		return &appError{err, "Error recording visit of user: " + email, http.StatusInternalServerError}
 	}
 	data := struct {
 		Username, Logout string
-		Visits           uint64
+		Visits           visits
 	}{
-		Username: u.Email,
-		// Retrieve the most recently edited column.
-		Visits: binary.BigEndian.Uint64(row[familyName][0].Value),
-		Logout: logoutURL,
+		Username: email,
+		Visits:   v,
+	}
+	if s.auth.iapAudience != "" {
+		// Signing out of IAP clears its cookie, and asks to sign in again.
+		data.Logout = "/?gcp-iap-mode=CLEAR_LOGIN_COOKIE"
 	}
 
 	// Display hello page.
@@ -137,6 +203,85 @@ func mainHandler(w http.ResponseWriter, r *http.Request) *appError {
 	return nil
 }
 
+// statsHandler returns the stats of the visits of the last days, as JSON.
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) *appError {
+	if _, err := s.auth.email(r); err != nil {
+		return &appError{err, "Sign in required", http.StatusUnauthorized}
+	}
+	days, err := intParam(r, "days", defaultStatsDays, int(dailyRetention/(24*time.Hour)))
+	if err != nil {
+		return &appError{err, err.Error(), http.StatusBadRequest}
+	}
+	top, err := intParam(r, "top", defaultStatsTop, maxStatsTop)
+	if err != nil {
+		return &appError{err, err.Error(), http.StatusBadRequest}
+	}
+
+	st, err := readStats(r.Context(), s.table, s.now(), days, top)
+	if err != nil {
+		return &appError{err, "Error reading stats", http.StatusInternalServerError}
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(st); err != nil {
+		return &appError{err, "Error encoding stats", http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	buf.WriteTo(w)
+	return nil
+}
+
+// intParam returns the value of the integer parameter name of r, between 1
+// and max, or def if it isn't set.
+func intParam(r *http.Request, name string, def, max int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%s must be a number between 1 and %d", name, max)
+	}
+	return n, nil
+}
+
+// visits are the numbers of visits of a user.
+type visits struct {
+	Total, Today, ThisHour int64
+}
+
+// recordVisit counts a visit of the user with email at t, and returns the
+// numbers of visits of the user.
+func recordVisit(ctx context.Context, table *bigtable.Table, email string, t time.Time) (visits, error) {
+	t = t.UTC()
+	day, hour := t.Format(dayLayout), t.Format(hourLayout)
+
+	// Increment the visit counts of the user, in a single row.
+	rmw := bigtable.NewReadModifyWrite()
+	rmw.Increment(totalFamily, email, 1)
+	rmw.Increment(dailyFamily, day, 1)
+	rmw.Increment(hourlyFamily, hour, 1)
+	row, err := table.ApplyReadModifyWrite(ctx, email, rmw)
+	if err != nil {
+		return visits{}, fmt.Errorf("ApplyReadModifyWrite(%s): %w", email, err)
+	}
+	return visits{
+		Total:    counter(row, totalFamily, email),
+		Today:    counter(row, dailyFamily, day),
+		ThisHour: counter(row, hourlyFamily, hour),
+	}, nil
+}
+
+// counter returns the value of the counter in the column of family in row,
+// or 0 if row doesn't have it.
+func counter(row bigtable.Row, family, column string) int64 {
+	for _, item := range row[family] {
+		if item.Column == family+":"+column && len(item.Value) == 8 {
+			return int64(binary.BigEndian.Uint64(item.Value))
+		}
+	}
+	return 0
+}
+
 var tmpl = template.Must(template.New("").Parse(`
 <html><body>
 
@@ -147,20 +292,14 @@ var tmpl = template.Must(template.New("").Parse(`
 </p>
 
 <p>
-You have visited {{.Visits}}
+You have visited {{.Visits.Total}} times, {{.Visits.Today}} today and {{.Visits.ThisHour}} in this hour.
 </p>
 
-</body></html>`))
+<p>
+<a href="/stats">Stats</a>
+</p>
 
-// sliceContains reports whether the provided string is present in the given slice of strings.
-func sliceContains(list []string, target string) bool {
-	for _, s := range list {
-		if s == target {
-			return true
-		}
-	}
-	return false
-}
+</body></html>`))
 
 // More info about this method of error handling can be found at: http://blog.golang.org/error-handling-and-go
 type appHandler func(http.ResponseWriter, *http.Request) *appError
@@ -173,8 +312,7 @@ type appError struct {
 
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if e := fn(w, r); e != nil {
-		ctx := appengine.NewContext(r)
-		aelog.Errorf(ctx, "%v", e.Error)
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, e.Error)
 		http.Error(w, e.Message, e.Code)
 	}
 }
@@ -0,0 +1,264 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newTestTable returns the table of the app, set up in an in-memory Bigtable
+// emulator.
+func newTestTable(t *testing.T) (*bigtable.Table, *bigtable.AdminClient) {
+	t.Helper()
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	adminClient, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewAdminClient: %v", err)
+	}
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("setupTable: %v", err)
+	}
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client.Open(tableName), adminClient
+}
+
+// testAuth accepts the ID tokens "iap:EMAIL" for IAP, and "oidc:EMAIL" for
+// OIDC.
+var testAuth = &authenticator{
+	iapAudience:  "iap-audience",
+	oidcAudience: "oidc-audience",
+	validate: func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+		kind, email, _ := strings.Cut(token, ":")
+		if kind+"-audience" != audience {
+			return nil, errors.New("invalid audience")
+		}
+		claims := map[string]interface{}{"email": email}
+		if kind == "oidc" {
+			claims["email_verified"] = !strings.HasPrefix(email, "unverified")
+		}
+		return &idtoken.Payload{Audience: audience, Claims: claims}, nil
+	},
+}
+
+func TestSetupTable(t *testing.T) {
+	ctx := context.Background()
+	_, adminClient := newTestTable(t)
+	// Setting up the table again keeps it.
+	if err := setupTable(ctx, adminClient); err != nil {
+		t.Fatalf("setupTable again: %v", err)
+	}
+	info, err := adminClient.TableInfo(ctx, tableName)
+	if err != nil {
+		t.Fatalf("TableInfo: %v", err)
+	}
+	for family := range families {
+		if !slices.Contains(info.Families, family) {
+			t.Errorf("TableInfo got families %v, want %s", info.Families, family)
+		}
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		header  string
+		value   string
+		auth    *authenticator
+		want    string
+		wantErr bool
+	}{
+		{name: "iap", header: iapHeader, value: "iap:a@example.com", want: "a@example.com"},
+		{name: "oidc", header: "Authorization", value: "Bearer oidc:b@example.com", want: "b@example.com"},
+		{name: "none", wantErr: true},
+		{name: "invalid", header: iapHeader, value: "oidc:a@example.com", wantErr: true},
+		{name: "no email", header: "Authorization", value: "Bearer oidc:", wantErr: true},
+		{name: "unverified", header: "Authorization", value: "Bearer oidc:unverified@example.com", wantErr: true},
+		{
+			name:    "iap disabled",
+			header:  iapHeader,
+			value:   "iap:a@example.com",
+			auth:    &authenticator{oidcAudience: "oidc-audience", validate: testAuth.validate},
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.header != "" {
+				r.Header.Set(test.header, test.value)
+			}
+			auth := test.auth
+			if auth == nil {
+				auth = testAuth
+			}
+			got, err := auth.email(r)
+			if (err != nil) != test.wantErr || got != test.want {
+				t.Errorf("email got %q, %v, want %q, error %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordVisit(t *testing.T) {
+	ctx := context.Background()
+	table, _ := newTestTable(t)
+	start := time.Date(2025, 3, 1, 23, 10, 0, 0, time.UTC)
+	for _, test := range []struct {
+		email string
+		t     time.Time
+		want  visits
+	}{
+		{email: "a@example.com", t: start, want: visits{Total: 1, Today: 1, ThisHour: 1}},
+		{email: "a@example.com", t: start.Add(time.Minute), want: visits{Total: 2, Today: 2, ThisHour: 2}},
+		{email: "b@example.com", t: start.Add(time.Minute), want: visits{Total: 1, Today: 1, ThisHour: 1}},
+		// The next day starts new daily and hourly counters.
+		{email: "a@example.com", t: start.Add(time.Hour), want: visits{Total: 3, Today: 1, ThisHour: 1}},
+		// The counters are of days in UTC.
+		{email: "a@example.com", t: start.Add(time.Hour).In(time.FixedZone("", -2*60*60)), want: visits{Total: 4, Today: 2, ThisHour: 2}},
+	} {
+		got, err := recordVisit(ctx, table, test.email, test.t)
+		if err != nil {
+			t.Fatalf("recordVisit: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("recordVisit(%s, %v) got %+v, want %+v", test.email, test.t, got, test.want)
+		}
+	}
+}
+
+func TestReadStats(t *testing.T) {
+	ctx := context.Background()
+	table, _ := newTestTable(t)
+	end := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, v := range []struct {
+		email   string
+		daysAgo int
+		visits  int
+	}{
+		{email: "a@example.com", daysAgo: 0, visits: 2},
+		{email: "a@example.com", daysAgo: 2, visits: 1},
+		{email: "b@example.com", daysAgo: 0, visits: 3},
+		{email: "c@example.com", daysAgo: 1, visits: 1},
+		// Too old for the stats.
+		{email: "d@example.com", daysAgo: 3, visits: 5},
+	} {
+		for range v.visits {
+			if _, err := recordVisit(ctx, table, v.email, end.AddDate(0, 0, -v.daysAgo)); err != nil {
+				t.Fatalf("recordVisit: %v", err)
+			}
+		}
+	}
+
+	got, err := readStats(ctx, table, end, 3, 2)
+	if err != nil {
+		t.Fatalf("readStats: %v", err)
+	}
+	want := &stats{
+		From:   "2025-03-08",
+		To:     "2025-03-10",
+		Visits: 7,
+		Users:  3,
+		TopUsers: []userVisits{
+			{Email: "a@example.com", Visits: 3},
+			{Email: "b@example.com", Visits: 3},
+		},
+		Daily: []dayVisits{
+			{Date: "2025-03-08", Visits: 1},
+			{Date: "2025-03-09", Visits: 1},
+			{Date: "2025-03-10", Visits: 5},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("readStats mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	table, _ := newTestTable(t)
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	s := &server{table: table, auth: testAuth, now: func() time.Time { return now }}
+	serve := func(h appHandler, target, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		if token != "" {
+			r.Header.Set(iapHeader, token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	if rr := serve(s.mainHandler, "/", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("GET / without identity got status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+	serve(s.mainHandler, "/", "iap:a@example.com")
+	rr := serve(s.mainHandler, "/", "iap:a@example.com")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "visited 2 times, 2 today") {
+		t.Errorf("GET / got status %d, body %q, want 2 visits", rr.Code, rr.Body)
+	}
+
+	if rr := serve(s.statsHandler, "/stats", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("GET /stats without identity got status %d, want %d", rr.Code, http.StatusUnauthorized)
+	}
+	for _, target := range []string{"/stats?days=0", "/stats?days=91", "/stats?top=x"} {
+		if rr := serve(s.statsHandler, target, "iap:a@example.com"); rr.Code != http.StatusBadRequest {
+			t.Errorf("GET %s got status %d, want %d", target, rr.Code, http.StatusBadRequest)
+		}
+	}
+	rr = serve(s.statsHandler, "/stats?days=2", "iap:a@example.com")
+	var got stats
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("json.Decode: %v", err)
+	}
+	want := stats{
+		From:     "2025-03-09",
+		To:       "2025-03-10",
+		Visits:   2,
+		Users:    1,
+		TopUsers: []userVisits{{Email: "a@example.com", Visits: 2}},
+		Daily:    []dayVisits{{Date: "2025-03-09"}, {Date: "2025-03-10", Visits: 2}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GET /stats mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+// stats are the visits of a range of days.
+type stats struct {
+	// From and To are the first and last days, in UTC.
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Visits is the number of visits, and Users the number of users who
+	// visited.
+	Visits int64 `json:"visits"`
+	Users  int   `json:"users"`
+	// TopUsers are the users with the most visits, most visits first.
+	TopUsers []userVisits `json:"topUsers"`
+	// Daily is the number of visits of each day.
+	Daily []dayVisits `json:"daily"`
+}
+
+type userVisits struct {
+	Email  string `json:"email"`
+	Visits int64  `json:"visits"`
+}
+
+type dayVisits struct {
+	Date   string `json:"date"`
+	Visits int64  `json:"visits"`
+}
+
+// readStats returns the stats of the visits of the days days ending with the
+// day of end, with the top users.
+// It reads the daily counters of all the users, which is fine for a sample,
+// but a large app would aggregate them, in a table keyed by day for example.
+func readStats(ctx context.Context, table *bigtable.Table, end time.Time, days, top int) (*stats, error) {
+	end = end.UTC()
+	first := end.AddDate(0, 0, 1-days)
+	st := &stats{
+		From:     first.Format(dayLayout),
+		To:       end.Format(dayLayout),
+		TopUsers: []userVisits{},
+		Daily:    make([]dayVisits, days),
+	}
+	index := make(map[string]int)
+	for i := range st.Daily {
+		st.Daily[i].Date = first.AddDate(0, 0, i).Format(dayLayout)
+		index[st.Daily[i].Date] = i
+	}
+
+	// The columns of the days sort in time order, so the days are a range of
+	// columns, ending before the day after end.
+	filter := bigtable.ChainFilters(
+		bigtable.ColumnRangeFilter(dailyFamily, st.From, end.AddDate(0, 0, 1).Format(dayLayout)),
+		bigtable.LatestNFilter(1),
+	)
+	var users []userVisits
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		u := userVisits{Email: row.Key()}
+		for _, item := range row[dailyFamily] {
+			i, ok := index[strings.TrimPrefix(item.Column, dailyFamily+":")]
+			if !ok || len(item.Value) != 8 {
+				continue
+			}
+			n := int64(binary.BigEndian.Uint64(item.Value))
+			st.Daily[i].Visits += n
+			u.Visits += n
+		}
+		if u.Visits > 0 {
+			users = append(users, u)
+			st.Visits += u.Visits
+		}
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return nil, fmt.Errorf("ReadRows: %w", err)
+	}
+
+	slices.SortFunc(users, func(a, b userVisits) int {
+		return cmp.Or(cmp.Compare(b.Visits, a.Visits), strings.Compare(a.Email, b.Email))
+	})
+	st.Users = len(users)
+	st.TopUsers = append(st.TopUsers, users[:min(top, len(users))]...)
+	return st, nil
+}
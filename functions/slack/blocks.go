@@ -0,0 +1,136 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Message is a Slack message, such as the response to a command.
+// See https://api.slack.com/reference/messaging/payload.
+type Message struct {
+	// ResponseType is "in_channel" for responses visible to everyone in
+	// the channel, or "ephemeral" (the default) for responses only visible
+	// to the user.
+	ResponseType string `json:"response_type,omitempty"`
+	// Text is the text of the message, or its summary for notifications
+	// when it has Blocks.
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
+	// ReplaceOriginal replaces the message of an interaction instead of
+	// adding a new message.
+	ReplaceOriginal bool `json:"replace_original,omitempty"`
+}
+
+// A Block is a Block Kit layout block.
+// See https://api.slack.com/reference/block-kit/blocks.
+type Block struct {
+	Type      string   `json:"type"`
+	BlockID   string   `json:"block_id,omitempty"`
+	Text      *Text    `json:"text,omitempty"`
+	Fields    []*Text  `json:"fields,omitempty"`
+	Accessory *Element `json:"accessory,omitempty"`
+	// Elements are the *Element of "actions" blocks, or the *Text and
+	// *Element of "context" blocks.
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// Text is a Block Kit text object.
+// See https://api.slack.com/reference/block-kit/composition-objects#text.
+type Text struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// An Element is a Block Kit block element, such as a button or an image.
+// See https://api.slack.com/reference/block-kit/block-elements.
+type Element struct {
+	Type     string `json:"type"`
+	ActionID string `json:"action_id,omitempty"`
+	Text     *Text  `json:"text,omitempty"`
+	Value    string `json:"value,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Style    string `json:"style,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	AltText  string `json:"alt_text,omitempty"`
+}
+
+// maxTextLength is the maximum length of the text of a section.
+const maxTextLength = 3000
+
+// Markdown returns a text object formatted with Slack's mrkdwn. Use Escape
+// for text that isn't formatted.
+func Markdown(s string) *Text {
+	if len(s) > maxTextLength {
+		n := maxTextLength - len("…")
+		for !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n] + "…"
+	}
+	return &Text{Type: "mrkdwn", Text: s}
+}
+
+// PlainText returns a plain text object.
+func PlainText(s string) *Text {
+	return &Text{Type: "plain_text", Text: s}
+}
+
+// Escape escapes the characters with a meaning in mrkdwn text.
+// See https://api.slack.com/reference/surfaces/formatting#escaping.
+func Escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Section returns a section block with the given text, and an optional
+// accessory such as an image.
+func Section(text *Text, accessory *Element) Block {
+	return Block{Type: "section", Text: text, Accessory: accessory}
+}
+
+// Divider returns a divider block.
+func Divider() Block {
+	return Block{Type: "divider"}
+}
+
+// Actions returns an actions block with the given interactive elements.
+func Actions(elements ...*Element) Block {
+	b := Block{Type: "actions"}
+	for _, e := range elements {
+		b.Elements = append(b.Elements, e)
+	}
+	return b
+}
+
+// Context returns a context block with the given text.
+func Context(texts ...*Text) Block {
+	b := Block{Type: "context"}
+	for _, t := range texts {
+		b.Elements = append(b.Elements, t)
+	}
+	return b
+}
+
+// Button returns a button calling the Router's ActionHandler for actionID
+// with the given value.
+func Button(actionID, label, value string) *Element {
+	return &Element{Type: "button", ActionID: actionID, Text: PlainText(label), Value: value}
+}
+
+// Image returns an image element.
+func Image(url, altText string) *Element {
+	return &Element{Type: "image", ImageURL: url, AltText: altText}
+}
@@ -17,31 +17,51 @@
 package slack
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"google.golang.org/api/kgsearch/v1"
 )
 
+// nextResultAction is the action ID of the "Next result" button.
+const nextResultAction = "kg_next_result"
+
+// maxResults is the number of results users can page through.
+const maxResults = 20
+
+// resultPosition is the value of the "Next result" button: the position of
+// the next result to show.
+type resultPosition struct {
+	Query string `json:"q"`
+	Index int    `json:"i"`
+}
+
 func formatSlackMessage(query string, response *kgsearch.SearchResponse) (*Message, error) {
+	return formatSlackMessageAt(query, response, 0)
+}
+
+// formatSlackMessageAt returns a message showing the result at the given
+// index of the response.
+func formatSlackMessageAt(query string, response *kgsearch.SearchResponse, index int) (*Message, error) {
 	if response == nil {
 		return nil, fmt.Errorf("empty response")
 	}
 
-	if response.ItemListElement == nil || len(response.ItemListElement) == 0 {
+	header := Context(Markdown(fmt.Sprintf("Query: *%s*", Escape(query))))
+	if len(response.ItemListElement) <= index {
+		text := "No results match your query."
+		if index > 0 {
+			text = "No more results match your query."
+		}
 		message := &Message{
 			ResponseType: "in_channel",
-			Text:         fmt.Sprintf("Query: %s", query),
-			Attachments: []attachment{
-				{
-					Color: "#d6334b",
-					Text:  "No results match your query.",
-				},
-			},
+			Text:         text,
+			Blocks:       []Block{header, Section(Markdown(text), nil)},
 		}
 		return message, nil
 	}
 
-	entity, ok := response.ItemListElement[0].(map[string]interface{})
+	entity, ok := response.ItemListElement[index].(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("could not parse response entity")
 	}
@@ -50,32 +70,49 @@ func formatSlackMessage(query string, response *kgsearch.SearchResponse) (*Messa
 		return nil, fmt.Errorf("error formatting response result")
 	}
 
-	attach := attachment{Color: "#3367d6"}
+	var title, titleLink, text string
+	var image *Element
 	if name, ok := result["name"].(string); ok {
 		if description, ok := result["description"].(string); ok {
-			attach.Title = fmt.Sprintf("%s: %s", name, description)
+			title = fmt.Sprintf("%s: %s", name, description)
 		} else {
-			attach.Title = name
+			title = name
 		}
 	}
 	if detailedDesc, ok := result["detailedDescription"].(map[string]interface{}); ok {
 		if url, ok := detailedDesc["url"].(string); ok {
-			attach.TitleLink = url
+			titleLink = url
 		}
 		if article, ok := detailedDesc["articleBody"].(string); ok {
-			attach.Text = article
+			text = article
+		}
+	}
+	if img, ok := result["image"].(map[string]interface{}); ok {
+		if imageURL, ok := img["contentUrl"].(string); ok {
+			image = Image(imageURL, title)
 		}
 	}
-	if image, ok := result["image"].(map[string]interface{}); ok {
-		if imageURL, ok := image["contentUrl"].(string); ok {
-			attach.ImageURL = imageURL
+
+	body := fmt.Sprintf("*%s*", Escape(title))
+	if titleLink != "" {
+		body = fmt.Sprintf("*<%s|%s>*", titleLink, Escape(title))
+	}
+	if text != "" {
+		body += "\n" + Escape(text)
+	}
+	blocks := []Block{header, Section(Markdown(body), image)}
+	if index+1 < maxResults {
+		next, err := json.Marshal(resultPosition{Query: query, Index: index + 1})
+		if err != nil {
+			return nil, fmt.Errorf("json.Marshal: %w", err)
 		}
+		blocks = append(blocks, Actions(Button(nextResultAction, "Next result", string(next))))
 	}
 
 	message := &Message{
 		ResponseType: "in_channel",
 		Text:         fmt.Sprintf("Query: %s", query),
-		Attachments:  []attachment{attach},
+		Blocks:       blocks,
 	}
 	return message, nil
 }
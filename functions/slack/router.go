@@ -0,0 +1,389 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Command is a slash command.
+// See https://api.slack.com/interactivity/slash-commands.
+type Command struct {
+	// Command is the name of the command, such as "/kg".
+	Command     string
+	Text        string
+	UserID      string
+	UserName    string
+	ChannelID   string
+	TeamID      string
+	ResponseURL string
+	TriggerID   string
+}
+
+// A CommandHandler responds to a slash command. Commands taking longer than
+// a couple of seconds are acknowledged right away, and their response is
+// sent later to the command's ResponseURL.
+type CommandHandler func(ctx context.Context, cmd *Command) (*Message, error)
+
+// Event is an Events API event.
+// See https://api.slack.com/apis/connections/events-api.
+type Event struct {
+	TeamID  string `json:"-"`
+	EventID string `json:"-"`
+
+	// Type is the type of the event, such as "app_mention".
+	Type    string `json:"type"`
+	User    string `json:"user"`
+	Text    string `json:"text"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+
+	// Raw is the JSON of the event, for its other fields.
+	Raw json.RawMessage `json:"-"`
+}
+
+// An EventHandler handles an event. Events are acknowledged before their
+// handler is called, so errors are only logged.
+type EventHandler func(ctx context.Context, e *Event) error
+
+// Interaction is the payload of a block_actions interaction, sent when users
+// click on an interactive element of a message.
+// See https://api.slack.com/reference/interaction-payloads/block-actions.
+type Interaction struct {
+	Type        string `json:"type"`
+	TriggerID   string `json:"trigger_id"`
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Actions []Action `json:"actions"`
+}
+
+// Action is an action of an Interaction.
+type Action struct {
+	ActionID string `json:"action_id"`
+	BlockID  string `json:"block_id"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+}
+
+// An ActionHandler handles an action. Interactions are acknowledged before
+// their handlers are called, and the message they return, if any, is sent to
+// the interaction's ResponseURL.
+type ActionHandler func(ctx context.Context, in *Interaction, a *Action) (*Message, error)
+
+const (
+	// defaultAckTimeout is how long commands have to respond before being
+	// acknowledged. Slack needs a response within 3 seconds.
+	defaultAckTimeout = 2500 * time.Millisecond
+
+	// maxBodySize is the maximum size of the requests from Slack.
+	maxBodySize = 1 << 20
+
+	slackRetryNumHeader = "X-Slack-Retry-Num"
+)
+
+// A Router is an http.Handler for the requests Slack sends to an app: slash
+// commands, Events API callbacks, and interactions. It verifies each request
+// with verifyWebHook, and dispatches it to the handler registered for it.
+// Create one with NewRouter.
+type Router struct {
+	secret   string
+	commands map[string]CommandHandler
+	events   map[string]EventHandler
+	actions  map[string]ActionHandler
+
+	// client sends the responses to response URLs.
+	client     *http.Client
+	ackTimeout time.Duration
+}
+
+// NewRouter returns a Router verifying requests with the app's signing
+// secret.
+func NewRouter(signingSecret string) *Router {
+	return &Router{
+		secret:     signingSecret,
+		commands:   map[string]CommandHandler{},
+		events:     map[string]EventHandler{},
+		actions:    map[string]ActionHandler{},
+		client:     &http.Client{Timeout: 10 * time.Second},
+		ackTimeout: defaultAckTimeout,
+	}
+}
+
+// Command registers h for the slash command name, such as "/kg". The handler
+// for "" handles the commands without their own handler.
+func (rt *Router) Command(name string, h CommandHandler) {
+	rt.commands[name] = h
+}
+
+// Event registers h for the events of type eventType, such as
+// "app_mention". Events without a handler are ignored.
+func (rt *Router) Event(eventType string, h EventHandler) {
+	rt.events[eventType] = h
+}
+
+// Action registers h for the interactive elements with the given action ID.
+func (rt *Router) Action(actionID string, h ActionHandler) {
+	rt.actions[actionID] = h
+}
+
+// ServeHTTP implements http.Handler.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST requests are accepted", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	ok, err := verifyWebHook(r, rt.secret)
+	if err != nil {
+		log.Printf("verifyWebHook: %v", err)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	if !ok {
+		log.Printf("signatures did not match.")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/json" {
+		rt.serveEvent(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		log.Printf("ParseForm: %v", err)
+		http.Error(w, "Couldn't parse form", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case r.PostForm.Get("payload") != "":
+		rt.serveInteraction(w, r)
+	case r.PostForm.Get("command") != "":
+		rt.serveCommand(w, r)
+	default:
+		http.Error(w, "Unknown request", http.StatusBadRequest)
+	}
+}
+
+func (rt *Router) serveCommand(w http.ResponseWriter, r *http.Request) {
+	cmd := &Command{
+		Command:     r.PostForm.Get("command"),
+		Text:        r.PostForm.Get("text"),
+		UserID:      r.PostForm.Get("user_id"),
+		UserName:    r.PostForm.Get("user_name"),
+		ChannelID:   r.PostForm.Get("channel_id"),
+		TeamID:      r.PostForm.Get("team_id"),
+		ResponseURL: r.PostForm.Get("response_url"),
+		TriggerID:   r.PostForm.Get("trigger_id"),
+	}
+	h, ok := rt.commands[cmd.Command]
+	if !ok {
+		h, ok = rt.commands[""]
+	}
+	if !ok {
+		writeMessage(w, ephemeral(fmt.Sprintf("Sorry, I don't know the command %s.", cmd.Command)))
+		return
+	}
+
+	type result struct {
+		msg *Message
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		msg, err := h(r.Context(), cmd)
+		done <- result{msg, err}
+	}()
+
+	select {
+	case res := <-done:
+		writeMessage(w, commandResponse(cmd, res.msg, res.err))
+	case <-time.After(rt.ackTimeout):
+		// Acknowledge the command, and keep the function running until
+		// the response is sent to the response URL. This needs CPU to
+		// stay allocated after the response; see the README.
+		writeMessage(w, ephemeral("Working on it…"))
+		res := <-done
+		if cmd.ResponseURL == "" {
+			log.Printf("Command %s has no response URL", cmd.Command)
+			return
+		}
+		if err := rt.respond(r.Context(), cmd.ResponseURL, commandResponse(cmd, res.msg, res.err)); err != nil {
+			log.Printf("respond: %v", err)
+		}
+	}
+}
+
+// commandResponse returns the response to cmd for the result of its handler.
+func commandResponse(cmd *Command, msg *Message, err error) *Message {
+	if err != nil {
+		log.Printf("Command %s %q: %v", cmd.Command, cmd.Text, err)
+		return ephemeral("Sorry, something went wrong. Please try again.")
+	}
+	if msg == nil {
+		return &Message{}
+	}
+	return msg
+}
+
+func (rt *Router) serveEvent(w http.ResponseWriter, r *http.Request) {
+	var callback struct {
+		Type      string          `json:"type"`
+		Challenge string          `json:"challenge"`
+		TeamID    string          `json:"team_id"`
+		EventID   string          `json:"event_id"`
+		Event     json.RawMessage `json:"event"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&callback); err != nil {
+		log.Printf("json.Decode: %v", err)
+		http.Error(w, "Couldn't parse event", http.StatusBadRequest)
+		return
+	}
+
+	switch callback.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, callback.Challenge)
+		return
+	case "event_callback":
+	default:
+		ack(w)
+		return
+	}
+
+	e := &Event{}
+	if err := json.Unmarshal(callback.Event, e); err != nil {
+		log.Printf("json.Unmarshal: %v", err)
+		http.Error(w, "Couldn't parse event", http.StatusBadRequest)
+		return
+	}
+	e.TeamID = callback.TeamID
+	e.EventID = callback.EventID
+	e.Raw = callback.Event
+
+	ack(w)
+	h, ok := rt.events[e.Type]
+	// Events are acknowledged before being handled, so retries are for
+	// events that are already being handled.
+	if !ok || r.Header.Get(slackRetryNumHeader) != "" {
+		return
+	}
+	if err := h(r.Context(), e); err != nil {
+		log.Printf("Event %s %s: %v", e.EventID, e.Type, err)
+	}
+}
+
+func (rt *Router) serveInteraction(w http.ResponseWriter, r *http.Request) {
+	in := &Interaction{}
+	if err := json.Unmarshal([]byte(r.PostForm.Get("payload")), in); err != nil {
+		log.Printf("json.Unmarshal: %v", err)
+		http.Error(w, "Couldn't parse payload", http.StatusBadRequest)
+		return
+	}
+	ack(w)
+	if in.Type != "block_actions" {
+		return
+	}
+
+	for i := range in.Actions {
+		a := &in.Actions[i]
+		h, ok := rt.actions[a.ActionID]
+		if !ok {
+			log.Printf("No handler for action %q", a.ActionID)
+			continue
+		}
+		msg, err := h(r.Context(), in, a)
+		if err != nil {
+			log.Printf("Action %s: %v", a.ActionID, err)
+			msg = ephemeral("Sorry, something went wrong. Please try again.")
+		}
+		if msg == nil || in.ResponseURL == "" {
+			continue
+		}
+		if err := rt.respond(r.Context(), in.ResponseURL, msg); err != nil {
+			log.Printf("respond: %v", err)
+		}
+	}
+}
+
+// respond sends msg to the response URL of a command or an interaction.
+// See https://api.slack.com/interactivity/handling#message_responses.
+func (rt *Router) respond(ctx context.Context, responseURL string, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Do: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("response URL returned %s: %s", resp.Status, b)
+	}
+	return nil
+}
+
+// ephemeral returns a message only visible to the user.
+func ephemeral(text string) *Message {
+	return &Message{ResponseType: "ephemeral", Text: text}
+}
+
+// writeMessage writes msg as the complete response, so that Slack gets it
+// even if the function keeps running.
+func writeMessage(w http.ResponseWriter, msg *Message) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("json.Marshal: %v", err)
+		http.Error(w, "Error writing response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ack writes an empty response, acknowledging the request.
+func ack(w http.ResponseWriter) {
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
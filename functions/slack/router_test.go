@@ -0,0 +1,315 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slack
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/kgsearch/v1"
+)
+
+const testSecret = "talesfromthecrypt"
+
+// signedRequest returns a POST request with the given body, signed with
+// testSecret.
+func signedRequest(contentType, body string) *http.Request {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	base := fmt.Sprintf("%s:%s:%s", version, ts, body)
+	signature := fmt.Sprintf("%s=%s", version, hex.EncodeToString(getSignature([]byte(base), []byte(testSecret))))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set(slackRequestTimestampHeader, ts)
+	r.Header.Set(slackSignatureHeader, signature)
+	return r
+}
+
+func commandRequest(command, text, responseURL string) *http.Request {
+	form := url.Values{
+		"command":      {command},
+		"text":         {text},
+		"response_url": {responseURL},
+	}
+	return signedRequest("application/x-www-form-urlencoded", form.Encode())
+}
+
+// responseServer returns a server recording the messages sent to it as a
+// response URL.
+func responseServer(t *testing.T) (*httptest.Server, chan *Message) {
+	t.Helper()
+	messages := make(chan *Message, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := &Message{}
+		if err := json.NewDecoder(r.Body).Decode(msg); err != nil {
+			t.Errorf("json.Decode: %v", err)
+		}
+		messages <- msg
+	}))
+	t.Cleanup(s.Close)
+	return s, messages
+}
+
+func decodeMessage(t *testing.T, w *httptest.ResponseRecorder) *Message {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %v, want %v", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	msg := &Message{}
+	if err := json.NewDecoder(w.Body).Decode(msg); err != nil {
+		t.Fatalf("json.Decode: %v", err)
+	}
+	return msg
+}
+
+func TestRouterVerification(t *testing.T) {
+	rt := NewRouter(testSecret)
+	rt.Command("", func(ctx context.Context, cmd *Command) (*Message, error) {
+		t.Errorf("command handler called for an invalid request")
+		return nil, nil
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET got status %v, want %v", w.Code, http.StatusMethodNotAllowed)
+	}
+
+	r := commandRequest("/kg", "Google", "")
+	r.Header.Set(slackSignatureHeader, "v0=146abde6763faeba19adc4d9fe4961668f4be11f7405a1c05b636f29312eac2e")
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature got status %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	r = commandRequest("/kg", "Google", "")
+	r.Header.Del(slackRequestTimestampHeader)
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("no timestamp got status %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, signedRequest("application/x-www-form-urlencoded", "foo=bar"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown request got status %v, want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRouterCommand(t *testing.T) {
+	rt := NewRouter(testSecret)
+	rt.Command("/echo", func(ctx context.Context, cmd *Command) (*Message, error) {
+		return &Message{ResponseType: "in_channel", Text: cmd.Text}, nil
+	})
+	rt.Command("/fail", func(ctx context.Context, cmd *Command) (*Message, error) {
+		return nil, errors.New("failed")
+	})
+
+	tests := []struct {
+		command      string
+		wantType     string
+		wantContains string
+	}{
+		{command: "/echo", wantType: "in_channel", wantContains: "hello"},
+		{command: "/fail", wantType: "ephemeral", wantContains: "went wrong"},
+		{command: "/unknown", wantType: "ephemeral", wantContains: "/unknown"},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, commandRequest(tc.command, "hello", ""))
+		msg := decodeMessage(t, w)
+		if msg.ResponseType != tc.wantType || !strings.Contains(msg.Text, tc.wantContains) {
+			t.Errorf("%s got %+v, want a %s message containing %q", tc.command, msg, tc.wantType, tc.wantContains)
+		}
+	}
+}
+
+func TestRouterSlowCommand(t *testing.T) {
+	responses, messages := responseServer(t)
+
+	rt := NewRouter(testSecret)
+	rt.ackTimeout = 10 * time.Millisecond
+	release := make(chan bool)
+	rt.Command("/slow", func(ctx context.Context, cmd *Command) (*Message, error) {
+		<-release
+		return &Message{ResponseType: "in_channel", Text: "done"}, nil
+	})
+
+	w := httptest.NewRecorder()
+	served := make(chan bool)
+	go func() {
+		rt.ServeHTTP(w, commandRequest("/slow", "", responses.URL))
+		close(served)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	select {
+	case msg := <-messages:
+		if msg.Text != "done" || msg.ResponseType != "in_channel" {
+			t.Errorf("delayed response got %+v, want the handler's message", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no delayed response")
+	}
+	<-served
+
+	if msg := decodeMessage(t, w); msg.ResponseType != "ephemeral" {
+		t.Errorf("acknowledgement got %+v, want an ephemeral message", msg)
+	}
+}
+
+func TestRouterEvents(t *testing.T) {
+	rt := NewRouter(testSecret)
+	events := make(chan *Event, 1)
+	rt.Event("app_mention", func(ctx context.Context, e *Event) error {
+		events <- e
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, signedRequest("application/json", `{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`))
+	if got, want := w.Body.String(), "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"; w.Code != http.StatusOK || got != want {
+		t.Errorf("url_verification got (%v, %q), want (%v, %q)", w.Code, got, http.StatusOK, want)
+	}
+
+	callback := `{"type":"event_callback","team_id":"T1","event_id":"Ev1","event":{"type":"app_mention","user":"U1","text":"<@U2> Google","channel":"C1","ts":"1.2"}}`
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, signedRequest("application/json", callback))
+	if w.Code != http.StatusOK {
+		t.Errorf("event_callback got status %v, want %v", w.Code, http.StatusOK)
+	}
+	select {
+	case e := <-events:
+		if e.EventID != "Ev1" || e.TeamID != "T1" || e.User != "U1" || e.Channel != "C1" || e.Text != "<@U2> Google" || len(e.Raw) == 0 {
+			t.Errorf("event got %+v", e)
+		}
+	default:
+		t.Errorf("app_mention handler not called")
+	}
+
+	// Retries are acknowledged without calling the handler again.
+	r := signedRequest("application/json", callback)
+	r.Header.Set(slackRetryNumHeader, "1")
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("retry got status %v, want %v", w.Code, http.StatusOK)
+	}
+	if len(events) != 0 {
+		t.Errorf("app_mention handler called for a retry")
+	}
+
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, signedRequest("application/json", `{"type":"event_callback","event":{"type":"reaction_added"}}`))
+	if w.Code != http.StatusOK {
+		t.Errorf("unhandled event got status %v, want %v", w.Code, http.StatusOK)
+	}
+}
+
+func TestRouterInteraction(t *testing.T) {
+	responses, messages := responseServer(t)
+
+	rt := NewRouter(testSecret)
+	rt.Action("more", func(ctx context.Context, in *Interaction, a *Action) (*Message, error) {
+		return &Message{Text: in.User.ID + ":" + a.Value, ReplaceOriginal: true}, nil
+	})
+
+	payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U1"},"response_url":%q,"actions":[{"action_id":"more","value":"2"},{"action_id":"other"}]}`, responses.URL)
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, signedRequest("application/x-www-form-urlencoded", url.Values{"payload": {payload}}.Encode()))
+	if w.Code != http.StatusOK {
+		t.Errorf("interaction got status %v, want %v", w.Code, http.StatusOK)
+	}
+	if body, _ := io.ReadAll(w.Body); len(body) != 0 {
+		t.Errorf("interaction got body %q, want none", body)
+	}
+	select {
+	case msg := <-messages:
+		if msg.Text != "U1:2" || !msg.ReplaceOriginal {
+			t.Errorf("response got %+v, want the handler's message", msg)
+		}
+	default:
+		t.Errorf("no response sent to the response URL")
+	}
+}
+
+func TestFormatSlackMessageAt(t *testing.T) {
+	result := func(name string) interface{} {
+		return map[string]interface{}{
+			"result": map[string]interface{}{
+				"name":        name,
+				"description": "Company",
+				"detailedDescription": map[string]interface{}{
+					"url":         "https://example.com/" + name,
+					"articleBody": name + " <is> a company.",
+				},
+				"image": map[string]interface{}{"contentUrl": "https://example.com/logo.png"},
+			},
+		}
+	}
+	res := &kgsearch.SearchResponse{ItemListElement: []interface{}{result("First"), result("Second")}}
+
+	msg, err := formatSlackMessageAt("companies", res, 1)
+	if err != nil {
+		t.Fatalf("formatSlackMessageAt: %v", err)
+	}
+	got := messageText(msg)
+	for _, want := range []string{"*companies*", "<https://example.com/Second|Second: Company>", "Second &lt;is&gt; a company."} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatSlackMessageAt got text %q, want it to contain %q", got, want)
+		}
+	}
+	if a := msg.Blocks[1].Accessory; a == nil || a.ImageURL != "https://example.com/logo.png" {
+		t.Errorf("formatSlackMessageAt got accessory %+v, want the image", a)
+	}
+
+	last := msg.Blocks[len(msg.Blocks)-1]
+	if last.Type != "actions" || len(last.Elements) != 1 {
+		t.Fatalf("formatSlackMessageAt got last block %+v, want the next result button", last)
+	}
+	button := last.Elements[0].(*Element)
+	var p resultPosition
+	if err := json.Unmarshal([]byte(button.Value), &p); err != nil {
+		t.Fatalf("json.Unmarshal(%q): %v", button.Value, err)
+	}
+	if button.ActionID != nextResultAction || p.Query != "companies" || p.Index != 2 {
+		t.Errorf("next result button got %+v with value %+v, want query companies at index 2", button, p)
+	}
+
+	msg, err = formatSlackMessageAt("companies", res, 2)
+	if err != nil {
+		t.Fatalf("formatSlackMessageAt: %v", err)
+	}
+	if got := messageText(msg); !strings.Contains(got, "No more results") {
+		t.Errorf("formatSlackMessageAt past the results got %q, want no more results", got)
+	}
+}
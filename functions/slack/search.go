@@ -16,10 +16,14 @@
 
 // Package slack is a Cloud Function which recieves a query from
 // a Slack command and responds with the KG API result.
+//
+// Requests from Slack are handled by a Router, which dispatches slash
+// commands, Events API callbacks and interactions to their handlers.
 package slack
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -47,73 +51,57 @@ const (
 	slackSignatureHeader        = "X-Slack-Signature"
 )
 
-type attachment struct {
-	Color     string `json:"color"`
-	Title     string `json:"title"`
-	TitleLink string `json:"title_link"`
-	Text      string `json:"text"`
-	ImageURL  string `json:"image_url"`
-}
-
-// Message is the a Slack message event.
-// see https://api.slack.com/docs/message-formatting
-type Message struct {
-	ResponseType string       `json:"response_type"`
-	Text         string       `json:"text"`
-	Attachments  []attachment `json:"attachments"`
-}
-
 // KGSearch uses the Knowledge Graph API to search for a query provided
 // by a Slack command.
 func KGSearch(w http.ResponseWriter, r *http.Request) {
-	setup(r.Context())
-
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Fatalf("Couldn't read request body: %v", err)
-	}
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
-	if r.Method != "POST" {
-		http.Error(w, "Only POST requests are accepted", http.StatusMethodNotAllowed)
-	}
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Couldn't parse form", 400)
-		log.Fatalf("ParseForm: %v", err)
+	if err := setup(r.Context()); err != nil {
+		log.Printf("setup: %v", err)
+		http.Error(w, "Error setting up", http.StatusInternalServerError)
+		return
 	}
+	router.ServeHTTP(w, r)
+}
 
-	// Reset r.Body as ParseForm depletes it by reading the io.ReadCloser.
-	r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	result, err := verifyWebHook(r, slackSecret)
-	if err != nil {
-		log.Fatalf("verifyWebhook: %v", err)
-	}
-	if !result {
-		log.Fatalf("signatures did not match.")
+// searchCommand responds to a slash command with the first result of the
+// search for its text.
+func searchCommand(ctx context.Context, cmd *Command) (*Message, error) {
+	query := strings.TrimSpace(cmd.Text)
+	if query == "" {
+		return ephemeral(fmt.Sprintf("Usage: %s <query>", cmd.Command)), nil
 	}
+	return makeSearchRequestAt(ctx, query, 0)
+}
 
-	if len(r.Form["text"]) == 0 {
-		log.Fatalf("empty text in form")
+// nextResult responds to a click on the "Next result" button of a search
+// result with the next result, replacing the message.
+func nextResult(ctx context.Context, in *Interaction, a *Action) (*Message, error) {
+	var p resultPosition
+	if err := json.Unmarshal([]byte(a.Value), &p); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
 	}
-	kgSearchResponse, err := makeSearchRequest(r.Form["text"][0])
+	msg, err := makeSearchRequestAt(ctx, p.Query, p.Index)
 	if err != nil {
-		log.Fatalf("makeSearchRequest: %v", err)
-	}
-	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(kgSearchResponse); err != nil {
-		log.Fatalf("json.Marshal: %v", err)
+		return nil, err
 	}
+	msg.ReplaceOriginal = true
+	return msg, nil
 }
 
 // [END functions_slack_search]
 
 // [START functions_slack_request]
 func makeSearchRequest(query string) (*Message, error) {
-	res, err := entitiesService.Search().Query(query).Limit(1).Do()
+	return makeSearchRequestAt(context.Background(), query, 0)
+}
+
+// makeSearchRequestAt returns the result at the given index of the search
+// for query.
+func makeSearchRequestAt(ctx context.Context, query string, index int) (*Message, error) {
+	res, err := entitiesService.Search().Query(query).Limit(int64(index) + 1).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("do: %w", err)
 	}
-	return formatSlackMessage(query, res)
+	return formatSlackMessageAt(query, res, index)
 }
 
 // [END functions_slack_request]
@@ -18,8 +18,9 @@ package slack
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"os"
+	"sync"
 
 	"google.golang.org/api/kgsearch/v1"
 	"google.golang.org/api/option"
@@ -29,19 +30,37 @@ var (
 	entitiesService *kgsearch.EntitiesService
 	kgKey           string
 	slackSecret     string
+	router          *Router
+
+	// setupMu guards the variables above while setup initializes them.
+	setupMu sync.Mutex
 )
 
-func setup(ctx context.Context) {
-	kgKey = os.Getenv("KG_API_KEY")
-	slackSecret = os.Getenv("SLACK_SECRET")
+func setup(ctx context.Context) error {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	if kgKey == "" {
+		kgKey = os.Getenv("KG_API_KEY")
+	}
+	if slackSecret == "" {
+		slackSecret = os.Getenv("SLACK_SECRET")
+	}
 
 	if entitiesService == nil {
 		kgService, err := kgsearch.NewService(ctx, option.WithAPIKey(kgKey))
 		if err != nil {
-			log.Fatalf("kgsearch.NewService: %v", err)
+			return fmt.Errorf("kgsearch.NewService: %w", err)
 		}
 		entitiesService = kgsearch.NewEntitiesService(kgService)
 	}
+
+	if router == nil {
+		router = NewRouter(slackSecret)
+		router.Command("", searchCommand)
+		router.Action(nextResultAction, nextResult)
+	}
+	return nil
 }
 
 // [END functions_slack_setup]
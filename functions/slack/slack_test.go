@@ -39,25 +39,50 @@ func TestMain(m *testing.M) {
 	ctx := context.Background()
 	slackURL = os.Getenv("GOLANG_SAMPLES_SLACK_URL")
 	kgKey = os.Getenv("GOLANG_SAMPLES_KG_KEY")
-	if kgKey == "" {
-		log.Print("GOLANG_SAMPLES_KG_KEY is unset. Skipping.")
-		return
-	}
 	slackSecret = os.Getenv("GOLANG_SAMPLES_SLACK_SECRET")
-	if slackSecret == "" {
-		log.Print("GOLANG_SAMPLES_SLACK_SECRET is unset. Skipping.")
-		return
-	}
-	kgService, err := kgsearch.NewService(ctx, option.WithAPIKey(kgKey))
-	if err != nil {
-		log.Fatalf("kgsearch.NewClient: %v", err)
+	switch {
+	case kgKey == "":
+		log.Print("GOLANG_SAMPLES_KG_KEY is unset. Skipping Knowledge Graph tests.")
+	case slackSecret == "":
+		log.Print("GOLANG_SAMPLES_SLACK_SECRET is unset. Skipping Knowledge Graph tests.")
+	default:
+		kgService, err := kgsearch.NewService(ctx, option.WithAPIKey(kgKey))
+		if err != nil {
+			log.Fatalf("kgsearch.NewClient: %v", err)
+		}
+		entitiesService = kgsearch.NewEntitiesService(kgService)
 	}
-	entitiesService = kgsearch.NewEntitiesService(kgService)
 
 	os.Exit(m.Run())
 }
 
+// skipWithoutKG skips tests calling the Knowledge Graph API when it isn't
+// configured.
+func skipWithoutKG(t *testing.T) {
+	t.Helper()
+	if entitiesService == nil {
+		t.Skip("Set GOLANG_SAMPLES_KG_KEY and GOLANG_SAMPLES_SLACK_SECRET.")
+	}
+}
+
+// messageText returns the text of all the blocks of msg.
+func messageText(msg *Message) string {
+	var texts []string
+	for _, b := range msg.Blocks {
+		if b.Text != nil {
+			texts = append(texts, b.Text.Text)
+		}
+		for _, e := range b.Elements {
+			if text, ok := e.(*Text); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
 func TestFormatSlackMessage(t *testing.T) {
+	skipWithoutKG(t)
 	tests := []struct {
 		query string
 		want  string
@@ -80,7 +105,7 @@ func TestFormatSlackMessage(t *testing.T) {
 		if err != nil {
 			t.Errorf("formatSlackMessage: %v", err)
 		}
-		got := msg.Attachments[0].Text
+		got := messageText(msg)
 		if !strings.Contains(got, test.want) {
 			t.Errorf("formatSlackMessage(%q) got %q, want %q", test.query, got, test.want)
 		}
@@ -88,6 +113,7 @@ func TestFormatSlackMessage(t *testing.T) {
 }
 
 func TestMakeSearchRequest(t *testing.T) {
+	skipWithoutKG(t)
 	query := "Google"
 	want := "Google"
 	msg, err := makeSearchRequest(query)
@@ -101,19 +127,21 @@ func TestMakeSearchRequest(t *testing.T) {
 	if !strings.Contains(got, want) {
 		t.Errorf("makeSearchRequest(%q) got %q, want %q", query, got, want)
 	}
-	if len(msg.Attachments) == 0 {
-		t.Errorf("makeSearchRequest(%q) returned no attachments", query)
+	if len(msg.Blocks) == 0 {
+		t.Errorf("makeSearchRequest(%q) returned no blocks", query)
 	}
-	got = msg.Attachments[0].Text
+	got = messageText(msg)
 	if !strings.Contains(got, want) {
 		t.Errorf("makeSearchRequest(%q) got %q, want %q", query, got, want)
 	}
 }
 
 func TestKGSearch(t *testing.T) {
+	skipWithoutKG(t)
 	w := httptest.NewRecorder()
 	form := url.Values{
-		"text": []string{"Google"},
+		"command": []string{"/kg"},
+		"text":    []string{"Google"},
 	}
 
 	ts := strconv.FormatInt(time.Now().Unix(), 10)
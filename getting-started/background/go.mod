@@ -7,6 +7,7 @@ require (
 	cloud.google.com/go/pubsub v1.45.3
 	cloud.google.com/go/translate v1.12.3
 	golang.org/x/text v0.21.0
+	google.golang.org/api v0.217.0
 	google.golang.org/grpc v1.69.4
 )
 
@@ -40,7 +41,6 @@ require (
 	golang.org/x/sync v0.10.0 // indirect
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/time v0.9.0 // indirect
-	google.golang.org/api v0.217.0 // indirect
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
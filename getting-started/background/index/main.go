@@ -16,7 +16,9 @@
 
 // Command index is an HTTP app that displays all previous translations
 // (stored in Firestore) and has a form to request new translations. On form
-// submission, the request is sent to Pub/Sub to be processed in the background.
+// submission, the request is recorded in Firestore and sent to Pub/Sub to be
+// processed in the background. The page polls /translations/{id} to show the
+// progress of pending requests.
 package main
 
 import (
@@ -28,10 +30,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/pubsub"
 	"github.com/GoogleCloudPlatform/golang-samples/getting-started/background"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // topicName is the Pub/Sub topic to publish requests to. The Cloud Function to
@@ -45,6 +52,10 @@ type app struct {
 	pubsubTopic     *pubsub.Topic
 	firestoreClient *firestore.Client
 	tmpl            *template.Template
+
+	// languages are the sorted languages supported by the translation
+	// engine, which translations can be requested to.
+	languages []string
 }
 
 func main() {
@@ -60,6 +71,7 @@ func main() {
 
 	http.HandleFunc("/", a.index)
 	http.HandleFunc("/request-translation", a.requestTranslation)
+	http.HandleFunc("/translations/", a.translationStatus)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -94,12 +106,25 @@ func newApp(projectID, templateDir string) (*app, error) {
 		return nil, fmt.Errorf("template.New: %w", err)
 	}
 
+	// Use the same engine as the Translate function, configured by
+	// TRANSLATE_ENGINE, to offer only the languages it supports.
+	translator, err := background.NewTranslatorFromEnv(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("NewTranslatorFromEnv: %w", err)
+	}
+	languages, err := translator.Languages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Languages: %w", err)
+	}
+
 	return &app{
 		pubsubClient: pubsubClient,
 		pubsubTopic:  pubsubTopic,
 
 		firestoreClient: firestoreClient,
 		tmpl:            tmpl,
+
+		languages: languages,
 	}, nil
 }
 
@@ -107,9 +132,9 @@ func newApp(projectID, templateDir string) (*app, error) {
 
 // [START getting_started_background_app_list]
 
-// index lists the current translations.
+// index lists the current translations, most recent first.
 func (a *app) index(w http.ResponseWriter, r *http.Request) {
-	docs, err := a.firestoreClient.Collection("translations").Documents(r.Context()).GetAll()
+	docs, err := a.firestoreClient.Collection(background.Collection).Documents(r.Context()).GetAll()
 	if err != nil {
 		log.Printf("GetAll: %v", err)
 		http.Error(w, fmt.Sprintf("Error getting translations: %v", err), http.StatusInternalServerError)
@@ -126,8 +151,17 @@ func (a *app) index(w http.ResponseWriter, r *http.Request) {
 		}
 		translations = append(translations, t)
 	}
+	// Translations recorded before requests had a status have no
+	// Requested time, and are listed last.
+	sort.SliceStable(translations, func(i, j int) bool {
+		return translations[i].Requested.After(translations[j].Requested)
+	})
 
-	if err := a.tmpl.Execute(w, translations); err != nil {
+	data := struct {
+		Translations []background.Translation
+		Languages    []string
+	}{translations, a.languages}
+	if err := a.tmpl.Execute(w, data); err != nil {
 		log.Printf("tmpl.Execute: %v", err)
 		http.Error(w, "Error writing response", http.StatusInternalServerError)
 		return
@@ -151,27 +185,40 @@ func (a *app) requestTranslation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Empty value", http.StatusBadRequest)
 		return
 	}
-	acceptableLanguages := map[string]bool{
-		"de": true,
-		"en": true,
-		"es": true,
-		"fr": true,
-		"ja": true,
-		"sw": true,
-	}
 	lang := r.PostFormValue("lang")
-	if !acceptableLanguages[lang] {
+	if !a.supports(lang) {
 		log.Printf("Unsupported language: %v", lang)
 		http.Error(w, fmt.Sprintf("Unsupported language: %v", lang), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Translation requested: %q -> %s", v, lang)
+	id, err := background.NewRequestID()
+	if err != nil {
+		log.Printf("NewRequestID: %v", err)
+		http.Error(w, "Error requesting translation", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Translation %s requested: %q -> %s", id, v, lang)
 
+	now := time.Now()
 	t := background.Translation{
-		Original: v,
-		Language: lang,
+		ID:        id,
+		Original:  v,
+		Language:  lang,
+		Status:    background.StatusQueued,
+		Requested: now,
+		Updated:   now,
+	}
+	// Record the request before publishing it, so it is listed even if it
+	// hasn't been processed yet.
+	ref := a.firestoreClient.Collection(background.Collection).Doc(id)
+	if _, err := ref.Create(r.Context(), t); err != nil {
+		log.Printf("Create: %v", err)
+		http.Error(w, "Error requesting translation", http.StatusInternalServerError)
+		return
 	}
+
 	msg, err := json.Marshal(t)
 	if err != nil {
 		log.Printf("json.Marshal: %v", err)
@@ -182,9 +229,70 @@ func (a *app) requestTranslation(w http.ResponseWriter, r *http.Request) {
 	res := a.pubsubTopic.Publish(r.Context(), &pubsub.Message{Data: msg})
 	if _, err := res.Get(r.Context()); err != nil {
 		log.Printf("Publish.Get: %v", err)
+		// The request will never be processed.
+		if _, err := ref.Set(r.Context(), map[string]interface{}{
+			"Status":  background.StatusFailed,
+			"Error":   "could not queue the request",
+			"Updated": time.Now(),
+		}, firestore.MergeAll); err != nil {
+			log.Printf("Set: %v", err)
+		}
 		http.Error(w, "Error requesting translation", http.StatusInternalServerError)
 		return
 	}
+
+	w.Header().Set("Location", "/translations/"+id)
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+// supports reports whether translations can be requested to lang.
+func (a *app) supports(lang string) bool {
+	i := sort.SearchStrings(a.languages, lang)
+	return i < len(a.languages) && a.languages[i] == lang
 }
 
 // [END getting_started_background_app_request]
+
+// translationStatus writes the translation request /translations/{id} as
+// JSON, so that the page can poll it until it is done or failed.
+func (a *app) translationStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/translations/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	doc, err := a.firestoreClient.Collection(background.Collection).Doc(id).Get(r.Context())
+	if status.Code(err) == codes.NotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Get: %v", err)
+		http.Error(w, "Error getting translation", http.StatusInternalServerError)
+		return
+	}
+	t := background.Translation{}
+	if err := doc.DataTo(&t); err != nil {
+		log.Printf("DataTo: %v", err)
+		http.Error(w, "Error reading translation", http.StatusInternalServerError)
+		return
+	}
+
+	// Don't let clients cache a status that is going to change.
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, t)
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("json.Encode: %v", err)
+	}
+}
@@ -15,10 +15,15 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/GoogleCloudPlatform/golang-samples/getting-started/background"
 )
 
 func TestIndex(t *testing.T) {
@@ -41,3 +46,29 @@ func TestIndex(t *testing.T) {
 		t.Errorf("wrong status code, got %v, want %v", resp.StatusCode, http.StatusOK)
 	}
 }
+
+func TestRequestTranslationLanguages(t *testing.T) {
+	languages, err := (&background.StubTranslator{}).Languages(context.Background())
+	if err != nil {
+		t.Fatalf("Languages: %v", err)
+	}
+	a := &app{languages: languages}
+
+	for _, lang := range []string{"", "xx", "FR"} {
+		form := url.Values{"v": {"Me"}, "lang": {lang}}
+		r := httptest.NewRequest("POST", "/request-translation", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		a.requestTranslation(w, r)
+
+		if got := w.Result().StatusCode; got != http.StatusBadRequest {
+			t.Errorf("requestTranslation(lang=%q) got status %v, want %v", lang, got, http.StatusBadRequest)
+		}
+	}
+
+	for _, lang := range []string{"de", "fr", "sw"} {
+		if !a.supports(lang) {
+			t.Errorf("supports(%q) = false, want true", lang)
+		}
+	}
+}
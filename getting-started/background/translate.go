@@ -17,63 +17,130 @@
 // Package background contains a Cloud Function to translate text.
 // The function listens to Pub/Sub, does the translations, and stores the
 // result in Firestore.
+//
+// Each translation request is a Firestore document, created by the index app
+// with the status StatusQueued before the request is published. The
+// Translate function moves it to StatusInProgress, then to StatusDone or
+// StatusFailed, so the app can show the progress of requests.
 package background
 
 import (
 	"context"
-	"crypto/sha512"
-	"encoding/base64"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/translate"
-	"golang.org/x/text/language"
+	"cloud.google.com/go/pubsub"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// A Translation contains the original and translated text.
+// Status is the processing status of a Translation.
+type Status string
+
+// The lifecycle of a Translation: StatusQueued, then StatusInProgress, then
+// StatusDone or StatusFailed. A request that failed with a temporary error
+// is StatusQueued again until it is retried.
+const (
+	StatusQueued     Status = "queued"
+	StatusInProgress Status = "in-progress"
+	StatusDone       Status = "done"
+	StatusFailed     Status = "failed"
+)
+
+// Final reports whether s is a final status, which never changes.
+func (s Status) Final() bool {
+	return s == StatusDone || s == StatusFailed
+}
+
+// A Translation contains the original and translated text, and the status of
+// the request to translate it.
 type Translation struct {
+	// ID identifies the request. It is the ID of the Translation's
+	// document in the translations collection.
+	ID string `json:"id"`
+
 	Original         string `json:"original"`
 	Translated       string `json:"translated"`
 	OriginalLanguage string `json:"original_language"`
 	Language         string `json:"language"`
+
+	Status Status `json:"status"`
+	// Error describes why the request failed, or why the last attempt
+	// failed if it is going to be retried.
+	Error string `json:"error,omitempty"`
+	// Attempts is the number of times processing the request started.
+	Attempts int `json:"attempts"`
+
+	Requested time.Time `json:"requested"`
+	Updated   time.Time `json:"updated"`
+}
+
+const (
+	// Collection is the Firestore collection of Translations.
+	Collection = "translations"
+
+	// DeadLetterTopic is the Pub/Sub topic that messages which can't be
+	// processed are sent to, by Translate for malformed messages, and by the
+	// dead-letter policy of the subscription for messages that failed too
+	// many times.
+	DeadLetterTopic = "translate-dead-letter"
+
+	// leaseDuration is how long a request stays StatusInProgress before
+	// another delivery of its message can take it over, in case the
+	// function processing it crashed.
+	leaseDuration = 2 * time.Minute
+)
+
+// NewRequestID returns a new random Translation.ID.
+func NewRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // Clients reused between function invocations.
 var (
-	translateClient *translate.Client
+	translator      Translator
 	firestoreClient *firestore.Client
+	pubsubClient    *pubsub.Client
 )
 
 // PubSubMessage is the payload of a Pub/Sub event.
 // See https://cloud.google.com/functions/docs/calling/pubsub.
 type PubSubMessage struct {
-	Data []byte `json:"data"`
+	Data       []byte            `json:"data"`
+	Attributes map[string]string `json:"attributes"`
 }
 
 // [END getting_started_background_translate_setup]
 
 // [START getting_started_background_translate_init]
 
-// initializeClients creates translateClient and firestoreClient if they haven't
-// been created yet.
+// initializeClients creates translator, firestoreClient and pubsubClient if
+// they haven't been created yet.
 func initializeClients() error {
 	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
 	if projectID == "" {
 		return fmt.Errorf("GOOGLE_CLOUD_PROJECT must be set")
 	}
 
-	if translateClient == nil {
-		// Pre-declare err to avoid shadowing translateClient.
+	if translator == nil {
+		// Pre-declare err to avoid shadowing translator.
 		var err error
 		// Use context.Background() so the client can be reused.
-		translateClient, err = translate.NewClient(context.Background())
+		translator, err = NewTranslatorFromEnv(context.Background(), projectID)
 		if err != nil {
-			return fmt.Errorf("translate.NewClient: %w", err)
+			return fmt.Errorf("NewTranslatorFromEnv: %w", err)
 		}
 	}
 	if firestoreClient == nil {
@@ -85,86 +152,220 @@ func initializeClients() error {
 			return fmt.Errorf("firestore.NewClient: %w", err)
 		}
 	}
+	if pubsubClient == nil {
+		// Pre-declare err to avoid shadowing pubsubClient.
+		var err error
+		// Use context.Background() so the client can be reused.
+		pubsubClient, err = pubsub.NewClient(context.Background(), projectID)
+		if err != nil {
+			return fmt.Errorf("pubsub.NewClient: %w", err)
+		}
+	}
 	return nil
 }
 
 // [END getting_started_background_translate_init]
 
-// [START getting_started_background_translate_string]
+// [START getting_started_background_translate]
+
+// Translate translates the text of the request in the given message and
+// stores the result in Firestore.
+//
+// Pub/Sub can deliver a message more than once, so requests that are done
+// or failed are not processed again. Translate returns an error for
+// temporary failures, for the function to be retried: deploy it with
+// --retry, and give its subscription a dead-letter policy sending messages
+// to DeadLetterTopic after a few attempts. Malformed messages are sent to
+// DeadLetterTopic right away.
+func Translate(ctx context.Context, m PubSubMessage) error {
+	if err := initializeClients(); err != nil {
+		return err
+	}
 
-// translateString translates text to lang, returning:
-// * the translated text,
-// * the automatically detected source language, and
-// * an error.
-func translateString(ctx context.Context, text string, lang string) (translated string, originalLang string, err error) {
-	l, err := language.Parse(lang)
+	t := Translation{}
+	if err := json.Unmarshal(m.Data, &t); err != nil {
+		return deadLetter(ctx, m, fmt.Sprintf("json.Unmarshal: %v", err))
+	}
+	if t.ID == "" || strings.Contains(t.ID, "/") || t.Original == "" || t.Language == "" {
+		return deadLetter(ctx, m, "message needs a valid id, original and language")
+	}
+	ref := firestoreClient.Collection(Collection).Doc(t.ID)
+
+	t, start, err := claim(ctx, ref, t)
 	if err != nil {
-		return "", "", fmt.Errorf("language.Parse: %w", err)
+		return fmt.Errorf("claim: %w", err)
+	}
+	if !start {
+		return nil
 	}
 
-	outs, err := translateClient.Translate(ctx, []string{text}, l, nil)
+	translated, originalLang, err := translator.Translate(ctx, t.Original, t.Language)
+	if errors.Is(err, ErrPermanent) {
+		log.Printf("Translation %s failed: %v", t.ID, err)
+		return finish(ctx, ref, t.Attempts, map[string]interface{}{
+			"Status": StatusFailed,
+			"Error":  err.Error(),
+		})
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("Translate: %w", err)
+		// Let another attempt try again.
+		if err := finish(ctx, ref, t.Attempts, map[string]interface{}{
+			"Status": StatusQueued,
+			"Error":  fmt.Sprintf("attempt %d: %v", t.Attempts, err),
+		}); err != nil {
+			log.Printf("finish: %v", err)
+		}
+		return fmt.Errorf("Translate: %w", err)
 	}
+	return finish(ctx, ref, t.Attempts, map[string]interface{}{
+		"Status":           StatusDone,
+		"Translated":       translated,
+		"OriginalLanguage": originalLang,
+		"Error":            "",
+	})
+}
 
-	if len(outs) < 1 {
-		return "", "", fmt.Errorf("Translate got %d translations, need at least 1", len(outs))
+// claim marks the request t as in progress, unless it is already final or
+// in progress elsewhere, returning the stored request and whether to start
+// processing it. A request that is in progress elsewhere is an error, so
+// that the message is delivered again in case the other attempt fails.
+func claim(ctx context.Context, ref *firestore.DocumentRef, t Translation) (Translation, bool, error) {
+	start := false
+	// Run in a transaction to prevent concurrent duplicate translations.
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		start = false
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("Get: %w", err)
+		}
+		now := time.Now()
+		if doc.Exists() {
+			if err := doc.DataTo(&t); err != nil {
+				return fmt.Errorf("DataTo: %w", err)
+			}
+		} else {
+			// The request was published without being recorded first.
+			t.Requested = now
+		}
+		switch {
+		case t.Status.Final():
+			log.Printf("Translation %s is already %s", t.ID, t.Status)
+			return nil
+		case t.Status == StatusInProgress && now.Sub(t.Updated) < leaseDuration:
+			return fmt.Errorf("translation %s is in progress since %v", t.ID, t.Updated)
+		}
+		t.Status = StatusInProgress
+		t.Attempts++
+		t.Updated = now
+		start = true
+		return tx.Set(ref, t)
+	})
+	if err != nil {
+		return t, false, fmt.Errorf("RunTransaction: %w", err)
 	}
+	return t, start, nil
+}
 
-	return outs[0].Text, outs[0].Source.String(), nil
+// finish updates the request being processed by the given attempt, unless
+// another attempt has taken it over since.
+func finish(ctx context.Context, ref *firestore.DocumentRef, attempt int, fields map[string]interface{}) error {
+	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return fmt.Errorf("Get: %w", err)
+		}
+		var cur Translation
+		if err := doc.DataTo(&cur); err != nil {
+			return fmt.Errorf("DataTo: %w", err)
+		}
+		if cur.Status != StatusInProgress || cur.Attempts != attempt {
+			log.Printf("Translation %s was taken over by attempt %d", cur.ID, cur.Attempts)
+			return nil
+		}
+		fields["Updated"] = time.Now()
+		return tx.Set(ref, fields, firestore.MergeAll)
+	})
+	if err != nil {
+		return fmt.Errorf("RunTransaction: %w", err)
+	}
+	return nil
 }
 
-// [END getting_started_background_translate_string]
+// deadLetter publishes a message that can't be processed to DeadLetterTopic,
+// with the reason as its "error" attribute, so that it isn't retried.
+func deadLetter(ctx context.Context, m PubSubMessage, reason string) error {
+	log.Printf("Dead-lettering message: %s", reason)
+	attrs := map[string]string{"error": reason}
+	for k, v := range m.Attributes {
+		attrs[k] = v
+	}
+	topic := pubsubClient.Topic(DeadLetterTopic)
+	defer topic.Stop()
+	res := topic.Publish(ctx, &pubsub.Message{Data: m.Data, Attributes: attrs})
+	if _, err := res.Get(ctx); err != nil {
+		return fmt.Errorf("Publish.Get: %w", err)
+	}
+	return nil
+}
 
-// [START getting_started_background_translate]
+// [END getting_started_background_translate]
 
-// Translate translates the given message and stores the result in Firestore.
-func Translate(ctx context.Context, m PubSubMessage) error {
-	initializeClients()
+// DeadLetter records the requests of messages sent to DeadLetterTopic as
+// failed, so that they don't stay queued forever. Deploy it with a trigger
+// on DeadLetterTopic and --retry.
+//
+// A request that is in progress, with a lease that hasn't expired, may still
+// succeed, so it is left alone and DeadLetter returns an error to be retried.
+// Once the lease expires, the worker holding it is presumed to have crashed.
+func DeadLetter(ctx context.Context, m PubSubMessage) error {
+	if err := initializeClients(); err != nil {
+		return err
+	}
 
+	reason := m.Attributes["error"]
+	if reason == "" {
+		// Set by Pub/Sub when it forwards a message to the dead-letter topic.
+		reason = fmt.Sprintf("gave up after %s delivery attempts", m.Attributes["CloudPubSubDeadLetterSourceDeliveryCount"])
+	}
 	t := Translation{}
-	if err := json.Unmarshal(m.Data, &t); err != nil {
-		return fmt.Errorf("json.Unmarshal: %w", err)
+	if err := json.Unmarshal(m.Data, &t); err != nil || t.ID == "" || strings.Contains(t.ID, "/") {
+		// There is no request to update. Keep the message in the logs.
+		log.Printf("Dead-lettered message %q: %s", m.Data, reason)
+		return nil
 	}
 
-	// Use a unique document name to prevent duplicate translations.
-	key := fmt.Sprintf("%s/%s", t.Language, t.Original)
-	sum := sha512.Sum512([]byte(key))
-	// Base64 encode the sum to make a nice string. The [:] converts the byte
-	// array to a byte slice.
-	docName := base64.StdEncoding.EncodeToString(sum[:])
-	// The document name cannot contain "/".
-	docName = strings.Replace(docName, "/", "-", -1)
-	ref := firestoreClient.Collection("translations").Doc(docName)
-
-	// Run in a transation to prevent concurrent duplicate translations.
+	ref := firestoreClient.Collection(Collection).Doc(t.ID)
 	err := firestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		doc, err := tx.Get(ref)
-		if err != nil && status.Code(err) != codes.NotFound {
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
 			return fmt.Errorf("Get: %w", err)
 		}
-		// Do nothing if the document already exists.
-		if doc.Exists() {
+		var cur Translation
+		if err := doc.DataTo(&cur); err != nil {
+			return fmt.Errorf("DataTo: %w", err)
+		}
+		if cur.Status.Final() {
 			return nil
 		}
-
-		translated, originalLang, err := translateString(ctx, t.Original, t.Language)
-		if err != nil {
-			return fmt.Errorf("translateString: %w", err)
+		if cur.Status == StatusInProgress && time.Since(cur.Updated) < leaseDuration {
+			return fmt.Errorf("translation %s is in progress since %v", t.ID, cur.Updated)
 		}
-		t.Translated = translated
-		t.OriginalLanguage = originalLang
-
-		if err := tx.Set(ref, t); err != nil {
-			return fmt.Errorf("Set: %w", err)
+		msg := reason
+		if cur.Error != "" {
+			msg += "; last error: " + cur.Error
 		}
-		return nil
+		return tx.Set(ref, map[string]interface{}{
+			"Status":  StatusFailed,
+			"Error":   msg,
+			"Updated": time.Now(),
+		}, firestore.MergeAll)
 	})
-
 	if err != nil {
 		return fmt.Errorf("RunTransaction: %w", err)
 	}
+	log.Printf("Translation %s failed: %s", t.ID, reason)
 	return nil
 }
-
-// [END getting_started_background_translate]
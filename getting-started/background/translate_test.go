@@ -18,12 +18,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
 )
 
 func TestTranslate(t *testing.T) {
@@ -56,8 +59,10 @@ func TestTranslate(t *testing.T) {
 			}
 
 			msg, err := json.Marshal(Translation{
+				ID:       "test-translate",
 				Original: "Me",
 				Language: "fr",
+				Status:   StatusQueued,
 			})
 			if err != nil {
 				errorf("json.Marshal: %v", err)
@@ -78,13 +83,16 @@ func TestTranslate(t *testing.T) {
 				return false
 			}
 			want := Translation{
+				ID:               "test-translate",
 				Original:         "Me",
 				OriginalLanguage: "en",
 				Language:         "fr",
 				Translated:       "Moi",
+				Status:           StatusDone,
+				Attempts:         1,
 			}
-			if translations[0] != want {
-				errorf("Translate got:\n%+v\nWant:\n%+v", translations[0], want)
+			if got := withoutTimes(translations[0]); got != want {
+				errorf("Translate got:\n%+v\nWant:\n%+v", got, want)
 				return false
 			}
 
@@ -102,6 +110,10 @@ func TestTranslate(t *testing.T) {
 				errorf("Translate got %d translations, want 1", len(translations))
 				return false
 			}
+			if got := withoutTimes(translations[0]); got != want {
+				errorf("Translate of a duplicate got:\n%+v\nWant:\n%+v", got, want)
+				return false
+			}
 			return true
 		}()
 		if pass {
@@ -114,8 +126,230 @@ func TestTranslate(t *testing.T) {
 	t.Fatalf("Translate failed after %d attempts: %v", maxRetries, failureLog.String())
 }
 
+func TestTranslateRetry(t *testing.T) {
+	projectID := firestoreTestProject(t)
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	if err := deleteAll(ctx, client, projectID); err != nil {
+		t.Fatalf("deleteAll: %v", err)
+	}
+
+	flaky := &flakyTranslator{failures: 1}
+	old := translator
+	translator = flaky
+	defer func() { translator = old }()
+
+	msg, err := json.Marshal(Translation{
+		ID:       "test-retry",
+		Original: "Me",
+		Language: "fr",
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	m := PubSubMessage{Data: msg}
+
+	if err := Translate(ctx, m); err == nil {
+		t.Fatalf("Translate got no error for a temporary error, want one to be retried")
+	}
+	got, err := get(ctx, client, "test-retry")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != StatusQueued || got.Attempts != 1 || got.Error == "" {
+		t.Errorf("after a temporary error got %+v, want it queued with 1 attempt and an error", got)
+	}
+
+	// Redeliveries after the request is done must not translate it again.
+	for i := 0; i < 2; i++ {
+		if err := Translate(ctx, m); err != nil {
+			t.Fatalf("Translate: %v", err)
+		}
+	}
+	got, err = get(ctx, client, "test-retry")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != StatusDone || got.Attempts != 2 || got.Error != "" || got.Translated != "[fr] Me" {
+		t.Errorf("after retrying got %+v, want it done after 2 attempts", got)
+	}
+	if flaky.calls != 2 {
+		t.Errorf("Translate called %d times, want 2", flaky.calls)
+	}
+}
+
+func TestTranslatePermanentError(t *testing.T) {
+	projectID := firestoreTestProject(t)
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	if err := deleteAll(ctx, client, projectID); err != nil {
+		t.Fatalf("deleteAll: %v", err)
+	}
+
+	msg, err := json.Marshal(Translation{
+		ID:       "test-permanent",
+		Original: "Me",
+		Language: "not a language",
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	// The request can't succeed, so it must not be retried.
+	if err := Translate(ctx, PubSubMessage{Data: msg}); err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	got, err := get(ctx, client, "test-permanent")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("got %+v, want it failed with an error", got)
+	}
+}
+
+func TestDeadLetterLease(t *testing.T) {
+	projectID := firestoreTestProject(t)
+
+	ctx := context.Background()
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		t.Fatalf("firestore.NewClient: %v", err)
+	}
+	if err := deleteAll(ctx, client, projectID); err != nil {
+		t.Fatalf("deleteAll: %v", err)
+	}
+
+	ref := client.Collection(Collection).Doc("test-dead-letter")
+	req := Translation{
+		ID:       "test-dead-letter",
+		Original: "Me",
+		Language: "fr",
+		Status:   StatusInProgress,
+		Attempts: 1,
+		Updated:  time.Now(),
+	}
+	if _, err := ref.Set(ctx, req); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	msg, err := json.Marshal(Translation{ID: req.ID, Original: req.Original, Language: req.Language})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	m := PubSubMessage{Data: msg, Attributes: map[string]string{"error": "gave up"}}
+
+	// Another worker holds the lease, and may still succeed.
+	if err := DeadLetter(ctx, m); err == nil {
+		t.Errorf("DeadLetter of a request with a live lease got no error, want one to be retried")
+	}
+	got, err := get(ctx, client, req.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != StatusInProgress {
+		t.Errorf("DeadLetter of a request with a live lease changed it to %+v", got)
+	}
+
+	// The lease expired, so the worker is gone.
+	req.Updated = time.Now().Add(-leaseDuration)
+	if _, err := ref.Set(ctx, req); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := DeadLetter(ctx, m); err != nil {
+		t.Fatalf("DeadLetter: %v", err)
+	}
+	got, err = get(ctx, client, req.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != StatusFailed || got.Error != "gave up" {
+		t.Errorf("DeadLetter after the lease expired got %+v, want it failed", got)
+	}
+}
+
+// firestoreTestProject returns the project of the tests that use Firestore,
+// and sets GOOGLE_CLOUD_PROJECT to it for the functions. If
+// FIRESTORE_EMULATOR_HOST is set, the tests use the emulator, and don't need
+// credentials: the functions translate with a StubTranslator, unless
+// TRANSLATE_ENGINE is set, and don't reach Pub/Sub, unless
+// PUBSUB_EMULATOR_HOST is set. Otherwise the tests use
+// GOLANG_SAMPLES_FIRESTORE_PROJECT, and are skipped if it isn't set.
+func firestoreTestProject(t *testing.T) string {
+	t.Helper()
+	projectID := os.Getenv("GOLANG_SAMPLES_FIRESTORE_PROJECT")
+	if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+		if projectID == "" {
+			t.Skip("Skipping Firestore test. Set GOLANG_SAMPLES_FIRESTORE_PROJECT or FIRESTORE_EMULATOR_HOST.")
+		}
+		os.Setenv("GOOGLE_CLOUD_PROJECT", projectID)
+		return projectID
+	}
+
+	if projectID == "" {
+		projectID = "background-test"
+	}
+	os.Setenv("GOOGLE_CLOUD_PROJECT", projectID)
+	if os.Getenv("TRANSLATE_ENGINE") == "" {
+		t.Setenv("TRANSLATE_ENGINE", "stub")
+	}
+	if pubsubClient == nil && os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		// Only dead-lettering publishes, and these tests don't.
+		c, err := pubsub.NewClient(context.Background(), projectID, option.WithoutAuthentication())
+		if err != nil {
+			t.Fatalf("pubsub.NewClient: %v", err)
+		}
+		pubsubClient = c
+	}
+	return projectID
+}
+
+// flakyTranslator is a StubTranslator whose first translations fail with a
+// temporary error.
+type flakyTranslator struct {
+	StubTranslator
+	failures int
+	calls    int
+}
+
+func (t *flakyTranslator) Translate(ctx context.Context, text, lang string) (string, string, error) {
+	t.calls++
+	if t.calls <= t.failures {
+		return "", "", errors.New("temporary error")
+	}
+	return t.StubTranslator.Translate(ctx, text, lang)
+}
+
+// withoutTimes returns t without its times, which can't be predicted.
+func withoutTimes(t Translation) Translation {
+	t.Requested = time.Time{}
+	t.Updated = time.Time{}
+	return t
+}
+
+func get(ctx context.Context, client *firestore.Client, id string) (Translation, error) {
+	t := Translation{}
+	doc, err := client.Collection(Collection).Doc(id).Get(ctx)
+	if err != nil {
+		return t, err
+	}
+	if err := doc.DataTo(&t); err != nil {
+		return t, fmt.Errorf("DataTo: %v", err)
+	}
+	return t, nil
+}
+
 func deleteAll(ctx context.Context, client *firestore.Client, projectID string) error {
-	docs, err := client.Collection("translations").Documents(ctx).GetAll()
+	docs, err := client.Collection(Collection).Documents(ctx).GetAll()
 	if err != nil {
 		return err
 	}
@@ -128,7 +362,7 @@ func deleteAll(ctx context.Context, client *firestore.Client, projectID string)
 }
 
 func getAll(ctx context.Context, client *firestore.Client, projectID string) ([]Translation, error) {
-	docs, err := client.Collection("translations").Documents(ctx).GetAll()
+	docs, err := client.Collection(Collection).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, err
 	}
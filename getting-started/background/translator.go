@@ -0,0 +1,263 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"cloud.google.com/go/translate"
+	translate3 "cloud.google.com/go/translate/apiv3"
+	"cloud.google.com/go/translate/apiv3/translatepb"
+	"golang.org/x/text/language"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A Translator translates text. Errors that retrying won't fix, such as an
+// unsupported language, wrap ErrPermanent.
+type Translator interface {
+	// Translate translates text to lang, returning the translated text and
+	// the detected language of text.
+	Translate(ctx context.Context, text, lang string) (translated, originalLang string, err error)
+	// Languages returns the sorted codes of the languages text can be
+	// translated to.
+	Languages(ctx context.Context) ([]string, error)
+}
+
+// ErrPermanent is wrapped by errors that retrying won't fix.
+var ErrPermanent = errors.New("permanent error")
+
+// NewTranslatorFromEnv returns the Translator configured by the environment:
+//
+//   - TRANSLATE_ENGINE is "v2" (the default) for the Cloud Translation API
+//     v2, "v3" for the Cloud Translation API v3, or "stub" for a
+//     StubTranslator, which doesn't use the network.
+//   - TRANSLATE_LOCATION is the location used by v3. It defaults to
+//     "global", or "us-central1" with a glossary.
+//   - TRANSLATE_GLOSSARY is the ID of a v3 glossary to use, if any.
+//   - TRANSLATE_SOURCE_LANGUAGE is the language of the text to translate
+//     with a glossary. If empty, it is detected for each translation.
+func NewTranslatorFromEnv(ctx context.Context, projectID string) (Translator, error) {
+	switch engine := os.Getenv("TRANSLATE_ENGINE"); engine {
+	case "", "v2":
+		client, err := translate.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("translate.NewClient: %w", err)
+		}
+		return &V2Translator{client: client}, nil
+	case "v3":
+		client, err := translate3.NewTranslationClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("NewTranslationClient: %w", err)
+		}
+		t := &V3Translator{
+			client:         client,
+			projectID:      projectID,
+			location:       os.Getenv("TRANSLATE_LOCATION"),
+			glossaryID:     os.Getenv("TRANSLATE_GLOSSARY"),
+			sourceLanguage: os.Getenv("TRANSLATE_SOURCE_LANGUAGE"),
+		}
+		if t.location == "" {
+			t.location = "global"
+			if t.glossaryID != "" {
+				// Glossaries aren't available in the global location.
+				t.location = "us-central1"
+			}
+		}
+		return t, nil
+	case "stub":
+		return &StubTranslator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown TRANSLATE_ENGINE %q, want v2, v3 or stub", engine)
+	}
+}
+
+// V2Translator is a Translator using the Cloud Translation API v2.
+type V2Translator struct {
+	client *translate.Client
+}
+
+// [START getting_started_background_translate_string]
+
+// Translate implements Translator.
+func (t *V2Translator) Translate(ctx context.Context, text, lang string) (translated, originalLang string, err error) {
+	l, err := language.Parse(lang)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: language.Parse: %v", ErrPermanent, err)
+	}
+
+	outs, err := t.client.Translate(ctx, []string{text}, l, nil)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+		return "", "", fmt.Errorf("%w: Translate: %v", ErrPermanent, err)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("Translate: %w", err)
+	}
+
+	if len(outs) < 1 {
+		return "", "", fmt.Errorf("Translate got %d translations, need at least 1", len(outs))
+	}
+
+	return outs[0].Text, outs[0].Source.String(), nil
+}
+
+// [END getting_started_background_translate_string]
+
+// Languages implements Translator.
+func (t *V2Translator) Languages(ctx context.Context) ([]string, error) {
+	langs, err := t.client.SupportedLanguages(ctx, language.English)
+	if err != nil {
+		return nil, fmt.Errorf("SupportedLanguages: %w", err)
+	}
+	var supported []string
+	for _, l := range langs {
+		supported = append(supported, l.Tag.String())
+	}
+	sort.Strings(supported)
+	return supported, nil
+}
+
+// V3Translator is a Translator using the Cloud Translation API v3, with a
+// glossary if one is configured.
+type V3Translator struct {
+	client     *translate3.TranslationClient
+	projectID  string
+	location   string
+	glossaryID string
+	// sourceLanguage is the language of text translated with the glossary.
+	// If empty, it is detected.
+	sourceLanguage string
+}
+
+func (t *V3Translator) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", t.projectID, t.location)
+}
+
+// Translate implements Translator.
+func (t *V3Translator) Translate(ctx context.Context, text, lang string) (translated, originalLang string, err error) {
+	req := &translatepb.TranslateTextRequest{
+		Parent:             t.parent(),
+		TargetLanguageCode: lang,
+		MimeType:           "text/plain",
+		Contents:           []string{text},
+	}
+	if t.glossaryID != "" {
+		// Glossaries need the source language.
+		source := t.sourceLanguage
+		if source == "" {
+			if source, err = t.detect(ctx, text); err != nil {
+				return "", "", err
+			}
+		}
+		req.SourceLanguageCode = source
+		req.GlossaryConfig = &translatepb.TranslateTextGlossaryConfig{
+			Glossary: fmt.Sprintf("%s/glossaries/%s", t.parent(), t.glossaryID),
+		}
+	}
+
+	resp, err := t.client.TranslateText(ctx, req)
+	if status.Code(err) == codes.InvalidArgument {
+		return "", "", fmt.Errorf("%w: TranslateText: %v", ErrPermanent, err)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("TranslateText: %w", err)
+	}
+
+	outs := resp.GetTranslations()
+	if t.glossaryID != "" {
+		outs = resp.GetGlossaryTranslations()
+	}
+	if len(outs) < 1 {
+		return "", "", fmt.Errorf("TranslateText got %d translations, need at least 1", len(outs))
+	}
+	originalLang = req.SourceLanguageCode
+	if originalLang == "" {
+		originalLang = outs[0].GetDetectedLanguageCode()
+	}
+	return outs[0].GetTranslatedText(), originalLang, nil
+}
+
+// detect returns the most likely language of text.
+func (t *V3Translator) detect(ctx context.Context, text string) (string, error) {
+	resp, err := t.client.DetectLanguage(ctx, &translatepb.DetectLanguageRequest{
+		Parent:   t.parent(),
+		MimeType: "text/plain",
+		Source:   &translatepb.DetectLanguageRequest_Content{Content: text},
+	})
+	if err != nil {
+		return "", fmt.Errorf("DetectLanguage: %w", err)
+	}
+	if len(resp.GetLanguages()) < 1 {
+		return "", fmt.Errorf("%w: DetectLanguage found no language", ErrPermanent)
+	}
+	return resp.GetLanguages()[0].GetLanguageCode(), nil
+}
+
+// Languages implements Translator.
+func (t *V3Translator) Languages(ctx context.Context) ([]string, error) {
+	resp, err := t.client.GetSupportedLanguages(ctx, &translatepb.GetSupportedLanguagesRequest{
+		Parent: t.parent(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetSupportedLanguages: %w", err)
+	}
+	var supported []string
+	for _, l := range resp.GetLanguages() {
+		if l.GetSupportTarget() {
+			supported = append(supported, l.GetLanguageCode())
+		}
+	}
+	sort.Strings(supported)
+	return supported, nil
+}
+
+// stubLanguages are the default languages of a StubTranslator.
+var stubLanguages = []string{"de", "en", "es", "fr", "ja", "sw"}
+
+// StubTranslator is a Translator that doesn't use the network, to run and
+// test the app locally. It translates text to lang as "[lang] text", and
+// detects all text as English.
+type StubTranslator struct {
+	// Supported are the languages to translate to. If empty, they are
+	// de, en, es, fr, ja and sw.
+	Supported []string
+}
+
+// Translate implements Translator.
+func (t *StubTranslator) Translate(ctx context.Context, text, lang string) (translated, originalLang string, err error) {
+	langs, _ := t.Languages(ctx)
+	i := sort.SearchStrings(langs, lang)
+	if i == len(langs) || langs[i] != lang {
+		return "", "", fmt.Errorf("%w: unsupported language %q", ErrPermanent, lang)
+	}
+	return fmt.Sprintf("[%s] %s", lang, text), "en", nil
+}
+
+// Languages implements Translator.
+func (t *StubTranslator) Languages(ctx context.Context) ([]string, error) {
+	if len(t.Supported) == 0 {
+		return stubLanguages, nil
+	}
+	langs := append([]string(nil), t.Supported...)
+	sort.Strings(langs)
+	return langs, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package background
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStubTranslator(t *testing.T) {
+	ctx := context.Background()
+
+	var tr Translator = &StubTranslator{}
+	langs, err := tr.Languages(ctx)
+	if err != nil {
+		t.Fatalf("Languages: %v", err)
+	}
+	if want := []string{"de", "en", "es", "fr", "ja", "sw"}; !reflect.DeepEqual(langs, want) {
+		t.Errorf("Languages got %q, want %q", langs, want)
+	}
+
+	translated, originalLang, err := tr.Translate(ctx, "Me", "fr")
+	if err != nil {
+		t.Fatalf("Translate: %v", err)
+	}
+	if translated != "[fr] Me" || originalLang != "en" {
+		t.Errorf("Translate got (%q, %q), want (%q, %q)", translated, originalLang, "[fr] Me", "en")
+	}
+
+	if _, _, err := tr.Translate(ctx, "Me", "xx"); !errors.Is(err, ErrPermanent) {
+		t.Errorf("Translate to an unsupported language got error %v, want ErrPermanent", err)
+	}
+
+	tr = &StubTranslator{Supported: []string{"pt", "it"}}
+	langs, err = tr.Languages(ctx)
+	if err != nil {
+		t.Fatalf("Languages: %v", err)
+	}
+	if want := []string{"it", "pt"}; !reflect.DeepEqual(langs, want) {
+		t.Errorf("Languages got %q, want %q", langs, want)
+	}
+	if _, _, err := tr.Translate(ctx, "Me", "fr"); !errors.Is(err, ErrPermanent) {
+		t.Errorf("Translate to an unsupported language got error %v, want ErrPermanent", err)
+	}
+}
+
+func TestNewTranslatorFromEnv(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv("TRANSLATE_ENGINE", "stub")
+	tr, err := NewTranslatorFromEnv(ctx, "my-project")
+	if err != nil {
+		t.Fatalf("NewTranslatorFromEnv: %v", err)
+	}
+	if _, ok := tr.(*StubTranslator); !ok {
+		t.Errorf("NewTranslatorFromEnv got %T, want *StubTranslator", tr)
+	}
+
+	t.Setenv("TRANSLATE_ENGINE", "v4")
+	if _, err := NewTranslatorFromEnv(ctx, "my-project"); err == nil {
+		t.Errorf("NewTranslatorFromEnv with an unknown engine got no error, want one")
+	}
+}
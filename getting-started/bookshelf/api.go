@@ -0,0 +1,385 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+)
+
+// errInvalidRequest is wrapped by errors for malformed or invalid API
+// requests, such as a book without a title.
+var errInvalidRequest = errors.New("invalid request")
+
+// errPreconditionRequired is returned (possibly wrapped) when a request to
+// replace a book has no If-Match header.
+var errPreconditionRequired = errors.New("If-Match header required")
+
+const (
+	// maxRequestBytes limits the size of API request bodies.
+	maxRequestBytes = 1 << 20
+
+	// maxImageBytes limits the size of images uploaded with signed URLs.
+	maxImageBytes = 10 << 20
+
+	// uploadURLExpiry is how long a signed upload URL can be used for.
+	uploadURLExpiry = 15 * time.Minute
+)
+
+// registerAPIHandlers registers the handlers of the JSON REST API on r,
+// under /api/v1:
+//
+//	GET    /api/v1/books       lists books, with the same query parameters
+//	                           as /books, including mine=true.
+//	POST   /api/v1/books       adds a book, created by the signed-in user.
+//	GET    /api/v1/books/{id}  gets a book and its ETag.
+//	PUT    /api/v1/books/{id}  replaces a book, if it still matches the
+//	                           If-Match header, which is required.
+//	DELETE /api/v1/books/{id}  deletes a book.
+//	POST   /api/v1/uploads     returns a signed URL to upload an image to,
+//	                           to use as a book's imageURL.
+//
+// When authentication is on (see auth.go), adding books and uploading images
+// need a signed-in user, and only the user who created a book, or an admin,
+// can replace or delete it.
+//
+// When the thumbnail pipeline is on (see thumbnails.go), uploaded images are
+// private until processed: books using them have imageStatus "pending" until
+// their imageURL and thumbnailURL link to the processed images.
+//
+// Errors have a JSON body, see apiHandler.
+func (b *Bookshelf) registerAPIHandlers(r *mux.Router) {
+	api := r.PathPrefix("/api/v1").Subrouter()
+
+	api.Methods("GET").Path("/books").
+		Handler(apiHandler(b.apiListHandler))
+	api.Methods("POST").Path("/books").
+		Handler(apiHandler(b.apiCreateHandler))
+	api.Methods("GET").Path("/books/{id:[0-9a-zA-Z_\\-]+}").
+		Handler(apiHandler(b.apiGetHandler))
+	api.Methods("PUT").Path("/books/{id:[0-9a-zA-Z_\\-]+}").
+		Handler(apiHandler(b.apiUpdateHandler))
+	api.Methods("DELETE").Path("/books/{id:[0-9a-zA-Z_\\-]+}").
+		Handler(apiHandler(b.apiDeleteHandler))
+	api.Methods("POST").Path("/uploads").
+		Handler(apiHandler(b.apiUploadHandler))
+}
+
+// apiHandler is an appHandler for the JSON API: it writes errors as JSON
+// instead of plain text.
+type apiHandler func(http.ResponseWriter, *http.Request) *appError
+
+// apiErrorBody is the body of API error responses.
+type apiErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (fn apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if e := fn(w, r); e != nil {
+		e.report()
+		var body apiErrorBody
+		body.Error.Code = e.code
+		body.Error.Status = http.StatusText(e.code)
+		body.Error.Message = e.message
+		writeJSON(w, e.code, body)
+	}
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// decodeJSON reads the JSON request body into v. Unknown fields are an
+// error, so that typos don't go unnoticed.
+func (b *Bookshelf) decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) *appError {
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		err := fmt.Errorf("Content-Type is %q, want application/json", r.Header.Get("Content-Type"))
+		return b.appErrorCode(r, err, http.StatusUnsupportedMediaType, "%v", err)
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		err = fmt.Errorf("%w: could not decode request body: %v", errInvalidRequest, err)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	return nil
+}
+
+// bookFromJSON reads and validates a book from the JSON request body.
+func (b *Bookshelf) bookFromJSON(w http.ResponseWriter, r *http.Request) (*Book, *appError) {
+	book := &Book{}
+	if e := b.decodeJSON(w, r, book); e != nil {
+		return nil, e
+	}
+	if err := validateBook(book); err != nil {
+		return nil, b.appErrorf(r, err, "%v", err)
+	}
+	return book, nil
+}
+
+// validateBook checks the fields of a book sent to the API.
+func validateBook(book *Book) error {
+	if strings.TrimSpace(book.Title) == "" {
+		return fmt.Errorf("%w: title is required", errInvalidRequest)
+	}
+	if book.ImageURL != "" {
+		u, err := url.Parse(book.ImageURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: imageURL %q is not an http(s) URL", errInvalidRequest, book.ImageURL)
+		}
+	}
+	return nil
+}
+
+// apiBookPage is the response body of the list API.
+type apiBookPage struct {
+	Books         []*Book `json:"books"`
+	NextPageToken string  `json:"nextPageToken,omitempty"`
+}
+
+// apiListHandler lists books as JSON.
+func (b *Bookshelf) apiListHandler(w http.ResponseWriter, r *http.Request) *appError {
+	opts, err := b.listOptionsFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	page, err := b.DB.ListBooks(r.Context(), opts)
+	if err != nil {
+		return b.appErrorf(r, err, "could not list books: %v", err)
+	}
+	writeJSON(w, http.StatusOK, apiBookPage{Books: page.Books, NextPageToken: page.NextPageToken})
+	return nil
+}
+
+// apiGetHandler returns a book as JSON, with its ETag. It responds with 304
+// Not Modified if the book still has the ETag in If-None-Match.
+func (b *Bookshelf) apiGetHandler(w http.ResponseWriter, r *http.Request) *appError {
+	book, err := b.bookFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	etag := book.ETag()
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	writeJSON(w, http.StatusOK, book)
+	return nil
+}
+
+// apiCreateHandler adds the book in the request body, ignoring any ID,
+// creator or image status in it.
+func (b *Bookshelf) apiCreateHandler(w http.ResponseWriter, r *http.Request) *appError {
+	u, err := b.requireUser(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	book, e := b.bookFromJSON(w, r)
+	if e != nil {
+		return e
+	}
+	book.ID = ""
+	setCreator(book, u)
+	process := b.prepareImage(book, nil)
+	id, err := b.DB.AddBook(r.Context(), book)
+	if err != nil {
+		return b.appErrorf(r, err, "could not save book: %v", err)
+	}
+	book.ID = id
+	if process {
+		if err := b.queueThumbnails(r.Context(), book); err != nil {
+			return b.appErrorf(r, err, "%v", err)
+		}
+	}
+	w.Header().Set("Location", "/api/v1/books/"+id)
+	w.Header().Set("ETag", book.ETag())
+	writeJSON(w, http.StatusCreated, book)
+	return nil
+}
+
+// apiUpdateHandler replaces a book with the one in the request body. The
+// request must have an If-Match header, or it fails with 428 Precondition
+// Required. The book is only replaced if its ETag still matches, so
+// concurrent edits aren't lost: a mismatch is 412 Precondition Failed, and
+// the client should get the book again and retry. "If-Match: *" replaces any
+// current version. The creator of the book can't be changed, and the image
+// status only changes with the imageURL.
+func (b *Bookshelf) apiUpdateHandler(w http.ResponseWriter, r *http.Request) *appError {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	book, e := b.bookFromJSON(w, r)
+	if e != nil {
+		return e
+	}
+	if book.ID != "" && book.ID != id {
+		err := fmt.Errorf("%w: id %q in body does not match %q in URL", errInvalidRequest, book.ID, id)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	book.ID = id
+
+	cur, err := b.DB.GetBook(ctx, id)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.canChange(r, cur); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	book.CreatedBy, book.CreatedByID = cur.CreatedBy, cur.CreatedByID
+	process := b.prepareImage(book, cur)
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		err := fmt.Errorf("%w: get the book and send its ETag, or *", errPreconditionRequired)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if ifMatch == "*" {
+		// Any current version will do, as long as there is one.
+		ifMatch = cur.ETag()
+	}
+	if err := b.DB.UpdateBook(ctx, book, ifMatch); err != nil {
+		return b.appErrorf(r, err, "UpdateBook: %v", err)
+	}
+	b.discardPendingImage(ctx, cur, book)
+	if process {
+		if err := b.queueThumbnails(ctx, book); err != nil {
+			return b.appErrorf(r, err, "%v", err)
+		}
+	}
+	w.Header().Set("ETag", book.ETag())
+	writeJSON(w, http.StatusOK, book)
+	return nil
+}
+
+// apiDeleteHandler deletes a book.
+func (b *Bookshelf) apiDeleteHandler(w http.ResponseWriter, r *http.Request) *appError {
+	book, err := b.bookFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.canChange(r, book); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.DB.DeleteBook(r.Context(), book.ID); err != nil {
+		return b.appErrorf(r, err, "DeleteBook: %v", err)
+	}
+	b.discardPendingImage(r.Context(), book, nil)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// uploadRequest is the request body of the upload API.
+type uploadRequest struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+}
+
+// uploadResponse tells the client how to upload an image: send a request
+// with Method and Headers to UploadURL, with the image as its body, then use
+// ImageURL as the book's imageURL.
+type uploadResponse struct {
+	UploadURL string            `json:"uploadURL"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ImageURL  string            `json:"imageURL"`
+	Expires   time.Time         `json:"expires"`
+}
+
+// [START getting_started_bookshelf_signed_url]
+
+// apiUploadHandler returns a V4 signed URL the client can upload an image
+// to directly, so the image doesn't pass through this server.
+func (b *Bookshelf) apiUploadHandler(w http.ResponseWriter, r *http.Request) *appError {
+	if _, err := b.requireUser(r); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	var req uploadRequest
+	if e := b.decodeJSON(w, r, &req); e != nil {
+		return e
+	}
+	if !strings.HasPrefix(req.ContentType, "image/") {
+		err := fmt.Errorf("%w: contentType %q is not an image type", errInvalidRequest, req.ContentType)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	// random filename, retaining existing extension.
+	name := uuid.Must(uuid.NewV4()).String() + path.Ext(req.Filename)
+	acl := "public-read"
+	if b.thumbnails != nil {
+		// Keep the image private until it is processed. See thumbnails.go.
+		name = newUploadName(req.Filename)
+		acl = "private"
+	}
+	// The client must send these headers, because they are signed. They
+	// set the image's ACL, like uploadFileFromForm, and limit its size.
+	headers := map[string]string{
+		"Content-Type":                req.ContentType,
+		"x-goog-acl":                  acl,
+		"x-goog-content-length-range": fmt.Sprintf("0,%d", maxImageBytes),
+	}
+	opts := &storage.SignedURLOptions{
+		Scheme:      storage.SigningSchemeV4,
+		Method:      "PUT",
+		ContentType: req.ContentType,
+		Headers: []string{
+			"x-goog-acl:" + headers["x-goog-acl"],
+			"x-goog-content-length-range:" + headers["x-goog-content-length-range"],
+		},
+		Expires: time.Now().Add(uploadURLExpiry),
+	}
+	signedURL := b.signedURL
+	if signedURL == nil {
+		if b.StorageBucket == nil {
+			err := errors.New("storage bucket is missing: check bookshelf.go")
+			return b.appErrorf(r, err, "%v", err)
+		}
+		signedURL = b.StorageBucket.SignedURL
+	}
+	u, err := signedURL(name, opts)
+	if err != nil {
+		return b.appErrorf(r, err, "could not sign upload URL: %v", err)
+	}
+
+	const publicURL = "https://storage.googleapis.com/%s/%s"
+	writeJSON(w, http.StatusOK, uploadResponse{
+		UploadURL: u,
+		Method:    opts.Method,
+		Headers:   headers,
+		ImageURL:  fmt.Sprintf(publicURL, b.StorageBucketName, name),
+		Expires:   opts.Expires,
+	})
+	return nil
+}
+
+// [END getting_started_bookshelf_signed_url]
@@ -0,0 +1,200 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/gorilla/mux"
+)
+
+// newAPIServer returns a server for the JSON API backed by a memoryDB, with
+// authentication off if auth is nil. It doesn't need a project: there is no
+// Error Reporting client, and upload URLs are signed by a fake.
+func newAPIServer(t *testing.T, auth *iapAuth) *httptest.Server {
+	t.Helper()
+	shelf := &Bookshelf{
+		DB:                newMemoryDB(),
+		StorageBucketName: "my-bucket",
+		logWriter:         io.Discard,
+		signedURL: func(object string, opts *storage.SignedURLOptions) (string, error) {
+			return "https://storage.googleapis.com/my-bucket/" + object + "?X-Goog-Signature=fake", nil
+		},
+		auth: auth,
+	}
+	r := mux.NewRouter()
+	r.Use(shelf.authenticate)
+	shelf.registerAPIHandlers(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// apiDo sends a request to the API and decodes the JSON response into v, if
+// not nil. It returns the response, whose body has been closed.
+func apiDo(t *testing.T, srv *httptest.Server, method, path, body string, header http.Header, v interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func TestAPIBookLifecycle(t *testing.T) {
+	srv := newAPIServer(t, nil)
+
+	var created Book
+	resp := apiDo(t, srv, "POST", "/api/v1/books", `{"title": "simpsons", "author": "homer"}`, nil, &created)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if created.ID == "" || created.Title != "simpsons" {
+		t.Fatalf("create: got %+v", created)
+	}
+	bookPath := "/api/v1/books/" + created.ID
+	if got := resp.Header.Get("Location"); got != bookPath {
+		t.Errorf("create: Location = %q, want %q", got, bookPath)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag != created.ETag() {
+		t.Errorf("create: ETag = %q, want %q", etag, created.ETag())
+	}
+
+	var got Book
+	resp = apiDo(t, srv, "GET", bookPath, "", nil, &got)
+	if resp.StatusCode != http.StatusOK || got != created || resp.Header.Get("ETag") != etag {
+		t.Errorf("get: status %d, book %+v, ETag %q; want 200, %+v, %q", resp.StatusCode, got, resp.Header.Get("ETag"), created, etag)
+	}
+	resp = apiDo(t, srv, "GET", bookPath, "", http.Header{"If-None-Match": {etag}}, nil)
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("get with If-None-Match: status %d, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+
+	var updated Book
+	resp = apiDo(t, srv, "PUT", bookPath, `{"title": "simpsons", "author": "marge"}`, http.Header{"If-Match": {etag}}, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Author != "marge" {
+		t.Fatalf("update: status %d, book %+v; want 200 and author marge", resp.StatusCode, updated)
+	}
+	newETag := resp.Header.Get("ETag")
+	if newETag == etag {
+		t.Errorf("update: ETag did not change")
+	}
+
+	// The old ETag is stale now.
+	var e apiErrorBody
+	resp = apiDo(t, srv, "PUT", bookPath, `{"title": "simpsons", "author": "bart"}`, http.Header{"If-Match": {etag}}, &e)
+	if resp.StatusCode != http.StatusPreconditionFailed || e.Error.Code != http.StatusPreconditionFailed {
+		t.Errorf("update with stale ETag: status %d, body %+v; want %d", resp.StatusCode, e, http.StatusPreconditionFailed)
+	}
+	resp = apiDo(t, srv, "PUT", bookPath, `{"title": "simpsons", "author": "lisa"}`, http.Header{"If-Match": {"*"}}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("update with If-Match *: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var page apiBookPage
+	resp = apiDo(t, srv, "GET", "/api/v1/books?author=lisa", "", nil, &page)
+	if resp.StatusCode != http.StatusOK || len(page.Books) != 1 || page.Books[0].ID != created.ID {
+		t.Errorf("list: status %d, page %+v; want 200 and the book", resp.StatusCode, page)
+	}
+
+	resp = apiDo(t, srv, "DELETE", bookPath, "", nil, nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	resp = apiDo(t, srv, "GET", bookPath, "", nil, &e)
+	if resp.StatusCode != http.StatusNotFound || e.Error.Status != "Not Found" {
+		t.Errorf("get after delete: status %d, body %+v; want 404", resp.StatusCode, e)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	srv := newAPIServer(t, nil)
+
+	var created Book
+	apiDo(t, srv, "POST", "/api/v1/books", `{"title": "simpsons"}`, nil, &created)
+
+	for _, tc := range []struct {
+		name         string
+		method, path string
+		body         string
+		header       http.Header
+		want         int
+	}{
+		{"missing title", "POST", "/api/v1/books", `{"author": "homer"}`, nil, http.StatusBadRequest},
+		{"unknown field", "POST", "/api/v1/books", `{"title": "a", "pages": 3}`, nil, http.StatusBadRequest},
+		{"malformed JSON", "POST", "/api/v1/books", `{"title":`, nil, http.StatusBadRequest},
+		{"bad image URL", "POST", "/api/v1/books", `{"title": "a", "imageURL": "javascript:alert(1)"}`, nil, http.StatusBadRequest},
+		{"not JSON", "POST", "/api/v1/books", "title=a", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, http.StatusUnsupportedMediaType},
+		{"bad sort", "GET", "/api/v1/books?sort=pages", "", nil, http.StatusBadRequest},
+		{"bad page token", "GET", "/api/v1/books?page_token=!!", "", nil, http.StatusBadRequest},
+		{"get missing", "GET", "/api/v1/books/404", "", nil, http.StatusNotFound},
+		{"delete missing", "DELETE", "/api/v1/books/404", "", nil, http.StatusNotFound},
+		{"update mismatched ID", "PUT", "/api/v1/books/" + created.ID, `{"id": "other", "title": "a"}`, nil, http.StatusBadRequest},
+		{"update missing with If-Match", "PUT", "/api/v1/books/404", `{"title": "a"}`, http.Header{"If-Match": {`"x"`}}, http.StatusNotFound},
+		{"update without If-Match", "PUT", "/api/v1/books/" + created.ID, `{"title": "a"}`, nil, http.StatusPreconditionRequired},
+		{"upload non-image", "POST", "/api/v1/uploads", `{"filename": "a.exe", "contentType": "application/octet-stream"}`, nil, http.StatusBadRequest},
+	} {
+		var e apiErrorBody
+		resp := apiDo(t, srv, tc.method, tc.path, tc.body, tc.header, &e)
+		if resp.StatusCode != tc.want || e.Error.Code != tc.want || e.Error.Message == "" {
+			t.Errorf("%s: status %d, body %+v; want status %d", tc.name, resp.StatusCode, e, tc.want)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: Content-Type = %q, want JSON", tc.name, ct)
+		}
+	}
+}
+
+func TestAPIUpload(t *testing.T) {
+	srv := newAPIServer(t, nil)
+
+	var got uploadResponse
+	resp := apiDo(t, srv, "POST", "/api/v1/uploads", `{"filename": "cover.png", "contentType": "image/png"}`, nil, &got)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got.Method != "PUT" || !strings.HasPrefix(got.UploadURL, "https://storage.googleapis.com/my-bucket/") {
+		t.Errorf("upload: got %s %s, want a PUT to the bucket", got.Method, got.UploadURL)
+	}
+	if !strings.HasPrefix(got.ImageURL, "https://storage.googleapis.com/my-bucket/") || !strings.HasSuffix(got.ImageURL, ".png") {
+		t.Errorf("upload: imageURL = %q, want a .png object in the bucket", got.ImageURL)
+	}
+	if got.Headers["Content-Type"] != "image/png" || got.Headers["x-goog-acl"] != "public-read" {
+		t.Errorf("upload: headers = %v, want Content-Type and x-goog-acl", got.Headers)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/golang-jwt/jwt"
+)
+
+// The identity of users comes from the signed header that Identity-Aware
+// Proxy (IAP) adds to every request it lets through. The validation follows
+// getting-started/authenticating-users.
+// See https://cloud.google.com/iap/docs/signed-headers-howto.
+
+var (
+	// errUnauthenticated is returned (possibly wrapped) when a request that
+	// needs a user has none, or has an invalid IAP assertion.
+	errUnauthenticated = errors.New("sign-in required")
+
+	// errForbidden is returned (possibly wrapped) when the user may not
+	// change a book, because they neither created it nor are an admin.
+	errForbidden = errors.New("only the creator of a book or an admin can change it")
+)
+
+// iapIssuer is the issuer of IAP assertions.
+const iapIssuer = "https://cloud.google.com/iap"
+
+// User is a signed-in user of the bookshelf.
+type User struct {
+	Email string
+	ID    string // The stable IAP user ID, such as "accounts.google.com:1234".
+	Admin bool
+}
+
+// iapAuth identifies users from IAP assertions.
+type iapAuth struct {
+	certs  map[string]string // Maps key IDs to PEM encoded public keys.
+	aud    string
+	admins map[string]bool // Emails of the users who can change any book.
+}
+
+// newAuthFromEnv returns the iapAuth configured by the environment, or nil if
+// authentication is off. BOOKSHELF_AUTH selects the mode:
+//
+//   - "" (the default) turns authentication off: everyone can change every
+//     book, as in the single-user bookshelf.
+//   - "iap" validates assertions signed by IAP. The audience is
+//     BOOKSHELF_IAP_AUDIENCE if it is set, or else the audience of the App
+//     Engine app this is running as. Elsewhere, such as on Cloud Run behind
+//     a load balancer, BOOKSHELF_IAP_AUDIENCE must be set to
+//     /projects/PROJECT_NUMBER/global/backendServices/SERVICE_ID.
+//   - "test" validates locally signed assertions instead, for trying the
+//     bookshelf without IAP. BOOKSHELF_IAP_CERTS is the path to a JSON file
+//     mapping key IDs to PEM encoded ES256 public keys, the same format IAP
+//     publishes its keys in, and BOOKSHELF_IAP_AUDIENCE is the audience the
+//     assertions must have.
+//
+// BOOKSHELF_ADMINS is a comma-separated list of the emails of admins.
+func newAuthFromEnv() (*iapAuth, error) {
+	a := &iapAuth{admins: map[string]bool{}}
+	for _, email := range strings.Split(os.Getenv("BOOKSHELF_ADMINS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			a.admins[strings.ToLower(email)] = true
+		}
+	}
+
+	var err error
+	switch mode := os.Getenv("BOOKSHELF_AUTH"); mode {
+	case "":
+		return nil, nil
+	case "iap":
+		if a.certs, err = certificates(); err != nil {
+			return nil, err
+		}
+		if a.aud, err = audience(); err != nil {
+			return nil, err
+		}
+	case "test":
+		f, err := os.ReadFile(os.Getenv("BOOKSHELF_IAP_CERTS"))
+		if err != nil {
+			return nil, fmt.Errorf("could not read BOOKSHELF_IAP_CERTS: %w", err)
+		}
+		if err := json.Unmarshal(f, &a.certs); err != nil {
+			return nil, fmt.Errorf("could not parse BOOKSHELF_IAP_CERTS: %w", err)
+		}
+		if a.aud = os.Getenv("BOOKSHELF_IAP_AUDIENCE"); a.aud == "" {
+			return nil, errors.New("BOOKSHELF_IAP_AUDIENCE must be set with BOOKSHELF_AUTH=test")
+		}
+	default:
+		return nil, fmt.Errorf("unknown BOOKSHELF_AUTH %q: want iap or test", mode)
+	}
+	return a, nil
+}
+
+// userFromRequest returns the user identified by the IAP assertion of r, or
+// nil if r has no assertion.
+func (a *iapAuth) userFromRequest(r *http.Request) (*User, error) {
+	assertion := r.Header.Get("X-Goog-IAP-JWT-Assertion")
+	if assertion == "" {
+		return nil, nil
+	}
+	email, userID, err := validateAssertion(assertion, a.certs, a.aud)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnauthenticated, err)
+	}
+	// IAP prefixes emails with the identity provider, such as
+	// "accounts.google.com:user@example.com".
+	if i := strings.LastIndex(email, ":"); i >= 0 {
+		email = email[i+1:]
+	}
+	return &User{
+		Email: email,
+		ID:    userID,
+		Admin: a.admins[strings.ToLower(email)],
+	}, nil
+}
+
+// validateAssertion validates assertion was signed by one of certs for the
+// audience aud, and returns the associated email and userID.
+func validateAssertion(assertion string, certs map[string]string, aud string) (email string, userID string, err error) {
+	token, err := jwt.Parse(assertion, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %q", token.Header["alg"])
+		}
+		keyID, _ := token.Header["kid"].(string)
+		cert, ok := certs[keyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", keyID)
+		}
+		return jwt.ParseECPublicKeyFromPEM([]byte(cert))
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", "", fmt.Errorf("could not extract claims (%T): %+v", token.Claims, token.Claims)
+	}
+	if !claims.VerifyAudience(aud, true) {
+		return "", "", fmt.Errorf("mismatched audience. aud field %q does not match %q", claims["aud"], aud)
+	}
+	if !claims.VerifyIssuer(iapIssuer, true) {
+		return "", "", fmt.Errorf("unexpected issuer %q", claims["iss"])
+	}
+	email, _ = claims["email"].(string)
+	userID, _ = claims["sub"].(string)
+	if email == "" || userID == "" {
+		return "", "", errors.New("assertion has no email or sub claim")
+	}
+	return email, userID, nil
+}
+
+// audience returns the expected audience value for this service:
+// BOOKSHELF_IAP_AUDIENCE, or the App Engine app's audience if it isn't set.
+func audience() (string, error) {
+	if aud := os.Getenv("BOOKSHELF_IAP_AUDIENCE"); aud != "" {
+		return aud, nil
+	}
+
+	projectNumber, err := metadata.NumericProjectID()
+	if err != nil {
+		return "", fmt.Errorf("metadata.NumericProjectID: %w", err)
+	}
+
+	projectID, err := metadata.ProjectID()
+	if err != nil {
+		return "", fmt.Errorf("metadata.ProjectID: %w", err)
+	}
+
+	return "/projects/" + projectNumber + "/apps/" + projectID, nil
+}
+
+// certificates returns Cloud IAP's cryptographic public keys.
+func certificates() (map[string]string, error) {
+	const url = "https://www.gstatic.com/iap/verify/public_key"
+	client := http.Client{
+		Timeout: 5 * time.Second,
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var certs map[string]string
+	dec := json.NewDecoder(resp.Body)
+	if err := dec.Decode(&certs); err != nil {
+		return nil, fmt.Errorf("Decode: %w", err)
+	}
+
+	return certs, nil
+}
+
+// userKey is the context key of the User of a request.
+type userKey struct{}
+
+// userFromContext returns the signed-in user, or nil.
+func userFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userKey{}).(*User)
+	return u
+}
+
+// authenticate is middleware that adds the user identified by the request's
+// IAP assertion to the request context. Requests with an invalid assertion
+// are rejected, with a JSON body for the API.
+func (b *Bookshelf) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if b.auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, err := b.auth.userFromRequest(r)
+		if err != nil {
+			h := func(http.ResponseWriter, *http.Request) *appError {
+				return b.appErrorf(r, err, "%v", err)
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				apiHandler(h).ServeHTTP(w, r)
+			} else {
+				appHandler(h).ServeHTTP(w, r)
+			}
+			return
+		}
+		if u != nil {
+			r = r.WithContext(context.WithValue(r.Context(), userKey{}, u))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireUser returns the signed-in user of r. When authentication is off,
+// it returns nil and no error.
+func (b *Bookshelf) requireUser(r *http.Request) (*User, error) {
+	if b.auth == nil {
+		return nil, nil
+	}
+	u := userFromContext(r.Context())
+	if u == nil {
+		return nil, errUnauthenticated
+	}
+	return u, nil
+}
+
+// canChange returns an error wrapping errForbidden unless the user of r may
+// change or delete book: its creator or an admin. Books created while
+// authentication was off have no creator, so only admins can change them.
+func (b *Bookshelf) canChange(r *http.Request, book *Book) error {
+	if b.auth == nil {
+		return nil
+	}
+	u, err := b.requireUser(r)
+	if err != nil {
+		return err
+	}
+	if u.Admin || (book.CreatedByID != "" && book.CreatedByID == u.ID) {
+		return nil
+	}
+	return fmt.Errorf("%w: book %q", errForbidden, book.ID)
+}
+
+// setCreator records u as the creator of a new book. With authentication
+// off, u is nil and the book has no creator.
+func setCreator(book *Book, u *User) {
+	book.CreatedBy, book.CreatedByID = "", ""
+	if u != nil {
+		book.CreatedBy, book.CreatedByID = u.Email, u.ID
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testKeyID    = "test-key"
+	testAudience = "/projects/123/apps/bookshelf-test"
+)
+
+// testSigner signs IAP assertions with a locally generated key, standing in
+// for IAP.
+type testSigner struct {
+	key   *ecdsa.PrivateKey
+	certs map[string]string // The public key, in the format IAP publishes.
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey: %v", err)
+	}
+	pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return &testSigner{key: key, certs: map[string]string{testKeyID: string(pub)}}
+}
+
+// sign returns an assertion for the user with the given email and ID, with
+// claims overriding the defaults.
+func (s *testSigner) sign(t *testing.T, email, id string, claims jwt.MapClaims) string {
+	t.Helper()
+	c := jwt.MapClaims{
+		"aud":   testAudience,
+		"iss":   iapIssuer,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(10 * time.Minute).Unix(),
+		"email": "accounts.google.com:" + email,
+		"sub":   "accounts.google.com:" + id,
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return signed
+}
+
+// header returns the request header IAP would add for the user.
+func (s *testSigner) header(t *testing.T, email, id string) http.Header {
+	return http.Header{"X-Goog-Iap-Jwt-Assertion": {s.sign(t, email, id, nil)}}
+}
+
+func TestValidateAssertion(t *testing.T) {
+	s := newTestSigner(t)
+
+	email, id, err := validateAssertion(s.sign(t, "homer@example.com", "1", nil), s.certs, testAudience)
+	if err != nil {
+		t.Fatalf("validateAssertion: %v", err)
+	}
+	if email != "accounts.google.com:homer@example.com" || id != "accounts.google.com:1" {
+		t.Errorf("validateAssertion = %q, %q", email, id)
+	}
+
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"aud": testAudience}).SignedString([]byte("secret"))
+	other := newTestSigner(t)
+	for _, tc := range []struct {
+		name      string
+		assertion string
+	}{
+		{"wrong audience", s.sign(t, "homer@example.com", "1", jwt.MapClaims{"aud": "/projects/456/apps/other"})},
+		{"wrong issuer", s.sign(t, "homer@example.com", "1", jwt.MapClaims{"iss": "https://example.com"})},
+		{"expired", s.sign(t, "homer@example.com", "1", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})},
+		{"no email", s.sign(t, "homer@example.com", "1", jwt.MapClaims{"email": ""})},
+		{"other key", other.sign(t, "homer@example.com", "1", nil)},
+		{"HMAC", hs256},
+		{"garbage", "not.a.jwt"},
+	} {
+		if _, _, err := validateAssertion(tc.assertion, s.certs, testAudience); err == nil {
+			t.Errorf("validateAssertion(%s) succeeded, want error", tc.name)
+		}
+	}
+}
+
+func TestNewAuthFromEnv(t *testing.T) {
+	s := newTestSigner(t)
+	certs, _ := json.Marshal(s.certs)
+	path := filepath.Join(t.TempDir(), "certs.json")
+	if err := os.WriteFile(path, certs, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOOKSHELF_AUTH", "test")
+	t.Setenv("BOOKSHELF_IAP_CERTS", path)
+	t.Setenv("BOOKSHELF_IAP_AUDIENCE", testAudience)
+	t.Setenv("BOOKSHELF_ADMINS", "marge@example.com, Lisa@Example.com")
+
+	a, err := newAuthFromEnv()
+	if err != nil {
+		t.Fatalf("newAuthFromEnv: %v", err)
+	}
+	r, _ := http.NewRequest("GET", "/books", nil)
+	r.Header = s.header(t, "lisa@example.com", "2")
+	u, err := a.userFromRequest(r)
+	if err != nil {
+		t.Fatalf("userFromRequest: %v", err)
+	}
+	want := User{Email: "lisa@example.com", ID: "accounts.google.com:2", Admin: true}
+	if *u != want {
+		t.Errorf("userFromRequest = %+v, want %+v", u, want)
+	}
+
+	t.Setenv("BOOKSHELF_IAP_AUDIENCE", "/projects/123/global/backendServices/456")
+	if got, err := audience(); err != nil || got != "/projects/123/global/backendServices/456" {
+		t.Errorf("audience() = %q, %v; want BOOKSHELF_IAP_AUDIENCE", got, err)
+	}
+
+	t.Setenv("BOOKSHELF_AUTH", "")
+	if a, err := newAuthFromEnv(); a != nil || err != nil {
+		t.Errorf("newAuthFromEnv with auth off = %v, %v; want nil, nil", a, err)
+	}
+	t.Setenv("BOOKSHELF_AUTH", "basic")
+	if _, err := newAuthFromEnv(); err == nil {
+		t.Error("newAuthFromEnv with unknown mode succeeded, want error")
+	}
+}
+
+func TestAPIAuthorization(t *testing.T) {
+	s := newTestSigner(t)
+	srv := newAPIServer(t, &iapAuth{
+		certs:  s.certs,
+		aud:    testAudience,
+		admins: map[string]bool{"marge@example.com": true},
+	})
+	homer := s.header(t, "homer@example.com", "1")
+	bart := s.header(t, "bart@example.com", "2")
+	marge := s.header(t, "marge@example.com", "3")
+
+	var created Book
+	resp := apiDo(t, srv, "POST", "/api/v1/books", `{"title": "doughnuts", "createdBy": "bart@example.com"}`, homer, &created)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create: status %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if created.CreatedBy != "homer@example.com" || created.CreatedByID != "accounts.google.com:1" {
+		t.Errorf("create: creator = %q, %q; want homer", created.CreatedBy, created.CreatedByID)
+	}
+	apiDo(t, srv, "POST", "/api/v1/books", `{"title": "skateboards"}`, bart, nil)
+	bookPath := "/api/v1/books/" + created.ID
+
+	for _, tc := range []struct {
+		name         string
+		method, path string
+		body         string
+		header       http.Header
+		want         int
+	}{
+		{"anonymous create", "POST", "/api/v1/books", `{"title": "a"}`, nil, http.StatusUnauthorized},
+		{"anonymous mine", "GET", "/api/v1/books?mine=true", "", nil, http.StatusUnauthorized},
+		{"anonymous upload", "POST", "/api/v1/uploads", `{"filename": "a.png", "contentType": "image/png"}`, nil, http.StatusUnauthorized},
+		{"invalid assertion", "GET", "/api/v1/books", "", http.Header{"X-Goog-Iap-Jwt-Assertion": {"not.a.jwt"}}, http.StatusUnauthorized},
+		{"other user update", "PUT", bookPath, `{"title": "a"}`, bart, http.StatusForbidden},
+		{"other user delete", "DELETE", bookPath, "", bart, http.StatusForbidden},
+		{"anonymous delete", "DELETE", bookPath, "", nil, http.StatusUnauthorized},
+	} {
+		var e apiErrorBody
+		resp := apiDo(t, srv, tc.method, tc.path, tc.body, tc.header, &e)
+		if resp.StatusCode != tc.want || e.Error.Code != tc.want {
+			t.Errorf("%s: status %d, body %+v; want status %d", tc.name, resp.StatusCode, e, tc.want)
+		}
+	}
+
+	// Anyone can read.
+	if resp := apiDo(t, srv, "GET", bookPath, "", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("anonymous get: status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var page apiBookPage
+	apiDo(t, srv, "GET", "/api/v1/books?mine=true", "", homer, &page)
+	if len(page.Books) != 1 || page.Books[0].ID != created.ID {
+		t.Errorf("list mine: got %+v, want only homer's book", page.Books)
+	}
+
+	// The creator can update, but not change the creator.
+	var updated Book
+	update := homer.Clone()
+	update.Set("If-Match", "*")
+	resp = apiDo(t, srv, "PUT", bookPath, `{"title": "more doughnuts", "createdByID": "accounts.google.com:2"}`, update, &updated)
+	if resp.StatusCode != http.StatusOK || updated.CreatedByID != created.CreatedByID {
+		t.Errorf("owner update: status %d, book %+v; want 200 and creator unchanged", resp.StatusCode, updated)
+	}
+
+	// Admins can delete any book.
+	if resp := apiDo(t, srv, "DELETE", bookPath, "", marge, nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("admin delete: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+}
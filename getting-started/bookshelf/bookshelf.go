@@ -16,6 +16,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -26,20 +29,54 @@ import (
 
 // Book holds metadata about a book.
 type Book struct {
-	ID            string
-	Title         string
-	Author        string
-	PublishedDate string
-	ImageURL      string
-	Description   string
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	PublishedDate string `json:"publishedDate"`
+	ImageURL      string `json:"imageURL"`
+	Description   string `json:"description"`
+
+	// CreatedBy is the email, and CreatedByID the IAP user ID, of the user
+	// who added the book. Both are empty if authentication was off.
+	CreatedBy   string `json:"createdBy"`
+	CreatedByID string `json:"createdByID"`
+
+	// ThumbnailURL is a small version of the image at ImageURL, and
+	// ImageStatus tells whether the thumbnail pipeline has processed the
+	// image yet. See thumbnails.go.
+	ThumbnailURL string `json:"thumbnailURL"`
+	ImageStatus  string `json:"imageStatus"`
 }
 
+// ETag returns a strong entity tag for the current contents of b, quoted as
+// in an HTTP ETag header.
+func (b *Book) ETag() string {
+	h := sha256.New()
+	for _, f := range []string{b.ID, b.Title, b.Author, b.PublishedDate, b.ImageURL, b.Description, b.CreatedBy, b.CreatedByID, b.ThumbnailURL, b.ImageStatus} {
+		// Length-prefix each field so that field boundaries are unambiguous.
+		fmt.Fprintf(h, "%d:%s", len(f), f)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// errBookNotFound is returned (possibly wrapped) by BookDatabase methods
+// when there is no book with the given ID.
+var errBookNotFound = errors.New("book not found")
+
+// errPreconditionFailed is returned (possibly wrapped) by UpdateBook when the
+// stored book does not have the expected ETag, because it changed since it
+// was read.
+var errPreconditionFailed = errors.New("book was modified")
+
 // BookDatabase provides thread-safe access to a database of books.
 type BookDatabase interface {
-	// ListBooks returns a list of books, ordered by title.
-	ListBooks(context.Context) ([]*Book, error)
+	// ListBooks returns a page of the books selected by opts, in the order
+	// opts.Sort. It returns an error wrapping errInvalidListOptions if opts
+	// are not valid.
+	ListBooks(ctx context.Context, opts ListOptions) (*BookPage, error)
 
-	// GetBook retrieves a book by its ID.
+	// GetBook retrieves a book by its ID. It returns an error wrapping
+	// errBookNotFound if there is no such book.
 	GetBook(ctx context.Context, id string) (*Book, error)
 
 	// AddBook saves a given book, assigning it a new ID.
@@ -48,8 +85,11 @@ type BookDatabase interface {
 	// DeleteBook removes a given book by its ID.
 	DeleteBook(ctx context.Context, id string) error
 
-	// UpdateBook updates the entry for a given book.
-	UpdateBook(ctx context.Context, b *Book) error
+	// UpdateBook updates the entry for a given book. If ifMatch is not
+	// empty, the book is only updated if it exists and its current ETag is
+	// ifMatch; otherwise UpdateBook returns an error wrapping errBookNotFound
+	// or errPreconditionFailed.
+	UpdateBook(ctx context.Context, b *Book, ifMatch string) error
 }
 
 // Bookshelf holds a BookDatabase and storage info.
@@ -59,6 +99,10 @@ type Bookshelf struct {
 	StorageBucket     *storage.BucketHandle
 	StorageBucketName string
 
+	// signedURL signs image upload URLs. If nil, StorageBucket.SignedURL is
+	// used. It can be overridden for tests.
+	signedURL func(object string, opts *storage.SignedURLOptions) (string, error)
+
 	// logWriter is used for request logging and can be overridden for tests.
 	//
 	// See https://cloud.google.com/logging/docs/setup/go for how to use the
@@ -67,12 +111,25 @@ type Bookshelf struct {
 	logWriter io.Writer
 
 	errorClient *errorreporting.Client
+
+	// auth identifies users. If nil, authentication is off and anyone can
+	// change any book.
+	auth *iapAuth
+
+	// thumbnails processes uploaded images. If nil, books link to uploaded
+	// images as is.
+	thumbnails *thumbnailer
 }
 
 // NewBookshelf creates a new Bookshelf.
 func NewBookshelf(projectID string, db BookDatabase) (*Bookshelf, error) {
 	ctx := context.Background()
 
+	auth, err := newAuthFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("newAuthFromEnv: %w", err)
+	}
+
 	// This Cloud Storage bucket must exist to be able to upload book pictures.
 	// You can create it and make it public by running:
 	//     gsutil mb my-project_bucket
@@ -100,6 +157,11 @@ func NewBookshelf(projectID string, db BookDatabase) (*Bookshelf, error) {
 		DB:                db,
 		StorageBucketName: bucketName,
 		StorageBucket:     storageClient.Bucket(bucketName),
+		auth:              auth,
+	}
+	b.thumbnails, err = newThumbnailerFromEnv(ctx, projectID, db, b.StorageBucket, bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("newThumbnailerFromEnv: %w", err)
 	}
 	return b, nil
 }
@@ -0,0 +1,86 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+)
+
+// newBookDatabase creates the BookDatabase selected by the BOOKSHELF_DB
+// environment variable:
+//
+//   - "firestore" (the default) uses Cloud Firestore in projectID.
+//   - "postgres" uses Cloud SQL for PostgreSQL. It connects with the Cloud SQL
+//     Go connector if INSTANCE_CONNECTION_NAME is set, and over TCP to
+//     INSTANCE_HOST otherwise. See db_sql_connect.go for the other variables.
+//   - "spanner" uses the Cloud Spanner database named by SPANNER_DATABASE, in
+//     the form projects/P/instances/I/databases/D.
+//   - "memory" keeps books in memory, and loses them when the server stops.
+func newBookDatabase(ctx context.Context, projectID string) (BookDatabase, error) {
+	switch backend := os.Getenv("BOOKSHELF_DB"); backend {
+	case "", "firestore":
+		client, err := firestore.NewClient(ctx, projectID)
+		if err != nil {
+			return nil, fmt.Errorf("firestore.NewClient: %w", err)
+		}
+		return newFirestoreDB(client)
+	case "postgres":
+		connect := connectTCPSocket
+		if os.Getenv("INSTANCE_CONNECTION_NAME") != "" {
+			connect = connectWithConnector
+		}
+		db, err := connect()
+		if err != nil {
+			return nil, err
+		}
+		return newSQLDB(db)
+	case "spanner":
+		return connectSpanner(ctx, os.Getenv("SPANNER_DATABASE"))
+	case "memory":
+		return newMemoryDB(), nil
+	default:
+		return nil, fmt.Errorf("unknown BOOKSHELF_DB %q: want firestore, postgres, spanner or memory", backend)
+	}
+}
+
+// connectSpanner creates a spannerDB for the named database, migrating its
+// schema first.
+func connectSpanner(ctx context.Context, name string) (*spannerDB, error) {
+	if name == "" {
+		return nil, errors.New("SPANNER_DATABASE must be set")
+	}
+	admin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("database.NewDatabaseAdminClient: %w", err)
+	}
+	defer admin.Close()
+	client, err := spanner.NewClient(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("spanner.NewClient: %w", err)
+	}
+	db, err := newSpannerDB(client, admin)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,478 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestConformance runs the BookDatabase conformance suite against every
+// backend that is available:
+//
+//   - memoryDB always.
+//   - sqlDB if INSTANCE_HOST and the other connectTCPSocket variables point
+//     at a PostgreSQL server, e.g. one started with
+//     docker run -e POSTGRES_PASSWORD=pw -p 5432:5432 postgres.
+//   - spannerDB if SPANNER_EMULATOR_HOST points at the Cloud Spanner emulator.
+//   - firestoreDB if FIRESTORE_EMULATOR_HOST points at the Firestore emulator,
+//     e.g. one started with gcloud emulators firestore start.
+func TestConformance(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testConformance(t, newMemoryDB())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		if os.Getenv("INSTANCE_HOST") == "" {
+			t.Skip("INSTANCE_HOST not set")
+		}
+		conn, err := connectTCPSocket()
+		if err != nil {
+			t.Fatalf("connectTCPSocket: %v", err)
+		}
+		db, err := newSQLDB(conn)
+		if err != nil {
+			t.Fatalf("newSQLDB: %v", err)
+		}
+		defer db.Close(context.Background())
+
+		// Migrating an up-to-date schema must be a no-op.
+		if err := migrateSQL(context.Background(), conn, sqlMigrations); err != nil {
+			t.Fatalf("migrateSQL again: %v", err)
+		}
+		testConformance(t, db)
+	})
+
+	t.Run("spanner", func(t *testing.T) {
+		if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+			t.Skip("SPANNER_EMULATOR_HOST not set")
+		}
+		db := newEmulatorSpannerDB(t)
+		defer db.Close(context.Background())
+		testConformance(t, db)
+	})
+
+	t.Run("firestore", func(t *testing.T) {
+		if os.Getenv("FIRESTORE_EMULATOR_HOST") == "" {
+			t.Skip("FIRESTORE_EMULATOR_HOST not set")
+		}
+		client, err := firestore.NewClient(context.Background(), "bookshelf-test")
+		if err != nil {
+			t.Fatalf("firestore.NewClient: %v", err)
+		}
+		db, err := newFirestoreDB(client)
+		if err != nil {
+			t.Fatalf("newFirestoreDB: %v", err)
+		}
+		defer db.Close(context.Background())
+		// Use a fresh collection, so earlier runs can't get in the way.
+		db.collection = fmt.Sprintf("books-%d", time.Now().UnixNano())
+		testConformance(t, db)
+	})
+}
+
+// testConformance checks the behavior every BookDatabase must have. It only
+// looks at the books it adds, so db doesn't need to be empty.
+func testConformance(t *testing.T, db BookDatabase) {
+	t.Helper()
+	ctx := context.Background()
+	prefix := fmt.Sprintf("conformance-%d-", time.Now().UnixNano())
+
+	t.Run("AddGet", func(t *testing.T) {
+		want := &Book{
+			Title:         prefix + "add",
+			Author:        "testy mc testface",
+			PublishedDate: "2019-01-01",
+			ImageURL:      "https://example.com/cover.png",
+			Description:   "desc",
+			CreatedBy:     "testy@example.com",
+			CreatedByID:   "accounts.google.com:1234",
+			ThumbnailURL:  "https://example.com/cover-thumb.png",
+			ImageStatus:   imageReady,
+		}
+		id, err := db.AddBook(ctx, want)
+		if err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+		defer db.DeleteBook(ctx, id)
+		if id == "" {
+			t.Fatal("AddBook returned an empty ID")
+		}
+		if want.ID != id {
+			t.Errorf("AddBook set ID %q, returned %q", want.ID, id)
+		}
+
+		got, err := db.GetBook(ctx, id)
+		if err != nil {
+			t.Fatalf("GetBook: %v", err)
+		}
+		if *got != *want {
+			t.Errorf("GetBook = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("UniqueIDs", func(t *testing.T) {
+		seen := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			id, err := db.AddBook(ctx, &Book{Title: prefix + "unique"})
+			if err != nil {
+				t.Fatalf("AddBook: %v", err)
+			}
+			defer db.DeleteBook(ctx, id)
+			if seen[id] {
+				t.Errorf("AddBook returned ID %q twice", id)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		b := &Book{Title: prefix + "update", Description: "desc"}
+		id, err := db.AddBook(ctx, b)
+		if err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+		defer db.DeleteBook(ctx, id)
+
+		want := &Book{ID: id, Title: prefix + "updated", Author: "homer", Description: "newdesc"}
+		if err := db.UpdateBook(ctx, want, ""); err != nil {
+			t.Fatalf("UpdateBook: %v", err)
+		}
+		got, err := db.GetBook(ctx, id)
+		if err != nil {
+			t.Fatalf("GetBook: %v", err)
+		}
+		if *got != *want {
+			t.Errorf("GetBook after update = %+v, want %+v", got, want)
+		}
+
+		if err := db.UpdateBook(ctx, &Book{}, ""); err == nil {
+			t.Error("UpdateBook with an empty ID: want non-nil err")
+		}
+	})
+
+	t.Run("UpdateIfMatch", func(t *testing.T) {
+		b := &Book{Title: prefix + "etag", Description: "v1"}
+		id, err := db.AddBook(ctx, b)
+		if err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+		defer db.DeleteBook(ctx, id)
+		got, err := db.GetBook(ctx, id)
+		if err != nil {
+			t.Fatalf("GetBook: %v", err)
+		}
+		etag := got.ETag()
+
+		v2 := &Book{ID: id, Title: prefix + "etag", Description: "v2"}
+		if err := db.UpdateBook(ctx, v2, etag); err != nil {
+			t.Fatalf("UpdateBook with the current ETag: %v", err)
+		}
+		if v2.ETag() == etag {
+			t.Fatal("ETag did not change with the description")
+		}
+
+		// The first update changed the ETag, so repeating it must fail.
+		v3 := &Book{ID: id, Title: prefix + "etag", Description: "v3"}
+		if err := db.UpdateBook(ctx, v3, etag); !errors.Is(err, errPreconditionFailed) {
+			t.Errorf("UpdateBook with a stale ETag: got err %v, want errPreconditionFailed", err)
+		}
+		got, err = db.GetBook(ctx, id)
+		if err != nil {
+			t.Fatalf("GetBook: %v", err)
+		}
+		if got.Description != "v2" {
+			t.Errorf("Description after failed update = %q, want v2", got.Description)
+		}
+
+		missing := &Book{ID: "0", Title: prefix + "etag"}
+		if err := db.UpdateBook(ctx, missing, etag); !errors.Is(err, errBookNotFound) {
+			t.Errorf("UpdateBook of a missing book with an ETag: got err %v, want errBookNotFound", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		id, err := db.AddBook(ctx, &Book{Title: prefix + "delete"})
+		if err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+		if err := db.DeleteBook(ctx, id); err != nil {
+			t.Fatalf("DeleteBook: %v", err)
+		}
+		if _, err := db.GetBook(ctx, id); !errors.Is(err, errBookNotFound) {
+			t.Errorf("GetBook after delete: got err %v, want errBookNotFound", err)
+		}
+		if err := db.DeleteBook(ctx, id); !errors.Is(err, errBookNotFound) {
+			t.Errorf("DeleteBook of a deleted book: got err %v, want errBookNotFound", err)
+		}
+		if err := db.DeleteBook(ctx, ""); err == nil {
+			t.Error("DeleteBook with an empty ID: want non-nil err")
+		}
+	})
+
+	t.Run("GetMissing", func(t *testing.T) {
+		for _, id := range []string{"0", "does-not-exist"} {
+			if _, err := db.GetBook(ctx, id); !errors.Is(err, errBookNotFound) {
+				t.Errorf("GetBook(%q): got err %v, want errBookNotFound", id, err)
+			}
+		}
+	})
+
+	t.Run("ListSorted", func(t *testing.T) {
+		books := []*Book{
+			{Title: prefix + "sort-b", Author: "y", PublishedDate: "2001"},
+			{Title: prefix + "sort-a", Author: "z", PublishedDate: "2003"},
+			{Title: prefix + "sort-c", Author: "x", PublishedDate: "2002"},
+		}
+		for _, b := range books {
+			id, err := db.AddBook(ctx, b)
+			if err != nil {
+				t.Fatalf("AddBook: %v", err)
+			}
+			defer db.DeleteBook(ctx, id)
+		}
+
+		for _, tc := range []struct {
+			sort SortOrder
+			want []string
+		}{
+			{"", []string{"a", "b", "c"}},
+			{SortByTitle, []string{"a", "b", "c"}},
+			{SortByTitleDesc, []string{"c", "b", "a"}},
+			{SortByAuthor, []string{"c", "b", "a"}},
+			{SortByAuthorDesc, []string{"a", "b", "c"}},
+			{SortByPublished, []string{"b", "c", "a"}},
+			{SortByPublishedDesc, []string{"a", "c", "b"}},
+		} {
+			got := listTitles(t, db, ListOptions{Sort: tc.sort, Query: prefix + "sort-"}, prefix+"sort-")
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("ListBooks(Sort: %q) titles = %v, want %v", tc.sort, got, tc.want)
+			}
+		}
+
+		if _, err := db.ListBooks(ctx, ListOptions{Sort: "nope"}); !errors.Is(err, errInvalidListOptions) {
+			t.Errorf("ListBooks with an unknown sort order: got err %v, want errInvalidListOptions", err)
+		}
+	})
+
+	t.Run("ListFiltered", func(t *testing.T) {
+		books := []*Book{
+			{Title: prefix + "filter-a", Author: "homer", PublishedDate: "1999-12-31", Description: "Doughnuts", CreatedBy: "homer@example.com", CreatedByID: prefix + "homer"},
+			{Title: prefix + "filter-b", Author: "homer", PublishedDate: "2000-01-01"},
+			{Title: prefix + "filter-c", Author: "marge", PublishedDate: "2000", Description: "no DOUGHNUTS here", CreatedBy: "homer@example.com", CreatedByID: prefix + "homer"},
+			{Title: prefix + "filter-d-Doughnut", Author: "Homer", PublishedDate: "19990"},
+		}
+		for _, b := range books {
+			id, err := db.AddBook(ctx, b)
+			if err != nil {
+				t.Fatalf("AddBook: %v", err)
+			}
+			defer db.DeleteBook(ctx, id)
+		}
+
+		for _, tc := range []struct {
+			name string
+			opts ListOptions
+			want []string
+		}{
+			{"all", ListOptions{}, []string{"a", "b", "c", "d-Doughnut"}},
+			{"author", ListOptions{Author: "homer"}, []string{"a", "b"}},
+			{"year", ListOptions{Year: 2000}, []string{"b", "c"}},
+			{"author and year", ListOptions{Author: "homer", Year: 1999}, []string{"a"}},
+			{"search", ListOptions{Query: "doughnut"}, []string{"a", "c", "d-Doughnut"}},
+			{"search and author", ListOptions{Query: "doughnut", Author: "marge"}, []string{"c"}},
+			{"creator", ListOptions{CreatedByID: prefix + "homer"}, []string{"a", "c"}},
+			{"creator and author", ListOptions{CreatedByID: prefix + "homer", Author: "marge"}, []string{"c"}},
+			{"no match", ListOptions{Author: "bart"}, nil},
+		} {
+			opts := tc.opts
+			if opts.Query == "" {
+				opts.Query = prefix + "filter-"
+			}
+			got := listTitles(t, db, opts, prefix+"filter-")
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("ListBooks(%s) titles = %v, want %v", tc.name, got, tc.want)
+			}
+		}
+	})
+
+	t.Run("ListPaginated", func(t *testing.T) {
+		// Two books with the same title check that pages break ties by ID.
+		var want []string
+		for _, title := range []string{"a", "b", "b", "c", "d"} {
+			b := &Book{Title: prefix + "page-" + title}
+			id, err := db.AddBook(ctx, b)
+			if err != nil {
+				t.Fatalf("AddBook: %v", err)
+			}
+			defer db.DeleteBook(ctx, id)
+			want = append(want, title)
+		}
+
+		for _, sort := range []SortOrder{SortByTitle, SortByTitleDesc} {
+			opts := ListOptions{PageSize: 2, Sort: sort, Query: prefix + "page-"}
+			var (
+				got   []string
+				ids   = map[string]bool{}
+				pages int
+			)
+			for {
+				page, err := db.ListBooks(ctx, opts)
+				if err != nil {
+					t.Fatalf("ListBooks(%q) page %d: %v", sort, pages, err)
+				}
+				pages++
+				if len(page.Books) > opts.PageSize {
+					t.Errorf("ListBooks(%q) page %d has %d books, want at most %d", sort, pages, len(page.Books), opts.PageSize)
+				}
+				for _, b := range page.Books {
+					if ids[b.ID] {
+						t.Errorf("ListBooks(%q) returned ID %q twice", sort, b.ID)
+					}
+					ids[b.ID] = true
+					got = append(got, b.Title[len(prefix+"page-"):])
+				}
+				if page.NextPageToken == "" || pages > len(want) {
+					break
+				}
+				opts.PageToken = page.NextPageToken
+			}
+			if pages != 3 {
+				t.Errorf("ListBooks(%q) returned %d pages, want 3", sort, pages)
+			}
+			w := append([]string(nil), want...)
+			if sort.Desc() {
+				for i, j := 0, len(w)-1; i < j; i, j = i+1, j-1 {
+					w[i], w[j] = w[j], w[i]
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(w) {
+				t.Errorf("ListBooks(%q) titles = %v, want %v", sort, got, w)
+			}
+
+			// A token can't be used with a different sort order.
+			opts.Sort = SortByAuthor
+			if _, err := db.ListBooks(ctx, opts); !errors.Is(err, errInvalidListOptions) {
+				t.Errorf("ListBooks with a token for another sort order: got err %v, want errInvalidListOptions", err)
+			}
+		}
+
+		if _, err := db.ListBooks(ctx, ListOptions{PageToken: "!!"}); !errors.Is(err, errInvalidListOptions) {
+			t.Errorf("ListBooks with a malformed token: got err %v, want errInvalidListOptions", err)
+		}
+	})
+}
+
+// listTitles returns the titles of all the books selected by opts that start
+// with prefix, with prefix removed, following page tokens.
+func listTitles(t *testing.T, db BookDatabase, opts ListOptions, prefix string) []string {
+	t.Helper()
+	var titles []string
+	for i := 0; i < 100; i++ {
+		page, err := db.ListBooks(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("ListBooks: %v", err)
+		}
+		for _, b := range page.Books {
+			if strings.HasPrefix(b.Title, prefix) {
+				titles = append(titles, strings.TrimPrefix(b.Title, prefix))
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		opts.PageToken = page.NextPageToken
+	}
+	return titles
+}
+
+// newEmulatorSpannerDB creates a spannerDB backed by a new database in the
+// Cloud Spanner emulator.
+func newEmulatorSpannerDB(t *testing.T) *spannerDB {
+	t.Helper()
+	ctx := context.Background()
+	const (
+		projectID  = "bookshelf-test"
+		instanceID = "bookshelf-test"
+	)
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("instance.NewInstanceAdminClient: %v", err)
+	}
+	defer instanceAdmin.Close()
+	op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + projectID,
+		InstanceId: instanceID,
+		Instance: &instancepb.Instance{
+			Config:      "projects/" + projectID + "/instanceConfigs/emulator-config",
+			DisplayName: instanceID,
+			NodeCount:   1,
+		},
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		if err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+		if _, err := op.Wait(ctx); err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+	}
+
+	databaseID := fmt.Sprintf("books-%d", time.Now().UnixNano()%1e9)
+	admin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("database.NewDatabaseAdminClient: %v", err)
+	}
+	defer admin.Close()
+	dbOp, err := admin.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", projectID, instanceID),
+		CreateStatement: "CREATE DATABASE `" + databaseID + "`",
+	})
+	if err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if _, err := dbOp.Wait(ctx); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+
+	client, err := spanner.NewClient(ctx, fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, databaseID))
+	if err != nil {
+		t.Fatalf("spanner.NewClient: %v", err)
+	}
+	db, err := newSpannerDB(client, admin)
+	if err != nil {
+		t.Fatalf("newSpannerDB: %v", err)
+	}
+	// Migrating an up-to-date schema must be a no-op.
+	if err := migrateSpanner(ctx, client, admin, spannerMigrations); err != nil {
+		t.Fatalf("migrateSpanner again: %v", err)
+	}
+	return db
+}
@@ -16,11 +16,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // firestoreDB persists books to Cloud Firestore.
@@ -62,6 +65,9 @@ func (db *firestoreDB) Close(context.Context) error {
 // Book retrieves a book by its ID.
 func (db *firestoreDB) GetBook(ctx context.Context, id string) (*Book, error) {
 	ds, err := db.client.Collection(db.collection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("firestoredb: %w with ID %q", errBookNotFound, id)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("firestoredb: Get: %w", err)
 	}
@@ -84,26 +90,98 @@ func (db *firestoreDB) AddBook(ctx context.Context, b *Book) (id string, err err
 
 // DeleteBook removes a given book by its ID.
 func (db *firestoreDB) DeleteBook(ctx context.Context, id string) error {
-	if _, err := db.client.Collection(db.collection).Doc(id).Delete(ctx); err != nil {
-		return fmt.Errorf("firestore: Delete: %w", err)
+	if id == "" {
+		return errors.New("firestoredb: book with unassigned ID passed into DeleteBook")
+	}
+	// Without the Exists precondition, deleting a missing document succeeds.
+	_, err := db.client.Collection(db.collection).Doc(id).Delete(ctx, firestore.Exists)
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("firestoredb: could not delete book with ID %q: %w", id, errBookNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("firestoredb: Delete: %w", err)
 	}
 	return nil
 }
 
 // UpdateBook updates the entry for a given book.
-func (db *firestoreDB) UpdateBook(ctx context.Context, b *Book) error {
-	if _, err := db.client.Collection(db.collection).Doc(b.ID).Set(ctx, b); err != nil {
-		return fmt.Errorf("firestsore: Set: %w", err)
+func (db *firestoreDB) UpdateBook(ctx context.Context, b *Book, ifMatch string) error {
+	ref := db.client.Collection(db.collection).Doc(b.ID)
+	if ifMatch == "" {
+		if _, err := ref.Set(ctx, b); err != nil {
+			return fmt.Errorf("firestsore: Set: %w", err)
+		}
+		return nil
+	}
+
+	// Check the ETag and write in a transaction, so the book can't change in
+	// between.
+	err := db.client.RunTransaction(ctx, func(ctx context.Context, t *firestore.Transaction) error {
+		ds, err := t.Get(ref)
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("%w with ID %q", errBookNotFound, b.ID)
+		}
+		if err != nil {
+			return err
+		}
+		cur := &Book{}
+		if err := ds.DataTo(cur); err != nil {
+			return err
+		}
+		if cur.ETag() != ifMatch {
+			return fmt.Errorf("%w: book with ID %q", errPreconditionFailed, b.ID)
+		}
+		return t.Set(ref, b)
+	})
+	if err != nil {
+		return fmt.Errorf("firestoredb: UpdateBook: %w", err)
 	}
 	return nil
 }
 
-// ListBooks returns a list of books, ordered by title.
-func (db *firestoreDB) ListBooks(ctx context.Context) ([]*Book, error) {
+// firestoreMaxScan is the most books ListBooks reads from Firestore for one
+// page when filtering by year or query.
+const firestoreMaxScan = 1000
+
+// ListBooks returns a page of the books selected by opts.
+//
+// Filtering by author or creator with a sort order other than by author
+// needs a composite index on Author or CreatedByID and the sort field. Firestore can't match
+// substrings or prefixes alongside a different sort field, so the Year and
+// Query filters are applied to the query results. That reads every book in
+// the sort order until the page is full, which is O(collection) for a rare
+// match. To bound the cost of a request, ListBooks stops after
+// firestoreMaxScan books and returns a short, possibly empty, page with a
+// NextPageToken continuing after the last book read.
+func (db *firestoreDB) ListBooks(ctx context.Context, opts ListOptions) (*BookPage, error) {
+	opts, cursor, err := opts.normalize()
+	if err != nil {
+		return nil, fmt.Errorf("firestoredb: %w", err)
+	}
+
+	dir := firestore.Asc
+	if opts.Sort.Desc() {
+		dir = firestore.Desc
+	}
+	q := db.client.Collection(db.collection).Query.
+		OrderBy(opts.Sort.Field(), dir).
+		OrderBy(firestore.DocumentID, dir)
+	if opts.Author != "" {
+		q = q.Where("Author", "==", opts.Author)
+	}
+	if opts.CreatedByID != "" {
+		q = q.Where("CreatedByID", "==", opts.CreatedByID)
+	}
+	if cursor != nil {
+		q = q.StartAfter(cursor.Key, cursor.ID)
+	}
+
 	books := make([]*Book, 0)
-	iter := db.client.Collection(db.collection).Query.OrderBy("Title", firestore.Asc).Documents(ctx)
+	iter := q.Documents(ctx)
 	defer iter.Stop()
-	for {
+	// Read one more book than needed to know whether there is a next page.
+	var last *Book
+	for scanned := 0; len(books) <= opts.PageSize; scanned++ {
 		doc, err := iter.Next()
 		if err == iterator.Done {
 			break
@@ -111,11 +189,19 @@ func (db *firestoreDB) ListBooks(ctx context.Context) ([]*Book, error) {
 		if err != nil {
 			return nil, fmt.Errorf("firestoredb: could not list books: %w", err)
 		}
+		if scanned == firestoreMaxScan {
+			// There are more books to scan. Leave them to the next page.
+			return &BookPage{Books: books, NextPageToken: newPageCursor(opts.Sort, last).encode()}, nil
+		}
 		b := &Book{}
 		doc.DataTo(b)
+		last = b
+		if !opts.matches(b) {
+			continue
+		}
 		log.Printf("Book %q ID: %q", b.Title, b.ID)
 		books = append(books, b)
 	}
 
-	return books, nil
+	return newBookPage(opts, books), nil
 }
@@ -56,7 +56,7 @@ func (db *memoryDB) GetBook(_ context.Context, id string) (*Book, error) {
 
 	book, ok := db.books[id]
 	if !ok {
-		return nil, fmt.Errorf("memorydb: book not found with ID %q", id)
+		return nil, fmt.Errorf("memorydb: %w with ID %q", errBookNotFound, id)
 	}
 	return book, nil
 }
@@ -84,14 +84,14 @@ func (db *memoryDB) DeleteBook(_ context.Context, id string) error {
 	defer db.mu.Unlock()
 
 	if _, ok := db.books[id]; !ok {
-		return fmt.Errorf("memorydb: could not delete book with ID %q, does not exist", id)
+		return fmt.Errorf("memorydb: could not delete book with ID %q: %w", id, errBookNotFound)
 	}
 	delete(db.books, id)
 	return nil
 }
 
 // UpdateBook updates the entry for a given book.
-func (db *memoryDB) UpdateBook(_ context.Context, b *Book) error {
+func (db *memoryDB) UpdateBook(_ context.Context, b *Book, ifMatch string) error {
 	if b.ID == "" {
 		return errors.New("memorydb: book with unassigned ID passed into UpdateBook")
 	}
@@ -99,22 +99,42 @@ func (db *memoryDB) UpdateBook(_ context.Context, b *Book) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if ifMatch != "" {
+		cur, ok := db.books[b.ID]
+		if !ok {
+			return fmt.Errorf("memorydb: %w with ID %q", errBookNotFound, b.ID)
+		}
+		if cur.ETag() != ifMatch {
+			return fmt.Errorf("memorydb: %w: book with ID %q", errPreconditionFailed, b.ID)
+		}
+	}
 	db.books[b.ID] = b
 	return nil
 }
 
-// ListBooks returns a list of books, ordered by title.
-func (db *memoryDB) ListBooks(_ context.Context) ([]*Book, error) {
+// ListBooks returns a page of the books selected by opts.
+func (db *memoryDB) ListBooks(_ context.Context, opts ListOptions) (*BookPage, error) {
+	opts, cursor, err := opts.normalize()
+	if err != nil {
+		return nil, fmt.Errorf("memorydb: %w", err)
+	}
+
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	var books []*Book
 	for _, b := range db.books {
-		books = append(books, b)
+		if opts.matches(b) && (cursor == nil || cursor.after(b)) {
+			books = append(books, b)
+		}
 	}
 
 	sort.Slice(books, func(i, j int) bool {
-		return books[i].Title < books[j].Title
+		bi, bj := books[i], books[j]
+		return compareBooks(opts.Sort, opts.Sort.key(bi), bi.ID, opts.Sort.key(bj), bj.ID) < 0
 	})
-	return books, nil
+	if len(books) > opts.PageSize+1 {
+		books = books[:opts.PageSize+1]
+	}
+	return newBookPage(opts, books), nil
 }
@@ -0,0 +1,328 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	"github.com/gofrs/uuid"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+)
+
+// spannerDB persists books to Cloud Spanner.
+// See https://cloud.google.com/spanner/docs.
+type spannerDB struct {
+	client *spanner.Client
+}
+
+// Ensure spannerDB conforms to the BookDatabase interface.
+var _ BookDatabase = &spannerDB{}
+
+// spannerMigrations are the DDL batches applied by newSpannerDB, in order.
+// As with sqlMigrations, add new entries rather than editing existing ones.
+var spannerMigrations = [][]string{
+	{
+		`CREATE TABLE Books (
+			ID            STRING(36) NOT NULL,
+			Title         STRING(MAX) NOT NULL,
+			Author        STRING(MAX) NOT NULL,
+			PublishedDate STRING(MAX) NOT NULL,
+			ImageURL      STRING(MAX) NOT NULL,
+			Description   STRING(MAX) NOT NULL,
+		) PRIMARY KEY (ID)`,
+		`CREATE INDEX BooksByTitle ON Books(Title)`,
+	},
+	{
+		`CREATE INDEX BooksByAuthor ON Books(Author)`,
+		`CREATE INDEX BooksByPublishedDate ON Books(PublishedDate)`,
+	},
+	{
+		`ALTER TABLE Books ADD COLUMN CreatedBy STRING(MAX) NOT NULL DEFAULT ("")`,
+		`ALTER TABLE Books ADD COLUMN CreatedByID STRING(MAX) NOT NULL DEFAULT ("")`,
+		`CREATE INDEX BooksByCreatedByID ON Books(CreatedByID)`,
+	},
+	{
+		`ALTER TABLE Books ADD COLUMN ThumbnailURL STRING(MAX) NOT NULL DEFAULT ("")`,
+		`ALTER TABLE Books ADD COLUMN ImageStatus STRING(MAX) NOT NULL DEFAULT ("")`,
+	},
+}
+
+// bookFields are the Books columns read by spannerBook, in order.
+var bookFields = []string{"ID", "Title", "Author", "PublishedDate", "ImageURL", "Description", "CreatedBy", "CreatedByID", "ThumbnailURL", "ImageStatus"}
+
+// newSpannerDB creates a new BookDatabase backed by Cloud Spanner. If admin
+// is not nil, it is used to migrate the schema of the client's database to
+// the latest version first.
+func newSpannerDB(client *spanner.Client, admin *database.DatabaseAdminClient) (*spannerDB, error) {
+	ctx := context.Background()
+	if admin != nil {
+		if err := migrateSpanner(ctx, client, admin, spannerMigrations); err != nil {
+			return nil, fmt.Errorf("spannerdb: %w", err)
+		}
+	}
+	// Verify that we can communicate and authenticate with the database.
+	iter := client.Single().Query(ctx, spanner.Statement{SQL: "SELECT 1"})
+	defer iter.Stop()
+	if _, err := iter.Next(); err != nil {
+		return nil, fmt.Errorf("spannerdb: could not connect: %w", err)
+	}
+	return &spannerDB{client: client}, nil
+}
+
+// migrateSpanner applies the DDL batches that have not been applied to the
+// client's database yet, recording each in the SchemaMigrations table.
+func migrateSpanner(ctx context.Context, client *spanner.Client, admin *database.DatabaseAdminClient, migrations [][]string) error {
+	updateDDL := func(statements []string) error {
+		op, err := admin.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   client.DatabaseName(),
+			Statements: statements,
+		})
+		if err != nil {
+			return fmt.Errorf("UpdateDatabaseDdl: %w", err)
+		}
+		return op.Wait(ctx)
+	}
+
+	exists, err := spannerTableExists(ctx, client, "SchemaMigrations")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err := updateDDL([]string{`CREATE TABLE SchemaMigrations (
+			Version   INT64 NOT NULL,
+			AppliedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+		) PRIMARY KEY (Version)`})
+		if err != nil {
+			return fmt.Errorf("could not create SchemaMigrations: %w", err)
+		}
+	}
+
+	var version spanner.NullInt64
+	err = client.Single().Query(ctx, spanner.Statement{SQL: "SELECT MAX(Version) FROM SchemaMigrations"}).
+		Do(func(r *spanner.Row) error { return r.Column(0, &version) })
+	if err != nil {
+		return fmt.Errorf("could not read schema version: %w", err)
+	}
+	if int(version.Int64) > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this binary (%d)", version.Int64, len(migrations))
+	}
+	for i := int(version.Int64); i < len(migrations); i++ {
+		if err := updateDDL(migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		m := spanner.Insert("SchemaMigrations", []string{"Version", "AppliedAt"}, []interface{}{i + 1, spanner.CommitTimestamp})
+		if _, err := client.Apply(ctx, []*spanner.Mutation{m}); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// spannerTableExists reports whether the client's database has a table with
+// the given name.
+func spannerTableExists(ctx context.Context, client *spanner.Client, table string) (bool, error) {
+	stmt := spanner.Statement{
+		SQL:    `SELECT 1 FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @table`,
+		Params: map[string]interface{}{"table": table},
+	}
+	iter := client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	_, err := iter.Next()
+	if err == iterator.Done {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("could not read INFORMATION_SCHEMA: %w", err)
+	}
+	return true, nil
+}
+
+// Close closes the database.
+func (db *spannerDB) Close(context.Context) error {
+	db.client.Close()
+	return nil
+}
+
+// spannerBook reads a book from a row with the columns in bookFields.
+func spannerBook(r *spanner.Row) (*Book, error) {
+	b := &Book{}
+	if err := r.Columns(&b.ID, &b.Title, &b.Author, &b.PublishedDate, &b.ImageURL, &b.Description, &b.CreatedBy, &b.CreatedByID, &b.ThumbnailURL, &b.ImageStatus); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// bookValues returns the values of b for the columns in bookFields.
+func bookValues(b *Book) []interface{} {
+	return []interface{}{b.ID, b.Title, b.Author, b.PublishedDate, b.ImageURL, b.Description, b.CreatedBy, b.CreatedByID, b.ThumbnailURL, b.ImageStatus}
+}
+
+// GetBook retrieves a book by its ID.
+func (db *spannerDB) GetBook(ctx context.Context, id string) (*Book, error) {
+	row, err := db.client.Single().ReadRow(ctx, "Books", spanner.Key{id}, bookFields)
+	if spanner.ErrCode(err) == codes.NotFound {
+		return nil, fmt.Errorf("spannerdb: %w with ID %q", errBookNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("spannerdb: ReadRow: %w", err)
+	}
+	return spannerBook(row)
+}
+
+// AddBook saves a given book, assigning it a new ID.
+func (db *spannerDB) AddBook(ctx context.Context, b *Book) (id string, err error) {
+	// Random IDs spread writes across splits, unlike sequential ones.
+	b.ID = uuid.Must(uuid.NewV4()).String()
+	m := spanner.Insert("Books", bookFields, bookValues(b))
+	if _, err := db.client.Apply(ctx, []*spanner.Mutation{m}); err != nil {
+		return "", fmt.Errorf("spannerdb: Insert: %w", err)
+	}
+	return b.ID, nil
+}
+
+// DeleteBook removes a given book by its ID.
+func (db *spannerDB) DeleteBook(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("spannerdb: book with unassigned ID passed into DeleteBook")
+	}
+	_, err := db.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		n, err := txn.Update(ctx, spanner.Statement{
+			SQL:    `DELETE FROM Books WHERE ID = @id`,
+			Params: map[string]interface{}{"id": id},
+		})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("could not delete book with ID %q: %w", id, errBookNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("spannerdb: %w", err)
+	}
+	return nil
+}
+
+// UpdateBook updates the entry for a given book.
+func (db *spannerDB) UpdateBook(ctx context.Context, b *Book, ifMatch string) error {
+	if b.ID == "" {
+		return errors.New("spannerdb: book with unassigned ID passed into UpdateBook")
+	}
+	m := spanner.Update("Books", bookFields, bookValues(b))
+	if ifMatch == "" {
+		_, err := db.client.Apply(ctx, []*spanner.Mutation{m})
+		if spanner.ErrCode(err) == codes.NotFound {
+			return fmt.Errorf("spannerdb: %w with ID %q", errBookNotFound, b.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("spannerdb: Update: %w", err)
+		}
+		return nil
+	}
+
+	_, err := db.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		row, err := txn.ReadRow(ctx, "Books", spanner.Key{b.ID}, bookFields)
+		if spanner.ErrCode(err) == codes.NotFound {
+			return fmt.Errorf("%w with ID %q", errBookNotFound, b.ID)
+		}
+		if err != nil {
+			return err
+		}
+		cur, err := spannerBook(row)
+		if err != nil {
+			return err
+		}
+		if cur.ETag() != ifMatch {
+			return fmt.Errorf("%w: book with ID %q", errPreconditionFailed, b.ID)
+		}
+		return txn.BufferWrite([]*spanner.Mutation{m})
+	})
+	if err != nil {
+		return fmt.Errorf("spannerdb: UpdateBook: %w", err)
+	}
+	return nil
+}
+
+// ListBooks returns a page of the books selected by opts.
+func (db *spannerDB) ListBooks(ctx context.Context, opts ListOptions) (*BookPage, error) {
+	opts, cursor, err := opts.normalize()
+	if err != nil {
+		return nil, fmt.Errorf("spannerdb: %w", err)
+	}
+
+	stmt := spanner.Statement{Params: map[string]interface{}{}}
+	var where []string
+	if opts.Author != "" {
+		where = append(where, "Author = @author")
+		stmt.Params["author"] = opts.Author
+	}
+	if opts.CreatedByID != "" {
+		where = append(where, "CreatedByID = @createdByID")
+		stmt.Params["createdByID"] = opts.CreatedByID
+	}
+	if opts.Year != 0 {
+		where = append(where, "STARTS_WITH(PublishedDate, @year)")
+		stmt.Params["year"] = opts.yearPrefix()
+	}
+	if opts.Query != "" {
+		where = append(where, "(STRPOS(LOWER(Title), LOWER(@query)) > 0 OR STRPOS(LOWER(Description), LOWER(@query)) > 0)")
+		stmt.Params["query"] = opts.Query
+	}
+	col, dir, cmp := opts.Sort.Field(), "ASC", ">"
+	if opts.Sort.Desc() {
+		dir, cmp = "DESC", "<"
+	}
+	if cursor != nil {
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s @key OR (%[1]s = @key AND ID %[2]s @id))", col, cmp))
+		stmt.Params["key"] = cursor.Key
+		stmt.Params["id"] = cursor.ID
+	}
+
+	stmt.SQL = `SELECT ` + strings.Join(bookFields, ", ") + ` FROM Books`
+	if len(where) > 0 {
+		stmt.SQL += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Read one more book than needed to know whether there is a next page.
+	stmt.SQL += fmt.Sprintf(" ORDER BY %s %s, ID %[2]s LIMIT @limit", col, dir)
+	stmt.Params["limit"] = opts.PageSize + 1
+
+	iter := db.client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	books := make([]*Book, 0)
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("spannerdb: could not list books: %w", err)
+		}
+		b, err := spannerBook(row)
+		if err != nil {
+			return nil, fmt.Errorf("spannerdb: could not read book: %w", err)
+		}
+		books = append(books, b)
+	}
+	return newBookPage(opts, books), nil
+}
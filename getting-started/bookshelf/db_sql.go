@@ -0,0 +1,318 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sqlDB persists books to a PostgreSQL database, such as Cloud SQL for
+// PostgreSQL, using database/sql.
+// See https://cloud.google.com/sql/docs/postgres.
+type sqlDB struct {
+	db *sql.DB
+}
+
+// Ensure sqlDB conforms to the BookDatabase interface.
+var _ BookDatabase = &sqlDB{}
+
+// sqlMigrations are the schema changes applied by newSQLDB, in order. The
+// schema_migrations table records which have been applied, so entries must
+// never be edited or reordered once released: add a new entry instead.
+var sqlMigrations = []string{
+	`CREATE TABLE books (
+		id             BIGSERIAL PRIMARY KEY,
+		title          TEXT NOT NULL DEFAULT '',
+		author         TEXT NOT NULL DEFAULT '',
+		published_date TEXT NOT NULL DEFAULT '',
+		image_url      TEXT NOT NULL DEFAULT '',
+		description    TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX books_title_idx ON books (title)`,
+	`CREATE INDEX books_author_idx ON books (author, id)`,
+	`CREATE INDEX books_published_date_idx ON books (published_date, id)`,
+	`ALTER TABLE books
+		ADD COLUMN created_by    TEXT NOT NULL DEFAULT '',
+		ADD COLUMN created_by_id TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX books_created_by_id_idx ON books (created_by_id)`,
+	`ALTER TABLE books
+		ADD COLUMN thumbnail_url TEXT NOT NULL DEFAULT '',
+		ADD COLUMN image_status  TEXT NOT NULL DEFAULT ''`,
+}
+
+// newSQLDB creates a new BookDatabase backed by db, migrating the schema to
+// the latest version first. See connectWithConnector and connectTCPSocket for
+// creating a suitable *sql.DB.
+func newSQLDB(db *sql.DB) (*sqlDB, error) {
+	ctx := context.Background()
+	// Verify that we can communicate and authenticate with the database.
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("sqldb: could not connect: %w", err)
+	}
+	if err := migrateSQL(ctx, db, sqlMigrations); err != nil {
+		return nil, fmt.Errorf("sqldb: %w", err)
+	}
+	return &sqlDB{db: db}, nil
+}
+
+// migrateSQL applies the migrations that have not been applied to db yet. It
+// runs in a single transaction holding a lock on schema_migrations, so
+// instances starting at the same time don't apply a migration twice.
+func migrateSQL(ctx context.Context, db *sql.DB, migrations []string) error {
+	const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`
+	if _, err := db.ExecContext(ctx, createTable); err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("could not lock schema_migrations: %w", err)
+	}
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("could not read schema version: %w", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this binary (%d)", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, i+1); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the database.
+func (db *sqlDB) Close(context.Context) error {
+	return db.db.Close()
+}
+
+// bookColumns are the columns scanned by scanBook, in order.
+const bookColumns = `id, title, author, published_date, image_url, description, created_by, created_by_id, thumbnail_url, image_status`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads a book from the columns listed in bookColumns.
+func scanBook(s rowScanner) (*Book, error) {
+	var (
+		b  Book
+		id int64
+	)
+	if err := s.Scan(&id, &b.Title, &b.Author, &b.PublishedDate, &b.ImageURL, &b.Description, &b.CreatedBy, &b.CreatedByID, &b.ThumbnailURL, &b.ImageStatus); err != nil {
+		return nil, err
+	}
+	b.ID = strconv.FormatInt(id, 10)
+	return &b, nil
+}
+
+// GetBook retrieves a book by its ID.
+func (db *sqlDB) GetBook(ctx context.Context, id string) (*Book, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: %w with ID %q", errBookNotFound, id)
+	}
+	row := db.db.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = $1`, n)
+	b, err := scanBook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("sqldb: %w with ID %q", errBookNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: could not get book: %w", err)
+	}
+	return b, nil
+}
+
+// AddBook saves a given book, assigning it a new ID.
+func (db *sqlDB) AddBook(ctx context.Context, b *Book) (id string, err error) {
+	const q = `INSERT INTO books (title, author, published_date, image_url, description, created_by, created_by_id, thumbnail_url, image_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	var n int64
+	if err := db.db.QueryRowContext(ctx, q, b.Title, b.Author, b.PublishedDate, b.ImageURL, b.Description, b.CreatedBy, b.CreatedByID, b.ThumbnailURL, b.ImageStatus).Scan(&n); err != nil {
+		return "", fmt.Errorf("sqldb: could not add book: %w", err)
+	}
+	b.ID = strconv.FormatInt(n, 10)
+	return b.ID, nil
+}
+
+// DeleteBook removes a given book by its ID.
+func (db *sqlDB) DeleteBook(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("sqldb: book with unassigned ID passed into DeleteBook")
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("sqldb: could not delete book with ID %q: %w", id, errBookNotFound)
+	}
+	res, err := db.db.ExecContext(ctx, `DELETE FROM books WHERE id = $1`, n)
+	if err != nil {
+		return fmt.Errorf("sqldb: could not delete book: %w", err)
+	}
+	return checkAffected(res, id)
+}
+
+// UpdateBook updates the entry for a given book.
+func (db *sqlDB) UpdateBook(ctx context.Context, b *Book, ifMatch string) error {
+	if b.ID == "" {
+		return errors.New("sqldb: book with unassigned ID passed into UpdateBook")
+	}
+	n, err := strconv.ParseInt(b.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("sqldb: could not update book with ID %q: %w", b.ID, errBookNotFound)
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqldb: BeginTx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if ifMatch != "" {
+		// Lock the row so it can't change between the check and the update.
+		row := tx.QueryRowContext(ctx, `SELECT `+bookColumns+` FROM books WHERE id = $1 FOR UPDATE`, n)
+		cur, err := scanBook(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("sqldb: %w with ID %q", errBookNotFound, b.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("sqldb: could not get book: %w", err)
+		}
+		if cur.ETag() != ifMatch {
+			return fmt.Errorf("sqldb: %w: book with ID %q", errPreconditionFailed, b.ID)
+		}
+	}
+
+	const q = `UPDATE books
+		SET title = $2, author = $3, published_date = $4, image_url = $5, description = $6,
+			created_by = $7, created_by_id = $8, thumbnail_url = $9, image_status = $10
+		WHERE id = $1`
+	res, err := tx.ExecContext(ctx, q, n, b.Title, b.Author, b.PublishedDate, b.ImageURL, b.Description, b.CreatedBy, b.CreatedByID, b.ThumbnailURL, b.ImageStatus)
+	if err != nil {
+		return fmt.Errorf("sqldb: could not update book: %w", err)
+	}
+	if err := checkAffected(res, b.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqldb: Commit: %w", err)
+	}
+	return nil
+}
+
+// checkAffected returns an error wrapping errBookNotFound if res did not
+// change any rows.
+func checkAffected(res sql.Result, id string) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("sqldb: RowsAffected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("sqldb: %w with ID %q", errBookNotFound, id)
+	}
+	return nil
+}
+
+// sqlColumns maps the Book fields that books can be sorted by to columns.
+var sqlColumns = map[string]string{
+	"Title":         "title",
+	"Author":        "author",
+	"PublishedDate": "published_date",
+}
+
+// ListBooks returns a page of the books selected by opts.
+func (db *sqlDB) ListBooks(ctx context.Context, opts ListOptions) (*BookPage, error) {
+	opts, cursor, err := opts.normalize()
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: %w", err)
+	}
+
+	var (
+		where []string
+		args  []interface{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if opts.Author != "" {
+		where = append(where, "author = "+arg(opts.Author))
+	}
+	if opts.CreatedByID != "" {
+		where = append(where, "created_by_id = "+arg(opts.CreatedByID))
+	}
+	if opts.Year != 0 {
+		where = append(where, "left(published_date, 4) = "+arg(opts.yearPrefix()))
+	}
+	if opts.Query != "" {
+		q := arg(opts.Query)
+		where = append(where, fmt.Sprintf("(strpos(lower(title), lower(%s)) > 0 OR strpos(lower(description), lower(%[1]s)) > 0)", q))
+	}
+	col, dir, cmp := sqlColumns[opts.Sort.Field()], "ASC", ">"
+	if opts.Sort.Desc() {
+		dir, cmp = "DESC", "<"
+	}
+	if cursor != nil {
+		id, err := strconv.ParseInt(cursor.ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sqldb: %w: malformed page token", errInvalidListOptions)
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", col, cmp, arg(cursor.Key), arg(id)))
+	}
+
+	q := `SELECT ` + bookColumns + ` FROM books`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Read one more book than needed to know whether there is a next page.
+	q += fmt.Sprintf(" ORDER BY %s %s, id %[2]s LIMIT %s", col, dir, arg(opts.PageSize+1))
+
+	rows, err := db.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqldb: could not list books: %w", err)
+	}
+	defer rows.Close()
+
+	books := make([]*Book, 0)
+	for rows.Next() {
+		b, err := scanBook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("sqldb: could not read book: %w", err)
+		}
+		books = append(books, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqldb: could not list books: %w", err)
+	}
+	return newBookPage(opts, books), nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/cloudsqlconn"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// The connection helpers below follow the samples in
+// cloudsql/postgres/database-sql, but return an error instead of exiting when
+// configuration is missing.
+
+// envGetter reads required environment variables, remembering the ones that
+// are not set.
+type envGetter struct {
+	missing []string
+}
+
+func (e *envGetter) get(k string) string {
+	v := os.Getenv(k)
+	if v == "" {
+		e.missing = append(e.missing, k)
+	}
+	return v
+}
+
+func (e *envGetter) err() error {
+	if len(e.missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("environment variables not set: %s", strings.Join(e.missing, ", "))
+}
+
+// connectWithConnector opens a connection pool to a Cloud SQL for PostgreSQL
+// instance using the Cloud SQL Go connector, which does not require the Cloud
+// SQL Auth Proxy.
+func connectWithConnector() (*sql.DB, error) {
+	// Note: Saving credentials in environment variables is convenient, but not
+	// secure - consider a more secure solution such as
+	// Cloud Secret Manager (https://cloud.google.com/secret-manager) to help
+	// keep passwords and other secrets safe.
+	var env envGetter
+	var (
+		dbUser                 = env.get("DB_USER")                  // e.g. 'my-db-user'
+		dbPwd                  = env.get("DB_PASS")                  // e.g. 'my-db-password'
+		dbName                 = env.get("DB_NAME")                  // e.g. 'my-database'
+		instanceConnectionName = env.get("INSTANCE_CONNECTION_NAME") // e.g. 'project:region:instance'
+		usePrivate             = os.Getenv("PRIVATE_IP")
+	)
+	if err := env.err(); err != nil {
+		return nil, fmt.Errorf("connectWithConnector: %w", err)
+	}
+
+	dsn := fmt.Sprintf("user=%s password=%s database=%s", dbUser, dbPwd, dbName)
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	var opts []cloudsqlconn.Option
+	if usePrivate != "" {
+		opts = append(opts, cloudsqlconn.WithDefaultDialOptions(cloudsqlconn.WithPrivateIP()))
+	}
+	// WithLazyRefresh() Option is used to perform refresh
+	// when needed, rather than on a scheduled interval.
+	// This is recommended for serverless environments to
+	// avoid background refreshes from throttling CPU.
+	opts = append(opts, cloudsqlconn.WithLazyRefresh())
+	d, err := cloudsqlconn.NewDialer(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cloudsqlconn.NewDialer: %w", err)
+	}
+	config.DialFunc = func(ctx context.Context, network, instance string) (net.Conn, error) {
+		return d.Dial(ctx, instanceConnectionName)
+	}
+	dbURI := stdlib.RegisterConnConfig(config)
+	dbPool, err := sql.Open("pgx", dbURI)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	return dbPool, nil
+}
+
+// connectTCPSocket opens a connection pool to a PostgreSQL server over TCP,
+// such as a local server or the Cloud SQL Auth Proxy.
+func connectTCPSocket() (*sql.DB, error) {
+	var env envGetter
+	var (
+		dbUser    = env.get("DB_USER")       // e.g. 'my-db-user'
+		dbPwd     = env.get("DB_PASS")       // e.g. 'my-db-password'
+		dbTCPHost = env.get("INSTANCE_HOST") // e.g. '127.0.0.1'
+		dbPort    = env.get("DB_PORT")       // e.g. '5432'
+		dbName    = env.get("DB_NAME")       // e.g. 'my-database'
+	)
+	if err := env.err(); err != nil {
+		return nil, fmt.Errorf("connectTCPSocket: %w", err)
+	}
+
+	dbURI := fmt.Sprintf("host=%s user=%s password=%s port=%s database=%s",
+		dbTCPHost, dbUser, dbPwd, dbPort, dbName)
+	if sslMode := os.Getenv("DB_SSLMODE"); sslMode != "" { // e.g. 'disable' for a local server
+		dbURI += " sslmode=" + sslMode
+	}
+
+	dbPool, err := sql.Open("pgx", dbURI)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	return dbPool, nil
+}
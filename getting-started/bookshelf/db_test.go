@@ -42,7 +42,7 @@ func testDB(t *testing.T, db BookDatabase) {
 
 	b.ID = id
 	b.Description = "newdesc"
-	if err := db.UpdateBook(ctx, b); err != nil {
+	if err := db.UpdateBook(ctx, b, ""); err != nil {
 		t.Error(err)
 	}
 
@@ -0,0 +1,270 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// errInvalidListOptions is returned (possibly wrapped) by ListBooks when the
+// ListOptions are not valid, such as an unknown sort order or a malformed
+// page token.
+var errInvalidListOptions = errors.New("invalid list options")
+
+// SortOrder is the order in which ListBooks returns books. A leading "-"
+// means descending. Books that compare equal are ordered by ID.
+type SortOrder string
+
+// Sort orders supported by ListBooks.
+const (
+	SortByTitle         SortOrder = "title"
+	SortByTitleDesc     SortOrder = "-title"
+	SortByAuthor        SortOrder = "author"
+	SortByAuthorDesc    SortOrder = "-author"
+	SortByPublished     SortOrder = "published"
+	SortByPublishedDesc SortOrder = "-published"
+)
+
+// sortOrders are the valid sort orders, in the order they are offered in
+// templates/list.html.
+var sortOrders = []SortOrder{
+	SortByTitle, SortByTitleDesc,
+	SortByAuthor, SortByAuthorDesc,
+	SortByPublished, SortByPublishedDesc,
+}
+
+// Field returns the name of the Book field s sorts by.
+func (s SortOrder) Field() string {
+	switch strings.TrimPrefix(string(s), "-") {
+	case "author":
+		return "Author"
+	case "published":
+		return "PublishedDate"
+	default:
+		return "Title"
+	}
+}
+
+// Desc reports whether s is a descending order.
+func (s SortOrder) Desc() bool {
+	return strings.HasPrefix(string(s), "-")
+}
+
+// key returns the value of the field of b that s sorts by.
+func (s SortOrder) key(b *Book) string {
+	switch s.Field() {
+	case "Author":
+		return b.Author
+	case "PublishedDate":
+		return b.PublishedDate
+	default:
+		return b.Title
+	}
+}
+
+// ListOptions select and order the books returned by ListBooks.
+type ListOptions struct {
+	// PageSize is the maximum number of books to return. If zero,
+	// defaultPageSize is used. Values over maxPageSize are reduced to it.
+	PageSize int
+
+	// PageToken is the NextPageToken of a previous BookPage, to continue
+	// listing after it. It must be used with the same Sort.
+	PageToken string
+
+	// Sort is the order of the books. If empty, SortByTitle is used.
+	Sort SortOrder
+
+	// Author, if not empty, only selects books by exactly this author.
+	Author string
+
+	// Year, if not zero, only selects books whose PublishedDate starts with
+	// this year, such as "2019" or "2019-05-01".
+	Year int
+
+	// Query, if not empty, only selects books whose title or description
+	// contains it, ignoring case.
+	Query string
+
+	// CreatedByID, if not empty, only selects books created by the user with
+	// this ID, for the "my books" view.
+	CreatedByID string
+}
+
+// BookPage is a page of books returned by ListBooks.
+type BookPage struct {
+	Books []*Book
+
+	// NextPageToken continues the listing after the last book in Books. It
+	// is empty on the last page.
+	NextPageToken string
+}
+
+// normalize returns a copy of o with defaults applied, and the decoded page
+// cursor, if any.
+func (o ListOptions) normalize() (ListOptions, *pageCursor, error) {
+	if o.PageSize <= 0 {
+		o.PageSize = defaultPageSize
+	}
+	if o.PageSize > maxPageSize {
+		o.PageSize = maxPageSize
+	}
+	if o.Sort == "" {
+		o.Sort = SortByTitle
+	}
+	if !validSortOrder(o.Sort) {
+		return o, nil, fmt.Errorf("%w: unknown sort order %q", errInvalidListOptions, o.Sort)
+	}
+	if o.Year < 0 || o.Year > 9999 {
+		return o, nil, fmt.Errorf("%w: year %d out of range", errInvalidListOptions, o.Year)
+	}
+	if o.PageToken == "" {
+		return o, nil, nil
+	}
+	c, err := decodePageCursor(o.PageToken)
+	if err != nil {
+		return o, nil, err
+	}
+	if c.Sort != o.Sort {
+		return o, nil, fmt.Errorf("%w: page token is for sort order %q, not %q", errInvalidListOptions, c.Sort, o.Sort)
+	}
+	return o, c, nil
+}
+
+func validSortOrder(s SortOrder) bool {
+	for _, o := range sortOrders {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+// yearPrefix returns the prefix of PublishedDate selected by o.Year.
+func (o ListOptions) yearPrefix() string {
+	return fmt.Sprintf("%04d", o.Year)
+}
+
+// matches reports whether b passes the filters of o. It is used by backends
+// that can't filter in their query language.
+func (o ListOptions) matches(b *Book) bool {
+	if o.Author != "" && b.Author != o.Author {
+		return false
+	}
+	if o.CreatedByID != "" && b.CreatedByID != o.CreatedByID {
+		return false
+	}
+	if o.Year != 0 && !strings.HasPrefix(b.PublishedDate, o.yearPrefix()) {
+		return false
+	}
+	if o.Query != "" {
+		q := strings.ToLower(o.Query)
+		if !strings.Contains(strings.ToLower(b.Title), q) && !strings.Contains(strings.ToLower(b.Description), q) {
+			return false
+		}
+	}
+	return true
+}
+
+// pageCursor is the position after which the next page starts: the sort key
+// and ID of the last book of the previous page.
+type pageCursor struct {
+	Sort SortOrder `json:"s"`
+	Key  string    `json:"k"`
+	ID   string    `json:"i"`
+}
+
+// newPageCursor returns the cursor for continuing after b in the order s.
+func newPageCursor(s SortOrder, b *Book) *pageCursor {
+	return &pageCursor{Sort: s, Key: s.key(b), ID: b.ID}
+}
+
+// encode returns the opaque page token for c.
+func (c *pageCursor) encode() string {
+	j, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(j)
+}
+
+func decodePageCursor(token string) (*pageCursor, error) {
+	j, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed page token", errInvalidListOptions)
+	}
+	c := &pageCursor{}
+	if err := json.Unmarshal(j, c); err != nil || c.ID == "" {
+		return nil, fmt.Errorf("%w: malformed page token", errInvalidListOptions)
+	}
+	return c, nil
+}
+
+// after reports whether b comes after c in the order c.Sort.
+func (c *pageCursor) after(b *Book) bool {
+	return compareBooks(c.Sort, c.Key, c.ID, c.Sort.key(b), b.ID) < 0
+}
+
+// compareBooks compares the books with the given sort keys and IDs in the
+// order s, returning -1 if the first comes first, and so on.
+func compareBooks(s SortOrder, key1, id1, key2, id2 string) int {
+	c := strings.Compare(key1, key2)
+	if c == 0 {
+		c = compareIDs(id1, id2)
+	}
+	if s.Desc() {
+		c = -c
+	}
+	return c
+}
+
+// compareIDs orders numeric IDs, as assigned by memoryDB, by value and other
+// IDs as strings.
+func compareIDs(a, b string) int {
+	an, aErr := strconv.ParseInt(a, 10, 64)
+	bn, bErr := strconv.ParseInt(b, 10, 64)
+	if aErr != nil || bErr != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case an < bn:
+		return -1
+	case an > bn:
+		return 1
+	}
+	return 0
+}
+
+// newBookPage returns the page for books, which holds up to one more book
+// than the page size, so the presence of that extra book means there is a
+// next page.
+func newBookPage(opts ListOptions, books []*Book) *BookPage {
+	p := &BookPage{Books: books}
+	if len(books) > opts.PageSize {
+		p.Books = books[:opts.PageSize]
+		p.NextPageToken = newPageCursor(opts.Sort, p.Books[len(p.Books)-1]).encode()
+	}
+	if p.Books == nil {
+		p.Books = []*Book{}
+	}
+	return p
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestListOptionsFromQuery(t *testing.T) {
+	want := ListOptions{
+		PageSize:  5,
+		PageToken: "tok",
+		Sort:      SortByPublishedDesc,
+		Author:    "homer simpson",
+		Year:      1999,
+		Query:     "doughnuts & beer",
+	}
+	u, err := url.Parse(listURL(want, want.PageToken))
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	if u.Path != "/books" {
+		t.Errorf("listURL path = %q, want /books", u.Path)
+	}
+	got, err := listOptionsFromQuery(u.Query())
+	if err != nil {
+		t.Fatalf("listOptionsFromQuery: %v", err)
+	}
+	if got != want {
+		t.Errorf("listOptionsFromQuery(listURL(%+v)) = %+v", want, got)
+	}
+
+	if got := listURL(ListOptions{}, ""); got != "/books" {
+		t.Errorf("listURL of zero options = %q, want /books", got)
+	}
+	if got := listURL(ListOptions{CreatedByID: "accounts.google.com:1"}, ""); got != "/books?mine=true" {
+		t.Errorf("listURL of my books = %q, want /books?mine=true", got)
+	}
+
+	for _, q := range []string{"page_size=ten", "year=MCMXCIX"} {
+		v, _ := url.ParseQuery(q)
+		if _, err := listOptionsFromQuery(v); !errors.Is(err, errInvalidListOptions) {
+			t.Errorf("listOptionsFromQuery(%q): got err %v, want errInvalidListOptions", q, err)
+		}
+	}
+}
+
+func TestListOptionsNormalize(t *testing.T) {
+	opts, cursor, err := ListOptions{}.normalize()
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if opts.PageSize != defaultPageSize || opts.Sort != SortByTitle || cursor != nil {
+		t.Errorf("normalize() = %+v, %v; want defaults and no cursor", opts, cursor)
+	}
+
+	opts, _, err = ListOptions{PageSize: maxPageSize + 1}.normalize()
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if opts.PageSize != maxPageSize {
+		t.Errorf("normalize() PageSize = %d, want %d", opts.PageSize, maxPageSize)
+	}
+
+	want := newPageCursor(SortByAuthor, &Book{ID: "7", Author: "homer"})
+	_, cursor, err = ListOptions{Sort: SortByAuthor, PageToken: want.encode()}.normalize()
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if *cursor != *want {
+		t.Errorf("normalize() cursor = %+v, want %+v", cursor, want)
+	}
+
+	for _, o := range []ListOptions{
+		{Sort: "title "},
+		{Year: -1},
+		{PageToken: "e30"}, // {}
+		{PageToken: want.encode()},
+	} {
+		if _, _, err := o.normalize(); !errors.Is(err, errInvalidListOptions) {
+			t.Errorf("%+v.normalize(): got err %v, want errInvalidListOptions", o, err)
+		}
+	}
+}
@@ -13,8 +13,8 @@
 // limitations under the License.
 
 // The bookshelf command starts the bookshelf server, a sample app
-// demonstrating several Google Cloud APIs, including App Engine, Firestore, and
-// Cloud Storage.
+// demonstrating several Google Cloud APIs, including App Engine, Firestore,
+// Cloud SQL, Cloud Spanner, and Cloud Storage.
 // See https://cloud.google.com/go/getting-started/tutorial-app.
 package main
 
@@ -25,12 +25,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"runtime/debug"
+	"strconv"
 
 	"cloud.google.com/go/errorreporting"
-	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/storage"
 	"github.com/gofrs/uuid"
 	"github.com/gorilla/handlers"
@@ -56,13 +57,9 @@ func main() {
 
 	ctx := context.Background()
 
-	client, err := firestore.NewClient(ctx, projectID)
+	db, err := newBookDatabase(ctx, projectID)
 	if err != nil {
-		log.Fatalf("firestore.NewClient: %v", err)
-	}
-	db, err := newFirestoreDB(client)
-	if err != nil {
-		log.Fatalf("newFirestoreDB: %v", err)
+		log.Fatalf("newBookDatabase: %v", err)
 	}
 	b, err := NewBookshelf(projectID, db)
 	if err != nil {
@@ -82,6 +79,9 @@ func (b *Bookshelf) registerHandlers() {
 	// See https://www.gorillatoolkit.org/pkg/mux.
 	r := mux.NewRouter()
 
+	// Identify users by their IAP assertion. See auth.go.
+	r.Use(b.authenticate)
+
 	r.Handle("/", http.RedirectHandler("/books", http.StatusFound))
 
 	r.Methods("GET").Path("/books").
@@ -103,20 +103,117 @@ func (b *Bookshelf) registerHandlers() {
 	r.Methods("GET").Path("/logs").Handler(appHandler(b.sendLog))
 	r.Methods("GET").Path("/errors").Handler(appHandler(b.sendError))
 
+	// See api.go.
+	b.registerAPIHandlers(r)
+	// See thumbnails.go.
+	b.registerThumbnailHandlers(r)
+
 	// Delegate all of the HTTP routing and serving to the gorilla/mux router.
 	// Log all requests using the standard Apache format.
 	http.Handle("/", handlers.CombinedLoggingHandler(b.logWriter, r))
 }
 
-// listHandler displays a list with summaries of books in the database.
+// listHandler displays a page of summaries of books in the database,
+// selected and sorted according to the URL's query parameters.
 func (b *Bookshelf) listHandler(w http.ResponseWriter, r *http.Request) *appError {
 	ctx := r.Context()
-	books, err := b.DB.ListBooks(ctx)
+	opts, err := b.listOptionsFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	page, err := b.DB.ListBooks(ctx, opts)
 	if err != nil {
 		return b.appErrorf(r, err, "could not list books: %v", err)
 	}
 
-	return listTmpl.Execute(b, w, r, books)
+	data := struct {
+		*BookPage
+		Options    ListOptions
+		SortOrders []SortOrder
+		FirstURL   string // Empty on the first page.
+		NextURL    string // Empty on the last page.
+	}{
+		BookPage:   page,
+		Options:    opts,
+		SortOrders: sortOrders,
+	}
+	if opts.PageToken != "" {
+		data.FirstURL = listURL(opts, "")
+	}
+	if page.NextPageToken != "" {
+		data.NextURL = listURL(opts, page.NextPageToken)
+	}
+	return listTmpl.Execute(b, w, r, data)
+}
+
+// listOptionsFromRequest reads ListOptions from the query parameters of r.
+// With mine=true, only the books created by the signed-in user are listed.
+func (b *Bookshelf) listOptionsFromRequest(r *http.Request) (ListOptions, error) {
+	q := r.URL.Query()
+	opts, err := listOptionsFromQuery(q)
+	if err != nil {
+		return opts, err
+	}
+	if mine, _ := strconv.ParseBool(q.Get("mine")); mine {
+		u := userFromContext(r.Context())
+		if u == nil {
+			return opts, fmt.Errorf("%w: mine=true lists your books", errUnauthenticated)
+		}
+		opts.CreatedByID = u.ID
+	}
+	return opts, nil
+}
+
+// listOptionsFromQuery reads ListOptions from the query parameters of a
+// /books URL.
+func listOptionsFromQuery(q url.Values) (ListOptions, error) {
+	opts := ListOptions{
+		PageToken: q.Get("page_token"),
+		Sort:      SortOrder(q.Get("sort")),
+		Author:    q.Get("author"),
+		Query:     q.Get("q"),
+	}
+	if v := q.Get("page_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: bad page_size %q", errInvalidListOptions, v)
+		}
+		opts.PageSize = n
+	}
+	if v := q.Get("year"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: bad year %q", errInvalidListOptions, v)
+		}
+		opts.Year = n
+	}
+	return opts, nil
+}
+
+// listURL returns the /books URL listing the books selected by opts, starting
+// at pageToken. A CreatedByID is assumed to be the signed-in user's.
+func listURL(opts ListOptions, pageToken string) string {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("page_token", pageToken)
+	set("sort", string(opts.Sort))
+	set("author", opts.Author)
+	set("q", opts.Query)
+	if opts.CreatedByID != "" {
+		q.Set("mine", "true")
+	}
+	if opts.PageSize != 0 {
+		q.Set("page_size", strconv.Itoa(opts.PageSize))
+	}
+	if opts.Year != 0 {
+		q.Set("year", strconv.Itoa(opts.Year))
+	}
+	u := url.URL{Path: "/books", RawQuery: q.Encode()}
+	return u.String()
 }
 
 // bookFromRequest retrieves a book from the database given a book ID in the
@@ -141,12 +238,22 @@ func (b *Bookshelf) detailHandler(w http.ResponseWriter, r *http.Request) *appEr
 		return b.appErrorf(r, err, "%v", err)
 	}
 
-	return detailTmpl.Execute(b, w, r, book)
+	data := struct {
+		*Book
+		CanChange bool // Whether to offer to edit and delete the book.
+	}{
+		Book:      book,
+		CanChange: b.canChange(r, book) == nil,
+	}
+	return detailTmpl.Execute(b, w, r, data)
 }
 
 // addFormHandler displays a form that captures details of a new book to add to
 // the database.
 func (b *Bookshelf) addFormHandler(w http.ResponseWriter, r *http.Request) *appError {
+	if _, err := b.requireUser(r); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
 	return editTmpl.Execute(b, w, r, nil)
 }
 
@@ -157,6 +264,9 @@ func (b *Bookshelf) editFormHandler(w http.ResponseWriter, r *http.Request) *app
 	if err != nil {
 		return b.appErrorf(r, err, "%v", err)
 	}
+	if err := b.canChange(r, book); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
 
 	return editTmpl.Execute(b, w, r, book)
 }
@@ -196,6 +306,11 @@ func (b *Bookshelf) uploadFileFromForm(ctx context.Context, r *http.Request) (ur
 		return "", err
 	}
 
+	if b.thumbnails != nil {
+		// Store the file privately until it is processed. See thumbnails.go.
+		return b.thumbnails.stage(ctx, f, fh.Filename, fh.Header.Get("Content-Type"))
+	}
+
 	if b.StorageBucket == nil {
 		return "", errors.New("storage bucket is missing: check bookshelf.go")
 	}
@@ -231,48 +346,78 @@ func (b *Bookshelf) uploadFileFromForm(ctx context.Context, r *http.Request) (ur
 
 // [END getting_started_bookshelf_storage]
 
-// createHandler adds a book to the database.
+// createHandler adds a book to the database, created by the signed-in user.
 func (b *Bookshelf) createHandler(w http.ResponseWriter, r *http.Request) *appError {
 	ctx := r.Context()
+	u, err := b.requireUser(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
 	book, err := b.bookFromForm(r)
 	if err != nil {
 		return b.appErrorf(r, err, "could not parse book from form: %v", err)
 	}
+	setCreator(book, u)
+	process := b.prepareImage(book, nil)
 	id, err := b.DB.AddBook(ctx, book)
 	if err != nil {
 		return b.appErrorf(r, err, "could not save book: %v", err)
 	}
+	if process {
+		if err := b.queueThumbnails(ctx, book); err != nil {
+			return b.appErrorf(r, err, "%v", err)
+		}
+	}
 	http.Redirect(w, r, fmt.Sprintf("/books/%s", id), http.StatusFound)
 	return nil
 }
 
-// updateHandler updates the details of a given book.
+// updateHandler updates the details of a given book, if the signed-in user
+// can change it.
 func (b *Bookshelf) updateHandler(w http.ResponseWriter, r *http.Request) *appError {
 	ctx := r.Context()
-	id := mux.Vars(r)["id"]
-	if id == "" {
-		return b.appErrorf(r, errors.New("no book with empty ID"), "no book with empty ID")
+	cur, err := b.bookFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.canChange(r, cur); err != nil {
+		return b.appErrorf(r, err, "%v", err)
 	}
 	book, err := b.bookFromForm(r)
 	if err != nil {
 		return b.appErrorf(r, err, "could not parse book from form: %v", err)
 	}
-	book.ID = id
+	book.ID = cur.ID
+	book.CreatedBy, book.CreatedByID = cur.CreatedBy, cur.CreatedByID
+	process := b.prepareImage(book, cur)
 
-	if err := b.DB.UpdateBook(ctx, book); err != nil {
+	if err := b.DB.UpdateBook(ctx, book, ""); err != nil {
 		return b.appErrorf(r, err, "UpdateBook: %v", err)
 	}
+	b.discardPendingImage(ctx, cur, book)
+	if process {
+		if err := b.queueThumbnails(ctx, book); err != nil {
+			return b.appErrorf(r, err, "%v", err)
+		}
+	}
 	http.Redirect(w, r, fmt.Sprintf("/books/%s", book.ID), http.StatusFound)
 	return nil
 }
 
-// deleteHandler deletes a given book.
+// deleteHandler deletes a given book, if the signed-in user can change it.
 func (b *Bookshelf) deleteHandler(w http.ResponseWriter, r *http.Request) *appError {
 	ctx := r.Context()
-	id := mux.Vars(r)["id"]
-	if err := b.DB.DeleteBook(ctx, id); err != nil {
+	book, err := b.bookFromRequest(r)
+	if err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.canChange(r, book); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.DB.DeleteBook(ctx, book.ID); err != nil {
 		return b.appErrorf(r, err, "DeleteBook: %v", err)
 	}
+	b.discardPendingImage(ctx, book, nil)
 	http.Redirect(w, r, "/books", http.StatusFound)
 	return nil
 }
@@ -311,26 +456,65 @@ type appError struct {
 
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if e := fn(w, r); e != nil { // e is *appError, not os.Error.
-		fmt.Fprintf(e.b.logWriter, "Handler error (reported to Error Reporting): status code: %d, message: %s, underlying err: %+v\n", e.code, e.message, e.err)
+		e.report()
 		w.WriteHeader(e.code)
 		fmt.Fprint(w, e.message)
+	}
+}
 
-		e.b.errorClient.Report(errorreporting.Entry{
-			Error: e.err,
-			Req:   r,
-			Stack: e.stack,
-		})
-		e.b.errorClient.Flush()
+// report logs e and, for server errors, reports it to Error Reporting.
+// Client errors (4xx) are the caller's fault, so they are only logged.
+func (e *appError) report() {
+	if e.code < 500 {
+		fmt.Fprintf(e.b.logWriter, "Handler error: status code: %d, message: %s, underlying err: %v\n", e.code, e.message, e.err)
+		return
 	}
+	fmt.Fprintf(e.b.logWriter, "Handler error (reported to Error Reporting): status code: %d, message: %s, underlying err: %+v\n", e.code, e.message, e.err)
+	if e.b.errorClient == nil {
+		return
+	}
+	e.b.errorClient.Report(errorreporting.Entry{
+		Error: e.err,
+		Req:   e.req,
+		Stack: e.stack,
+	})
+	e.b.errorClient.Flush()
 }
 
+// appErrorf returns an appError with a status code that depends on err: 404
+// if it wraps errBookNotFound, 400 for invalid requests, and so on, or 500
+// otherwise.
 func (b *Bookshelf) appErrorf(r *http.Request, err error, format string, v ...interface{}) *appError {
+	return b.appErrorCode(r, err, statusCode(err), format, v...)
+}
+
+// appErrorCode returns an appError with the given HTTP status code.
+func (b *Bookshelf) appErrorCode(r *http.Request, err error, code int, format string, v ...interface{}) *appError {
 	return &appError{
 		err:     err,
 		message: fmt.Sprintf(format, v...),
-		code:    500,
+		code:    code,
 		req:     r,
 		b:       b,
 		stack:   debug.Stack(),
 	}
 }
+
+// statusCode returns the HTTP status code for a handler that failed with err.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, errBookNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errInvalidListOptions), errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, errPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, errUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, errForbidden):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
@@ -42,8 +42,10 @@ func TestMain(m *testing.M) {
 
 	generalProjectID := os.Getenv("GOLANG_SAMPLES_PROJECT_ID")
 	if generalProjectID == "" {
-		log.Println("GOLANG_SAMPLES_PROJECT_ID not set. Skipping.")
-		return
+		// The database tests don't need a project; the handler tests skip
+		// themselves when b is nil.
+		log.Println("GOLANG_SAMPLES_PROJECT_ID not set. Skipping handler tests.")
+		os.Exit(m.Run())
 	}
 	projectID := generalProjectID
 
@@ -98,6 +100,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestNoBooks(t *testing.T) {
+	requireBookshelf(t)
 	for name, db := range testDBs {
 		t.Run(name, func(t *testing.T) {
 			b.DB = db
@@ -107,6 +110,7 @@ func TestNoBooks(t *testing.T) {
 }
 
 func TestBookDetail(t *testing.T) {
+	requireBookshelf(t)
 	for name, db := range testDBs {
 		t.Run(name, func(t *testing.T) {
 			b.DB = db
@@ -135,6 +139,7 @@ func TestBookDetail(t *testing.T) {
 }
 
 func TestEditBook(t *testing.T) {
+	requireBookshelf(t)
 	for name, db := range testDBs {
 		t.Run(name, func(t *testing.T) {
 			b.DB = db
@@ -177,6 +182,7 @@ func TestEditBook(t *testing.T) {
 }
 
 func TestAddAndDelete(t *testing.T) {
+	requireBookshelf(t)
 	for name, db := range testDBs {
 		t.Run(name, func(t *testing.T) {
 			b.DB = db
@@ -212,6 +218,7 @@ func TestAddAndDelete(t *testing.T) {
 }
 
 func TestSendLog(t *testing.T) {
+	requireBookshelf(t)
 	buf := &bytes.Buffer{}
 	oldLogger := b.logWriter
 	b.logWriter = buf
@@ -226,6 +233,7 @@ func TestSendLog(t *testing.T) {
 }
 
 func TestSendError(t *testing.T) {
+	requireBookshelf(t)
 	buf := &bytes.Buffer{}
 	oldLogger := b.logWriter
 	b.logWriter = buf
@@ -239,6 +247,14 @@ func TestSendError(t *testing.T) {
 	}
 }
 
+// requireBookshelf skips the test if TestMain did not create a Bookshelf.
+func requireBookshelf(t *testing.T) {
+	t.Helper()
+	if b == nil {
+		t.Skip("GOLANG_SAMPLES_PROJECT_ID not set")
+	}
+}
+
 func bodyContains(t *testing.T, wt *webtest.W, path, contains string) (ok bool) {
 	t.Helper()
 
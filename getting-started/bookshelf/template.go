@@ -46,8 +46,10 @@ type appTemplate struct {
 func (tmpl *appTemplate) Execute(b *Bookshelf, w http.ResponseWriter, r *http.Request, data interface{}) *appError {
 	d := struct {
 		Data interface{}
+		User *User // The signed-in user, or nil.
 	}{
 		Data: data,
+		User: userFromContext(r.Context()),
 	}
 
 	if err := tmpl.t.Execute(w, d); err != nil {
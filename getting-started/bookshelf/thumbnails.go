@@ -0,0 +1,515 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"google.golang.org/api/idtoken"
+)
+
+// Cover images can be processed asynchronously by a thumbnail pipeline,
+// instead of linking books to the uploaded image as is:
+//
+//  1. Uploaded images are stored privately under uploadsPrefix, and the book
+//     is saved with ImageStatus imagePending.
+//  2. A thumbnailJob is queued, usually on a Pub/Sub topic whose push
+//     subscription sends it back to thumbnailPushHandler.
+//  3. The job checks the image really is one, then uses ImageMagick, as in
+//     run/image-processing/imagemagick, to resize it to thumbnailSizes and
+//     strip its metadata, such as EXIF location data.
+//  4. The book is updated to link to the public resized images, and the
+//     original is deleted.
+//
+// An upload is only ever deleted as the pending image of its own book: once
+// it is processed or rejected, or when the book is changed to another image
+// or deleted. Jobs for other books, or for books that don't exist, are
+// ignored, so a job can't delete someone else's upload.
+
+// Values of Book.ImageStatus.
+const (
+	imagePending = "pending" // The image is waiting to be processed.
+	imageReady   = "ready"   // ImageURL and ThumbnailURL are the processed images.
+	imageFailed  = "failed"  // The upload was not a valid image.
+)
+
+const (
+	// uploadsPrefix is the prefix of the private objects that uploaded images
+	// are stored as until they are processed.
+	uploadsPrefix = "uploads/"
+
+	// imagesPrefix is the prefix of the public, processed images.
+	imagesPrefix = "images/"
+)
+
+// thumbnailSize is a size images are resized to fit in, keeping their aspect
+// ratio. Images are never enlarged.
+type thumbnailSize struct {
+	Name          string
+	Width, Height int
+}
+
+// thumbnailSizes are the sizes images are resized to. The "thumb" image is
+// the book's ThumbnailURL, and the "large" one its ImageURL.
+var thumbnailSizes = []thumbnailSize{
+	{Name: "thumb", Width: 200, Height: 300},
+	{Name: "large", Width: 800, Height: 1200},
+}
+
+// imageFormats maps the content types of the images that can be processed,
+// as detected by http.DetectContentType, to their ImageMagick format and file
+// extension.
+var imageFormats = map[string]struct{ format, ext string }{
+	"image/jpeg": {"jpeg", ".jpg"},
+	"image/png":  {"png", ".png"},
+	"image/gif":  {"gif", ".gif"},
+	"image/webp": {"webp", ".webp"},
+}
+
+// imageStore reads and writes the objects of a bucket. It is implemented by
+// gcsImageStore, and by a fake in tests.
+type imageStore interface {
+	// newReader opens an object. It returns an error wrapping
+	// storage.ErrObjectNotExist if there is no such object.
+	newReader(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// newWriter creates or replaces an object, which can be read by anyone
+	// if public is true. The object is written when the writer is closed.
+	newWriter(ctx context.Context, name, contentType string, public bool) io.WriteCloser
+
+	// delete deletes an object.
+	delete(ctx context.Context, name string) error
+}
+
+// gcsImageStore is an imageStore for a Cloud Storage bucket.
+type gcsImageStore struct {
+	bucket *storage.BucketHandle
+}
+
+func (s gcsImageStore) newReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	return s.bucket.Object(name).NewReader(ctx)
+}
+
+func (s gcsImageStore) newWriter(ctx context.Context, name, contentType string, public bool) io.WriteCloser {
+	w := s.bucket.Object(name).NewWriter(ctx)
+	w.ContentType = contentType
+	if public {
+		// Warning: storage.AllUsers gives public read access to anyone.
+		w.ACL = []storage.ACLRule{{Entity: storage.AllUsers, Role: storage.RoleReader}}
+		// Processed images are immutable, be aggressive about caching (1 day).
+		w.CacheControl = "public, max-age=86400"
+	} else {
+		// Override a public default object ACL on the bucket.
+		w.PredefinedACL = "projectPrivate"
+	}
+	return w
+}
+
+func (s gcsImageStore) delete(ctx context.Context, name string) error {
+	return s.bucket.Object(name).Delete(ctx)
+}
+
+// resizeFunc reads an image from src and writes it to dst in the given
+// ImageMagick format, resized to fit size, without metadata.
+type resizeFunc func(ctx context.Context, src io.Reader, dst io.Writer, format string, size thumbnailSize) error
+
+// imagemagickResize is a resizeFunc running ImageMagick's convert command,
+// which must be installed.
+func imagemagickResize(ctx context.Context, src io.Reader, dst io.Writer, format string, size thumbnailSize) error {
+	// Use - as input and output to use stdin and stdout. [0] only reads the
+	// first frame of animated images. -auto-orient applies the EXIF
+	// orientation before -strip removes it along with all other metadata.
+	// The > flag only shrinks larger images.
+	cmd := exec.CommandContext(ctx, "convert", "-[0]",
+		"-auto-orient", "-strip",
+		"-thumbnail", fmt.Sprintf("%dx%d>", size.Width, size.Height),
+		format+":-")
+	cmd.Stdin = src
+	cmd.Stdout = dst
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("convert: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return nil
+}
+
+// thumbnailJob asks for the uploaded image Object to be processed for the
+// book with ID BookID. It is the data of the Pub/Sub messages.
+type thumbnailJob struct {
+	BookID string `json:"bookID"`
+	Object string `json:"object"`
+}
+
+// thumbnailQueue queues thumbnailJobs for processing.
+type thumbnailQueue interface {
+	enqueue(ctx context.Context, job thumbnailJob) error
+}
+
+// localQueue processes jobs in the background in this process. It is meant
+// for trying the pipeline locally: jobs are lost if the process exits, and
+// failed jobs are not retried.
+type localQueue struct {
+	t *thumbnailer
+}
+
+func (q localQueue) enqueue(_ context.Context, job thumbnailJob) error {
+	go func() {
+		if err := q.t.process(context.Background(), job); err != nil {
+			log.Printf("thumbnails: could not process %+v: %v", job, err)
+		}
+	}()
+	return nil
+}
+
+// thumbnailer stages uploaded images and processes them.
+type thumbnailer struct {
+	db         BookDatabase
+	store      imageStore
+	bucketName string
+	resize     resizeFunc
+	queue      thumbnailQueue
+	// push authenticates the requests of the Pub/Sub push subscription. The
+	// push endpoint is only registered if it is set.
+	push *pushAuth
+}
+
+// pushAuth checks that a push request comes from Pub/Sub, which sends an
+// OIDC token of the subscription's service account for the audience the
+// subscription is configured with.
+// See https://cloud.google.com/pubsub/docs/authenticate-push-subscriptions.
+type pushAuth struct {
+	audience       string
+	serviceAccount string
+	// validate validates an ID token for audience. It is idtoken.Validate,
+	// except in tests.
+	validate func(ctx context.Context, token, audience string) (*idtoken.Payload, error)
+}
+
+// check returns an error wrapping errUnauthenticated unless r has a valid
+// token of the expected service account.
+func (a *pushAuth) check(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return fmt.Errorf("%w: no bearer token", errUnauthenticated)
+	}
+	payload, err := a.validate(r.Context(), token, a.audience)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnauthenticated, err)
+	}
+	email, _ := payload.Claims["email"].(string)
+	verified, _ := payload.Claims["email_verified"].(bool)
+	if email != a.serviceAccount || !verified {
+		return fmt.Errorf("%w: token of %q, want %q", errUnauthenticated, email, a.serviceAccount)
+	}
+	return nil
+}
+
+// newThumbnailerFromEnv returns the thumbnailer configured by the
+// environment, or nil if the pipeline is off. BOOKSHELF_THUMBNAILS selects
+// the queue:
+//
+//   - "" (the default) turns the pipeline off: books link to the uploaded
+//     images as is.
+//   - "pubsub" publishes jobs to the Pub/Sub topic BOOKSHELF_THUMBNAILS_TOPIC
+//     (default "bookshelf-thumbnails"). Create a push subscription sending
+//     them to the /internal/thumbnails path of the app, with authentication
+//     on. BOOKSHELF_THUMBNAILS_PUSH_SERVICE_ACCOUNT is the email of the
+//     subscription's service account, and BOOKSHELF_THUMBNAILS_PUSH_AUDIENCE
+//     the audience of its tokens. Other requests to the path are rejected.
+//   - "local" processes jobs in the background in this process.
+//
+// ImageMagick must be installed either way.
+func newThumbnailerFromEnv(ctx context.Context, projectID string, db BookDatabase, bucket *storage.BucketHandle, bucketName string) (*thumbnailer, error) {
+	t := &thumbnailer{
+		db:         db,
+		store:      gcsImageStore{bucket: bucket},
+		bucketName: bucketName,
+		resize:     imagemagickResize,
+	}
+	switch mode := os.Getenv("BOOKSHELF_THUMBNAILS"); mode {
+	case "":
+		return nil, nil
+	case "pubsub":
+		topicID := os.Getenv("BOOKSHELF_THUMBNAILS_TOPIC")
+		if topicID == "" {
+			topicID = "bookshelf-thumbnails"
+		}
+		t.push = &pushAuth{
+			audience:       os.Getenv("BOOKSHELF_THUMBNAILS_PUSH_AUDIENCE"),
+			serviceAccount: os.Getenv("BOOKSHELF_THUMBNAILS_PUSH_SERVICE_ACCOUNT"),
+			validate:       idtoken.Validate,
+		}
+		if t.push.audience == "" || t.push.serviceAccount == "" {
+			return nil, errors.New("BOOKSHELF_THUMBNAILS_PUSH_AUDIENCE and BOOKSHELF_THUMBNAILS_PUSH_SERVICE_ACCOUNT must be set with BOOKSHELF_THUMBNAILS=pubsub")
+		}
+		q, err := newPubSubQueue(ctx, projectID, topicID)
+		if err != nil {
+			return nil, err
+		}
+		t.queue = q
+	case "local":
+		t.queue = localQueue{t: t}
+	default:
+		return nil, fmt.Errorf("unknown BOOKSHELF_THUMBNAILS %q: want pubsub or local", mode)
+	}
+	return t, nil
+}
+
+// objectURL returns the URL of the object with the given name.
+func (t *thumbnailer) objectURL(name string) string {
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", t.bucketName, name)
+}
+
+// uploadObject returns the name of the uploaded image that imageURL links
+// to, or "" if it doesn't link to one.
+func (t *thumbnailer) uploadObject(imageURL string) string {
+	name := strings.TrimPrefix(imageURL, t.objectURL(""))
+	if name == imageURL || !strings.HasPrefix(name, uploadsPrefix) || strings.Contains(name, "..") {
+		return ""
+	}
+	return name
+}
+
+// newUploadName returns a random name for an uploaded image, retaining the
+// extension of filename.
+func newUploadName(filename string) string {
+	return uploadsPrefix + uuid.Must(uuid.NewV4()).String() + path.Ext(filename)
+}
+
+// stage stores an uploaded image privately, and returns its URL to use as
+// the book's ImageURL until it is processed.
+func (t *thumbnailer) stage(ctx context.Context, r io.Reader, filename, contentType string) (string, error) {
+	name := newUploadName(filename)
+	w := t.store.newWriter(ctx, name, contentType, false)
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return t.objectURL(name), nil
+}
+
+// prepareImage sets the image fields of book, which is replacing cur (nil for
+// a new book), and reports whether the book's image needs to be processed
+// once the book is saved.
+func (b *Bookshelf) prepareImage(book, cur *Book) bool {
+	if cur != nil && book.ImageURL == cur.ImageURL {
+		book.ThumbnailURL, book.ImageStatus = cur.ThumbnailURL, cur.ImageStatus
+		return false
+	}
+	book.ThumbnailURL, book.ImageStatus = "", ""
+	if b.thumbnails == nil || b.thumbnails.uploadObject(book.ImageURL) == "" {
+		return false
+	}
+	book.ImageStatus = imagePending
+	return true
+}
+
+// discardPendingImage deletes the pending upload of cur, a book that was just
+// deleted (book is nil) or updated to book, if the book no longer links to
+// it. Failures are only logged: the upload is private and unused either way.
+func (b *Bookshelf) discardPendingImage(ctx context.Context, cur, book *Book) {
+	if b.thumbnails == nil || cur.ImageStatus != imagePending {
+		return
+	}
+	if book != nil && book.ImageURL == cur.ImageURL {
+		return
+	}
+	name := b.thumbnails.uploadObject(cur.ImageURL)
+	if name == "" {
+		return
+	}
+	if err := b.thumbnails.deleteUpload(ctx, name); err != nil {
+		log.Printf("thumbnails: %v", err)
+	}
+}
+
+// queueThumbnails queues the processing of the image of a saved book, for
+// which prepareImage returned true.
+func (b *Bookshelf) queueThumbnails(ctx context.Context, book *Book) error {
+	job := thumbnailJob{BookID: book.ID, Object: b.thumbnails.uploadObject(book.ImageURL)}
+	if err := b.thumbnails.queue.enqueue(ctx, job); err != nil {
+		return fmt.Errorf("could not queue image processing: %w", err)
+	}
+	return nil
+}
+
+// process processes the image of a job. It returns an error if the job
+// should be retried, and nil once it is done, including when the image is
+// not valid: the book's ImageStatus is then imageFailed.
+func (t *thumbnailer) process(ctx context.Context, job thumbnailJob) error {
+	book, err := t.db.GetBook(ctx, job.BookID)
+	if errors.Is(err, errBookNotFound) {
+		// The book's upload was deleted along with it.
+		log.Printf("thumbnails: book %q does not exist, ignoring %s", job.BookID, job.Object)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if book.ImageStatus != imagePending || book.ImageURL != t.objectURL(job.Object) {
+		// The job was already done, the book's image has been changed
+		// since, or the object isn't the book's image at all. Either way,
+		// the object is not this book's to process or delete.
+		log.Printf("thumbnails: %s is not the pending image of book %q, ignoring it", job.Object, job.BookID)
+		return nil
+	}
+
+	data, err := t.readUpload(ctx, job.Object)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return t.fail(ctx, book, job, "the uploaded image does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) > maxImageBytes {
+		return t.fail(ctx, book, job, fmt.Sprintf("the image is larger than %d bytes", maxImageBytes))
+	}
+	// Don't trust the content type the image was uploaded with.
+	contentType := http.DetectContentType(data)
+	f, ok := imageFormats[contentType]
+	if !ok {
+		return t.fail(ctx, book, job, fmt.Sprintf("%s is not a supported image type", contentType))
+	}
+
+	base := strings.TrimSuffix(path.Base(job.Object), path.Ext(job.Object))
+	urls := map[string]string{}
+	for _, size := range thumbnailSizes {
+		var buf bytes.Buffer
+		if err := t.resize(ctx, bytes.NewReader(data), &buf, f.format, size); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			// ImageMagick couldn't read the image, so it's corrupt.
+			return t.fail(ctx, book, job, err.Error())
+		}
+		name := imagesPrefix + base + "-" + size.Name + f.ext
+		w := t.store.newWriter(ctx, name, contentType, true)
+		if _, err := io.Copy(w, &buf); err != nil {
+			w.Close()
+			return fmt.Errorf("could not write %s: %w", name, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("could not write %s: %w", name, err)
+		}
+		urls[size.Name] = t.objectURL(name)
+	}
+
+	updated := *book
+	updated.ImageURL = urls["large"]
+	updated.ThumbnailURL = urls["thumb"]
+	updated.ImageStatus = imageReady
+	// If the book changed while the image was processed, the job is retried
+	// and checks it again.
+	if err := t.db.UpdateBook(ctx, &updated, book.ETag()); err != nil {
+		return fmt.Errorf("could not update book: %w", err)
+	}
+	log.Printf("thumbnails: processed %s for book %q", job.Object, job.BookID)
+	return t.deleteUpload(ctx, job.Object)
+}
+
+// readUpload reads an uploaded image, up to one byte more than
+// maxImageBytes.
+func (t *thumbnailer) readUpload(ctx context.Context, name string) ([]byte, error) {
+	r, err := t.store.newReader(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(io.LimitReader(r, maxImageBytes+1))
+}
+
+// fail marks the image of book as failed, because of reason, and deletes it.
+func (t *thumbnailer) fail(ctx context.Context, book *Book, job thumbnailJob, reason string) error {
+	log.Printf("thumbnails: rejecting %s for book %q: %s", job.Object, job.BookID, reason)
+	updated := *book
+	updated.ImageURL = ""
+	updated.ThumbnailURL = ""
+	updated.ImageStatus = imageFailed
+	if err := t.db.UpdateBook(ctx, &updated, book.ETag()); err != nil {
+		return fmt.Errorf("could not update book: %w", err)
+	}
+	return t.deleteUpload(ctx, job.Object)
+}
+
+// deleteUpload deletes an uploaded image, if it still exists.
+func (t *thumbnailer) deleteUpload(ctx context.Context, name string) error {
+	if err := t.store.delete(ctx, name); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("could not delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// registerThumbnailHandlers registers the push endpoint of the thumbnail
+// pipeline on r, if the pipeline uses Pub/Sub. The endpoint only accepts
+// requests authenticated as the push subscription.
+func (b *Bookshelf) registerThumbnailHandlers(r *mux.Router) {
+	if b.thumbnails == nil || b.thumbnails.push == nil {
+		return
+	}
+	r.Methods("POST").Path("/internal/thumbnails").
+		Handler(appHandler(b.thumbnailPushHandler))
+}
+
+// pubSubMessage is the payload of a Pub/Sub push request.
+// See https://cloud.google.com/pubsub/docs/push.
+type pubSubMessage struct {
+	Message struct {
+		Data []byte `json:"data,omitempty"`
+		ID   string `json:"id"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// thumbnailPushHandler processes a thumbnailJob pushed by Pub/Sub. It
+// responds with an error status for Pub/Sub to retry the job later.
+func (b *Bookshelf) thumbnailPushHandler(w http.ResponseWriter, r *http.Request) *appError {
+	if err := b.thumbnails.push.check(r); err != nil {
+		return b.appErrorf(r, err, "%v", err)
+	}
+	var m pubSubMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&m); err != nil {
+		err = fmt.Errorf("%w: could not decode Pub/Sub message: %v", errInvalidRequest, err)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	var job thumbnailJob
+	if err := json.Unmarshal(m.Message.Data, &job); err != nil || job.BookID == "" || !strings.HasPrefix(job.Object, uploadsPrefix) {
+		err := fmt.Errorf("%w: message %q is not a thumbnail job", errInvalidRequest, m.Message.ID)
+		return b.appErrorf(r, err, "%v", err)
+	}
+	if err := b.thumbnails.process(r.Context(), job); err != nil {
+		err = fmt.Errorf("could not process %+v: %w", job, err)
+		return b.appErrorCode(r, err, http.StatusInternalServerError, "%v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
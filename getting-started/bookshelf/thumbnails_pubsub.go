@@ -0,0 +1,49 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// pubsubQueue publishes thumbnailJobs to a Pub/Sub topic. A push
+// subscription delivers them to thumbnailPushHandler, retrying jobs that
+// fail.
+type pubsubQueue struct {
+	topic *pubsub.Topic
+}
+
+func newPubSubQueue(ctx context.Context, projectID, topicID string) (*pubsubQueue, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub.NewClient: %w", err)
+	}
+	return &pubsubQueue{topic: client.Topic(topicID)}, nil
+}
+
+func (q *pubsubQueue) enqueue(ctx context.Context, job thumbnailJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	if _, err := q.topic.Publish(ctx, &pubsub.Message{Data: data}).Get(ctx); err != nil {
+		return fmt.Errorf("Publish: %w", err)
+	}
+	return nil
+}
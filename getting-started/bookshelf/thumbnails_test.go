@@ -0,0 +1,467 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/gorilla/mux"
+	"google.golang.org/api/idtoken"
+)
+
+// fakeObject is an object in a fakeStore.
+type fakeObject struct {
+	data        []byte
+	contentType string
+	public      bool
+}
+
+// fakeStore is an in-memory imageStore, standing in for a bucket.
+type fakeStore struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{objects: map[string]fakeObject{}}
+}
+
+func (s *fakeStore) get(name string) (fakeObject, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[name]
+	return o, ok
+}
+
+func (s *fakeStore) put(name string, o fakeObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[name] = o
+}
+
+func (s *fakeStore) newReader(_ context.Context, name string) (io.ReadCloser, error) {
+	o, ok := s.get(name)
+	if !ok {
+		return nil, fmt.Errorf("fake: %s: %w", name, storage.ErrObjectNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(o.data)), nil
+}
+
+// fakeWriter stores its object when closed.
+type fakeWriter struct {
+	bytes.Buffer
+	close func([]byte)
+}
+
+func (w *fakeWriter) Close() error {
+	w.close(w.Bytes())
+	return nil
+}
+
+func (s *fakeStore) newWriter(_ context.Context, name, contentType string, public bool) io.WriteCloser {
+	return &fakeWriter{close: func(data []byte) {
+		s.put(name, fakeObject{data: data, contentType: contentType, public: public})
+	}}
+}
+
+func (s *fakeStore) delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[name]; !ok {
+		return fmt.Errorf("fake: %s: %w", name, storage.ErrObjectNotExist)
+	}
+	delete(s.objects, name)
+	return nil
+}
+
+// fakeResize is a resizeFunc that doesn't need ImageMagick. It describes the
+// resized image instead of resizing it.
+func fakeResize(_ context.Context, src io.Reader, dst io.Writer, format string, size thumbnailSize) error {
+	if _, _, err := image.DecodeConfig(src); err != nil {
+		return fmt.Errorf("fake convert: %w", err)
+	}
+	_, err := fmt.Fprintf(dst, "%s %dx%d", format, size.Width, size.Height)
+	return err
+}
+
+// recordQueue is a thumbnailQueue recording the jobs queued.
+type recordQueue struct {
+	mu   sync.Mutex
+	jobs []thumbnailJob
+}
+
+func (q *recordQueue) enqueue(_ context.Context, job thumbnailJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func (q *recordQueue) take() []thumbnailJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := q.jobs
+	q.jobs = nil
+	return jobs
+}
+
+const (
+	pushAudience       = "https://bookshelf.example.com/internal/thumbnails"
+	pushServiceAccount = "pubsub-push@my-project.iam.gserviceaccount.com"
+)
+
+// fakeValidate is an idtoken.Validate accepting tokens of the form
+// "audience|email", for an account with a verified email.
+func fakeValidate(_ context.Context, token, audience string) (*idtoken.Payload, error) {
+	aud, email, ok := strings.Cut(token, "|")
+	if !ok || aud != audience {
+		return nil, fmt.Errorf("fake: invalid token %q for audience %q", token, audience)
+	}
+	return &idtoken.Payload{
+		Audience: aud,
+		Claims:   map[string]interface{}{"email": email, "email_verified": true},
+	}, nil
+}
+
+// newThumbnailServer returns a server for the JSON API and the thumbnail
+// push endpoint, with the pipeline using a fake bucket and ImageMagick.
+func newThumbnailServer(t *testing.T) (*httptest.Server, *fakeStore, *recordQueue) {
+	t.Helper()
+	db := newMemoryDB()
+	store := newFakeStore()
+	queue := &recordQueue{}
+	shelf := &Bookshelf{
+		DB:                db,
+		StorageBucketName: "my-bucket",
+		logWriter:         io.Discard,
+		signedURL: func(object string, opts *storage.SignedURLOptions) (string, error) {
+			return "https://storage.googleapis.com/my-bucket/" + object + "?X-Goog-Signature=fake", nil
+		},
+		thumbnails: &thumbnailer{
+			db:         db,
+			store:      store,
+			bucketName: "my-bucket",
+			resize:     fakeResize,
+			queue:      queue,
+			push: &pushAuth{
+				audience:       pushAudience,
+				serviceAccount: pushServiceAccount,
+				validate:       fakeValidate,
+			},
+		},
+	}
+	r := mux.NewRouter()
+	shelf.registerAPIHandlers(r)
+	shelf.registerThumbnailHandlers(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, store, queue
+}
+
+// pngImage returns an encoded PNG image.
+func pngImage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 60))); err != nil {
+		t.Fatalf("png.Encode: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// uploadImage uploads data the way an API client would, returning the URL
+// to use as a book's imageURL.
+func uploadImage(t *testing.T, srv *httptest.Server, store *fakeStore, data []byte) string {
+	t.Helper()
+	var up uploadResponse
+	resp := apiDo(t, srv, "POST", "/api/v1/uploads", `{"filename": "cover.png", "contentType": "image/png"}`, nil, &up)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("upload: status %d", resp.StatusCode)
+	}
+	if up.Headers["x-goog-acl"] != "private" {
+		t.Errorf("upload: x-goog-acl = %q, want private", up.Headers["x-goog-acl"])
+	}
+	name := strings.TrimPrefix(up.ImageURL, "https://storage.googleapis.com/my-bucket/")
+	if !strings.HasPrefix(name, uploadsPrefix) {
+		t.Fatalf("upload: imageURL %q is not under %s", up.ImageURL, uploadsPrefix)
+	}
+	store.put(name, fakeObject{data: data, contentType: "image/png"})
+	return up.ImageURL
+}
+
+// push delivers job to the push endpoint, as Pub/Sub would.
+func push(t *testing.T, srv *httptest.Server, job thumbnailJob) *http.Response {
+	t.Helper()
+	return pushWithToken(t, srv, job, pushAudience+"|"+pushServiceAccount)
+}
+
+// pushWithToken delivers job to the push endpoint, authenticated with token
+// if it isn't empty.
+func pushWithToken(t *testing.T, srv *httptest.Server, job thumbnailJob, token string) *http.Response {
+	t.Helper()
+	var m pubSubMessage
+	m.Message.Data, _ = json.Marshal(job)
+	m.Message.ID = "1"
+	body, _ := json.Marshal(m)
+	req, err := http.NewRequest("POST", srv.URL+"/internal/thumbnails", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestThumbnailPipeline(t *testing.T) {
+	srv, store, queue := newThumbnailServer(t)
+	imageURL := uploadImage(t, srv, store, pngImage(t))
+
+	var created Book
+	body := fmt.Sprintf(`{"title": "doughnuts", "imageURL": %q, "imageStatus": "ready"}`, imageURL)
+	apiDo(t, srv, "POST", "/api/v1/books", body, nil, &created)
+	if created.ImageStatus != imagePending {
+		t.Errorf("create: imageStatus = %q, want %q", created.ImageStatus, imagePending)
+	}
+	jobs := queue.take()
+	if len(jobs) != 1 || jobs[0].BookID != created.ID {
+		t.Fatalf("create: queued %+v, want one job for book %q", jobs, created.ID)
+	}
+
+	// Editing the book keeps the pending image.
+	body = fmt.Sprintf(`{"title": "more doughnuts", "imageURL": %q}`, imageURL)
+	var updated Book
+	apiDo(t, srv, "PUT", "/api/v1/books/"+created.ID, body, http.Header{"If-Match": {"*"}}, &updated)
+	if updated.ImageStatus != imagePending || len(queue.take()) != 0 {
+		t.Errorf("update: imageStatus = %q, want the job still pending", updated.ImageStatus)
+	}
+
+	if resp := push(t, srv, jobs[0]); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("push: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	var got Book
+	apiDo(t, srv, "GET", "/api/v1/books/"+created.ID, "", nil, &got)
+	if got.ImageStatus != imageReady || got.Title != "more doughnuts" {
+		t.Fatalf("after processing: got %+v, want imageStatus ready", got)
+	}
+	for _, tc := range []struct {
+		url, want string
+	}{
+		{got.ThumbnailURL, "png 200x300"},
+		{got.ImageURL, "png 800x1200"},
+	} {
+		name := strings.TrimPrefix(tc.url, "https://storage.googleapis.com/my-bucket/")
+		o, ok := store.get(name)
+		if !strings.HasPrefix(name, imagesPrefix) || !ok {
+			t.Errorf("processed image %q not in the bucket", tc.url)
+			continue
+		}
+		if string(o.data) != tc.want || !o.public || o.contentType != "image/png" {
+			t.Errorf("processed image %s = %q (public %v, %s), want public %q", name, o.data, o.public, o.contentType, tc.want)
+		}
+	}
+	if _, ok := store.get(jobs[0].Object); ok {
+		t.Errorf("original %s was not deleted", jobs[0].Object)
+	}
+
+	// Pub/Sub may deliver a job more than once.
+	if resp := push(t, srv, jobs[0]); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("push again: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	var again Book
+	apiDo(t, srv, "GET", "/api/v1/books/"+created.ID, "", nil, &again)
+	if again != got {
+		t.Errorf("push again changed the book to %+v", again)
+	}
+}
+
+func TestThumbnailPipelineRejects(t *testing.T) {
+	srv, store, queue := newThumbnailServer(t)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"not an image", []byte("#!/bin/sh\necho pwned\n")},
+		{"corrupt image", pngImage(t)[:30]},
+		{"too large", append(pngImage(t), make([]byte, maxImageBytes)...)},
+	} {
+		imageURL := uploadImage(t, srv, store, tc.data)
+		var created Book
+		apiDo(t, srv, "POST", "/api/v1/books", fmt.Sprintf(`{"title": "a", "imageURL": %q}`, imageURL), nil, &created)
+		jobs := queue.take()
+		if len(jobs) != 1 {
+			t.Fatalf("%s: queued %d jobs, want 1", tc.name, len(jobs))
+		}
+		if resp := push(t, srv, jobs[0]); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("%s: push: status %d, want %d", tc.name, resp.StatusCode, http.StatusNoContent)
+		}
+		var got Book
+		apiDo(t, srv, "GET", "/api/v1/books/"+created.ID, "", nil, &got)
+		if got.ImageStatus != imageFailed || got.ImageURL != "" || got.ThumbnailURL != "" {
+			t.Errorf("%s: got %+v, want a failed image", tc.name, got)
+		}
+		if _, ok := store.get(jobs[0].Object); ok {
+			t.Errorf("%s: rejected upload was not deleted", tc.name)
+		}
+	}
+}
+
+func TestThumbnailPipelineStaleJobs(t *testing.T) {
+	srv, store, queue := newThumbnailServer(t)
+
+	// The book's image is replaced before its first image is processed.
+	first := uploadImage(t, srv, store, pngImage(t))
+	var created Book
+	apiDo(t, srv, "POST", "/api/v1/books", fmt.Sprintf(`{"title": "a", "imageURL": %q}`, first), nil, &created)
+	apiDo(t, srv, "PUT", "/api/v1/books/"+created.ID, `{"title": "a", "imageURL": "https://example.com/cover.png"}`, http.Header{"If-Match": {"*"}}, nil)
+	jobs := queue.take()
+	if len(jobs) != 1 {
+		t.Fatalf("queued %d jobs, want 1", len(jobs))
+	}
+	if resp := push(t, srv, jobs[0]); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("push: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	var got Book
+	apiDo(t, srv, "GET", "/api/v1/books/"+created.ID, "", nil, &got)
+	if got.ImageURL != "https://example.com/cover.png" || got.ImageStatus != "" {
+		t.Errorf("stale job changed the book to %+v", got)
+	}
+	if _, ok := store.get(jobs[0].Object); ok {
+		t.Errorf("replaced upload %s was not deleted", jobs[0].Object)
+	}
+
+	// Malformed messages are rejected.
+	if resp := push(t, srv, thumbnailJob{BookID: created.ID, Object: "images/x.png"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("push of a job outside %s: status %d, want %d", uploadsPrefix, resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestThumbnailPushAuth(t *testing.T) {
+	srv, store, queue := newThumbnailServer(t)
+	imageURL := uploadImage(t, srv, store, pngImage(t))
+	var created Book
+	apiDo(t, srv, "POST", "/api/v1/books", fmt.Sprintf(`{"title": "a", "imageURL": %q}`, imageURL), nil, &created)
+	jobs := queue.take()
+	if len(jobs) != 1 {
+		t.Fatalf("queued %d jobs, want 1", len(jobs))
+	}
+
+	for _, tc := range []struct {
+		name, token string
+	}{
+		{"no token", ""},
+		{"invalid token", "not-a-token"},
+		{"wrong audience", "https://example.com|" + pushServiceAccount},
+		{"wrong account", pushAudience + "|mallory@example.com"},
+	} {
+		if resp := pushWithToken(t, srv, jobs[0], tc.token); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	var got Book
+	apiDo(t, srv, "GET", "/api/v1/books/"+created.ID, "", nil, &got)
+	if got.ImageStatus != imagePending {
+		t.Errorf("rejected pushes changed the book to %+v", got)
+	}
+	if _, ok := store.get(jobs[0].Object); !ok {
+		t.Errorf("rejected pushes deleted %s", jobs[0].Object)
+	}
+}
+
+func TestThumbnailPipelineForgedJobs(t *testing.T) {
+	srv, store, queue := newThumbnailServer(t)
+
+	var books [2]Book
+	for i := range books {
+		imageURL := uploadImage(t, srv, store, pngImage(t))
+		apiDo(t, srv, "POST", "/api/v1/books", fmt.Sprintf(`{"title": "a", "imageURL": %q}`, imageURL), nil, &books[i])
+	}
+	jobs := queue.take()
+	if len(jobs) != 2 {
+		t.Fatalf("queued %d jobs, want 2", len(jobs))
+	}
+	victim := jobs[1]
+
+	// Jobs naming another book's upload are ignored: the upload stays, and
+	// is still processed for its own book.
+	for _, forged := range []thumbnailJob{
+		{BookID: books[0].ID, Object: victim.Object},
+		{BookID: "no-such-book", Object: victim.Object},
+	} {
+		if resp := push(t, srv, forged); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("push %+v: status %d, want %d", forged, resp.StatusCode, http.StatusNoContent)
+		}
+		if _, ok := store.get(victim.Object); !ok {
+			t.Fatalf("push %+v deleted %s", forged, victim.Object)
+		}
+	}
+	var got Book
+	apiDo(t, srv, "GET", "/api/v1/books/"+books[0].ID, "", nil, &got)
+	if got.ImageStatus != imagePending || got.ImageURL != books[0].ImageURL {
+		t.Errorf("forged job changed book %q to %+v", books[0].ID, got)
+	}
+
+	if resp := push(t, srv, victim); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("push: status %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	apiDo(t, srv, "GET", "/api/v1/books/"+books[1].ID, "", nil, &got)
+	if got.ImageStatus != imageReady {
+		t.Errorf("after processing: got %+v, want imageStatus ready", got)
+	}
+
+	// Deleting a book deletes its pending upload.
+	apiDo(t, srv, "DELETE", "/api/v1/books/"+books[0].ID, "", nil, nil)
+	if _, ok := store.get(jobs[0].Object); ok {
+		t.Errorf("upload %s of the deleted book was not deleted", jobs[0].Object)
+	}
+}
+
+func TestImagemagickResize(t *testing.T) {
+	if _, err := exec.LookPath("convert"); err != nil {
+		t.Skip("ImageMagick is not installed")
+	}
+	var buf bytes.Buffer
+	size := thumbnailSize{Name: "thumb", Width: 20, Height: 20}
+	if err := imagemagickResize(context.Background(), bytes.NewReader(pngImage(t)), &buf, "png", size); err != nil {
+		t.Fatalf("imagemagickResize: %v", err)
+	}
+	cfg, err := png.DecodeConfig(&buf)
+	if err != nil {
+		t.Fatalf("png.DecodeConfig: %v", err)
+	}
+	// The 40x60 image is shrunk to fit, keeping its aspect ratio.
+	if cfg.Width > size.Width || cfg.Height != size.Height {
+		t.Errorf("resized to %dx%d, want to fit in %dx%d", cfg.Width, cfg.Height, size.Width, size.Height)
+	}
+}
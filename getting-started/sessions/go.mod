@@ -2,7 +2,12 @@ module github.com/GoogleCloudPlatform/golang-samples/getting-started/sessions
 
 go 1.23.0
 
-require cloud.google.com/go/firestore v1.18.0
+require (
+	cloud.google.com/go/firestore v1.18.0
+	github.com/gomodule/redigo v2.0.0+incompatible
+	google.golang.org/api v0.217.0
+	google.golang.org/grpc v1.69.4
+)
 
 require (
 	cloud.google.com/go v0.118.0 // indirect
@@ -32,10 +37,8 @@ require (
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	golang.org/x/time v0.9.0 // indirect
-	google.golang.org/api v0.217.0 // indirect
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/grpc v1.69.4 // indirect
 	google.golang.org/protobuf v1.36.3 // indirect
 )
@@ -17,27 +17,34 @@ package main
 // [START getting_started_sessions_setup]
 import (
 	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"html/template"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"cloud.google.com/go/firestore"
+	"github.com/GoogleCloudPlatform/golang-samples/getting-started/sessions/session"
+	"github.com/gomodule/redigo/redis"
 )
 
-// app stores a sessions.Store. Create a new app with newApp.
+// app stores a session.Manager. Create a new app with newApp.
 type app struct {
-	tmpl         *template.Template
-	collectionID string
-	projectID    string
+	tmpl     *template.Template
+	sessions *session.Manager
 }
 
-// session stores the client's session information.
-// This type is also used for executing the template.
-type session struct {
-	Greetings string `json:"greeting"`
-	Views     int    `json:"views"`
+// greeting is the client's session information used for executing the
+// template.
+type greeting struct {
+	Greetings string
+	Views     int
 }
 
 // greetings are the random greetings that will be assigned to sessions.
@@ -74,7 +81,7 @@ func main() {
 		log.Fatalf("newApp: %v", err)
 	}
 
-	http.HandleFunc("/", a.index)
+	http.Handle("/", a.handler())
 
 	log.Printf("Listening on port %s", port)
 	if err := http.ListenAndServe(":"+port, nil); err != nil {
@@ -83,19 +90,99 @@ func main() {
 }
 
 // newApp creates a new app.
+//
+// Sessions are stored in the Firestore collection collectionID, unless
+// SESSION_STORE is "redis", for the Memorystore for Redis instance at
+// REDISHOST:REDISPORT, or "memory". The session cookies are encrypted with
+// SESSION_KEYS, a comma-separated list of base64-encoded 32-byte keys,
+// newest first.
 func newApp(projectID, collectionID string) (app, error) {
 	tmpl, err := template.New("Index").Parse(`<body>{{.Views}} {{if eq .Views 1}}view{{else}}views{{end}} for "{{.Greetings}}"</body>`)
 	if err != nil {
-		log.Fatalf("template.New: %v", err)
+		return app{}, fmt.Errorf("template.New: %w", err)
+	}
+
+	store, err := newStore(projectID, collectionID)
+	if err != nil {
+		return app{}, err
+	}
+	keys, err := sessionKeys()
+	if err != nil {
+		return app{}, err
+	}
+	sessions, err := session.NewManager(store, session.Options{
+		Keys: keys,
+		// Allow plain HTTP when running locally.
+		Insecure: os.Getenv("GAE_ENV") == "",
+	})
+	if err != nil {
+		return app{}, fmt.Errorf("session.NewManager: %w", err)
 	}
 
 	return app{
-		tmpl:         tmpl,
-		collectionID: collectionID,
-		projectID:    projectID,
+		tmpl:     tmpl,
+		sessions: sessions,
 	}, nil
 }
 
+// newStore returns the session.Store selected by SESSION_STORE.
+func newStore(projectID, collectionID string) (session.Store, error) {
+	switch s := os.Getenv("SESSION_STORE"); s {
+	case "", "firestore":
+		// Use context.Background() so the client can be reused.
+		client, err := firestore.NewClient(context.Background(), projectID)
+		if err != nil {
+			return nil, fmt.Errorf("firestore.NewClient: %w", err)
+		}
+		return session.NewFirestoreStore(client, collectionID), nil
+	case "redis":
+		redisAddr := fmt.Sprintf("%s:%s", os.Getenv("REDISHOST"), os.Getenv("REDISPORT"))
+		const maxConnections = 10
+		pool := &redis.Pool{
+			MaxIdle: maxConnections,
+			Dial:    func() (redis.Conn, error) { return redis.Dial("tcp", redisAddr) },
+		}
+		return session.NewRedisStore(pool, collectionID+":"), nil
+	case "memory":
+		return session.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown SESSION_STORE %q, want firestore, redis or memory", s)
+	}
+}
+
+// sessionKeys returns the keys in SESSION_KEYS. When running locally and it
+// isn't set, it returns a random key, so sessions don't survive restarts. On
+// App Engine, SESSION_KEYS is required, since every instance would otherwise
+// encrypt cookies with its own key.
+func sessionKeys() ([][]byte, error) {
+	env := os.Getenv("SESSION_KEYS")
+	if env == "" {
+		if os.Getenv("GAE_ENV") != "" {
+			return nil, errors.New("SESSION_KEYS must be set on App Engine")
+		}
+		log.Printf("SESSION_KEYS isn't set, using a random key")
+		key := make([]byte, 32)
+		if _, err := crand.Read(key); err != nil {
+			return nil, fmt.Errorf("rand.Read: %w", err)
+		}
+		return [][]byte{key}, nil
+	}
+	var keys [][]byte
+	for _, k := range strings.Split(env, ",") {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(k))
+		if err != nil {
+			return nil, fmt.Errorf("SESSION_KEYS: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// handler returns the app's handler, with sessions.
+func (a *app) handler() http.Handler {
+	return a.sessions.Middleware(http.HandlerFunc(a.index))
+}
+
 // [END getting_started_sessions_main]
 
 // [START getting_started_sessions_handler]
@@ -109,80 +196,19 @@ func (a *app) index(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var session session
-	var doc *firestore.DocumentRef
-
-	isNewSession := false
-
-	ctx := context.Background()
-
-	client, err := firestore.NewClient(ctx, a.projectID)
-	if err != nil {
-		log.Fatalf("firestore.NewClient: %v", err)
-	}
-	defer client.Close()
-
-	// cookieName is a non-empty identifier for this app, it is used as the key name
-	// that contains the session's id value.
-	//
-	// Set it to something more descriptive for your app.
-	cookieName := "session_id"
-
-	// If err is different to nil, it means the cookie has not been set, so it will be created.
-	cookie, err := r.Cookie(cookieName)
-	if err != nil {
-		// isNewSession flag is set to true
-		isNewSession = true
+	s := session.FromContext(r.Context())
+	if s.Get("greeting") == "" {
+		s.Set("greeting", greetings[rand.Intn(len(greetings))])
 	}
+	views, _ := strconv.Atoi(s.Get("views"))
+	views++
+	s.Set("views", strconv.Itoa(views))
 
-	// If isNewSession flag is true, the session will be created
-	if isNewSession {
-		// Get unique id for new document
-		doc = client.Collection(a.collectionID).NewDoc()
-
-		session.Greetings = greetings[rand.Intn(len(greetings))]
-		session.Views = 1
-
-		// Cookie is set
-		cookie = &http.Cookie{
-			Name:  cookieName,
-			Value: doc.ID,
-		}
-		http.SetCookie(w, cookie)
-	} else {
-		// The session exists
-
-		// Retrieve document from collection by ID
-		docSnapshot, err := client.Collection(a.collectionID).Doc(cookie.Value).Get(ctx)
-		if err != nil {
-			log.Printf("doc.Get error: %v", err)
-			http.Error(w, "Error getting session", http.StatusInternalServerError)
-			return
-		}
-
-		// Unmarshal documents's content to local type
-		err = docSnapshot.DataTo(&session)
-		if err != nil {
-			log.Printf("doc.DataTo error: %v", err)
-			http.Error(w, "Error parsing session", http.StatusInternalServerError)
-			return
-		}
-
-		doc = docSnapshot.Ref
-
-		// Add 1 to current views value
-		session.Views++
-	}
-
-	// The document is created/updated
-	_, err = doc.Set(ctx, session)
-	if err != nil {
-		log.Printf("doc.Set error: %v", err)
-		http.Error(w, "Error creating session", http.StatusInternalServerError)
-		return
+	g := greeting{
+		Greetings: s.Get("greeting"),
+		Views:     views,
 	}
-
-	if err := a.tmpl.Execute(w, session); err != nil {
+	if err := a.tmpl.Execute(w, g); err != nil {
 		log.Printf("Execute: %v", err)
 	}
 }
@@ -41,7 +41,7 @@ func TestIndex(t *testing.T) {
 	r := httptest.NewRequest("GET", "/", nil)
 	rr := httptest.NewRecorder()
 
-	a.index(rr, r)
+	a.handler().ServeHTTP(rr, r)
 
 	// ResponseWriter body should contain 1 view
 	if got, want := rr.Body.String(), "1 view"; !strings.Contains(got, want) {
@@ -55,7 +55,7 @@ func TestIndex(t *testing.T) {
 	rr = httptest.NewRecorder()
 
 	// Simulate another HTTP GET request
-	a.index(rr, r)
+	a.handler().ServeHTTP(rr, r)
 
 	if got, want := rr.Body.String(), "2 views"; !strings.Contains(got, want) {
 		t.Errorf("index second visit got:\n----\n%v\n----\nWant to contain %q", got, want)
@@ -64,6 +64,26 @@ func TestIndex(t *testing.T) {
 	cleanup(t, projectID, collectionID)
 }
 
+func TestSessionKeys(t *testing.T) {
+	t.Setenv("SESSION_KEYS", "")
+	t.Setenv("GAE_ENV", "")
+	keys, err := sessionKeys()
+	if err != nil || len(keys) != 1 || len(keys[0]) != 32 {
+		t.Errorf("sessionKeys locally = %d keys, %v; want one random 32-byte key", len(keys), err)
+	}
+
+	t.Setenv("GAE_ENV", "standard")
+	if _, err := sessionKeys(); err == nil {
+		t.Errorf("sessionKeys on App Engine without SESSION_KEYS: got nil error, want an error")
+	}
+
+	t.Setenv("SESSION_KEYS", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=, //////////////////////////////////////////8=")
+	keys, err = sessionKeys()
+	if err != nil || len(keys) != 2 {
+		t.Errorf("sessionKeys with SESSION_KEYS = %d keys, %v; want 2 keys", len(keys), err)
+	}
+}
+
 // cleanup function deletes all documents inside a collection
 func cleanup(t *testing.T, projectID, collectionID string) {
 
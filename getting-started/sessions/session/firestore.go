@@ -0,0 +1,103 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FirestoreStore is a Store keeping each session in a document of a
+// Firestore collection.
+//
+// To delete expired sessions automatically, create a TTL policy on the
+// Expires field of the collection:
+//
+//	gcloud firestore fields ttls update Expires --collection-group=COLLECTION --enable-ttl
+//
+// Firestore deletes expired documents within a day or so; they aren't
+// loaded in the meantime. Without a TTL policy, call DeleteExpired
+// periodically.
+type FirestoreStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreStore returns a FirestoreStore keeping sessions in the given
+// collection.
+func NewFirestoreStore(client *firestore.Client, collection string) *FirestoreStore {
+	return &FirestoreStore{client: client, collection: collection}
+}
+
+// Load implements Store.
+func (st *FirestoreStore) Load(ctx context.Context, id string) (*Session, error) {
+	doc, err := st.client.Collection(st.collection).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Get: %w", err)
+	}
+	s := &Session{}
+	if err := doc.DataTo(s); err != nil {
+		return nil, fmt.Errorf("DataTo: %w", err)
+	}
+	s.ID = id
+	return s, nil
+}
+
+// Save implements Store.
+func (st *FirestoreStore) Save(ctx context.Context, s *Session) error {
+	if _, err := st.client.Collection(st.collection).Doc(s.ID).Set(ctx, s); err != nil {
+		return fmt.Errorf("Set: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (st *FirestoreStore) Delete(ctx context.Context, id string) error {
+	if _, err := st.client.Collection(st.collection).Doc(id).Delete(ctx); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes the sessions that expired, returning how many were
+// deleted.
+func (st *FirestoreStore) DeleteExpired(ctx context.Context) (int, error) {
+	iter := st.client.Collection(st.collection).Where("Expires", "<=", time.Now()).Documents(ctx)
+	defer iter.Stop()
+	n := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("Next: %w", err)
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return n, fmt.Errorf("Delete: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
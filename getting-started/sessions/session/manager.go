@@ -0,0 +1,314 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// CSRFHeader is the header VerifyCSRF reads the CSRF token from.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the form field VerifyCSRF reads the CSRF token from, if
+	// the request has no CSRFHeader.
+	CSRFField = "csrf_token"
+)
+
+// Options configure a Manager.
+type Options struct {
+	// Keys encrypt and authenticate the session cookies. Each key is 16,
+	// 24 or 32 random bytes, for AES-128, AES-192 or AES-256. The first
+	// key encrypts new cookies, and all of them decrypt cookies, so keys
+	// can be rotated by adding a new key first.
+	Keys [][]byte
+	// CookieName is the name of the session cookie. The default is
+	// "session".
+	CookieName string
+	// IdleTimeout is how long sessions last after their last use. The
+	// default is 24 hours.
+	IdleTimeout time.Duration
+	// Insecure allows the session cookie to be sent over plain HTTP, for
+	// local development.
+	Insecure bool
+}
+
+// A Manager loads and saves the sessions of requests. Create one with
+// NewManager.
+type Manager struct {
+	store       Store
+	aeads       []cipher.AEAD
+	cookieName  string
+	idleTimeout time.Duration
+	secure      bool
+
+	// now returns the current time, and can be overridden for tests.
+	now func() time.Time
+}
+
+// NewManager returns a Manager storing sessions in store.
+func NewManager(store Store, opts Options) (*Manager, error) {
+	if len(opts.Keys) == 0 {
+		return nil, errors.New("session: at least one key is needed")
+	}
+	m := &Manager{
+		store:       store,
+		cookieName:  opts.CookieName,
+		idleTimeout: opts.IdleTimeout,
+		secure:      !opts.Insecure,
+		now:         time.Now,
+	}
+	if m.cookieName == "" {
+		m.cookieName = "session"
+	}
+	if m.idleTimeout <= 0 {
+		m.idleTimeout = 24 * time.Hour
+	}
+	for i, key := range opts.Keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("session: key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("session: key %d: %w", i, err)
+		}
+		m.aeads = append(m.aeads, aead)
+	}
+	return m, nil
+}
+
+// encodeID returns the cookie value for the session ID id: the ID encrypted
+// with the first key, bound to the cookie name.
+func (m *Manager) encodeID(id string) (string, error) {
+	aead := m.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(id)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(id), []byte(m.cookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// decodeID returns the session ID in the cookie value v, or an error if v
+// wasn't encoded by encodeID with one of the keys.
+func (m *Manager) decodeID(v string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid session cookie: %w", err)
+	}
+	for _, aead := range m.aeads {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if id, err := aead.Open(nil, nonce, ciphertext, []byte(m.cookieName)); err == nil {
+			return string(id), nil
+		}
+	}
+	return "", errors.New("invalid session cookie")
+}
+
+// Load returns the session of r, or a new session if r has none, or its
+// session expired or can't be decoded. Middleware calls Load for each
+// request.
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	now := m.now()
+	c, err := r.Cookie(m.cookieName)
+	if err != nil {
+		return newSession(now)
+	}
+	id, err := m.decodeID(c.Value)
+	if err != nil {
+		return newSession(now)
+	}
+	s, err := m.store.Load(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		return newSession(now)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Load: %w", err)
+	}
+	// Stores may keep expired sessions for a while before deleting them.
+	if !now.Before(s.Expires) {
+		return newSession(now)
+	}
+	s.ID = id
+	return s, nil
+}
+
+// Save saves the changes to s, and sets or deletes its cookie on w. It must
+// be called before the response header is written. Middleware calls Save
+// for each request.
+//
+// Sessions that are new and unchanged aren't saved. Sessions that didn't
+// change are only saved once half of their IdleTimeout has passed, to
+// extend them without writing to the store on every request.
+func (m *Manager) Save(w http.ResponseWriter, r *http.Request, s *Session) error {
+	ctx := r.Context()
+	now := m.now()
+
+	s.mu.Lock()
+	destroyed, id, oldID := s.destroyed, s.ID, s.oldID
+	save := s.modified || (!s.isNew && s.Expires.Sub(now) <= m.idleTimeout/2)
+	var saved *Session
+	if save && !destroyed {
+		s.Expires = now.Add(m.idleTimeout)
+		saved = s.cloneLocked()
+	}
+	s.mu.Unlock()
+
+	if destroyed {
+		for _, id := range []string{id, oldID} {
+			if id == "" {
+				continue
+			}
+			if err := m.store.Delete(ctx, id); err != nil {
+				return fmt.Errorf("Delete: %w", err)
+			}
+		}
+		http.SetCookie(w, m.cookie("", -1))
+		return nil
+	}
+	if !save {
+		return nil
+	}
+
+	value, err := m.encodeID(id)
+	if err != nil {
+		return err
+	}
+	if err := m.store.Save(ctx, saved); err != nil {
+		return fmt.Errorf("Save: %w", err)
+	}
+	if oldID != "" {
+		if err := m.store.Delete(ctx, oldID); err != nil {
+			return fmt.Errorf("Delete: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.isNew = false
+	s.modified = false
+	s.oldID = ""
+	s.mu.Unlock()
+
+	http.SetCookie(w, m.cookie(value, int(m.idleTimeout/time.Second)))
+	return nil
+}
+
+func (m *Manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   m.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Middleware attaches the session of each request to its context, and
+// saves it before the response header is written.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := m.Load(r)
+		if err != nil {
+			log.Printf("session.Load: %v", err)
+			http.Error(w, "Error loading session", http.StatusInternalServerError)
+			return
+		}
+		r = r.WithContext(NewContext(r.Context(), s))
+		sw := &saveWriter{ResponseWriter: w, save: func() {
+			if err := m.Save(w, r, s); err != nil {
+				log.Printf("session.Save: %v", err)
+			}
+		}}
+		next.ServeHTTP(sw, r)
+		sw.saveOnce()
+	})
+}
+
+// saveWriter is an http.ResponseWriter that saves the session before the
+// header is written.
+type saveWriter struct {
+	http.ResponseWriter
+	save  func()
+	saved bool
+}
+
+func (w *saveWriter) saveOnce() {
+	if !w.saved {
+		w.saved = true
+		w.save()
+	}
+}
+
+func (w *saveWriter) WriteHeader(code int) {
+	w.saveOnce()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *saveWriter) Write(b []byte) (int, error) {
+	w.saveOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *saveWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// VerifyCSRF rejects requests that can change state (all methods except GET,
+// HEAD, OPTIONS and TRACE) unless they include the CSRF token of their
+// session, in the CSRFHeader header or the CSRFField form field. It must be
+// used inside Middleware. Pages include the token from Session.CSRFToken in
+// their forms and scripts.
+func (m *Manager) VerifyCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+		s := FromContext(r.Context())
+		if s == nil {
+			log.Printf("VerifyCSRF: no session, use Manager.Middleware")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		token := r.Header.Get(CSRFHeader)
+		if token == "" {
+			token = r.PostFormValue(CSRFField)
+		}
+		s.mu.Lock()
+		want := s.CSRF
+		s.mu.Unlock()
+		if want == "" || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
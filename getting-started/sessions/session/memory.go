@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping sessions in memory, for tests and apps
+// running a single instance. Sessions are lost when the app restarts.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]*Session{}}
+}
+
+// Load implements Store.
+func (st *MemoryStore) Load(ctx context.Context, id string) (*Session, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s, ok := st.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s.clone(), nil
+}
+
+// Save implements Store.
+func (st *MemoryStore) Save(ctx context.Context, s *Session) error {
+	c := s.clone()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sessions[c.ID] = c
+	return nil
+}
+
+// Delete implements Store.
+func (st *MemoryStore) Delete(ctx context.Context, id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, id)
+	return nil
+}
+
+// DeleteExpired deletes the sessions that expired, returning how many were
+// deleted. Call it periodically to free their memory.
+func (st *MemoryStore) DeleteExpired(ctx context.Context) (int, error) {
+	now := time.Now()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	n := 0
+	for id, s := range st.sessions {
+		if !now.Before(s.Expires) {
+			delete(st.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
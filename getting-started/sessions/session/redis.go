@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisStore is a Store keeping sessions in Redis, such as a Memorystore for
+// Redis instance. Each session is a JSON value with a TTL, so Redis deletes
+// it when it expires.
+type RedisStore struct {
+	pool   *redis.Pool
+	prefix string
+}
+
+// NewRedisStore returns a RedisStore using connections from pool. The keys
+// of sessions are their ID with the given prefix, such as "session:".
+func NewRedisStore(pool *redis.Pool, prefix string) *RedisStore {
+	return &RedisStore{pool: pool, prefix: prefix}
+}
+
+// Load implements Store.
+func (st *RedisStore) Load(ctx context.Context, id string) (*Session, error) {
+	conn := st.pool.Get()
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", st.prefix+id))
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GET: %w", err)
+	}
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %w", err)
+	}
+	s.ID = id
+	return s, nil
+}
+
+// Save implements Store.
+func (st *RedisStore) Save(ctx context.Context, s *Session) error {
+	ttl := time.Until(s.Expires).Milliseconds()
+	if ttl <= 0 {
+		return st.Delete(ctx, s.ID)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	conn := st.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", st.prefix+s.ID, data, "PX", ttl); err != nil {
+		return fmt.Errorf("SET: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (st *RedisStore) Delete(ctx context.Context, id string) error {
+	conn := st.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", st.prefix+id); err != nil {
+		return fmt.Errorf("DEL: %w", err)
+	}
+	return nil
+}
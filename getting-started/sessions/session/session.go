@@ -0,0 +1,210 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package session manages HTTP sessions stored on the server.
+//
+// A Manager identifies sessions with an encrypted cookie, and keeps them in a
+// Store: Firestore, Memorystore for Redis, or memory. Sessions expire after
+// being idle for a while, and are extended as they are used. Manager.Middleware
+// loads the session of each request into its context, where handlers get it
+// with FromContext:
+//
+//	m, err := session.NewManager(session.NewMemoryStore(), session.Options{Keys: keys})
+//	...
+//	http.Handle("/", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//		s := session.FromContext(r.Context())
+//		s.Set("name", "Gopher")
+//		...
+//	})))
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Store.Load for sessions that don't exist.
+var ErrNotFound = errors.New("session not found")
+
+// A Store stores sessions.
+type Store interface {
+	// Load returns the session with the given ID, or ErrNotFound.
+	Load(ctx context.Context, id string) (*Session, error)
+	// Save creates or replaces the session s. The store may delete it
+	// once s.Expires has passed.
+	Save(ctx context.Context, s *Session) error
+	// Delete deletes the session with the given ID, if it exists.
+	Delete(ctx context.Context, id string) error
+}
+
+// A Session holds the values stored for a client between requests. Its
+// methods are safe for concurrent use.
+//
+// The exported fields are the state saved by a Store. Handlers use the
+// methods instead, which record the changes to save.
+type Session struct {
+	ID      string            `json:"-" firestore:"-"`
+	Values  map[string]string `json:"values"`
+	CSRF    string            `json:"csrf,omitempty"`
+	Created time.Time         `json:"created"`
+	Expires time.Time         `json:"expires"`
+
+	mu        sync.Mutex
+	isNew     bool
+	modified  bool
+	destroyed bool
+	// oldID is the ID the session had before RenewID, to delete.
+	oldID string
+}
+
+// newSession returns a new, unsaved session.
+func newSession(now time.Time) (*Session, error) {
+	id, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		ID:      id,
+		Values:  map[string]string{},
+		Created: now,
+		isNew:   true,
+	}, nil
+}
+
+// Get returns the value of key, or "" if it isn't set.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Values[key]
+}
+
+// Set sets the value of key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Values == nil {
+		s.Values = map[string]string{}
+	}
+	s.Values[key] = value
+	s.modified = true
+}
+
+// Delete removes key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.Values[key]; ok {
+		delete(s.Values, key)
+		s.modified = true
+	}
+}
+
+// IsNew reports whether the session was created by this request.
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+// CSRFToken returns the token that requests changing state must include,
+// creating it if needed. See Manager.VerifyCSRF.
+func (s *Session) CSRFToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.CSRF == "" {
+		token, err := randomString()
+		if err != nil {
+			return "", err
+		}
+		s.CSRF = token
+		s.modified = true
+	}
+	return s.CSRF, nil
+}
+
+// RenewID gives the session a new ID, keeping its values. Call it when the
+// privileges of the client change, such as when signing in, to prevent
+// session fixation.
+func (s *Session) RenewID() error {
+	id, err := randomString()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew && s.oldID == "" {
+		s.oldID = s.ID
+	}
+	s.ID = id
+	s.modified = true
+	return nil
+}
+
+// Destroy deletes the session and its cookie, such as when signing out.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroyed = true
+}
+
+// clone returns a copy of the saved state of s, for stores that keep
+// sessions in memory.
+func (s *Session) clone() *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cloneLocked()
+}
+
+// cloneLocked is clone for callers holding s.mu.
+func (s *Session) cloneLocked() *Session {
+	c := &Session{
+		ID:      s.ID,
+		Values:  make(map[string]string, len(s.Values)),
+		CSRF:    s.CSRF,
+		Created: s.Created,
+		Expires: s.Expires,
+	}
+	for k, v := range s.Values {
+		c.Values[k] = v
+	}
+	return c
+}
+
+type contextKey struct{}
+
+// FromContext returns the session attached to ctx by Manager.Middleware, or
+// nil if there is none.
+func FromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(contextKey{}).(*Session)
+	return s
+}
+
+// NewContext returns a copy of ctx with the session s attached.
+func NewContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// randomString returns a random URL-safe string with 256 bits of entropy.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package session
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testKey  = bytes.Repeat([]byte("k"), 32)
+	otherKey = bytes.Repeat([]byte("o"), 32)
+)
+
+// counter counts the views of a session, and writes their number.
+var counter = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	s := FromContext(r.Context())
+	var views int
+	fmt.Sscan(s.Get("views"), &views)
+	if r.URL.Query().Get("peek") == "" {
+		views++
+		s.Set("views", fmt.Sprint(views))
+	}
+	fmt.Fprint(w, views)
+})
+
+// get makes a GET request to h with the given cookie, returning the body
+// and the session cookie set by the response, if any.
+func get(t *testing.T, h http.Handler, target string, cookie *http.Cookie) (string, *http.Cookie) {
+	t.Helper()
+	r := httptest.NewRequest("GET", target, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s got status %v, want %v", target, w.Code, http.StatusOK)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" {
+			return w.Body.String(), c
+		}
+	}
+	return w.Body.String(), nil
+}
+
+func newTestManager(t *testing.T, store Store, keys ...[]byte) *Manager {
+	t.Helper()
+	if len(keys) == 0 {
+		keys = [][]byte{testKey}
+	}
+	m, err := NewManager(store, Options{Keys: keys, IdleTimeout: time.Hour})
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	m := newTestManager(t, store)
+	h := m.Middleware(counter)
+
+	// New sessions aren't saved until they change.
+	if body, c := get(t, h, "/?peek=1", nil); body != "0" || c != nil {
+		t.Errorf("first peek got (%q, %v), want (%q, no cookie)", body, c, "0")
+	}
+	if len(store.sessions) != 0 {
+		t.Errorf("got %d stored sessions, want 0", len(store.sessions))
+	}
+
+	body, cookie := get(t, h, "/", nil)
+	if body != "1" || cookie == nil {
+		t.Fatalf("first view got (%q, %v), want (%q, a cookie)", body, cookie, "1")
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.MaxAge != 3600 {
+		t.Errorf("cookie got %+v, want HttpOnly, Secure and MaxAge 3600", cookie)
+	}
+	for id := range store.sessions {
+		if strings.Contains(cookie.Value, id) {
+			t.Errorf("cookie %q contains the session ID %q, want it encrypted", cookie.Value, id)
+		}
+	}
+
+	if body, _ := get(t, h, "/", cookie); body != "2" {
+		t.Errorf("second view got %q, want %q", body, "2")
+	}
+
+	// Cookies that weren't encrypted with the keys start new sessions.
+	tampered := &http.Cookie{Name: "session", Value: cookie.Value[:len(cookie.Value)-2] + "AA"}
+	if body, _ := get(t, h, "/", tampered); body != "1" {
+		t.Errorf("view with a tampered cookie got %q, want %q", body, "1")
+	}
+	other := newTestManager(t, store, otherKey)
+	if body, _ := get(t, other.Middleware(counter), "/", cookie); body != "1" {
+		t.Errorf("view with another key got %q, want %q", body, "1")
+	}
+
+	// Rotating keys keeps existing sessions.
+	rotated := newTestManager(t, store, otherKey, testKey)
+	body, newCookie := get(t, rotated.Middleware(counter), "/", cookie)
+	if body != "3" {
+		t.Errorf("view after rotating keys got %q, want %q", body, "3")
+	}
+	if body, _ := get(t, other.Middleware(counter), "/?peek=1", newCookie); body != "3" {
+		t.Errorf("cookie after rotating keys isn't encrypted with the new key: got %q, want %q", body, "3")
+	}
+}
+
+func TestSlidingExpiration(t *testing.T) {
+	store := NewMemoryStore()
+	m := newTestManager(t, store)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	h := m.Middleware(counter)
+
+	_, cookie := get(t, h, "/", nil)
+
+	// Unchanged sessions are only saved once half their life has passed.
+	now = now.Add(20 * time.Minute)
+	if body, c := get(t, h, "/?peek=1", cookie); body != "1" || c != nil {
+		t.Errorf("peek after 20m got (%q, %v), want (%q, no cookie)", body, c, "1")
+	}
+	now = now.Add(20 * time.Minute)
+	body, c := get(t, h, "/?peek=1", cookie)
+	if body != "1" || c == nil {
+		t.Fatalf("peek after 40m got (%q, %v), want (%q, a cookie)", body, c, "1")
+	}
+
+	// The session now expires an hour after the last save, not the first.
+	now = now.Add(50 * time.Minute)
+	if body, _ := get(t, h, "/?peek=1", cookie); body != "1" {
+		t.Errorf("peek after 90m got %q, want %q", body, "1")
+	}
+	now = now.Add(time.Hour)
+	if body, _ := get(t, h, "/?peek=1", cookie); body != "0" {
+		t.Errorf("peek after an idle hour got %q, want %q", body, "0")
+	}
+}
+
+func TestRenewIDAndDestroy(t *testing.T) {
+	store := NewMemoryStore()
+	m := newTestManager(t, store)
+	mux := http.NewServeMux()
+	mux.Handle("/", counter)
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := FromContext(r.Context()).RenewID(); err != nil {
+			t.Errorf("RenewID: %v", err)
+		}
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Destroy()
+	})
+	h := m.Middleware(mux)
+
+	_, cookie := get(t, h, "/", nil)
+	_, renewed := get(t, h, "/login", cookie)
+	if renewed == nil {
+		t.Fatalf("RenewID got no cookie, want one")
+	}
+	if len(store.sessions) != 1 {
+		t.Errorf("after RenewID got %d stored sessions, want 1", len(store.sessions))
+	}
+	if body, _ := get(t, h, "/?peek=1", cookie); body != "0" {
+		t.Errorf("peek with the old cookie got %q, want %q", body, "0")
+	}
+	if body, _ := get(t, h, "/?peek=1", renewed); body != "1" {
+		t.Errorf("peek with the renewed cookie got %q, want %q", body, "1")
+	}
+
+	_, cleared := get(t, h, "/logout", renewed)
+	if cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("Destroy got cookie %v, want it deleted", cleared)
+	}
+	if len(store.sessions) != 0 {
+		t.Errorf("after Destroy got %d stored sessions, want 0", len(store.sessions))
+	}
+}
+
+func TestVerifyCSRF(t *testing.T) {
+	m := newTestManager(t, NewMemoryStore())
+	h := m.Middleware(m.VerifyCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := FromContext(r.Context()).CSRFToken()
+		if err != nil {
+			t.Errorf("CSRFToken: %v", err)
+		}
+		fmt.Fprint(w, token)
+	})))
+
+	token, cookie := get(t, h, "/", nil)
+	if token == "" || cookie == nil {
+		t.Fatalf("GET got (%q, %v), want a token and a cookie", token, cookie)
+	}
+
+	post := func(header, field string) int {
+		form := url.Values{}
+		if field != "" {
+			form.Set(CSRFField, field)
+		}
+		r := httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(CSRFHeader, header)
+		}
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	tests := []struct {
+		name, header, field string
+		want                int
+	}{
+		{name: "header", header: token, want: http.StatusOK},
+		{name: "field", field: token, want: http.StatusOK},
+		{name: "missing", want: http.StatusForbidden},
+		{name: "wrong", header: token + "x", want: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		if got := post(tc.header, tc.field); got != tc.want {
+			t.Errorf("POST with %s token got status %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMemoryStoreDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i, expires := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(time.Hour)} {
+		if err := store.Save(ctx, &Session{ID: fmt.Sprint(i), Expires: expires}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	n, err := store.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpired got %d, want 1", n)
+	}
+	if _, err := store.Load(ctx, "1"); err != nil {
+		t.Errorf("Load of an unexpired session: %v", err)
+	}
+}
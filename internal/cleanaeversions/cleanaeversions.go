@@ -24,6 +24,9 @@
 //	      Project ID to clean.
 //	  -service Service/module ID
 //	      Service/module ID to clean. If omitted, cleans all services.
+//
+// The janitor command in internal/janitor can also delete App Engine versions,
+// filtered by age and prefix, along with other resource types left by tests.
 package main
 
 import (
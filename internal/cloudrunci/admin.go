@@ -0,0 +1,343 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrunci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	run "cloud.google.com/go/run/apiv2"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// adminTimeout bounds each Service or Job operation done with the
+	// Admin API, including waiting for long-running operations.
+	adminTimeout = 15 * time.Minute
+
+	// Polling intervals for long-running operations.
+	opPollInitial = time.Second
+	opPollMax     = 15 * time.Second
+)
+
+// Errors reported by the Admin API, for use with errors.Is.
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// APIError describes a failed Cloud Run Admin API call.
+type APIError struct {
+	// Op is the API method, such as "CreateService".
+	Op string
+	// Resource is the name of the resource the call was about.
+	Resource string
+	Code     codes.Code
+	Err      error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Resource, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match ErrNotFound, ErrAlreadyExists and
+// ErrPermissionDenied by status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == codes.NotFound
+	case ErrAlreadyExists:
+		return e.Code == codes.AlreadyExists
+	case ErrPermissionDenied:
+		return e.Code == codes.PermissionDenied
+	}
+	return false
+}
+
+func apiError(op, resource string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &APIError{Op: op, Resource: resource, Code: status.Code(err), Err: err}
+}
+
+// AdminClient deploys and manages Cloud Run services and jobs with the Cloud
+// Run Admin API, without gcloud.
+type AdminClient struct {
+	services *run.ServicesClient
+	jobs     *run.JobsClient
+}
+
+// NewAdminClient creates an AdminClient. Pass options to use an endpoint
+// other than the production API, such as the fake in internal/run/fake.
+func NewAdminClient(ctx context.Context, opts ...option.ClientOption) (*AdminClient, error) {
+	services, err := run.NewServicesClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("run.NewServicesClient: %w", err)
+	}
+	jobs, err := run.NewJobsClient(ctx, opts...)
+	if err != nil {
+		services.Close()
+		return nil, fmt.Errorf("run.NewJobsClient: %w", err)
+	}
+	return &AdminClient{services: services, jobs: jobs}, nil
+}
+
+// Close closes the connections to the API.
+func (c *AdminClient) Close() error {
+	return errors.Join(c.services.Close(), c.jobs.Close())
+}
+
+var (
+	defaultAdminOnce sync.Once
+	defaultAdmin     *AdminClient
+)
+
+// defaultAdminClient returns a shared AdminClient for services and jobs that
+// don't set one. It returns nil, so that gcloud is used, if the
+// CLOUDRUNCI_USE_GCLOUD environment variable is set or the client cannot be
+// created.
+func defaultAdminClient() *AdminClient {
+	defaultAdminOnce.Do(func() {
+		if os.Getenv("CLOUDRUNCI_USE_GCLOUD") != "" {
+			return
+		}
+		c, err := NewAdminClient(context.Background())
+		if err != nil {
+			log.Printf("cloudrunci: using gcloud, Admin API client unavailable: %v", err)
+			return
+		}
+		defaultAdmin = c
+	})
+	return defaultAdmin
+}
+
+// operation is a long-running operation returned by the run client.
+type operation[T any] interface {
+	Poll(ctx context.Context, opts ...gax.CallOption) (T, error)
+	Done() bool
+	Name() string
+}
+
+// wait polls op with exponential backoff until it is done.
+func wait[T any](ctx context.Context, label string, op operation[T]) (T, error) {
+	delay := opPollInitial
+	for {
+		res, err := op.Poll(ctx)
+		if err != nil || op.Done() {
+			return res, err
+		}
+		log.Printf("%s: waiting for operation %s", label, op.Name())
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, opPollMax)
+	}
+}
+
+// containers returns the container spec for image with env.
+func containers(image string, env EnvVars, http2 bool) []*runpb.Container {
+	c := &runpb.Container{Image: image}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c.Env = append(c.Env, &runpb.EnvVar{
+			Name:   k,
+			Values: &runpb.EnvVar_Value{Value: env[k]},
+		})
+	}
+	if http2 {
+		c.Ports = []*runpb.ContainerPort{{Name: "h2c", ContainerPort: 8080}}
+	}
+	return []*runpb.Container{c}
+}
+
+// deployService creates the service, or updates it with a new revision if it
+// already exists, and waits for the revision to be ready.
+func (c *AdminClient) deployService(ctx context.Context, parent, id string, s *Service) (*runpb.Service, error) {
+	label := s.operationLabel(labelOperationDeploy)
+	spec := &runpb.Service{
+		Labels:   map[string]string{"cloudrunci": "true"},
+		Template: &runpb.RevisionTemplate{Containers: containers(s.Image, s.Env, s.HTTP2)},
+	}
+	name := parent + "/services/" + id
+
+	var svc *runpb.Service
+	op, err := c.services.CreateService(ctx, &runpb.CreateServiceRequest{Parent: parent, ServiceId: id, Service: spec})
+	if status.Code(err) == codes.AlreadyExists {
+		spec.Name = name
+		uop, err := c.services.UpdateService(ctx, &runpb.UpdateServiceRequest{Service: spec})
+		if err != nil {
+			return nil, apiError("UpdateService", name, err)
+		}
+		if svc, err = wait(ctx, label, uop); err != nil {
+			return nil, apiError("UpdateService", name, err)
+		}
+	} else if err != nil {
+		return nil, apiError("CreateService", name, err)
+	} else if svc, err = wait(ctx, label, op); err != nil {
+		return nil, apiError("CreateService", name, err)
+	}
+
+	if cond := svc.GetTerminalCondition(); cond != nil && cond.GetState() != runpb.Condition_CONDITION_SUCCEEDED {
+		return nil, fmt.Errorf("service %s is not ready: %s", name, cond.GetMessage())
+	}
+
+	if s.AllowUnauthenticated {
+		if err := c.allowUnauthenticated(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	return svc, nil
+}
+
+// allowUnauthenticated grants allUsers the invoker role on a service. It
+// adds allUsers to an existing invoker binding, if there is one, and leaves
+// the policy alone if allUsers already has the role, so redeploying a
+// service doesn't grow its policy.
+func (c *AdminClient) allowUnauthenticated(ctx context.Context, name string) error {
+	const role, member = "roles/run.invoker", "allUsers"
+	policy, err := c.services.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: name})
+	if err != nil {
+		return apiError("GetIamPolicy", name, err)
+	}
+	var binding *iampb.Binding
+	for _, b := range policy.GetBindings() {
+		// Conditional bindings don't grant the role unconditionally.
+		if b.GetRole() == role && b.GetCondition() == nil {
+			binding = b
+			break
+		}
+	}
+	switch {
+	case binding == nil:
+		policy.Bindings = append(policy.Bindings, &iampb.Binding{Role: role, Members: []string{member}})
+	case slices.Contains(binding.GetMembers(), member):
+		return nil
+	default:
+		binding.Members = append(binding.Members, member)
+	}
+	if _, err := c.services.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{Resource: name, Policy: policy}); err != nil {
+		return apiError("SetIamPolicy", name, err)
+	}
+	return nil
+}
+
+func (c *AdminClient) getService(ctx context.Context, name string) (*runpb.Service, error) {
+	svc, err := c.services.GetService(ctx, &runpb.GetServiceRequest{Name: name})
+	return svc, apiError("GetService", name, err)
+}
+
+func (c *AdminClient) deleteService(ctx context.Context, label, name string) error {
+	op, err := c.services.DeleteService(ctx, &runpb.DeleteServiceRequest{Name: name})
+	if err != nil {
+		return apiError("DeleteService", name, err)
+	}
+	_, err = wait(ctx, label, op)
+	return apiError("DeleteService", name, err)
+}
+
+func (c *AdminClient) createJob(ctx context.Context, parent, id string, j *Job) error {
+	name := parent + "/jobs/" + id
+	op, err := c.jobs.CreateJob(ctx, &runpb.CreateJobRequest{
+		Parent: parent,
+		JobId:  id,
+		Job: &runpb.Job{
+			Labels: map[string]string{"cloudrunci": "true"},
+			Template: &runpb.ExecutionTemplate{
+				Template: &runpb.TaskTemplate{Containers: containers(j.Image, j.Env, false)},
+			},
+		},
+	})
+	if err != nil {
+		return apiError("CreateJob", name, err)
+	}
+	_, err = wait(ctx, fmt.Sprintf("%s: Creating Cloud Run Job", id), op)
+	return apiError("CreateJob", name, err)
+}
+
+// runJob starts an execution of the job and waits for it to finish.
+func (c *AdminClient) runJob(ctx context.Context, name string) (*runpb.Execution, error) {
+	op, err := c.jobs.RunJob(ctx, &runpb.RunJobRequest{Name: name})
+	if err != nil {
+		return nil, apiError("RunJob", name, err)
+	}
+	exec, err := wait(ctx, fmt.Sprintf("%s: Running cloud run job", name), op)
+	if err != nil {
+		return nil, apiError("RunJob", name, err)
+	}
+	if exec.GetFailedCount() > 0 || exec.GetCancelledCount() > 0 {
+		return exec, fmt.Errorf("execution %s: %d of %d tasks failed, %d cancelled", exec.GetName(), exec.GetFailedCount(), exec.GetTaskCount(), exec.GetCancelledCount())
+	}
+	return exec, nil
+}
+
+func (c *AdminClient) deleteJob(ctx context.Context, name string) error {
+	op, err := c.jobs.DeleteJob(ctx, &runpb.DeleteJobRequest{Name: name})
+	if err != nil {
+		return apiError("DeleteJob", name, err)
+	}
+	_, err = wait(ctx, fmt.Sprintf("%s: Deleting cloud run job", name), op)
+	return apiError("DeleteJob", name, err)
+}
+
+// TrafficTarget is the share of a service's traffic served by a revision.
+type TrafficTarget struct {
+	// Revision is the revision name. It is empty when Latest is set and
+	// the service has no ready revision yet.
+	Revision string
+	Percent  int32
+	Tag      string
+	// Latest reports whether the target follows the latest ready revision.
+	Latest bool
+	// URL is the address of a tagged target.
+	URL string
+}
+
+func trafficTargets(svc *runpb.Service) []TrafficTarget {
+	var targets []TrafficTarget
+	for _, t := range svc.GetTrafficStatuses() {
+		targets = append(targets, TrafficTarget{
+			Revision: t.GetRevision(),
+			Percent:  t.GetPercent(),
+			Tag:      t.GetTag(),
+			Latest:   t.GetType() == runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+			URL:      t.GetUri(),
+		})
+	}
+	return targets
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrunci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/run/fake"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func newFakeAdminClient(t *testing.T) (*fake.Server, *AdminClient) {
+	t.Helper()
+	server, opts := fake.New(t)
+	c, err := NewAdminClient(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("NewAdminClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return server, c
+}
+
+func TestAdminServiceLifecycle(t *testing.T) {
+	server, c := newFakeAdminClient(t)
+
+	service := NewService("my-service", "my-project")
+	service.Image = "us-docker.pkg.dev/my-project/cloudrunci/my-service:test"
+	service.Env = EnvVars{"NAME": "value"}
+	service.AllowUnauthenticated = true
+	service.Client = c
+
+	if err := service.Deploy(); err != nil {
+		t.Fatalf("service.Deploy: %v", err)
+	}
+	u, err := service.ParsedURL()
+	if err != nil {
+		t.Fatalf("service.ParsedURL: %v", err)
+	}
+	if want := "https://" + service.version() + "-fake.a.run.app"; u.String() != want {
+		t.Errorf("service.ParsedURL: got %s, want %s", u, want)
+	}
+
+	policy := server.Policy(service.resourceName())
+	if policy == nil || len(policy.GetBindings()) != 1 || policy.GetBindings()[0].GetMembers()[0] != "allUsers" {
+		t.Errorf("IAM policy: got %v, want allUsers bound", policy)
+	}
+
+	// Deploying again rolls out a new revision.
+	if err := service.Deploy(); err != nil {
+		t.Fatalf("service.Deploy (update): %v", err)
+	}
+	rev, err := service.LatestRevision()
+	if err != nil {
+		t.Fatalf("service.LatestRevision: %v", err)
+	}
+	if want := service.version() + "-00002-fake"; !strings.HasSuffix(rev, want) {
+		t.Errorf("service.LatestRevision: got %q, want suffix %q", rev, want)
+	}
+	// Redeploying leaves the policy alone instead of adding another binding.
+	if got := server.Policy(service.resourceName()); !proto.Equal(got, policy) {
+		t.Errorf("IAM policy after redeploy: got %v, want unchanged %v", got, policy)
+	}
+
+	traffic, err := service.Traffic()
+	if err != nil {
+		t.Fatalf("service.Traffic: %v", err)
+	}
+	if len(traffic) != 1 || traffic[0].Percent != 100 || !traffic[0].Latest || !strings.HasSuffix(rev, traffic[0].Revision) {
+		t.Errorf("service.Traffic: got %+v, want 100%% to the latest revision %s", traffic, rev)
+	}
+
+	if err := service.Clean(); err != nil {
+		t.Fatalf("service.Clean: %v", err)
+	}
+	if service.Deployed() {
+		t.Errorf("service.Deployed: got true after Clean")
+	}
+	err = service.Clean()
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("service.Clean twice: got %v, want ErrNotFound", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Op != "DeleteService" {
+		t.Errorf("service.Clean twice: got %v, want an APIError from DeleteService", err)
+	}
+}
+
+func TestAllowUnauthenticatedExistingBinding(t *testing.T) {
+	ctx := context.Background()
+	server, c := newFakeAdminClient(t)
+
+	service := NewService("my-service", "my-project")
+	service.Image = "us-docker.pkg.dev/my-project/cloudrunci/my-service:test"
+	service.Client = c
+	if err := service.Deploy(); err != nil {
+		t.Fatalf("service.Deploy: %v", err)
+	}
+	name := service.resourceName()
+	if _, err := c.services.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{
+		Resource: name,
+		Policy: &iampb.Policy{Bindings: []*iampb.Binding{
+			{Role: "roles/run.invoker", Members: []string{"user:alice@example.com"}},
+		}},
+	}); err != nil {
+		t.Fatalf("SetIamPolicy: %v", err)
+	}
+
+	for i := range 2 {
+		if err := c.allowUnauthenticated(ctx, name); err != nil {
+			t.Fatalf("allowUnauthenticated #%d: %v", i+1, err)
+		}
+	}
+	bindings := server.Policy(name).GetBindings()
+	want := []string{"user:alice@example.com", "allUsers"}
+	if len(bindings) != 1 || !slices.Equal(bindings[0].GetMembers(), want) {
+		t.Errorf("IAM policy bindings: got %v, want one run.invoker binding for %v", bindings, want)
+	}
+}
+
+func TestAdminJobLifecycle(t *testing.T) {
+	_, c := newFakeAdminClient(t)
+
+	job := NewJob("my-job", "my-project")
+	job.Image = "us-docker.pkg.dev/my-project/cloudrunci/my-job:test"
+	job.Client = c
+
+	if err := job.Run(); err != nil {
+		t.Fatalf("job.Run: %v", err)
+	}
+	if !job.created {
+		t.Errorf("job.Run did not create the job")
+	}
+	if err := job.Create(); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("job.Create twice: got %v, want ErrAlreadyExists", err)
+	}
+	if err := job.Clean(); err != nil {
+		t.Fatalf("job.Clean: %v", err)
+	}
+	if _, err := c.runJob(context.Background(), job.resourceName()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("runJob after Clean: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	for _, tc := range []struct {
+		code codes.Code
+		want error
+	}{
+		{codes.NotFound, ErrNotFound},
+		{codes.AlreadyExists, ErrAlreadyExists},
+		{codes.PermissionDenied, ErrPermissionDenied},
+	} {
+		err := fmt.Errorf("wrapped: %w", apiError("GetService", "name", status.Error(tc.code, "boom")))
+		if !errors.Is(err, tc.want) {
+			t.Errorf("errors.Is(%v, %v) = false, want true", err, tc.want)
+		}
+		if errors.Is(err, ErrNotFound) != (tc.want == ErrNotFound) {
+			t.Errorf("errors.Is(%v, ErrNotFound) matched a %v error", err, tc.code)
+		}
+	}
+	if err := apiError("GetService", "name", nil); err != nil {
+		t.Errorf("apiError(nil) = %v, want nil", err)
+	}
+}
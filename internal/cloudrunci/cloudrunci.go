@@ -15,7 +15,9 @@
 // Package cloudrunci facilitates end-to-end testing against the production Cloud Run.
 //
 // This is a specialized tool that could be used in addition to unit tests. It
-// calls the `gcloud beta run` command directly.
+// manages services and jobs with the Cloud Run Admin API, and calls the
+// `gcloud` command to build container images and for platforms the API does
+// not cover. Set CLOUDRUNCI_USE_GCLOUD to use gcloud for everything.
 //
 // gcloud (https://cloud.google.com/sdk) must be installed. You must be authorized via
 // the gcloud command-line tool (`gcloud auth login`).
@@ -30,13 +32,17 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"path"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/logging/logadmin"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
 )
 
 // labels are used in operation-related logs.
@@ -49,6 +55,9 @@ const (
 	defaultRegistryName         = "cloudrunci"
 )
 
+// logWaitTimeout bounds how long LogEntries polls for log ingestion.
+const logWaitTimeout = 10 * time.Minute
+
 // Service describes a Cloud Run service
 type Service struct {
 	// Name is an ID, used for logging and to generate a unique version to this run.
@@ -79,10 +88,19 @@ type Service struct {
 	// Strictly HTTP/2 serving
 	HTTP2 bool
 
+	// Client deploys, describes and deletes the service on the
+	// ManagedPlatform with the Cloud Run Admin API. If nil, a shared client
+	// is used, or gcloud if that client cannot be created or the
+	// CLOUDRUNCI_USE_GCLOUD environment variable is set. Container images
+	// are always built with gcloud.
+	Client *AdminClient
+
 	deployed bool     // Whether the service has been deployed.
 	built    bool     // Whether the container image has been built.
 	url      *url.URL // The url of the deployed service.
 
+	containerID string // The container running the service on LocalPlatform.
+
 	// Location to deploy the Service, and related artifacts
 	Location string
 }
@@ -93,15 +111,25 @@ var runID = time.Now().Format("20060102-150405")
 // NewService creates a new Service based on the name and projectID provided.
 // It will default to the ManagedPlatform in region us-central1,
 // and build a container image as needed  for deployment.
+// If the CLOUDRUNCI_PLATFORM environment variable is "local", it defaults to
+// the LocalPlatform instead.
 func NewService(name, projectID string) *Service {
 	return &Service{
 		Name:      name,
 		ProjectID: projectID,
-		Platform:  ManagedPlatform{Region: "us-central1"},
+		Platform:  defaultPlatform(),
 		Location:  "us-central1",
 	}
 }
 
+// defaultPlatform returns the platform selected by CLOUDRUNCI_PLATFORM.
+func defaultPlatform() Platform {
+	if os.Getenv("CLOUDRUNCI_PLATFORM") == "local" {
+		return LocalPlatform{}
+	}
+	return ManagedPlatform{Region: "us-central1"}
+}
+
 // Deployed reports whether the service has been deployed.
 func (s *Service) Deployed() bool {
 	return s.deployed
@@ -226,6 +254,10 @@ func (s *Service) Host() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("service.ParsedURL: %w", err)
 	}
+	if s.isLocal() {
+		// The local URL carries its port.
+		return u.Host, nil
+	}
 	return u.Host + ":443", nil
 }
 
@@ -235,6 +267,17 @@ func (s *Service) ParsedURL() (*url.URL, error) {
 	if !s.deployed {
 		return nil, errors.New("URL called before Deploy")
 	}
+	if s.url == nil && s.admin() != nil {
+		svc, err := s.describe()
+		if err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(svc.GetUri())
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse: %w", err)
+		}
+		s.url = u
+	}
 	if s.url == nil {
 		out, err := gcloud(s.operationLabel(labelOperationGetURL), s.urlCmd())
 		if err != nil {
@@ -254,7 +297,7 @@ func (s *Service) ParsedURL() (*url.URL, error) {
 
 // validate confirms all required service properties are present.
 func (s *Service) validate() error {
-	if s.ProjectID == "" {
+	if s.ProjectID == "" && !s.isLocal() {
 		return errors.New("Project ID missing")
 	}
 	if s.Platform == nil {
@@ -292,6 +335,24 @@ func (s *Service) Deploy() error {
 		}
 	}
 
+	if s.isLocal() {
+		return s.deployLocal()
+	}
+
+	if c := s.admin(); c != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+		svc, err := c.deployService(ctx, s.parent(), s.version(), s)
+		if err != nil {
+			return err
+		}
+		if s.url, err = url.Parse(svc.GetUri()); err != nil {
+			return fmt.Errorf("url.Parse: %w", err)
+		}
+		s.deployed = true
+		return nil
+	}
+
 	if _, err := gcloud(s.operationLabel(labelOperationDeploy), s.deployCmd()); err != nil {
 		return fmt.Errorf("gcloud: %s: %q", s.version(), err)
 	}
@@ -312,6 +373,9 @@ func (s *Service) Build() error {
 	if s.built {
 		return fmt.Errorf("container image already built")
 	}
+	if s.isLocal() {
+		return s.buildLocal()
+	}
 	if s.Image == "" {
 		err := s.ensureDefaultImageRepo()
 		if err != nil {
@@ -338,10 +402,21 @@ func (s *Service) Clean() error {
 		return err
 	}
 
-	if _, err := gcloud(s.operationLabel(labelOperationDeleteService), s.deleteServiceCmd()); err != nil {
+	if s.isLocal() {
+		return s.cleanLocal()
+	}
+
+	if c := s.admin(); c != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+		if err := c.deleteService(ctx, s.operationLabel(labelOperationDeleteService), s.resourceName()); err != nil {
+			return err
+		}
+	} else if _, err := gcloud(s.operationLabel(labelOperationDeleteService), s.deleteServiceCmd()); err != nil {
 		return fmt.Errorf("gcloud: %v: %q", s.version(), err)
 	}
 	s.deployed = false
+	s.url = nil
 
 	// If s.built is false no image was created or is not managed by cloudrun-ci.
 	if s.built {
@@ -355,6 +430,70 @@ func (s *Service) Clean() error {
 	return nil
 }
 
+// admin returns the Admin API client for the service, or nil to use gcloud.
+func (s *Service) admin() *AdminClient {
+	if _, ok := s.Platform.(ManagedPlatform); !ok {
+		return nil
+	}
+	if s.Client != nil {
+		return s.Client
+	}
+	return defaultAdminClient()
+}
+
+// parent returns the Admin API name of the location of the service.
+func (s *Service) parent() string {
+	region := s.Location
+	if p, ok := s.Platform.(ManagedPlatform); ok {
+		region = p.Region
+	}
+	return fmt.Sprintf("projects/%s/locations/%s", s.ProjectID, region)
+}
+
+// resourceName returns the Admin API name of the service.
+func (s *Service) resourceName() string {
+	return s.parent() + "/services/" + s.version()
+}
+
+// describe gets the deployed service with the Admin API.
+func (s *Service) describe() (*runpb.Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+	return s.admin().getService(ctx, s.resourceName())
+}
+
+// Traffic reports how traffic is split across the revisions of the deployed
+// service. It requires the Admin API.
+func (s *Service) Traffic() ([]TrafficTarget, error) {
+	if !s.deployed {
+		return nil, errors.New("Traffic called before Deploy")
+	}
+	if s.admin() == nil {
+		return nil, errors.New("Traffic requires the Cloud Run Admin API")
+	}
+	svc, err := s.describe()
+	if err != nil {
+		return nil, err
+	}
+	return trafficTargets(svc), nil
+}
+
+// LatestRevision returns the name of the latest ready revision of the
+// deployed service. It requires the Admin API.
+func (s *Service) LatestRevision() (string, error) {
+	if !s.deployed {
+		return "", errors.New("LatestRevision called before Deploy")
+	}
+	if s.admin() == nil {
+		return "", errors.New("LatestRevision requires the Cloud Run Admin API")
+	}
+	svc, err := s.describe()
+	if err != nil {
+		return "", err
+	}
+	return svc.GetLatestReadyRevision(), nil
+}
+
 func (s *Service) operationLabel(op string) string {
 	return fmt.Sprintf("operation [%s] for service [%s]", op, s.Name)
 }
@@ -473,30 +612,45 @@ func (s *Service) urlCmd() *exec.Cmd {
 	return cmd
 }
 
+// LogEntries reports whether a log entry of the service matching filter
+// contains find. Log ingestion is eventually consistent, so it polls with
+// exponential backoff for up to maxAttempts attempts.
+// On the LocalPlatform the container output is searched and filter is ignored.
 func (s *Service) LogEntries(filter string, find string, maxAttempts int) (bool, error) {
-	ctx := context.Background()
-	client, err := logadmin.NewClient(ctx, s.ProjectID)
+	if s.isLocal() {
+		return s.localLogEntries(find, maxAttempts)
+	}
+	preparedFilter := fmt.Sprintf(`resource.type="cloud_run_revision" resource.labels.service_name="%s" %s`, s.version(), filter)
+	return findLogEntry(s.ProjectID, preparedFilter, find, maxAttempts)
+}
+
+// findLogEntry polls Cloud Logging until an entry matching filter contains
+// find, maxAttempts are used up, or logWaitTimeout passes. Errors reading logs
+// are returned unless they are transient.
+func findLogEntry(projectID, filter, find string, maxAttempts int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), logWaitTimeout)
+	defer cancel()
+	client, err := logadmin.NewClient(ctx, projectID)
 	if err != nil {
 		return false, fmt.Errorf("logadmin.NewClient: %w", err)
 	}
 	defer client.Close()
 
-	preparedFilter := fmt.Sprintf(`resource.type="cloud_run_revision" resource.labels.service_name="%s" %s`, s.version(), filter)
-	log.Printf("Using log filter: %s\n", preparedFilter)
-
-	log.Println("Waiting for logs...")
-	time.Sleep(3 * time.Minute)
+	log.Printf("Using log filter: %s\n", filter)
 
-	for i := 1; i < maxAttempts; i++ {
-		log.Printf("Attempt #%d\n", i)
-		it := client.Entries(ctx, logadmin.Filter(preparedFilter))
+	var found bool
+	var readErr error
+	err = testutil.RetryContextWithoutTest(ctx, func(r *testutil.R) {
+		log.Printf("Attempt #%d\n", r.Attempt)
+		it := client.Entries(r.Context(), logadmin.Filter(filter))
 		for {
 			entry, err := it.Next()
 			if err == iterator.Done {
 				break
 			}
-			if err != nil {
-				return false, fmt.Errorf("it.Next: %w", err)
+			if r.Check(err) {
+				readErr = fmt.Errorf("it.Next: %w", err)
+				return
 			}
 			payload := fmt.Sprintf("%v", entry.Payload)
 			if len(payload) > 0 {
@@ -504,12 +658,22 @@ func (s *Service) LogEntries(filter string, find string, maxAttempts int) (bool,
 			}
 			if strings.Contains(payload, find) {
 				log.Printf("%q log entry found.\n", find)
-				return true, nil
+				found = true
+				return
 			}
 		}
-		time.Sleep(15 * time.Second)
-	}
-	return false, nil
+		readErr = nil
+		r.Errorf("%q log entry not found yet", find)
+	},
+		testutil.WithMaxAttempts(maxAttempts),
+		testutil.WithBackoff(15*time.Second, 2*time.Minute, 1.5),
+		testutil.WithRetryableCodes(codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Internal),
+	)
+	if found {
+		return true, nil
+	}
+	log.Printf("Giving up on log entries: %v", err)
+	return false, readErr
 }
 
 // ensureDefaultImageRepo creates a default docker repo in the given project and location
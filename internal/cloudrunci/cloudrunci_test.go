@@ -17,6 +17,9 @@ package cloudrunci
 import (
 	"fmt"
 	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -120,3 +123,102 @@ func contains(haystack []string, needle string) bool {
 	}
 	return false
 }
+
+func TestNewServicePlatform(t *testing.T) {
+	t.Setenv("CLOUDRUNCI_PLATFORM", "")
+	if p := NewService("my-service", "my-project").Platform; p.Name() != "managed" {
+		t.Errorf("default platform: got %q, want %q", p.Name(), "managed")
+	}
+
+	t.Setenv("CLOUDRUNCI_PLATFORM", "local")
+	service := NewService("my-service", "")
+	if p := service.Platform; p.Name() != "local" {
+		t.Errorf("CLOUDRUNCI_PLATFORM=local platform: got %q, want %q", p.Name(), "local")
+	}
+	if err := service.validate(); err != nil && strings.Contains(err.Error(), "Project ID") {
+		t.Errorf("service.validate: local services need no project: %v", err)
+	}
+}
+
+func TestLocalRunArgs(t *testing.T) {
+	service := &Service{
+		Name:     "my-service",
+		Image:    "cloudrunci/my-service:test",
+		Platform: LocalPlatform{},
+		Env:      EnvVars{"NAME": "value"},
+	}
+	args := service.localRunArgs()
+	for _, want := range []string{"NAME=value", "PORT=8080", "K_SERVICE=" + service.version(), "127.0.0.1::8080"} {
+		if !contains(args, want) {
+			t.Errorf("localRunArgs: missing %q in %q", want, args)
+		}
+	}
+	if got := args[len(args)-1]; got != service.Image {
+		t.Errorf("localRunArgs: last argument got %q, want image %q", got, service.Image)
+	}
+
+	service.AsBuildpack = true
+	if cmd := service.localBuildCmd(); !contains(cmd.Args, defaultBuilder) {
+		t.Errorf("localBuildCmd: missing builder in %q", cmd.Args)
+	}
+}
+
+func TestParseDockerPort(t *testing.T) {
+	got, err := parseDockerPort("127.0.0.1:49153\n[::1]:49153\n")
+	if err != nil {
+		t.Fatalf("parseDockerPort: %v", err)
+	}
+	if want := "127.0.0.1:49153"; got != want {
+		t.Errorf("parseDockerPort: got %q, want %q", got, want)
+	}
+	if _, err := parseDockerPort("Error: No public port '8080/tcp' published"); err == nil {
+		t.Errorf("parseDockerPort: want error for unexpected output")
+	}
+}
+
+func TestDockerOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as docker")
+	}
+	fakeDocker := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/sh
+echo "Unable to find image 'my-image:test' locally" >&2
+if [ "$1" = "fail" ]; then
+	echo "docker: Error response from daemon: pull access denied." >&2
+	exit 125
+fi
+echo "0123abcd"
+`
+	if err := os.WriteFile(fakeDocker, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(bin string) { dockerBin = bin }(dockerBin)
+	dockerBin = fakeDocker
+
+	id, err := dockerOutput("run", "run", "my-image:test")
+	if err != nil {
+		t.Fatalf("dockerOutput: %v", err)
+	}
+	if id != "0123abcd" {
+		t.Errorf("dockerOutput: got %q, want only the container ID from stdout", id)
+	}
+
+	_, err = dockerOutput("run", "fail")
+	if err == nil || !strings.Contains(err.Error(), "pull access denied") {
+		t.Errorf("dockerOutput: got error %v, want it to include stderr", err)
+	}
+}
+
+func TestLocalServiceHost(t *testing.T) {
+	service := &Service{Name: "my-service", Platform: LocalPlatform{}}
+	service.url = &url.URL{Scheme: "http", Host: "127.0.0.1:49153"}
+	service.deployed = true
+
+	got, err := service.Host()
+	if err != nil {
+		t.Fatalf("service.Host: %v", err)
+	}
+	if want := "127.0.0.1:49153"; got != want {
+		t.Errorf("service.Host: got %s, want %s", got, want)
+	}
+}
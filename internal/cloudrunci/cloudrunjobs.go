@@ -15,7 +15,9 @@
 // Package cloudrunci facilitates end-to-end testing against the production Cloud Run.
 //
 // This is a specialized tool that could be used in addition to unit tests. It
-// calls the `gcloud beta run` command directly.
+// manages services and jobs with the Cloud Run Admin API, and calls the
+// `gcloud` command to build container images and for platforms the API does
+// not cover. Set CLOUDRUNCI_USE_GCLOUD to use gcloud for everything.
 //
 // gcloud (https://cloud.google.com/sdk) must be installed. You must be authorized via
 // the gcloud command-line tool (`gcloud auth login`).
@@ -28,11 +30,6 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
-	"strings"
-	"time"
-
-	"cloud.google.com/go/logging/logadmin"
-	"google.golang.org/api/iterator"
 )
 
 // Job describes a Cloud Run Job
@@ -70,6 +67,12 @@ type Job struct {
 	// Build this Image as a BuildPack, without using a Dockerfile
 	AsBuildpack bool
 
+	// Client creates, runs and deletes the job with the Cloud Run Admin API.
+	// If nil, a shared client is used, or gcloud if that client cannot be
+	// created or the CLOUDRUNCI_USE_GCLOUD environment variable is set. Jobs
+	// with ExtraCreateFlags always use gcloud.
+	Client *AdminClient
+
 	built   bool // True if container image has been built.
 	created bool // True if job has been created.
 	started bool // true if the Job has been started.
@@ -130,7 +133,13 @@ func (j *Job) Create() error {
 		}
 	}
 
-	if _, err := gcloud(fmt.Sprintf("%s: Creating Cloud Run Job", j.version()), j.createCmd()); err != nil {
+	if c := j.admin(); c != nil && len(j.ExtraCreateFlags) == 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+		if err := c.createJob(ctx, j.parent(), j.version(), j); err != nil {
+			return err
+		}
+	} else if _, err := gcloud(fmt.Sprintf("%s: Creating Cloud Run Job", j.version()), j.createCmd()); err != nil {
 		return fmt.Errorf("gcloud: %s: %q", j.version(), err)
 	}
 
@@ -174,6 +183,12 @@ func (j *Job) Run() error {
 			return err
 		}
 	}
+	if c := j.admin(); c != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+		_, err := c.runJob(ctx, j.resourceName())
+		return err
+	}
 	if _, err := gcloud(fmt.Sprintf("%s: Running cloud run job", j.version()), j.runCmd()); err != nil {
 		return fmt.Errorf("gcloud: %v: %q", j.version(), err)
 	}
@@ -189,7 +204,13 @@ func (j *Job) Clean() error {
 		return err
 	}
 
-	if _, err := gcloud(fmt.Sprintf("%s: Deleting cloud run job", j.version()), j.deleteJobCmd()); err != nil {
+	if c := j.admin(); c != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		defer cancel()
+		if err := c.deleteJob(ctx, j.resourceName()); err != nil {
+			return err
+		}
+	} else if _, err := gcloud(fmt.Sprintf("%s: Deleting cloud run job", j.version()), j.deleteJobCmd()); err != nil {
 		return fmt.Errorf("gcloud: %v: %q", j.version(), err)
 	}
 	j.created = false
@@ -206,6 +227,24 @@ func (j *Job) Clean() error {
 	return nil
 }
 
+// admin returns the Admin API client for the job, or nil to use gcloud.
+func (j *Job) admin() *AdminClient {
+	if j.Client != nil {
+		return j.Client
+	}
+	return defaultAdminClient()
+}
+
+// parent returns the Admin API name of the location of the job.
+func (j *Job) parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", j.ProjectID, j.Region)
+}
+
+// resourceName returns the Admin API name of the job.
+func (j *Job) resourceName() string {
+	return j.parent() + "/jobs/" + j.version()
+}
+
 func (j *Job) createCmd() *exec.Cmd {
 	args := append([]string{
 		"--quiet",
@@ -297,38 +336,9 @@ func (j *Job) deleteJobCmd() *exec.Cmd {
 	return cmd
 }
 
+// LogEntries reports whether a log entry of the job matching filter contains
+// find. It polls with exponential backoff for up to maxAttempts attempts.
 func (j *Job) LogEntries(filter string, find string, maxAttempts int) (bool, error) {
-	ctx := context.Background()
-	client, err := logadmin.NewClient(ctx, j.ProjectID)
-	if err != nil {
-		return false, fmt.Errorf("logadmin.NewClient: %w", err)
-	}
-	defer client.Close()
-
 	preparedFilter := fmt.Sprintf(`resource.type="cloud_run_job" resource.labels.job_name="%s" %s`, j.version(), filter)
-	fmt.Printf("Using log filter: %s\n", preparedFilter)
-
-	for i := 1; i < maxAttempts; i++ {
-		fmt.Printf("Attempt #%d\n", i)
-		it := client.Entries(ctx, logadmin.Filter(preparedFilter))
-		for {
-			entry, err := it.Next()
-			if err == iterator.Done {
-				break
-			}
-			if err != nil {
-				return false, fmt.Errorf("it.Next: %w", err)
-			}
-			payload := fmt.Sprintf("%v", entry.Payload)
-			if len(payload) > 0 {
-				fmt.Printf("entry.Payload: %v\n", entry.Payload)
-			}
-			if strings.Contains(payload, find) {
-				fmt.Printf("%q log entry found.\n", find)
-				return true, nil
-			}
-		}
-		time.Sleep(30 * time.Second)
-	}
-	return false, nil
+	return findLogEntry(j.ProjectID, preparedFilter, find, maxAttempts)
 }
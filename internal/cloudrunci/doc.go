@@ -87,5 +87,25 @@ Configure the service for deploying to a Cloud Run for Anthos on VMWare cluster:
 		ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT"),
 		Platform:  cloudrunci.KubernetesPlatform{Kubeconfig: "~/.kubeconfig", Context: "my-cluster"},
 	}
+
+Configure the service to build and run in a container on the local Docker daemon, without gcloud:
+
+	myService := &cloudrunci.Service{
+		Name:     "my-service",
+		Dir:      "../my-service",
+		Platform: cloudrunci.LocalPlatform{},
+	}
+
+Setting CLOUDRUNCI_PLATFORM=local makes NewService use the LocalPlatform, so
+existing tests run their services in local containers instead of on Cloud
+Run. It only replaces the deployment. The end-to-end tests in run/testing get
+their test context from testutil.EndToEndTest, so they are still skipped
+unless GOLANG_SAMPLES_E2E_TEST is set, and fail unless
+GOLANG_SAMPLES_PROJECT_ID is set, even though the local containers don't use
+the project. Samples that call other Google Cloud APIs, such as Pub/Sub or
+Cloud Storage, still need those APIs and credentials. For example, from the
+run/testing directory:
+
+	GOLANG_SAMPLES_E2E_TEST=1 GOLANG_SAMPLES_PROJECT_ID=my-project CLOUDRUNCI_PLATFORM=local go test -run TestHelloworldService
 */
 package cloudrunci
@@ -0,0 +1,261 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudrunci
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// localPort is the port the container listens on, passed as $PORT like
+	// Cloud Run does.
+	localPort = "8080"
+
+	// localStartTimeout bounds how long Deploy waits for a local container
+	// to accept connections.
+	localStartTimeout = 2 * time.Minute
+
+	defaultBuilder = "gcr.io/buildpacks/builder:v1"
+)
+
+// dockerBin and packBin are the paths to the docker and pack executables.
+var dockerBin, packBin string
+
+func init() {
+	dockerBin = os.Getenv("DOCKER_BIN")
+	if dockerBin == "" {
+		dockerBin = "docker"
+	}
+	packBin = os.Getenv("PACK_BIN")
+	if packBin == "" {
+		packBin = "pack"
+	}
+}
+
+// LocalPlatform runs services as containers on the local Docker daemon
+// instead of deploying them to Cloud Run. It needs neither gcloud nor a
+// project, so tests can run offline.
+//
+// The container image is built from Service.Dir with docker, or with the
+// pack CLI when Service.AsBuildpack is set. The container gets Service.Env
+// and the PORT, K_SERVICE, K_REVISION and K_CONFIGURATION variables set by
+// Cloud Run, and is published on a random localhost port. Requests are not
+// authenticated.
+type LocalPlatform struct {
+	platformBase
+	// Builder is the buildpacks builder image. It defaults to the Google
+	// Cloud buildpacks builder.
+	Builder string
+}
+
+// Name retrieves the ID for the local platform.
+func (p LocalPlatform) Name() string {
+	return "local"
+}
+
+// Validate confirms docker is installed.
+func (p LocalPlatform) Validate() error {
+	if _, err := exec.LookPath(dockerBin); err != nil {
+		return fmt.Errorf("docker not found: %w", err)
+	}
+	return nil
+}
+
+// CommandFlags returns no flags, since the local platform does not use gcloud.
+func (p LocalPlatform) CommandFlags() []string {
+	return nil
+}
+
+func (p LocalPlatform) builder() string {
+	if p.Builder == "" {
+		return defaultBuilder
+	}
+	return p.Builder
+}
+
+// isLocal reports whether the service runs on the local platform.
+func (s *Service) isLocal() bool {
+	_, ok := s.Platform.(LocalPlatform)
+	return ok
+}
+
+// buildLocal builds the container image into the local Docker daemon.
+func (s *Service) buildLocal() error {
+	if s.Image == "" {
+		s.Image = fmt.Sprintf("cloudrunci/%s:%s", strings.ToLower(s.Name), runID)
+	}
+	if out, err := gcloudWithoutRetry(s.operationLabel(labelOperationBuild), s.localBuildCmd()); err != nil {
+		log.Print(string(out))
+		return fmt.Errorf("local build: %s: %w", s.Image, err)
+	}
+	s.built = true
+	return nil
+}
+
+func (s *Service) localBuildCmd() *exec.Cmd {
+	var cmd *exec.Cmd
+	if s.AsBuildpack {
+		cmd = exec.Command(packBin, "build", s.Image, "--builder", s.Platform.(LocalPlatform).builder())
+	} else {
+		cmd = exec.Command(dockerBin, "build", "--tag", s.Image, ".")
+	}
+	cmd.Dir = s.Dir
+	return cmd
+}
+
+// deployLocal starts a container for the service and waits for it to accept
+// connections.
+func (s *Service) deployLocal() error {
+	id, err := dockerOutput(s.operationLabel(labelOperationDeploy), s.localRunArgs()...)
+	if err != nil {
+		return fmt.Errorf("docker run: %s: %w", s.version(), err)
+	}
+	s.containerID = id
+
+	out, err := gcloudWithoutRetry(s.operationLabel(labelOperationGetURL), exec.Command(dockerBin, "port", s.containerID, localPort+"/tcp"))
+	if err != nil {
+		return fmt.Errorf("docker port: %s: %w", s.version(), err)
+	}
+	host, err := parseDockerPort(string(out))
+	if err != nil {
+		return err
+	}
+	s.url = &url.URL{Scheme: "http", Host: host}
+
+	if err := s.waitForLocal(); err != nil {
+		return err
+	}
+	s.deployed = true
+	return nil
+}
+
+// dockerOutput runs docker with args and returns its trimmed stdout, such as
+// the container ID printed by docker run. Stderr, where docker writes pull
+// progress and warnings, is kept out of the result and included in the error
+// if the command fails.
+func dockerOutput(label string, args ...string) (string, error) {
+	cmd := exec.Command(dockerBin, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	log.Printf("Running: %s...", label)
+	log.Printf("Executing: %s: %s: %s", label, cmd.Path, strings.Join(args, " "))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", label, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (s *Service) localRunArgs() []string {
+	args := []string{
+		"run",
+		"--detach",
+		"--publish", "127.0.0.1::" + localPort,
+		"--label", "cloudrunci=" + s.Name,
+		"--env", "PORT=" + localPort,
+		"--env", "K_SERVICE=" + s.version(),
+		"--env", "K_REVISION=" + s.version() + "-00001",
+		"--env", "K_CONFIGURATION=" + s.version(),
+	}
+	for k := range s.Env {
+		args = append(args, "--env", s.Env.Variable(k))
+	}
+	return append(args, s.Image)
+}
+
+// parseDockerPort returns the first host:port in the output of docker port,
+// which lists one mapping per line.
+func parseDockerPort(out string) (string, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	if _, _, err := net.SplitHostPort(line); err != nil {
+		return "", fmt.Errorf("unexpected docker port output %q: %w", out, err)
+	}
+	return line, nil
+}
+
+// waitForLocal waits until the container accepts connections. It fails fast
+// if the container exits, including its logs in the error.
+func (s *Service) waitForLocal() error {
+	deadline := time.Now().Add(localStartTimeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", s.url.Host, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		out, err := exec.Command(dockerBin, "inspect", "--format", "{{.State.Running}}", s.containerID).Output()
+		if err == nil && strings.TrimSpace(string(out)) == "false" {
+			logs, _ := s.localLogs()
+			return fmt.Errorf("container for %s exited during startup:\n%s", s.version(), logs)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("container for %s not listening on %s after %v", s.version(), s.url.Host, localStartTimeout)
+}
+
+func (s *Service) localLogs() (string, error) {
+	if s.containerID == "" {
+		return "", errors.New("no container")
+	}
+	out, err := exec.Command(dockerBin, "logs", s.containerID).CombinedOutput()
+	return string(out), err
+}
+
+// cleanLocal removes the service's container and the image it built.
+func (s *Service) cleanLocal() error {
+	if s.containerID != "" {
+		if _, err := gcloudWithoutRetry(s.operationLabel(labelOperationDeleteService), exec.Command(dockerBin, "rm", "--force", s.containerID)); err != nil {
+			return fmt.Errorf("docker rm: %s: %w", s.version(), err)
+		}
+		s.containerID = ""
+	}
+	s.deployed = false
+	s.url = nil
+
+	if s.built {
+		if _, err := gcloudWithoutRetry(s.operationLabel(labelOperationDeleteImage), exec.Command(dockerBin, "rmi", "--force", s.Image)); err != nil {
+			return fmt.Errorf("docker rmi: %s: %w", s.Image, err)
+		}
+		s.built = false
+	}
+	return nil
+}
+
+// localLogEntries reports whether the container's output contains find,
+// polling up to maxAttempts times. The log filter does not apply locally.
+func (s *Service) localLogEntries(find string, maxAttempts int) (bool, error) {
+	for i := 1; i <= maxAttempts; i++ {
+		logs, err := s.localLogs()
+		if err != nil {
+			return false, fmt.Errorf("docker logs: %w", err)
+		}
+		if strings.Contains(logs, find) {
+			log.Printf("%q log entry found.\n", find)
+			return true, nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return false, nil
+}
@@ -63,3 +63,16 @@ func TestKubernetesPlatformRequest(t *testing.T) {
 		t.Errorf("KubernetesPlatform.Request: unexpected authentication header: %q", err)
 	}
 }
+
+func TestLocalPlatformRequest(t *testing.T) {
+	p := cloudrunci.LocalPlatform{}
+
+	req, err := p.NewRequest("GET", "http://127.0.0.1:8080")
+	if err != nil {
+		t.Errorf("LocalPlatform.Request: %q", err)
+	}
+	authzHeader := req.Header.Get("Authorization")
+	if authzHeader != "" {
+		t.Errorf("LocalPlatform.Request: unexpected authentication header: %q", authzHeader)
+	}
+}
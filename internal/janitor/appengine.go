@@ -0,0 +1,93 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appengine "google.golang.org/api/appengine/v1"
+)
+
+// aeVersionCleaner deletes App Engine versions in every service of the app.
+// A version that receives traffic cannot be deleted and is reported as a
+// failure.
+type aeVersionCleaner struct {
+	svc *appengine.APIService
+}
+
+func newAEVersionCleaner(ctx context.Context) (Cleaner, error) {
+	svc, err := appengine.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &aeVersionCleaner{svc: svc}, nil
+}
+
+func (c *aeVersionCleaner) Name() string { return "appengine-versions" }
+
+func (c *aeVersionCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var services []string
+	if err := c.svc.Apps.Services.List(project).Pages(ctx, func(resp *appengine.ListServicesResponse) error {
+		for _, s := range resp.Services {
+			services = append(services, s.Id)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	for _, service := range services {
+		if err := c.svc.Apps.Services.Versions.List(project, service).Pages(ctx, func(resp *appengine.ListVersionsResponse) error {
+			for _, v := range resp.Versions {
+				resources = append(resources, Resource{
+					Name:    v.Name,
+					ID:      v.Id,
+					Created: parseTime(v.CreateTime),
+				})
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("service %q: %w", service, err)
+		}
+	}
+	return resources, nil
+}
+
+func (c *aeVersionCleaner) Delete(ctx context.Context, r Resource) error {
+	// r.Name is "apps/{project}/services/{service}/versions/{version}".
+	parts := strings.Split(r.Name, "/")
+	if len(parts) != 6 {
+		return fmt.Errorf("unexpected version name %q", r.Name)
+	}
+	project, service, version := parts[1], parts[3], parts[5]
+	op, err := c.svc.Apps.Services.Versions.Delete(project, service, version).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	opID := lastSegment(op.Name)
+	return waitForOperation(ctx, func() (bool, error) {
+		op, err = c.svc.Apps.Operations.Get(project, opID).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		if op.Done && op.Error != nil {
+			return true, fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
+		}
+		return op.Done, nil
+	})
+}
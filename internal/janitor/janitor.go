@@ -0,0 +1,209 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Resource is a cloud resource that a Cleaner may delete.
+type Resource struct {
+	// Name is the full resource name, used for logging and deletion.
+	Name string
+	// ID is the user-chosen part of the name that prefix filters match, such
+	// as a bucket name or topic ID.
+	ID string
+	// Created is when the resource was created. It is zero if unknown.
+	Created time.Time
+	Labels  map[string]string
+}
+
+// Cleaner lists and deletes one type of resource.
+type Cleaner interface {
+	// Name identifies the resource type, e.g. "pubsub-topics".
+	Name() string
+	// List returns all resources of the type in project.
+	List(ctx context.Context, project string) ([]Resource, error)
+	// Delete deletes a resource returned by List, waiting for the deletion
+	// to complete when the API makes that possible.
+	Delete(ctx context.Context, r Resource) error
+}
+
+// Filter selects the resources to delete. A resource must match every
+// condition that is set.
+type Filter struct {
+	// MinAge is how old a resource must be. Resources with an unknown
+	// creation time never match when MinAge is set.
+	MinAge time.Duration
+	// Prefixes, if any, must include a prefix of the resource ID.
+	Prefixes []string
+	// Labels must all be present on the resource with the same values.
+	Labels map[string]string
+}
+
+// Match reports whether r should be deleted at time now.
+func (f Filter) Match(r Resource, now time.Time) bool {
+	if f.MinAge > 0 && (r.Created.IsZero() || now.Sub(r.Created) < f.MinAge) {
+		return false
+	}
+	if len(f.Prefixes) > 0 {
+		var ok bool
+		for _, p := range f.Prefixes {
+			if strings.HasPrefix(r.ID, p) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for k, v := range f.Labels {
+		if got, ok := r.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Result summarizes the work done by one Cleaner.
+type Result struct {
+	Cleaner string   `json:"cleaner"`
+	Listed  int      `json:"listed"`
+	Matched int      `json:"matched"`
+	Deleted int      `json:"deleted"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// Janitor deletes the resources selected by Filter using Cleaners.
+type Janitor struct {
+	Project  string
+	Cleaners []Cleaner
+	Filter   Filter
+	// DryRun logs the resources that would be deleted without deleting them.
+	DryRun bool
+	// Concurrency limits how many deletions run at once, across cleaners.
+	Concurrency int
+	// now returns the current time; tests override it.
+	now func() time.Time
+}
+
+// Run lists and deletes resources with every cleaner and returns one result
+// per cleaner, in the order of Cleaners.
+func (j *Janitor) Run(ctx context.Context) []*Result {
+	now := time.Now
+	if j.now != nil {
+		now = j.now
+	}
+	concurrency := j.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	results := make([]*Result, len(j.Cleaners))
+	for i, c := range j.Cleaners {
+		res := &Result{Cleaner: c.Name()}
+		results[i] = res
+
+		resources, err := c.List(ctx, j.Project)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("list: %v", err))
+			log.Printf("%s: could not list resources: %v", c.Name(), err)
+			continue
+		}
+		res.Listed = len(resources)
+
+		var mu sync.Mutex
+		for _, r := range resources {
+			if !j.Filter.Match(r, now()) {
+				continue
+			}
+			res.Matched++
+			if j.DryRun {
+				log.Printf("%s: would delete %s", c.Name(), r.Name)
+				continue
+			}
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(c Cleaner, r Resource) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				log.Printf("%s: deleting %s", c.Name(), r.Name)
+				err := c.Delete(ctx, r)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					res.Failed++
+					res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", r.Name, err))
+					log.Printf("%s: FAILED to delete %s: %v", c.Name(), r.Name, err)
+					return
+				}
+				res.Deleted++
+			}(c, r)
+		}
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		sort.Strings(res.Errors)
+	}
+	return results
+}
+
+// writeReport writes a table summarizing results to w.
+func writeReport(w io.Writer, results []*Result, dryRun bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	deleted := "DELETED"
+	if dryRun {
+		deleted = "DELETED (DRY RUN)"
+	}
+	fmt.Fprintf(tw, "CLEANER\tLISTED\tMATCHED\t%s\tFAILED\n", deleted)
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", r.Cleaner, r.Listed, r.Matched, r.Deleted, r.Failed)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		for _, e := range r.Errors {
+			fmt.Fprintf(w, "%s: %s\n", r.Cleaner, e)
+		}
+	}
+	return nil
+}
+
+// failed reports whether any cleaner could not list or delete resources.
+func failed(results []*Result) bool {
+	for _, r := range results {
+		if len(r.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFilterMatch(t *testing.T) {
+	old := Resource{ID: "test-old", Created: now.Add(-48 * time.Hour), Labels: map[string]string{"owner": "ci"}}
+	young := Resource{ID: "test-young", Created: now.Add(-time.Hour)}
+	unknown := Resource{ID: "test-unknown"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		r      Resource
+		want   bool
+	}{
+		{name: "empty filter", r: unknown, want: true},
+		{name: "old enough", filter: Filter{MinAge: 24 * time.Hour}, r: old, want: true},
+		{name: "too young", filter: Filter{MinAge: 24 * time.Hour}, r: young},
+		{name: "unknown age", filter: Filter{MinAge: 24 * time.Hour}, r: unknown},
+		{name: "prefix", filter: Filter{Prefixes: []string{"other-", "test-"}}, r: young, want: true},
+		{name: "no prefix", filter: Filter{Prefixes: []string{"other-"}}, r: young},
+		{name: "label", filter: Filter{Labels: map[string]string{"owner": "ci"}}, r: old, want: true},
+		{name: "label value", filter: Filter{Labels: map[string]string{"owner": "me"}}, r: old},
+		{name: "missing label", filter: Filter{Labels: map[string]string{"owner": "ci"}}, r: young},
+		{name: "all conditions", filter: Filter{MinAge: time.Hour, Prefixes: []string{"test-"}, Labels: map[string]string{"owner": "ci"}}, r: old, want: true},
+	}
+	for _, test := range tests {
+		if got := test.filter.Match(test.r, now); got != test.want {
+			t.Errorf("%s: Match(%+v) = %v, want %v", test.name, test.r, got, test.want)
+		}
+	}
+}
+
+// fakeCleaner records deletions and fails to delete resources in failIDs.
+type fakeCleaner struct {
+	name      string
+	resources []Resource
+	listErr   error
+	failIDs   map[string]bool
+
+	mu       sync.Mutex
+	deleted  []string
+	inFlight int
+	maxSeen  int
+}
+
+func (c *fakeCleaner) Name() string { return c.name }
+
+func (c *fakeCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	return c.resources, c.listErr
+}
+
+func (c *fakeCleaner) Delete(ctx context.Context, r Resource) error {
+	c.mu.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxSeen {
+		c.maxSeen = c.inFlight
+	}
+	c.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	if c.failIDs[r.ID] {
+		return errors.New("permission denied")
+	}
+	c.deleted = append(c.deleted, r.ID)
+	return nil
+}
+
+func fakeResources(ids ...string) []Resource {
+	var rs []Resource
+	for _, id := range ids {
+		rs = append(rs, Resource{Name: "projects/p/things/" + id, ID: id, Created: now.Add(-48 * time.Hour)})
+	}
+	return rs
+}
+
+func TestRun(t *testing.T) {
+	things := &fakeCleaner{
+		name:      "things",
+		resources: fakeResources("test-1", "test-2", "test-3", "test-4", "keep-1"),
+		failIDs:   map[string]bool{"test-4": true},
+	}
+	broken := &fakeCleaner{name: "broken", listErr: errors.New("API not enabled")}
+
+	j := &Janitor{
+		Project:     "p",
+		Cleaners:    []Cleaner{things, broken},
+		Filter:      Filter{MinAge: time.Hour, Prefixes: []string{"test-"}},
+		Concurrency: 2,
+		now:         func() time.Time { return now },
+	}
+	results := j.Run(context.Background())
+
+	if r := results[0]; r.Listed != 5 || r.Matched != 4 || r.Deleted != 3 || r.Failed != 1 {
+		t.Errorf("things result = %+v, want 5 listed, 4 matched, 3 deleted, 1 failed", *r)
+	}
+	if len(results[0].Errors) != 1 || !strings.Contains(results[0].Errors[0], "test-4") {
+		t.Errorf("things errors = %q, want one error for test-4", results[0].Errors)
+	}
+	if things.maxSeen > 2 {
+		t.Errorf("saw %d concurrent deletions, want at most 2", things.maxSeen)
+	}
+	if len(results[1].Errors) != 1 || results[1].Listed != 0 {
+		t.Errorf("broken result = %+v, want a single list error", *results[1])
+	}
+	if !failed(results) {
+		t.Errorf("failed(results) = false, want true")
+	}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, results, false); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	for _, want := range []string{"things", "broken", "API not enabled"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	c := &fakeCleaner{name: "things", resources: fakeResources("test-1", "test-2")}
+	j := &Janitor{Cleaners: []Cleaner{c}, DryRun: true, now: func() time.Time { return now }}
+	results := j.Run(context.Background())
+
+	if len(c.deleted) != 0 {
+		t.Errorf("dry run deleted %v", c.deleted)
+	}
+	if r := results[0]; r.Matched != 2 || r.Deleted != 0 {
+		t.Errorf("dry run result = %+v, want 2 matched and 0 deleted", *r)
+	}
+	if failed(results) {
+		t.Errorf("failed(results) = true, want false")
+	}
+}
+
+func TestTimeFromSuffix(t *testing.T) {
+	want := time.Unix(1700000000, 0)
+	tests := []struct {
+		id   string
+		want time.Time
+	}{
+		{id: "my-topic-1700000000", want: want},
+		{id: "my_topic_1700000000000", want: want},
+		{id: "my-topic-1700000000000000000", want: want},
+		{id: "my-topic", want: time.Time{}},
+		{id: "my-topic-42", want: time.Time{}},
+		{id: "1700000000", want: time.Time{}},
+	}
+	for _, test := range tests {
+		if got := timeFromSuffix(test.id); !got.Equal(test.want) {
+			t.Errorf("timeFromSuffix(%q) = %v, want %v", test.id, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+)
+
+// kmsVersionCleaner schedules Cloud KMS key versions for destruction. Key
+// rings and keys cannot be deleted, so test keys accumulate; destroying their
+// versions is what stops them from being billed. Prefix filters match the
+// crypto key ID and label filters match the crypto key's labels.
+type kmsVersionCleaner struct {
+	svc       *cloudkms.Service
+	locations []string
+}
+
+func newKMSVersionCleaner(ctx context.Context, locations []string) (Cleaner, error) {
+	svc, err := cloudkms.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &kmsVersionCleaner{svc: svc, locations: locations}, nil
+}
+
+func (c *kmsVersionCleaner) Name() string { return "kms-key-versions" }
+
+func (c *kmsVersionCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	for _, location := range c.locations {
+		parent := fmt.Sprintf("projects/%s/locations/%s", project, location)
+		var keyRings []string
+		if err := c.svc.Projects.Locations.KeyRings.List(parent).Pages(ctx, func(resp *cloudkms.ListKeyRingsResponse) error {
+			for _, kr := range resp.KeyRings {
+				keyRings = append(keyRings, kr.Name)
+			}
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("%s: %w", parent, err)
+		}
+
+		for _, kr := range keyRings {
+			var keys []*cloudkms.CryptoKey
+			if err := c.svc.Projects.Locations.KeyRings.CryptoKeys.List(kr).Pages(ctx, func(resp *cloudkms.ListCryptoKeysResponse) error {
+				keys = append(keys, resp.CryptoKeys...)
+				return nil
+			}); err != nil {
+				return nil, fmt.Errorf("%s: %w", kr, err)
+			}
+
+			for _, key := range keys {
+				if err := c.svc.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.List(key.Name).Pages(ctx, func(resp *cloudkms.ListCryptoKeyVersionsResponse) error {
+					for _, v := range resp.CryptoKeyVersions {
+						// Versions already scheduled for destruction, or
+						// destroyed, need no further action.
+						if v.State != "ENABLED" && v.State != "DISABLED" {
+							continue
+						}
+						resources = append(resources, Resource{
+							Name:    v.Name,
+							ID:      lastSegment(key.Name),
+							Created: parseTime(v.CreateTime),
+							Labels:  key.Labels,
+						})
+					}
+					return nil
+				}); err != nil {
+					return nil, fmt.Errorf("%s: %w", key.Name, err)
+				}
+			}
+		}
+	}
+	return resources, nil
+}
+
+func (c *kmsVersionCleaner) Delete(ctx context.Context, r Resource) error {
+	_, err := c.svc.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.Destroy(r.Name, &cloudkms.DestroyCryptoKeyVersionRequest{}).Context(ctx).Do()
+	return err
+}
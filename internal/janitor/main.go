@@ -0,0 +1,222 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command janitor deletes cloud resources left behind by tests.
+//
+// Each resource type is handled by a Cleaner. A resource is deleted when it
+// matches every filter given: its age, a prefix of its ID, and its labels.
+// At least one of -prefix, -label or -all is required, so that running
+// janitor without arguments never deletes everything in a project.
+//
+//	Usage of janitor:
+//	  -all
+//	      Allow running without a -prefix or -label filter.
+//	  -concurrency n
+//	      Maximum number of deletions in flight. (default 8)
+//	  -json
+//	      Print the summary report as JSON.
+//	  -label key=value
+//	      Only delete resources with this label. May be repeated.
+//	  -locations list
+//	      Comma-separated locations to search for Cloud Run services and KMS keys. (default "global,us-central1,us-east1")
+//	  -n  Dry run.
+//	  -older-than duration
+//	      Only delete resources at least this old. (default 24h0m0s)
+//	  -prefix prefix
+//	      Only delete resources whose ID starts with prefix. May be repeated.
+//	  -project Project ID
+//	      Project ID to clean.
+//	  -resources list
+//	      Comma-separated resource types to clean. (default all)
+//	  -timeout duration
+//	      Maximum time to run. (default 30m0s)
+//
+// For example, to see which Pub/Sub topics and secrets older than a week
+// start with "test-":
+//
+//	go run ./internal/janitor -project my-project -resources pubsub-topics,secrets -prefix test- -older-than 168h -n
+//
+// The janitor complements the cleanup the tests already do: the storage
+// tests still sweep their own expired buckets with
+// testutil.DeleteExpiredBuckets, and internal/cleanaeversions still cleans
+// App Engine versions, until CI runs the janitor on a schedule.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	proj        = flag.String("project", "", "`Project ID` to clean.")
+	dryRun      = flag.Bool("n", false, "Dry run.")
+	olderThan   = flag.Duration("older-than", 24*time.Hour, "Only delete resources at least this old.")
+	resources   = flag.String("resources", "all", "Comma-separated resource types to clean.")
+	locations   = flag.String("locations", "global,us-central1,us-east1", "Comma-separated locations to search for Cloud Run services and KMS keys.")
+	concurrency = flag.Int("concurrency", 8, "Maximum number of deletions in flight.")
+	timeout     = flag.Duration("timeout", 30*time.Minute, "Maximum time to run.")
+	all         = flag.Bool("all", false, "Allow running without a -prefix or -label filter.")
+	jsonReport  = flag.Bool("json", false, "Print the summary report as JSON.")
+
+	prefixes listFlag
+	labels   = labelFlag{}
+)
+
+func init() {
+	flag.Var(&prefixes, "prefix", "Only delete resources whose ID starts with `prefix`. May be repeated.")
+	flag.Var(labels, "label", "Only delete resources with this label, given as `key=value`. May be repeated.")
+}
+
+// cleaners constructs a Cleaner for each resource type.
+var cleaners = map[string]func(ctx context.Context) (Cleaner, error){
+	"appengine-versions": newAEVersionCleaner,
+	"kms-key-versions": func(ctx context.Context) (Cleaner, error) {
+		return newKMSVersionCleaner(ctx, splitList(*locations))
+	},
+	"pubsub-topics": newTopicCleaner,
+	"run-services": func(ctx context.Context) (Cleaner, error) {
+		var regions []string
+		for _, l := range splitList(*locations) {
+			// Cloud Run has no global location.
+			if l != "global" {
+				regions = append(regions, l)
+			}
+		}
+		return newRunServiceCleaner(ctx, regions)
+	},
+	"secrets":           newSecretCleaner,
+	"spanner-instances": newSpannerInstanceCleaner,
+	"storage-buckets":   newBucketCleaner,
+}
+
+func main() {
+	flag.Parse()
+	if *proj == "" {
+		fmt.Fprintln(os.Stderr, "-project flag is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if len(prefixes) == 0 && len(labels) == 0 && !*all {
+		fmt.Fprintln(os.Stderr, "at least one -prefix or -label is required, or -all to clean every resource")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	names, err := resourceTypes(*resources)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	j := &Janitor{
+		Project: *proj,
+		Filter: Filter{
+			MinAge:   *olderThan,
+			Prefixes: prefixes,
+			Labels:   labels,
+		},
+		DryRun:      *dryRun,
+		Concurrency: *concurrency,
+	}
+	for _, name := range names {
+		c, err := cleaners[name](ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create %s cleaner: %v\n", name, err)
+			os.Exit(1)
+		}
+		j.Cleaners = append(j.Cleaners, c)
+	}
+
+	results := j.Run(ctx)
+	if *jsonReport {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = writeReport(os.Stdout, results, *dryRun)
+	}
+	if err != nil {
+		log.Fatalf("Could not write report: %v", err)
+	}
+	if failed(results) {
+		os.Exit(1)
+	}
+}
+
+// resourceTypes returns the sorted cleaner names selected by list, which is
+// either "all" or a comma-separated list of names.
+func resourceTypes(list string) ([]string, error) {
+	var names []string
+	if list == "all" {
+		for name := range cleaners {
+			names = append(names, name)
+		}
+	} else {
+		for _, name := range splitList(list) {
+			if _, ok := cleaners[name]; !ok {
+				return nil, fmt.Errorf("unknown resource type %q", name)
+			}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// listFlag is a flag that may be given multiple times.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+// labelFlag collects key=value flags into a map.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	var kvs []string
+	for k, v := range l {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
+
+func (l labelFlag) Set(v string) error {
+	k, val, ok := strings.Cut(v, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label %q is not key=value", v)
+	}
+	l[k] = val
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	pubsub "google.golang.org/api/pubsub/v1"
+)
+
+// topicCleaner deletes Pub/Sub topics. Subscriptions to a deleted topic are
+// left detached and expire on their own.
+type topicCleaner struct {
+	svc *pubsub.Service
+}
+
+func newTopicCleaner(ctx context.Context) (Cleaner, error) {
+	svc, err := pubsub.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &topicCleaner{svc: svc}, nil
+}
+
+func (c *topicCleaner) Name() string { return "pubsub-topics" }
+
+func (c *topicCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	err := c.svc.Projects.Topics.List("projects/"+project).Pages(ctx, func(resp *pubsub.ListTopicsResponse) error {
+		for _, t := range resp.Topics {
+			id := lastSegment(t.Name)
+			resources = append(resources, Resource{
+				Name:    t.Name,
+				ID:      id,
+				Created: timeFromSuffix(id),
+				Labels:  t.Labels,
+			})
+		}
+		return nil
+	})
+	return resources, err
+}
+
+func (c *topicCleaner) Delete(ctx context.Context, r Resource) error {
+	_, err := c.svc.Projects.Topics.Delete(r.Name).Context(ctx).Do()
+	return err
+}
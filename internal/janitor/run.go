@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	run "google.golang.org/api/run/v2"
+)
+
+// runServiceCleaner deletes Cloud Run services in a set of regions.
+type runServiceCleaner struct {
+	svc     *run.Service
+	regions []string
+}
+
+func newRunServiceCleaner(ctx context.Context, regions []string) (Cleaner, error) {
+	svc, err := run.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &runServiceCleaner{svc: svc, regions: regions}, nil
+}
+
+func (c *runServiceCleaner) Name() string { return "run-services" }
+
+func (c *runServiceCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	for _, region := range c.regions {
+		parent := fmt.Sprintf("projects/%s/locations/%s", project, region)
+		err := c.svc.Projects.Locations.Services.List(parent).Pages(ctx, func(resp *run.GoogleCloudRunV2ListServicesResponse) error {
+			for _, s := range resp.Services {
+				resources = append(resources, Resource{
+					Name:    s.Name,
+					ID:      lastSegment(s.Name),
+					Created: parseTime(s.CreateTime),
+					Labels:  s.Labels,
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parent, err)
+		}
+	}
+	return resources, nil
+}
+
+func (c *runServiceCleaner) Delete(ctx context.Context, r Resource) error {
+	op, err := c.svc.Projects.Locations.Services.Delete(r.Name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	return waitForOperation(ctx, func() (bool, error) {
+		op, err = c.svc.Projects.Locations.Operations.Get(op.Name).Context(ctx).Do()
+		if err != nil {
+			return false, err
+		}
+		if op.Done && op.Error != nil {
+			return true, fmt.Errorf("%s (code %d)", op.Error.Message, op.Error.Code)
+		}
+		return op.Done, nil
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// secretCleaner deletes Secret Manager secrets and all of their versions.
+type secretCleaner struct {
+	svc *secretmanager.Service
+}
+
+func newSecretCleaner(ctx context.Context) (Cleaner, error) {
+	svc, err := secretmanager.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &secretCleaner{svc: svc}, nil
+}
+
+func (c *secretCleaner) Name() string { return "secrets" }
+
+func (c *secretCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	err := c.svc.Projects.Secrets.List("projects/"+project).Pages(ctx, func(resp *secretmanager.ListSecretsResponse) error {
+		for _, s := range resp.Secrets {
+			resources = append(resources, Resource{
+				Name:    s.Name,
+				ID:      lastSegment(s.Name),
+				Created: parseTime(s.CreateTime),
+				Labels:  s.Labels,
+			})
+		}
+		return nil
+	})
+	return resources, err
+}
+
+func (c *secretCleaner) Delete(ctx context.Context, r Resource) error {
+	_, err := c.svc.Projects.Secrets.Delete(r.Name).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,58 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	spanner "google.golang.org/api/spanner/v1"
+)
+
+// spannerInstanceCleaner deletes Spanner instances, including their
+// databases. Instances with backups cannot be deleted until the backups are.
+type spannerInstanceCleaner struct {
+	svc *spanner.Service
+}
+
+func newSpannerInstanceCleaner(ctx context.Context) (Cleaner, error) {
+	svc, err := spanner.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &spannerInstanceCleaner{svc: svc}, nil
+}
+
+func (c *spannerInstanceCleaner) Name() string { return "spanner-instances" }
+
+func (c *spannerInstanceCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	err := c.svc.Projects.Instances.List("projects/"+project).Pages(ctx, func(resp *spanner.ListInstancesResponse) error {
+		for _, i := range resp.Instances {
+			resources = append(resources, Resource{
+				Name:    i.Name,
+				ID:      lastSegment(i.Name),
+				Created: parseTime(i.CreateTime),
+				Labels:  i.Labels,
+			})
+		}
+		return nil
+	})
+	return resources, err
+}
+
+func (c *spannerInstanceCleaner) Delete(ctx context.Context, r Resource) error {
+	_, err := c.svc.Projects.Instances.Delete(r.Name).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,63 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/iterator"
+)
+
+// bucketCleaner deletes Cloud Storage buckets along with their objects.
+type bucketCleaner struct {
+	client *storage.Client
+}
+
+func newBucketCleaner(ctx context.Context) (Cleaner, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketCleaner{client: client}, nil
+}
+
+func (c *bucketCleaner) Name() string { return "storage-buckets" }
+
+func (c *bucketCleaner) List(ctx context.Context, project string) ([]Resource, error) {
+	var resources []Resource
+	it := c.client.Buckets(ctx, project)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, Resource{
+			Name:    "gs://" + attrs.Name,
+			ID:      attrs.Name,
+			Created: attrs.Created,
+			Labels:  attrs.Labels,
+		})
+	}
+	return resources, nil
+}
+
+func (c *bucketCleaner) Delete(ctx context.Context, r Resource) error {
+	return testutil.DeleteBucketIfExists(ctx, c.client, r.ID)
+}
@@ -0,0 +1,83 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lastSegment returns the part of a resource name after the last slash.
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// parseTime parses an RFC 3339 timestamp as returned by REST APIs. It returns
+// the zero time if s is empty or malformed.
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// waitForOperation calls poll until it reports that a long-running operation
+// is done, or ctx is done.
+func waitForOperation(ctx context.Context, poll func() (done bool, err error)) error {
+	for {
+		done, err := poll()
+		if err != nil || done {
+			return err
+		}
+		// 5 to 10 second sleep.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration((5 + rand.Float64()*5) * float64(time.Second))):
+		}
+	}
+}
+
+// timeFromSuffix guesses when a resource was created from a Unix timestamp
+// at the end of its ID, as in "my-topic-1700000000" or
+// "my_topic_1700000000123456789". Test resources are commonly named this way
+// when the API does not report a creation time. It returns the zero time if
+// the ID has no such suffix.
+func timeFromSuffix(id string) time.Time {
+	i := strings.LastIndexAny(id, "-_")
+	if i < 0 {
+		return time.Time{}
+	}
+	n, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	// Accept seconds, milliseconds, microseconds and nanoseconds, as long as
+	// the result lands in a plausible range.
+	for _, unit := range []time.Duration{time.Second, time.Millisecond, time.Microsecond, time.Nanosecond} {
+		if n > int64(1<<63-1)/int64(unit) {
+			continue
+		}
+		t := time.Unix(0, n*int64(unit))
+		if t.Year() >= 2015 && t.Before(time.Now().Add(24*time.Hour)) {
+			return t
+		}
+	}
+	return time.Time{}
+}
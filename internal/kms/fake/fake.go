@@ -0,0 +1,453 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory Cloud KMS server for tests.
+// Importing it registers the fake with testutil under the name "kms".
+//
+// Only symmetric encryption keys are supported. Ciphertexts are produced with
+// AES-GCM using a random key per key version, so they round-trip through
+// Decrypt but are meaningless outside the fake.
+package fake
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	testutil.RegisterFake("kms", Options)
+}
+
+// Options starts a fake Cloud KMS server and returns the options to connect
+// to it.
+func Options(t *testing.T) []option.ClientOption {
+	server := newServer()
+	return testutil.ServeFake(t, func(s *grpc.Server) {
+		kmspb.RegisterKeyManagementServiceServer(s, server)
+	})
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func crc32c(data []byte) int64 {
+	return int64(crc32.Checksum(data, crcTable))
+}
+
+type cryptoKey struct {
+	key      *kmspb.CryptoKey
+	versions []*cryptoKeyVersion
+}
+
+type cryptoKeyVersion struct {
+	version *kmspb.CryptoKeyVersion
+	aead    cipher.AEAD
+}
+
+type server struct {
+	kmspb.UnimplementedKeyManagementServiceServer
+
+	mu       sync.Mutex
+	keyRings map[string]*kmspb.KeyRing
+	keys     map[string]*cryptoKey
+}
+
+func newServer() *server {
+	return &server{
+		keyRings: map[string]*kmspb.KeyRing{},
+		keys:     map[string]*cryptoKey{},
+	}
+}
+
+func (s *server) lookupKey(name string) (*cryptoKey, error) {
+	k, ok := s.keys[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "CryptoKey %s not found.", name)
+	}
+	return k, nil
+}
+
+func (s *server) lookupVersion(name string) (*cryptoKey, *cryptoKeyVersion, error) {
+	parent, id, ok := strings.Cut(name, "/cryptoKeyVersions/")
+	if !ok {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "invalid CryptoKeyVersion name %q", name)
+	}
+	k, err := s.lookupKey(parent)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 || n > len(k.versions) {
+		return nil, nil, status.Errorf(codes.NotFound, "CryptoKeyVersion %s not found.", name)
+	}
+	return k, k.versions[n-1], nil
+}
+
+// newVersion adds an enabled version to k. Callers must hold s.mu.
+func (s *server) newVersion(k *cryptoKey) (*cryptoKeyVersion, error) {
+	if k.key.GetPurpose() != kmspb.CryptoKey_ENCRYPT_DECRYPT {
+		return nil, status.Errorf(codes.Unimplemented, "fake only supports ENCRYPT_DECRYPT keys, got %s", k.key.GetPurpose())
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, status.Errorf(codes.Internal, "rand.Read: %v", err)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "aes.NewCipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cipher.NewGCM: %v", err)
+	}
+	v := &cryptoKeyVersion{
+		version: &kmspb.CryptoKeyVersion{
+			Name:            fmt.Sprintf("%s/cryptoKeyVersions/%d", k.key.GetName(), len(k.versions)+1),
+			State:           kmspb.CryptoKeyVersion_ENABLED,
+			Algorithm:       kmspb.CryptoKeyVersion_GOOGLE_SYMMETRIC_ENCRYPTION,
+			ProtectionLevel: kmspb.ProtectionLevel_SOFTWARE,
+			CreateTime:      timestamppb.Now(),
+		},
+		aead: aead,
+	}
+	k.versions = append(k.versions, v)
+	return v, nil
+}
+
+func (s *server) CreateKeyRing(ctx context.Context, req *kmspb.CreateKeyRingRequest) (*kmspb.KeyRing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := fmt.Sprintf("%s/keyRings/%s", req.GetParent(), req.GetKeyRingId())
+	if _, ok := s.keyRings[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "KeyRing %s already exists.", name)
+	}
+	kr := &kmspb.KeyRing{Name: name, CreateTime: timestamppb.Now()}
+	s.keyRings[name] = kr
+	return proto.Clone(kr).(*kmspb.KeyRing), nil
+}
+
+func (s *server) GetKeyRing(ctx context.Context, req *kmspb.GetKeyRingRequest) (*kmspb.KeyRing, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kr, ok := s.keyRings[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "KeyRing %s not found.", req.GetName())
+	}
+	return proto.Clone(kr).(*kmspb.KeyRing), nil
+}
+
+func (s *server) ListKeyRings(ctx context.Context, req *kmspb.ListKeyRingsRequest) (*kmspb.ListKeyRingsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.keyRings {
+		if strings.HasPrefix(name, req.GetParent()+"/keyRings/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	resp := &kmspb.ListKeyRingsResponse{TotalSize: int32(len(names))}
+	for _, name := range names {
+		resp.KeyRings = append(resp.KeyRings, proto.Clone(s.keyRings[name]).(*kmspb.KeyRing))
+	}
+	return resp, nil
+}
+
+func (s *server) CreateCryptoKey(ctx context.Context, req *kmspb.CreateCryptoKeyRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keyRings[req.GetParent()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "KeyRing %s not found.", req.GetParent())
+	}
+	name := fmt.Sprintf("%s/cryptoKeys/%s", req.GetParent(), req.GetCryptoKeyId())
+	if _, ok := s.keys[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "CryptoKey %s already exists.", name)
+	}
+	key := &kmspb.CryptoKey{}
+	if req.GetCryptoKey() != nil {
+		key = proto.Clone(req.GetCryptoKey()).(*kmspb.CryptoKey)
+	}
+	key.Name = name
+	key.CreateTime = timestamppb.Now()
+	k := &cryptoKey{key: key}
+	if !req.GetSkipInitialVersionCreation() {
+		v, err := s.newVersion(k)
+		if err != nil {
+			return nil, err
+		}
+		key.Primary = v.version
+	}
+	s.keys[name] = k
+	return proto.Clone(key).(*kmspb.CryptoKey), nil
+}
+
+func (s *server) GetCryptoKey(ctx context.Context, req *kmspb.GetCryptoKeyRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(k.key).(*kmspb.CryptoKey), nil
+}
+
+func (s *server) ListCryptoKeys(ctx context.Context, req *kmspb.ListCryptoKeysRequest) (*kmspb.ListCryptoKeysResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.keys {
+		if strings.HasPrefix(name, req.GetParent()+"/cryptoKeys/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	resp := &kmspb.ListCryptoKeysResponse{TotalSize: int32(len(names))}
+	for _, name := range names {
+		resp.CryptoKeys = append(resp.CryptoKeys, proto.Clone(s.keys[name].key).(*kmspb.CryptoKey))
+	}
+	return resp, nil
+}
+
+func (s *server) UpdateCryptoKey(ctx context.Context, req *kmspb.UpdateCryptoKeyRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetCryptoKey().GetName())
+	if err != nil {
+		return nil, err
+	}
+	updated := proto.Clone(k.key).(*kmspb.CryptoKey)
+	if err := testutil.ApplyFieldMask(updated, req.GetCryptoKey(), req.GetUpdateMask().GetPaths()); err != nil {
+		return nil, err
+	}
+	k.key = updated
+	return proto.Clone(updated).(*kmspb.CryptoKey), nil
+}
+
+func (s *server) UpdateCryptoKeyPrimaryVersion(ctx context.Context, req *kmspb.UpdateCryptoKeyPrimaryVersionRequest) (*kmspb.CryptoKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.lookupVersion(fmt.Sprintf("%s/cryptoKeyVersions/%s", req.GetName(), req.GetCryptoKeyVersionId()))
+	if err != nil {
+		return nil, err
+	}
+	if v.version.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is not enabled.", v.version.Name)
+	}
+	k, _ := s.lookupKey(req.GetName())
+	k.key.Primary = v.version
+	return proto.Clone(k.key).(*kmspb.CryptoKey), nil
+}
+
+func (s *server) CreateCryptoKeyVersion(ctx context.Context, req *kmspb.CreateCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.newVersion(k)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.version).(*kmspb.CryptoKeyVersion), nil
+}
+
+func (s *server) GetCryptoKeyVersion(ctx context.Context, req *kmspb.GetCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.lookupVersion(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.version).(*kmspb.CryptoKeyVersion), nil
+}
+
+func (s *server) ListCryptoKeyVersions(ctx context.Context, req *kmspb.ListCryptoKeyVersionsRequest) (*kmspb.ListCryptoKeyVersionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	resp := &kmspb.ListCryptoKeyVersionsResponse{TotalSize: int32(len(k.versions))}
+	for _, v := range k.versions {
+		resp.CryptoKeyVersions = append(resp.CryptoKeyVersions, proto.Clone(v.version).(*kmspb.CryptoKeyVersion))
+	}
+	return resp, nil
+}
+
+func (s *server) UpdateCryptoKeyVersion(ctx context.Context, req *kmspb.UpdateCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.lookupVersion(req.GetCryptoKeyVersion().GetName())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range req.GetUpdateMask().GetPaths() {
+		if path != "state" {
+			return nil, status.Errorf(codes.InvalidArgument, "fake only supports updating state, got %q", path)
+		}
+	}
+	switch want := req.GetCryptoKeyVersion().GetState(); want {
+	case kmspb.CryptoKeyVersion_ENABLED, kmspb.CryptoKeyVersion_DISABLED:
+		if st := v.version.State; st != kmspb.CryptoKeyVersion_ENABLED && st != kmspb.CryptoKeyVersion_DISABLED {
+			return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is in state %s.", v.version.Name, st)
+		}
+		v.version.State = want
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "cannot set state to %s", want)
+	}
+	return proto.Clone(v.version).(*kmspb.CryptoKeyVersion), nil
+}
+
+func (s *server) DestroyCryptoKeyVersion(ctx context.Context, req *kmspb.DestroyCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.lookupVersion(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if st := v.version.State; st != kmspb.CryptoKeyVersion_ENABLED && st != kmspb.CryptoKeyVersion_DISABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is in state %s.", v.version.Name, st)
+	}
+	v.version.State = kmspb.CryptoKeyVersion_DESTROY_SCHEDULED
+	v.version.DestroyTime = timestamppb.Now()
+	return proto.Clone(v.version).(*kmspb.CryptoKeyVersion), nil
+}
+
+func (s *server) RestoreCryptoKeyVersion(ctx context.Context, req *kmspb.RestoreCryptoKeyVersionRequest) (*kmspb.CryptoKeyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, v, err := s.lookupVersion(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.version.State != kmspb.CryptoKeyVersion_DESTROY_SCHEDULED {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is not scheduled for destruction.", v.version.Name)
+	}
+	v.version.State = kmspb.CryptoKeyVersion_DISABLED
+	v.version.DestroyTime = nil
+	return proto.Clone(v.version).(*kmspb.CryptoKeyVersion), nil
+}
+
+func (s *server) Encrypt(ctx context.Context, req *kmspb.EncryptRequest) (*kmspb.EncryptResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if k.key.GetPrimary() == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKey %s has no primary version.", k.key.GetName())
+	}
+	if c := req.GetPlaintextCrc32C(); c != nil && c.GetValue() != crc32c(req.GetPlaintext()) {
+		return nil, status.Error(codes.InvalidArgument, "plaintext checksum mismatch")
+	}
+	_, v, err := s.lookupVersion(k.key.GetPrimary().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.version.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is not enabled.", v.version.Name)
+	}
+
+	// Ciphertext layout: 4-byte version number, nonce, sealed plaintext.
+	n, _ := strconv.Atoi(v.version.Name[strings.LastIndex(v.version.Name, "/")+1:])
+	ciphertext := binary.BigEndian.AppendUint32(nil, uint32(n))
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, status.Errorf(codes.Internal, "rand.Read: %v", err)
+	}
+	ciphertext = append(ciphertext, nonce...)
+	ciphertext = v.aead.Seal(ciphertext, nonce, req.GetPlaintext(), req.GetAdditionalAuthenticatedData())
+
+	return &kmspb.EncryptResponse{
+		Name:                    v.version.Name,
+		Ciphertext:              ciphertext,
+		CiphertextCrc32C:        wrapperspb.Int64(crc32c(ciphertext)),
+		VerifiedPlaintextCrc32C: req.GetPlaintextCrc32C() != nil,
+		ProtectionLevel:         v.version.ProtectionLevel,
+	}, nil
+}
+
+func (s *server) Decrypt(ctx context.Context, req *kmspb.DecryptRequest) (*kmspb.DecryptResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, err := s.lookupKey(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := req.GetCiphertext()
+	if c := req.GetCiphertextCrc32C(); c != nil && c.GetValue() != crc32c(ciphertext) {
+		return nil, status.Error(codes.InvalidArgument, "ciphertext checksum mismatch")
+	}
+	if len(ciphertext) < 4 {
+		return nil, status.Error(codes.InvalidArgument, "Decryption failed: the ciphertext is invalid.")
+	}
+	n := int(binary.BigEndian.Uint32(ciphertext))
+	if n < 1 || n > len(k.versions) {
+		return nil, status.Error(codes.InvalidArgument, "Decryption failed: the ciphertext is invalid.")
+	}
+	v := k.versions[n-1]
+	if v.version.State != kmspb.CryptoKeyVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "CryptoKeyVersion %s is not enabled.", v.version.Name)
+	}
+	rest := ciphertext[4:]
+	if len(rest) < v.aead.NonceSize() {
+		return nil, status.Error(codes.InvalidArgument, "Decryption failed: the ciphertext is invalid.")
+	}
+	plaintext, err := v.aead.Open(nil, rest[:v.aead.NonceSize()], rest[v.aead.NonceSize():], req.GetAdditionalAuthenticatedData())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Decryption failed: the ciphertext is invalid.")
+	}
+	return &kmspb.DecryptResponse{
+		Plaintext:       plaintext,
+		PlaintextCrc32C: wrapperspb.Int64(crc32c(plaintext)),
+		UsedPrimary:     k.key.GetPrimary().GetName() == v.version.Name,
+		ProtectionLevel: v.version.ProtectionLevel,
+	}, nil
+}
+
+func (s *server) GenerateRandomBytes(ctx context.Context, req *kmspb.GenerateRandomBytesRequest) (*kmspb.GenerateRandomBytesResponse, error) {
+	if n := req.GetLengthBytes(); n < 8 || n > 1024 {
+		return nil, status.Errorf(codes.InvalidArgument, "length_bytes must be between 8 and 1024, got %d", n)
+	}
+	data := make([]byte, req.GetLengthBytes())
+	if _, err := rand.Read(data); err != nil {
+		return nil, status.Errorf(codes.Internal, "rand.Read: %v", err)
+	}
+	return &kmspb.GenerateRandomBytesResponse{
+		Data:       data,
+		DataCrc32C: wrapperspb.Int64(crc32c(data)),
+	}, nil
+}
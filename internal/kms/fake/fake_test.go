@@ -0,0 +1,146 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testKeyRing = "projects/p/locations/global/keyRings/r"
+
+// newTestClient returns a client for a fresh fake that already holds the
+// key ring testKeyRing.
+func newTestClient(t *testing.T) *kms.KeyManagementClient {
+	t.Helper()
+	ctx := context.Background()
+	client, err := kms.NewKeyManagementClient(ctx, Options(t)...)
+	if err != nil {
+		t.Fatalf("kms.NewKeyManagementClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	if _, err := client.CreateKeyRing(ctx, &kmspb.CreateKeyRingRequest{
+		Parent:    "projects/p/locations/global",
+		KeyRingId: "r",
+	}); err != nil {
+		t.Fatalf("CreateKeyRing: %v", err)
+	}
+	return client
+}
+
+func createKey(ctx context.Context, client *kms.KeyManagementClient, parent, id string) (*kmspb.CryptoKey, error) {
+	return client.CreateCryptoKey(ctx, &kmspb.CreateCryptoKeyRequest{
+		Parent:      parent,
+		CryptoKeyId: id,
+		CryptoKey:   &kmspb.CryptoKey{Purpose: kmspb.CryptoKey_ENCRYPT_DECRYPT},
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.GetKeyRing(ctx, &kmspb.GetKeyRingRequest{Name: "projects/p/locations/global/keyRings/missing"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetKeyRing(missing): got code %v, want NotFound", got)
+	}
+
+	_, err = createKey(ctx, client, "projects/p/locations/global/keyRings/missing", "k")
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("CreateCryptoKey in missing key ring: got code %v, want NotFound", got)
+	}
+
+	k, err := createKey(ctx, client, testKeyRing, "k")
+	if err != nil {
+		t.Fatalf("CreateCryptoKey: %v", err)
+	}
+	_, err = client.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: k.Name + "/cryptoKeyVersions/2"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetCryptoKeyVersion(missing): got code %v, want NotFound", got)
+	}
+}
+
+func TestAlreadyExists(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.CreateKeyRing(ctx, &kmspb.CreateKeyRingRequest{
+		Parent:    "projects/p/locations/global",
+		KeyRingId: "r",
+	})
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateKeyRing twice: got code %v, want AlreadyExists", got)
+	}
+
+	if _, err := createKey(ctx, client, testKeyRing, "k"); err != nil {
+		t.Fatalf("CreateCryptoKey: %v", err)
+	}
+	_, err = createKey(ctx, client, testKeyRing, "k")
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateCryptoKey twice: got code %v, want AlreadyExists", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	for _, id := range []string{"b", "a"} {
+		if _, err := createKey(ctx, client, testKeyRing, id); err != nil {
+			t.Fatalf("CreateCryptoKey(%q): %v", id, err)
+		}
+	}
+
+	var got []string
+	it := client.ListCryptoKeys(ctx, &kmspb.ListCryptoKeysRequest{Parent: testKeyRing})
+	for {
+		k, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ListCryptoKeys: %v", err)
+		}
+		got = append(got, k.Name)
+	}
+	want := []string{testKeyRing + "/cryptoKeys/a", testKeyRing + "/cryptoKeys/b"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListCryptoKeys = %v, want %v", got, want)
+	}
+
+	got = nil
+	rit := client.ListKeyRings(ctx, &kmspb.ListKeyRingsRequest{Parent: "projects/p/locations/global"})
+	for {
+		r, err := rit.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ListKeyRings: %v", err)
+		}
+		got = append(got, r.Name)
+	}
+	if want := []string{testKeyRing}; !slices.Equal(got, want) {
+		t.Errorf("ListKeyRings = %v, want %v", got, want)
+	}
+}
@@ -12,156 +12,483 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package fake provides an in-memory Managed Kafka server for tests.
+// Importing it registers the fake with testutil under the name
+// "managedkafka".
+//
+// Clusters, topics and consumer groups are stored in memory and behave like
+// the real API: missing resources are NotFound, duplicates are
+// AlreadyExists, Update calls honor their update_mask, and cluster
+// operations are long-running and complete asynchronously.
 package fake
 
 import (
 	"context"
 	"fmt"
-	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"cloud.google.com/go/managedkafka/apiv1/managedkafkapb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 	"google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
 )
 
-const (
-	clusterID       = "fake-cluster"
-	topicID         = "fake-topic"
-	consumerGroupID = "fake-consumergroup"
-)
+// operationDelay is how long cluster operations stay pending before they
+// complete.
+const operationDelay = 100 * time.Millisecond
+
+func init() {
+	testutil.RegisterFake("managedkafka", func(t *testing.T) []option.ClientOption {
+		return Options(t)
+	})
+}
 
 // The reason why we have a fake server is because testing end-to-end will exceed the deadline of 10 minutes.
 // There is currently no strong support available for maintaining persistent resources either.
 type fakeManagedKafkaServer struct {
 	managedkafkapb.UnimplementedManagedKafkaServer
+	longrunningpb.UnimplementedOperationsServer
+
+	mu             sync.Mutex
+	clusters       map[string]*managedkafkapb.Cluster
+	topics         map[string]*managedkafkapb.Topic
+	consumerGroups map[string]*managedkafkapb.ConsumerGroup
+	operations     map[string]*longrunningpb.Operation
+	nextOperation  int
+	// timers holds pending operation completions so they can be stopped
+	// when the test ends.
+	timers []*time.Timer
+}
+
+// Options starts a fake Managed Kafka server and returns the options to
+// connect to it.
+//
+// Each seed resource, a *managedkafkapb.Cluster, *managedkafkapb.Topic or
+// *managedkafkapb.ConsumerGroup with its full resource name, exists before
+// the first request. Seed clusters are ACTIVE. Consumer groups can only be
+// created by Kafka clients, so seeding is the only way to add them.
+func Options(t *testing.T, seed ...proto.Message) []option.ClientOption {
+	server := newFakeManagedKafkaServer()
+	for _, m := range seed {
+		if err := server.seed(m); err != nil {
+			t.Fatalf("fake.Options: %v", err)
+		}
+	}
+	t.Cleanup(server.stop)
+	return testutil.ServeFake(t, func(s *grpc.Server) {
+		managedkafkapb.RegisterManagedKafkaServer(s, server)
+		longrunningpb.RegisterOperationsServer(s, server)
+	})
+}
+
+func newFakeManagedKafkaServer() *fakeManagedKafkaServer {
+	return &fakeManagedKafkaServer{
+		clusters:       map[string]*managedkafkapb.Cluster{},
+		topics:         map[string]*managedkafkapb.Topic{},
+		consumerGroups: map[string]*managedkafkapb.ConsumerGroup{},
+		operations:     map[string]*longrunningpb.Operation{},
+	}
+}
+
+func (f *fakeManagedKafkaServer) seed(m proto.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r := proto.Clone(m).(type) {
+	case *managedkafkapb.Cluster:
+		r.State = managedkafkapb.Cluster_ACTIVE
+		r.CreateTime = timestamppb.Now()
+		r.UpdateTime = r.CreateTime
+		f.clusters[r.GetName()] = r
+	case *managedkafkapb.Topic:
+		if _, ok := f.clusters[parentOf(r.GetName(), "topics")]; !ok {
+			return fmt.Errorf("seed topic %q has no seeded cluster", r.GetName())
+		}
+		f.topics[r.GetName()] = r
+	case *managedkafkapb.ConsumerGroup:
+		if _, ok := f.clusters[parentOf(r.GetName(), "consumerGroups")]; !ok {
+			return fmt.Errorf("seed consumer group %q has no seeded cluster", r.GetName())
+		}
+		f.consumerGroups[r.GetName()] = r
+	default:
+		return fmt.Errorf("cannot seed %T", m)
+	}
+	return nil
+}
+
+func (f *fakeManagedKafkaServer) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.timers {
+		t.Stop()
+	}
+}
+
+// parentOf returns the parent of a resource name in the given collection,
+// for example the cluster name of a topic.
+func parentOf(name, collection string) string {
+	parent, _, _ := strings.Cut(name, "/"+collection+"/")
+	return parent
+}
+
+// locationOf returns the projects/*/locations/* prefix of a resource name.
+func locationOf(name string) string {
+	parts := strings.SplitN(name, "/", 5)
+	if len(parts) < 4 {
+		return name
+	}
+	return strings.Join(parts[:4], "/")
+}
+
+// page returns the page of sorted names selected by pageSize and pageToken,
+// and the token for the next page. The page token is the offset of the first
+// name in the page.
+func page(names []string, pageSize int32, pageToken string) ([]string, string, error) {
+	sort.Strings(names)
+	start := 0
+	if pageToken != "" {
+		n, err := strconv.Atoi(pageToken)
+		if err != nil || n < 0 || n > len(names) {
+			return nil, "", status.Errorf(codes.InvalidArgument, "invalid page_token %q", pageToken)
+		}
+		start = n
+	}
+	end := len(names)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+	}
+	next := ""
+	if end < len(names) {
+		next = strconv.Itoa(end)
+	}
+	return names[start:end], next, nil
+}
+
+// childNames returns the names in m that belong to parent's collection.
+func childNames[V any](m map[string]V, parent, collection string) []string {
+	var names []string
+	for name := range m {
+		if parentOf(name, collection) == parent && name != parent {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
-func Options(t *testing.T) []option.ClientOption {
-	server := &fakeManagedKafkaServer{}
-	listener, err := net.Listen("tcp", "localhost:0")
+// startOperation records a pending operation on target and schedules done
+// to run after operationDelay. done returns the operation result; its
+// return value becomes the operation's response or error. Callers must hold
+// f.mu, and done is called with f.mu held.
+func (f *fakeManagedKafkaServer) startOperation(target, verb string, done func() (proto.Message, error)) (*longrunningpb.Operation, error) {
+	f.nextOperation++
+	metadata, err := anypb.New(&managedkafkapb.OperationMetadata{
+		CreateTime: timestamppb.Now(),
+		Target:     target,
+		Verb:       verb,
+		ApiVersion: "v1",
+	})
 	if err != nil {
-		t.Fatal(err)
-	}
-	gsrv := grpc.NewServer()
-	managedkafkapb.RegisterManagedKafkaServer(gsrv, server)
-	fakeServerAddr := listener.Addr().String()
-	go func() {
-		if err := gsrv.Serve(listener); err != nil {
-			panic(err)
+		return nil, status.Errorf(codes.Internal, "anypb.New got err: %v", err)
+	}
+	op := &longrunningpb.Operation{
+		Name:     fmt.Sprintf("%s/operations/operation-%d", locationOf(target), f.nextOperation),
+		Metadata: metadata,
+	}
+	f.operations[op.Name] = op
+
+	f.timers = append(f.timers, time.AfterFunc(operationDelay, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		op.Done = true
+		resp, err := done()
+		if err != nil {
+			op.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+			return
 		}
-	}()
+		packed, err := anypb.New(resp)
+		if err != nil {
+			op.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+			return
+		}
+		op.Result = &longrunningpb.Operation_Response{Response: packed}
+	}))
+	return proto.Clone(op).(*longrunningpb.Operation), nil
+}
 
-	return []option.ClientOption{
-		option.WithEndpoint(fakeServerAddr),
-		option.WithoutAuthentication(),
-		option.WithGRPCDialOption(grpc.WithInsecure()),
+func (f *fakeManagedKafkaServer) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	op, ok := f.operations[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", req.GetName())
 	}
+	return proto.Clone(op).(*longrunningpb.Operation), nil
 }
 
 func (f *fakeManagedKafkaServer) CreateCluster(ctx context.Context, req *managedkafkapb.CreateClusterRequest) (*longrunningpb.Operation, error) {
-	anypb := &anypb.Any{}
-	err := anypb.MarshalFrom(req.Cluster)
-	if err != nil {
-		return nil, fmt.Errorf("anypb.MarshalFrom got err: %w", err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req.GetClusterId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "cluster_id is required")
+	}
+	name := fmt.Sprintf("%s/clusters/%s", req.GetParent(), req.GetClusterId())
+	if _, ok := f.clusters[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "cluster %q already exists", name)
+	}
+	cluster := &managedkafkapb.Cluster{}
+	if req.GetCluster() != nil {
+		cluster = proto.Clone(req.GetCluster()).(*managedkafkapb.Cluster)
 	}
-	return &longrunningpb.Operation{
-		Done: true,
-		Result: &longrunningpb.Operation_Response{
-			Response: anypb,
-		},
-	}, nil
+	cluster.Name = name
+	cluster.State = managedkafkapb.Cluster_CREATING
+	cluster.CreateTime = timestamppb.Now()
+	cluster.UpdateTime = cluster.CreateTime
+	f.clusters[name] = cluster
+
+	return f.startOperation(name, "create", func() (proto.Message, error) {
+		cluster.State = managedkafkapb.Cluster_ACTIVE
+		return proto.Clone(cluster), nil
+	})
 }
 
 func (f *fakeManagedKafkaServer) DeleteCluster(ctx context.Context, req *managedkafkapb.DeleteClusterRequest) (*longrunningpb.Operation, error) {
-	return &longrunningpb.Operation{
-		Done: true,
-		Result: &longrunningpb.Operation_Response{
-			Response: &anypb.Any{},
-		},
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cluster, ok := f.clusters[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", req.GetName())
+	}
+	if cluster.State != managedkafkapb.Cluster_ACTIVE {
+		return nil, status.Errorf(codes.FailedPrecondition, "cluster %q is %s", req.GetName(), cluster.State)
+	}
+	cluster.State = managedkafkapb.Cluster_DELETING
+
+	return f.startOperation(req.GetName(), "delete", func() (proto.Message, error) {
+		delete(f.clusters, req.GetName())
+		for _, name := range childNames(f.topics, req.GetName(), "topics") {
+			delete(f.topics, name)
+		}
+		for _, name := range childNames(f.consumerGroups, req.GetName(), "consumerGroups") {
+			delete(f.consumerGroups, name)
+		}
+		return &emptypb.Empty{}, nil
+	})
 }
 
 func (f *fakeManagedKafkaServer) GetCluster(ctx context.Context, req *managedkafkapb.GetClusterRequest) (*managedkafkapb.Cluster, error) {
-	return &managedkafkapb.Cluster{
-		Name: clusterID,
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cluster, ok := f.clusters[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", req.GetName())
+	}
+	return proto.Clone(cluster).(*managedkafkapb.Cluster), nil
 }
 
 func (f *fakeManagedKafkaServer) ListClusters(ctx context.Context, req *managedkafkapb.ListClustersRequest) (*managedkafkapb.ListClustersResponse, error) {
-	return &managedkafkapb.ListClustersResponse{
-		Clusters: []*managedkafkapb.Cluster{{
-			Name: clusterID,
-		}},
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names, next, err := page(childNames(f.clusters, req.GetParent(), "clusters"), req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	resp := &managedkafkapb.ListClustersResponse{NextPageToken: next}
+	for _, name := range names {
+		resp.Clusters = append(resp.Clusters, proto.Clone(f.clusters[name]).(*managedkafkapb.Cluster))
+	}
+	return resp, nil
 }
 
 func (f *fakeManagedKafkaServer) UpdateCluster(ctx context.Context, req *managedkafkapb.UpdateClusterRequest) (*longrunningpb.Operation, error) {
-	anypb := &anypb.Any{}
-	err := anypb.MarshalFrom(req.Cluster)
-	if err != nil {
-		return nil, fmt.Errorf("anypb.MarshalFrom got err: %w", err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.GetCluster().GetName()
+	cluster, ok := f.clusters[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", name)
 	}
-	return &longrunningpb.Operation{
-		Done: true,
-		Result: &longrunningpb.Operation_Response{
-			Response: anypb,
-		},
-	}, nil
+	if cluster.State != managedkafkapb.Cluster_ACTIVE {
+		return nil, status.Errorf(codes.FailedPrecondition, "cluster %q is %s", name, cluster.State)
+	}
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	updated := proto.Clone(cluster).(*managedkafkapb.Cluster)
+	if err := testutil.ApplyFieldMask(updated, req.GetCluster(), req.GetUpdateMask().GetPaths()); err != nil {
+		return nil, err
+	}
+
+	return f.startOperation(name, "update", func() (proto.Message, error) {
+		current, ok := f.clusters[name]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "cluster %q not found", name)
+		}
+		updated.State = current.State
+		updated.UpdateTime = timestamppb.Now()
+		f.clusters[name] = updated
+		return proto.Clone(updated), nil
+	})
+}
+
+// activeCluster returns an error unless the named cluster exists and is
+// ACTIVE. Callers must hold f.mu.
+func (f *fakeManagedKafkaServer) activeCluster(name string) error {
+	cluster, ok := f.clusters[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "cluster %q not found", name)
+	}
+	if cluster.State != managedkafkapb.Cluster_ACTIVE {
+		return status.Errorf(codes.FailedPrecondition, "cluster %q is %s", name, cluster.State)
+	}
+	return nil
 }
 
 func (f *fakeManagedKafkaServer) CreateTopic(ctx context.Context, req *managedkafkapb.CreateTopicRequest) (*managedkafkapb.Topic, error) {
-	return req.Topic, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.activeCluster(req.GetParent()); err != nil {
+		return nil, err
+	}
+	if req.GetTopicId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "topic_id is required")
+	}
+	if req.GetTopic().GetPartitionCount() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "partition_count must be positive")
+	}
+	name := fmt.Sprintf("%s/topics/%s", req.GetParent(), req.GetTopicId())
+	if _, ok := f.topics[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "topic %q already exists", name)
+	}
+	topic := proto.Clone(req.GetTopic()).(*managedkafkapb.Topic)
+	topic.Name = name
+	f.topics[name] = topic
+	return proto.Clone(topic).(*managedkafkapb.Topic), nil
 }
 
 func (f *fakeManagedKafkaServer) DeleteTopic(ctx context.Context, req *managedkafkapb.DeleteTopicRequest) (*emptypb.Empty, error) {
-	return nil, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.topics[req.GetName()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "topic %q not found", req.GetName())
+	}
+	delete(f.topics, req.GetName())
+	return &emptypb.Empty{}, nil
 }
 
 func (f *fakeManagedKafkaServer) GetTopic(ctx context.Context, req *managedkafkapb.GetTopicRequest) (*managedkafkapb.Topic, error) {
-	return &managedkafkapb.Topic{
-		Name: topicID,
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	topic, ok := f.topics[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "topic %q not found", req.GetName())
+	}
+	return proto.Clone(topic).(*managedkafkapb.Topic), nil
 }
 
 func (f *fakeManagedKafkaServer) ListTopics(ctx context.Context, req *managedkafkapb.ListTopicsRequest) (*managedkafkapb.ListTopicsResponse, error) {
-	return &managedkafkapb.ListTopicsResponse{
-		Topics: []*managedkafkapb.Topic{{
-			Name: topicID,
-		}},
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clusters[req.GetParent()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", req.GetParent())
+	}
+	names, next, err := page(childNames(f.topics, req.GetParent(), "topics"), req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	resp := &managedkafkapb.ListTopicsResponse{NextPageToken: next}
+	for _, name := range names {
+		resp.Topics = append(resp.Topics, proto.Clone(f.topics[name]).(*managedkafkapb.Topic))
+	}
+	return resp, nil
 }
 
 func (f *fakeManagedKafkaServer) UpdateTopic(ctx context.Context, req *managedkafkapb.UpdateTopicRequest) (*managedkafkapb.Topic, error) {
-	return &managedkafkapb.Topic{
-		Name: topicID,
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.GetTopic().GetName()
+	topic, ok := f.topics[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "topic %q not found", name)
+	}
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	updated := proto.Clone(topic).(*managedkafkapb.Topic)
+	if err := testutil.ApplyFieldMask(updated, req.GetTopic(), req.GetUpdateMask().GetPaths()); err != nil {
+		return nil, err
+	}
+	// Kafka can add partitions to a topic but never remove them.
+	if updated.GetPartitionCount() < topic.GetPartitionCount() {
+		return nil, status.Errorf(codes.InvalidArgument, "partition_count cannot decrease from %d to %d", topic.GetPartitionCount(), updated.GetPartitionCount())
+	}
+	f.topics[name] = updated
+	return proto.Clone(updated).(*managedkafkapb.Topic), nil
 }
 
 func (f *fakeManagedKafkaServer) DeleteConsumerGroup(ctx context.Context, req *managedkafkapb.DeleteConsumerGroupRequest) (*emptypb.Empty, error) {
-	return nil, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.consumerGroups[req.GetName()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "consumer group %q not found", req.GetName())
+	}
+	delete(f.consumerGroups, req.GetName())
+	return &emptypb.Empty{}, nil
 }
 
 func (f *fakeManagedKafkaServer) GetConsumerGroup(ctx context.Context, req *managedkafkapb.GetConsumerGroupRequest) (*managedkafkapb.ConsumerGroup, error) {
-	return &managedkafkapb.ConsumerGroup{
-		Name: consumerGroupID,
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	group, ok := f.consumerGroups[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "consumer group %q not found", req.GetName())
+	}
+	return proto.Clone(group).(*managedkafkapb.ConsumerGroup), nil
 }
 
 func (f *fakeManagedKafkaServer) ListConsumerGroups(ctx context.Context, req *managedkafkapb.ListConsumerGroupsRequest) (*managedkafkapb.ListConsumerGroupsResponse, error) {
-	return &managedkafkapb.ListConsumerGroupsResponse{
-		ConsumerGroups: []*managedkafkapb.ConsumerGroup{{
-			Name: consumerGroupID,
-		}},
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clusters[req.GetParent()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "cluster %q not found", req.GetParent())
+	}
+	names, next, err := page(childNames(f.consumerGroups, req.GetParent(), "consumerGroups"), req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	resp := &managedkafkapb.ListConsumerGroupsResponse{NextPageToken: next}
+	for _, name := range names {
+		resp.ConsumerGroups = append(resp.ConsumerGroups, proto.Clone(f.consumerGroups[name]).(*managedkafkapb.ConsumerGroup))
+	}
+	return resp, nil
 }
 
 func (f *fakeManagedKafkaServer) UpdateConsumerGroup(ctx context.Context, req *managedkafkapb.UpdateConsumerGroupRequest) (*managedkafkapb.ConsumerGroup, error) {
-	return &managedkafkapb.ConsumerGroup{
-		Name: consumerGroupID,
-	}, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.GetConsumerGroup().GetName()
+	group, ok := f.consumerGroups[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "consumer group %q not found", name)
+	}
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	updated := proto.Clone(group).(*managedkafkapb.ConsumerGroup)
+	if err := testutil.ApplyFieldMask(updated, req.GetConsumerGroup(), req.GetUpdateMask().GetPaths()); err != nil {
+		return nil, err
+	}
+	f.consumerGroups[name] = updated
+	return proto.Clone(updated).(*managedkafkapb.ConsumerGroup), nil
 }
@@ -0,0 +1,44 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake registers the Pub/Sub client library's pstest server with
+// testutil under the name "pubsub".
+package fake
+
+import (
+	"testing"
+
+	"cloud.google.com/go/pubsub/pstest"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func init() {
+	testutil.RegisterFake("pubsub", Options)
+}
+
+// Options starts an in-memory Pub/Sub server and returns the options to
+// connect to it. The server is closed when t completes.
+func Options(t *testing.T) []option.ClientOption {
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	return []option.ClientOption{
+		option.WithEndpoint(srv.Addr),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestClient(t *testing.T) *pubsub.Client {
+	t.Helper()
+	client, err := pubsub.NewClient(context.Background(), "p", Options(t)...)
+	if err != nil {
+		t.Fatalf("pubsub.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	err := client.Topic("missing").Delete(ctx)
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("Delete(missing): got code %v, want NotFound", got)
+	}
+	ok, err := client.Topic("missing").Exists(ctx)
+	if err != nil {
+		t.Fatalf("Exists: %v", err)
+	}
+	if ok {
+		t.Errorf("Exists(missing) = true, want false")
+	}
+}
+
+func TestAlreadyExists(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if _, err := client.CreateTopic(ctx, "t"); err != nil {
+		t.Fatalf("CreateTopic: %v", err)
+	}
+	_, err := client.CreateTopic(ctx, "t")
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateTopic twice: got code %v, want AlreadyExists", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	for _, id := range []string{"b", "a"} {
+		if _, err := client.CreateTopic(ctx, id); err != nil {
+			t.Fatalf("CreateTopic(%q): %v", id, err)
+		}
+	}
+
+	var got []string
+	it := client.Topics(ctx)
+	for {
+		topic, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Topics: %v", err)
+		}
+		got = append(got, topic.ID())
+	}
+	slices.Sort(got)
+	if want := []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("Topics = %v, want %v", got, want)
+	}
+}
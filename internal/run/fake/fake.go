@@ -0,0 +1,389 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory Cloud Run Admin API (v2) server for
+// tests. Importing it registers the fake with testutil under the name "run".
+//
+// Services and jobs are stored in memory. Every create or update of a service
+// makes a new ready revision that receives all traffic. Job executions
+// succeed immediately. Long-running operations complete the first time they
+// are polled, so clients exercise their polling code. IAM policies get a new
+// etag on every write, and writes with a stale etag fail with Aborted.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/iam/apiv1/iampb"
+	"cloud.google.com/go/run/apiv2/runpb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	longrunningpb "cloud.google.com/go/longrunning/autogen/longrunningpb"
+)
+
+func init() {
+	testutil.RegisterFake("run", Options)
+}
+
+// Server is an in-memory Cloud Run Admin API server.
+//
+// Both the Services and Jobs services have IAM methods, so Server implements
+// them itself for services and jobs alike.
+type Server struct {
+	runpb.UnimplementedServicesServer
+	runpb.UnimplementedJobsServer
+	longrunningpb.UnimplementedOperationsServer
+
+	mu            sync.Mutex
+	services      map[string]*runpb.Service
+	jobs          map[string]*runpb.Job
+	policies      map[string]*iampb.Policy
+	operations    map[string]*pendingOperation
+	nextOperation int
+	// nextEtag versions IAM policies, so that callers can tell whether a
+	// policy was written.
+	nextEtag int
+}
+
+// pendingOperation is an operation and the function that completes it.
+type pendingOperation struct {
+	op   *longrunningpb.Operation
+	done func() (proto.Message, error)
+}
+
+// Options starts a fake Cloud Run Admin API server and returns the options
+// to connect to it.
+func Options(t *testing.T) []option.ClientOption {
+	_, opts := New(t)
+	return opts
+}
+
+// New starts a fake Cloud Run Admin API server and returns it, for tests
+// that inspect its state, along with the options to connect to it.
+func New(t *testing.T) (*Server, []option.ClientOption) {
+	server := &Server{
+		services:   map[string]*runpb.Service{},
+		jobs:       map[string]*runpb.Job{},
+		policies:   map[string]*iampb.Policy{},
+		operations: map[string]*pendingOperation{},
+	}
+	return server, testutil.ServeFake(t, func(s *grpc.Server) {
+		runpb.RegisterServicesServer(s, server)
+		runpb.RegisterJobsServer(s, server)
+		longrunningpb.RegisterOperationsServer(s, server)
+	})
+}
+
+// Policy returns the IAM policy set on a resource, or nil.
+func (f *Server) Policy(resource string) *iampb.Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.policies[resource]; ok {
+		return proto.Clone(p).(*iampb.Policy)
+	}
+	return nil
+}
+
+// startOperation records an operation that completes with the result of
+// done the first time it is polled. Callers must hold f.mu, and done is
+// called with f.mu held.
+func (f *Server) startOperation(parent string, metadata proto.Message, done func() (proto.Message, error)) (*longrunningpb.Operation, error) {
+	f.nextOperation++
+	md, err := anypb.New(metadata)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "anypb.New got err: %v", err)
+	}
+	op := &longrunningpb.Operation{
+		Name:     fmt.Sprintf("%s/operations/operation-%d", parent, f.nextOperation),
+		Metadata: md,
+	}
+	f.operations[op.Name] = &pendingOperation{op: op, done: done}
+	return proto.Clone(op).(*longrunningpb.Operation), nil
+}
+
+func (f *Server) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.operations[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "operation %q not found", req.GetName())
+	}
+	if !p.op.Done {
+		p.op.Done = true
+		resp, err := p.done()
+		if err == nil {
+			var packed *anypb.Any
+			if packed, err = anypb.New(resp); err == nil {
+				p.op.Result = &longrunningpb.Operation_Response{Response: packed}
+			}
+		}
+		if err != nil {
+			p.op.Result = &longrunningpb.Operation_Error{Error: status.Convert(err).Proto()}
+		}
+	}
+	return proto.Clone(p.op).(*longrunningpb.Operation), nil
+}
+
+// locationOf returns the projects/*/locations/* prefix of a resource name.
+func locationOf(name string) string {
+	parts := strings.SplitN(name, "/", 5)
+	if len(parts) < 4 {
+		return name
+	}
+	return strings.Join(parts[:4], "/")
+}
+
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// ready returns a Ready condition in the succeeded state.
+func ready() *runpb.Condition {
+	return &runpb.Condition{
+		Type:               "Ready",
+		State:              runpb.Condition_CONDITION_SUCCEEDED,
+		LastTransitionTime: timestamppb.Now(),
+	}
+}
+
+// rollOut makes a new revision of svc and sends all traffic to it.
+func rollOut(svc *runpb.Service) {
+	svc.Generation++
+	svc.UpdateTime = timestamppb.Now()
+	svc.Etag = fmt.Sprintf(`"%d"`, svc.Generation)
+	revision := fmt.Sprintf("%s-%05d-fake", lastSegment(svc.Name), svc.Generation)
+	svc.LatestCreatedRevision = fmt.Sprintf("%s/revisions/%s", svc.Name, revision)
+	svc.LatestReadyRevision = svc.LatestCreatedRevision
+	svc.ObservedGeneration = svc.Generation
+	svc.TerminalCondition = ready()
+	svc.Reconciling = false
+	svc.TrafficStatuses = []*runpb.TrafficTargetStatus{{
+		Type:     runpb.TrafficTargetAllocationType_TRAFFIC_TARGET_ALLOCATION_TYPE_LATEST,
+		Revision: revision,
+		Percent:  100,
+	}}
+}
+
+func (f *Server) CreateService(ctx context.Context, req *runpb.CreateServiceRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req.GetServiceId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "service_id is required")
+	}
+	if req.GetService().GetTemplate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "service.template is required")
+	}
+	name := fmt.Sprintf("%s/services/%s", req.GetParent(), req.GetServiceId())
+	if _, ok := f.services[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "service %q already exists", name)
+	}
+	svc := proto.Clone(req.GetService()).(*runpb.Service)
+	svc.Name = name
+	svc.Uid = fmt.Sprintf("uid-%s", req.GetServiceId())
+	svc.CreateTime = timestamppb.Now()
+	svc.Uri = fmt.Sprintf("https://%s-fake.a.run.app", req.GetServiceId())
+	svc.Reconciling = true
+	f.services[name] = svc
+
+	return f.startOperation(req.GetParent(), svc, func() (proto.Message, error) {
+		rollOut(svc)
+		return proto.Clone(svc), nil
+	})
+}
+
+func (f *Server) GetService(ctx context.Context, req *runpb.GetServiceRequest) (*runpb.Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	svc, ok := f.services[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetName())
+	}
+	return proto.Clone(svc).(*runpb.Service), nil
+}
+
+func (f *Server) UpdateService(ctx context.Context, req *runpb.UpdateServiceRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.GetService().GetName()
+	svc, ok := f.services[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", name)
+	}
+	if etag := req.GetService().GetEtag(); etag != "" && etag != svc.Etag {
+		return nil, status.Errorf(codes.Aborted, "etag %s does not match %s", etag, svc.Etag)
+	}
+	svc.Template = proto.Clone(req.GetService().GetTemplate()).(*runpb.RevisionTemplate)
+	svc.Labels = req.GetService().GetLabels()
+	svc.Reconciling = true
+
+	return f.startOperation(locationOf(name), svc, func() (proto.Message, error) {
+		rollOut(svc)
+		return proto.Clone(svc), nil
+	})
+}
+
+func (f *Server) DeleteService(ctx context.Context, req *runpb.DeleteServiceRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	svc, ok := f.services[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetName())
+	}
+	return f.startOperation(locationOf(req.GetName()), svc, func() (proto.Message, error) {
+		delete(f.services, req.GetName())
+		delete(f.policies, req.GetName())
+		return proto.Clone(svc), nil
+	})
+}
+
+func (f *Server) GetIamPolicy(ctx context.Context, req *iampb.GetIamPolicyRequest) (*iampb.Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkResource(req.GetResource()); err != nil {
+		return nil, err
+	}
+	if p, ok := f.policies[req.GetResource()]; ok {
+		return proto.Clone(p).(*iampb.Policy), nil
+	}
+	return &iampb.Policy{}, nil
+}
+
+func (f *Server) SetIamPolicy(ctx context.Context, req *iampb.SetIamPolicyRequest) (*iampb.Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkResource(req.GetResource()); err != nil {
+		return nil, err
+	}
+	policy := proto.Clone(req.GetPolicy()).(*iampb.Policy)
+	if old, ok := f.policies[req.GetResource()]; ok && len(policy.GetEtag()) > 0 && !bytes.Equal(policy.GetEtag(), old.GetEtag()) {
+		return nil, status.Errorf(codes.Aborted, "policy for %q was modified concurrently", req.GetResource())
+	}
+	f.nextEtag++
+	policy.Etag = []byte(strconv.Itoa(f.nextEtag))
+	f.policies[req.GetResource()] = policy
+	return proto.Clone(policy).(*iampb.Policy), nil
+}
+
+func (f *Server) TestIamPermissions(ctx context.Context, req *iampb.TestIamPermissionsRequest) (*iampb.TestIamPermissionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.checkResource(req.GetResource()); err != nil {
+		return nil, err
+	}
+	return &iampb.TestIamPermissionsResponse{Permissions: req.GetPermissions()}, nil
+}
+
+// checkResource returns NotFound unless resource is a known service or job.
+// Callers must hold f.mu.
+func (f *Server) checkResource(resource string) error {
+	if _, ok := f.services[resource]; ok {
+		return nil
+	}
+	if _, ok := f.jobs[resource]; ok {
+		return nil
+	}
+	return status.Errorf(codes.NotFound, "resource %q not found", resource)
+}
+
+func (f *Server) CreateJob(ctx context.Context, req *runpb.CreateJobRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if req.GetJobId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "job_id is required")
+	}
+	if req.GetJob().GetTemplate() == nil {
+		return nil, status.Error(codes.InvalidArgument, "job.template is required")
+	}
+	name := fmt.Sprintf("%s/jobs/%s", req.GetParent(), req.GetJobId())
+	if _, ok := f.jobs[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "job %q already exists", name)
+	}
+	job := proto.Clone(req.GetJob()).(*runpb.Job)
+	job.Name = name
+	job.Uid = fmt.Sprintf("uid-%s", req.GetJobId())
+	job.CreateTime = timestamppb.Now()
+	job.Generation = 1
+	job.Reconciling = true
+	f.jobs[name] = job
+
+	return f.startOperation(req.GetParent(), job, func() (proto.Message, error) {
+		job.Reconciling = false
+		job.TerminalCondition = ready()
+		return proto.Clone(job), nil
+	})
+}
+
+func (f *Server) GetJob(ctx context.Context, req *runpb.GetJobRequest) (*runpb.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetName())
+	}
+	return proto.Clone(job).(*runpb.Job), nil
+}
+
+func (f *Server) RunJob(ctx context.Context, req *runpb.RunJobRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetName())
+	}
+	if job.Reconciling {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %q is not ready", req.GetName())
+	}
+	job.ExecutionCount++
+	exec := &runpb.Execution{
+		Name:       fmt.Sprintf("%s/executions/%s-fake%d", req.GetName(), lastSegment(req.GetName()), job.ExecutionCount),
+		Job:        lastSegment(req.GetName()),
+		CreateTime: timestamppb.Now(),
+		TaskCount:  max(job.GetTemplate().GetTaskCount(), 1),
+	}
+	job.LatestCreatedExecution = &runpb.ExecutionReference{Name: exec.Name, CreateTime: exec.CreateTime}
+
+	return f.startOperation(locationOf(req.GetName()), exec, func() (proto.Message, error) {
+		exec.CompletionTime = timestamppb.Now()
+		exec.SucceededCount = exec.TaskCount
+		exec.Conditions = []*runpb.Condition{{Type: "Completed", State: runpb.Condition_CONDITION_SUCCEEDED}}
+		job.LatestCreatedExecution.CompletionTime = exec.CompletionTime
+		return proto.Clone(exec), nil
+	})
+}
+
+func (f *Server) DeleteJob(ctx context.Context, req *runpb.DeleteJobRequest) (*longrunningpb.Operation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job, ok := f.jobs[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "job %q not found", req.GetName())
+	}
+	return f.startOperation(locationOf(req.GetName()), job, func() (proto.Message, error) {
+		delete(f.jobs, req.GetName())
+		return proto.Clone(job), nil
+	})
+}
@@ -0,0 +1,311 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory Secret Manager server for tests.
+// Importing it registers the fake with testutil under the name
+// "secretmanager".
+package fake
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func init() {
+	testutil.RegisterFake("secretmanager", Options)
+}
+
+// Options starts a fake Secret Manager server and returns the options to
+// connect to it.
+func Options(t *testing.T) []option.ClientOption {
+	server := newServer()
+	return testutil.ServeFake(t, func(s *grpc.Server) {
+		secretmanagerpb.RegisterSecretManagerServiceServer(s, server)
+	})
+}
+
+type secret struct {
+	secret   *secretmanagerpb.Secret
+	versions []*version
+}
+
+type version struct {
+	version *secretmanagerpb.SecretVersion
+	payload []byte
+}
+
+type server struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+
+	mu      sync.Mutex
+	secrets map[string]*secret
+	etag    int
+}
+
+func newServer() *server {
+	return &server{secrets: map[string]*secret{}}
+}
+
+// nextEtag returns a new opaque etag. Callers must hold s.mu.
+func (s *server) nextEtag() string {
+	s.etag++
+	return strconv.Quote(strconv.Itoa(s.etag))
+}
+
+func checkEtag(want, got string) error {
+	if want != "" && want != got {
+		return status.Errorf(codes.FailedPrecondition, "etag %s does not match current etag %s", want, got)
+	}
+	return nil
+}
+
+func (s *server) lookupSecret(name string) (*secret, error) {
+	sec, ok := s.secrets[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Secret [%s] not found or has no versions.", name)
+	}
+	return sec, nil
+}
+
+// lookupVersion resolves a version name, including the "latest" alias and
+// any alias from the secret's version_aliases.
+func (s *server) lookupVersion(name string) (*version, error) {
+	parent, id, ok := strings.Cut(name, "/versions/")
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid secret version name %q", name)
+	}
+	sec, err := s.lookupSecret(parent)
+	if err != nil {
+		return nil, err
+	}
+	if id == "latest" {
+		for i := len(sec.versions) - 1; i >= 0; i-- {
+			if sec.versions[i].version.State == secretmanagerpb.SecretVersion_ENABLED {
+				return sec.versions[i], nil
+			}
+		}
+		return nil, status.Errorf(codes.NotFound, "Secret [%s] has no enabled versions.", parent)
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		alias, ok := sec.secret.GetVersionAliases()[id]
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "Secret Version [%s] not found.", name)
+		}
+		n = int(alias)
+	}
+	if n < 1 || n > len(sec.versions) {
+		return nil, status.Errorf(codes.NotFound, "Secret Version [%s] not found.", name)
+	}
+	return sec.versions[n-1], nil
+}
+
+func (s *server) CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.GetSecretId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "secret_id is required")
+	}
+	name := fmt.Sprintf("%s/secrets/%s", req.GetParent(), req.GetSecretId())
+	if _, ok := s.secrets[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "Secret [%s] already exists.", name)
+	}
+	sec := &secretmanagerpb.Secret{}
+	if req.GetSecret() != nil {
+		sec = proto.Clone(req.GetSecret()).(*secretmanagerpb.Secret)
+	}
+	sec.Name = name
+	sec.CreateTime = timestamppb.Now()
+	sec.Etag = s.nextEtag()
+	s.secrets[name] = &secret{secret: sec}
+	return proto.Clone(sec).(*secretmanagerpb.Secret), nil
+}
+
+func (s *server) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.lookupSecret(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(sec.secret).(*secretmanagerpb.Secret), nil
+}
+
+func (s *server) ListSecrets(ctx context.Context, req *secretmanagerpb.ListSecretsRequest) (*secretmanagerpb.ListSecretsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.secrets {
+		if strings.HasPrefix(name, req.GetParent()+"/secrets/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	resp := &secretmanagerpb.ListSecretsResponse{TotalSize: int32(len(names))}
+	for _, name := range names {
+		resp.Secrets = append(resp.Secrets, proto.Clone(s.secrets[name].secret).(*secretmanagerpb.Secret))
+	}
+	return resp, nil
+}
+
+func (s *server) UpdateSecret(ctx context.Context, req *secretmanagerpb.UpdateSecretRequest) (*secretmanagerpb.Secret, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.lookupSecret(req.GetSecret().GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEtag(req.GetSecret().GetEtag(), sec.secret.GetEtag()); err != nil {
+		return nil, err
+	}
+	updated := proto.Clone(sec.secret).(*secretmanagerpb.Secret)
+	if err := testutil.ApplyFieldMask(updated, req.GetSecret(), req.GetUpdateMask().GetPaths()); err != nil {
+		return nil, err
+	}
+	updated.Etag = s.nextEtag()
+	sec.secret = updated
+	return proto.Clone(updated).(*secretmanagerpb.Secret), nil
+}
+
+func (s *server) DeleteSecret(ctx context.Context, req *secretmanagerpb.DeleteSecretRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.lookupSecret(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEtag(req.GetEtag(), sec.secret.GetEtag()); err != nil {
+		return nil, err
+	}
+	delete(s.secrets, req.GetName())
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.lookupSecret(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	data := req.GetPayload().GetData()
+	if c := req.GetPayload().DataCrc32C; c != nil && *c != int64(crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))) {
+		return nil, status.Error(codes.DataLoss, "payload checksum mismatch")
+	}
+	v := &secretmanagerpb.SecretVersion{
+		Name:       fmt.Sprintf("%s/versions/%d", req.GetParent(), len(sec.versions)+1),
+		CreateTime: timestamppb.Now(),
+		State:      secretmanagerpb.SecretVersion_ENABLED,
+		Etag:       s.nextEtag(),
+	}
+	sec.versions = append(sec.versions, &version{version: v, payload: append([]byte(nil), data...)})
+	return proto.Clone(v).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *server) GetSecretVersion(ctx context.Context, req *secretmanagerpb.GetSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookupVersion(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(v.version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *server) ListSecretVersions(ctx context.Context, req *secretmanagerpb.ListSecretVersionsRequest) (*secretmanagerpb.ListSecretVersionsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, err := s.lookupSecret(req.GetParent())
+	if err != nil {
+		return nil, err
+	}
+	resp := &secretmanagerpb.ListSecretVersionsResponse{TotalSize: int32(len(sec.versions))}
+	// Versions are listed newest first.
+	for i := len(sec.versions) - 1; i >= 0; i-- {
+		resp.Versions = append(resp.Versions, proto.Clone(sec.versions[i].version).(*secretmanagerpb.SecretVersion))
+	}
+	return resp, nil
+}
+
+func (s *server) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookupVersion(req.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if v.version.State != secretmanagerpb.SecretVersion_ENABLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "Secret Version [%s] is in %s state.", v.version.Name, v.version.State)
+	}
+	crc := int64(crc32.Checksum(v.payload, crc32.MakeTable(crc32.Castagnoli)))
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name: v.version.Name,
+		Payload: &secretmanagerpb.SecretPayload{
+			Data:       append([]byte(nil), v.payload...),
+			DataCrc32C: &crc,
+		},
+	}, nil
+}
+
+// setVersionState moves the named version to state, enforcing the etag and
+// that destroyed versions stay destroyed.
+func (s *server) setVersionState(name, etag string, state secretmanagerpb.SecretVersion_State) (*secretmanagerpb.SecretVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.lookupVersion(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEtag(etag, v.version.GetEtag()); err != nil {
+		return nil, err
+	}
+	if v.version.State == secretmanagerpb.SecretVersion_DESTROYED {
+		return nil, status.Errorf(codes.FailedPrecondition, "Secret Version [%s] is destroyed.", v.version.Name)
+	}
+	v.version.State = state
+	v.version.Etag = s.nextEtag()
+	if state == secretmanagerpb.SecretVersion_DESTROYED {
+		v.version.DestroyTime = timestamppb.Now()
+		v.payload = nil
+	}
+	return proto.Clone(v.version).(*secretmanagerpb.SecretVersion), nil
+}
+
+func (s *server) EnableSecretVersion(ctx context.Context, req *secretmanagerpb.EnableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.GetName(), req.GetEtag(), secretmanagerpb.SecretVersion_ENABLED)
+}
+
+func (s *server) DisableSecretVersion(ctx context.Context, req *secretmanagerpb.DisableSecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.GetName(), req.GetEtag(), secretmanagerpb.SecretVersion_DISABLED)
+}
+
+func (s *server) DestroySecretVersion(ctx context.Context, req *secretmanagerpb.DestroySecretVersionRequest) (*secretmanagerpb.SecretVersion, error) {
+	return s.setVersionState(req.GetName(), req.GetEtag(), secretmanagerpb.SecretVersion_DESTROYED)
+}
@@ -0,0 +1,144 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestClient(t *testing.T) *secretmanager.Client {
+	t.Helper()
+	client, err := secretmanager.NewClient(context.Background(), Options(t)...)
+	if err != nil {
+		t.Fatalf("secretmanager.NewClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func createSecret(t *testing.T, client *secretmanager.Client, id string) *secretmanagerpb.Secret {
+	t.Helper()
+	s, err := client.CreateSecret(context.Background(), &secretmanagerpb.CreateSecretRequest{
+		Parent:   "projects/p",
+		SecretId: id,
+		Secret:   &secretmanagerpb.Secret{},
+	})
+	if err != nil {
+		t.Fatalf("CreateSecret(%q): %v", id, err)
+	}
+	return s
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: "projects/p/secrets/missing"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetSecret(missing): got code %v, want NotFound", got)
+	}
+
+	s := createSecret(t, client, "s")
+	_, err = client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: s.Name + "/versions/1"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("AccessSecretVersion(missing version): got code %v, want NotFound", got)
+	}
+
+	if err := client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{Name: s.Name}); err != nil {
+		t.Fatalf("DeleteSecret: %v", err)
+	}
+	err = client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{Name: s.Name})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("DeleteSecret twice: got code %v, want NotFound", got)
+	}
+}
+
+func TestAlreadyExists(t *testing.T) {
+	client := newTestClient(t)
+
+	createSecret(t, client, "s")
+	_, err := client.CreateSecret(context.Background(), &secretmanagerpb.CreateSecretRequest{
+		Parent:   "projects/p",
+		SecretId: "s",
+		Secret:   &secretmanagerpb.Secret{},
+	})
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateSecret twice: got code %v, want AlreadyExists", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	createSecret(t, client, "b")
+	createSecret(t, client, "a")
+	if _, err := client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+		Parent:   "projects/other",
+		SecretId: "c",
+		Secret:   &secretmanagerpb.Secret{},
+	}); err != nil {
+		t.Fatalf("CreateSecret: %v", err)
+	}
+
+	var got []string
+	it := client.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{Parent: "projects/p"})
+	for {
+		s, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ListSecrets: %v", err)
+		}
+		got = append(got, s.Name)
+	}
+	if want := []string{"projects/p/secrets/a", "projects/p/secrets/b"}; !slices.Equal(got, want) {
+		t.Errorf("ListSecrets = %v, want %v", got, want)
+	}
+
+	for range 2 {
+		if _, err := client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+			Parent:  "projects/p/secrets/a",
+			Payload: &secretmanagerpb.SecretPayload{Data: []byte("x")},
+		}); err != nil {
+			t.Fatalf("AddSecretVersion: %v", err)
+		}
+	}
+	got = nil
+	vit := client.ListSecretVersions(ctx, &secretmanagerpb.ListSecretVersionsRequest{Parent: "projects/p/secrets/a"})
+	for {
+		v, err := vit.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ListSecretVersions: %v", err)
+		}
+		got = append(got, v.Name)
+	}
+	// Versions are listed newest first.
+	if want := []string{"projects/p/secrets/a/versions/2", "projects/p/secrets/a/versions/1"}; !slices.Equal(got, want) {
+		t.Errorf("ListSecretVersions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory Service Directory server for tests.
+// Importing it registers the fake with testutil under the name
+// "servicedirectory".
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	sdpb "cloud.google.com/go/servicedirectory/apiv1/servicedirectorypb"
+	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func init() {
+	testutil.RegisterFake("servicedirectory", Options)
+}
+
+// Options starts a fake Service Directory server serving both the
+// registration and lookup APIs, and returns the options to connect to it.
+func Options(t *testing.T) []option.ClientOption {
+	server := newServer()
+	return testutil.ServeFake(t, func(s *grpc.Server) {
+		sdpb.RegisterRegistrationServiceServer(s, server)
+		sdpb.RegisterLookupServiceServer(s, &lookupServer{server})
+	})
+}
+
+type server struct {
+	sdpb.UnimplementedRegistrationServiceServer
+
+	mu         sync.Mutex
+	namespaces map[string]*sdpb.Namespace
+	services   map[string]*sdpb.Service
+	endpoints  map[string]*sdpb.Endpoint
+}
+
+func newServer() *server {
+	return &server{
+		namespaces: map[string]*sdpb.Namespace{},
+		services:   map[string]*sdpb.Service{},
+		endpoints:  map[string]*sdpb.Endpoint{},
+	}
+}
+
+func (s *server) CreateNamespace(ctx context.Context, req *sdpb.CreateNamespaceRequest) (*sdpb.Namespace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := fmt.Sprintf("%s/namespaces/%s", req.GetParent(), req.GetNamespaceId())
+	if _, ok := s.namespaces[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "namespace %q already exists", name)
+	}
+	ns := &sdpb.Namespace{}
+	if req.GetNamespace() != nil {
+		ns = proto.Clone(req.GetNamespace()).(*sdpb.Namespace)
+	}
+	ns.Name = name
+	s.namespaces[name] = ns
+	return proto.Clone(ns).(*sdpb.Namespace), nil
+}
+
+func (s *server) GetNamespace(ctx context.Context, req *sdpb.GetNamespaceRequest) (*sdpb.Namespace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ns, ok := s.namespaces[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "namespace %q not found", req.GetName())
+	}
+	return proto.Clone(ns).(*sdpb.Namespace), nil
+}
+
+func (s *server) ListNamespaces(ctx context.Context, req *sdpb.ListNamespacesRequest) (*sdpb.ListNamespacesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &sdpb.ListNamespacesResponse{}
+	for _, name := range sortedChildren(s.namespaces, req.GetParent()) {
+		resp.Namespaces = append(resp.Namespaces, proto.Clone(s.namespaces[name]).(*sdpb.Namespace))
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteNamespace(ctx context.Context, req *sdpb.DeleteNamespaceRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.namespaces[req.GetName()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "namespace %q not found", req.GetName())
+	}
+	// Deleting a namespace deletes everything registered in it.
+	for name := range s.services {
+		if strings.HasPrefix(name, req.GetName()+"/") {
+			delete(s.services, name)
+		}
+	}
+	for name := range s.endpoints {
+		if strings.HasPrefix(name, req.GetName()+"/") {
+			delete(s.endpoints, name)
+		}
+	}
+	delete(s.namespaces, req.GetName())
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) CreateService(ctx context.Context, req *sdpb.CreateServiceRequest) (*sdpb.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.namespaces[req.GetParent()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "namespace %q not found", req.GetParent())
+	}
+	name := fmt.Sprintf("%s/services/%s", req.GetParent(), req.GetServiceId())
+	if _, ok := s.services[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "service %q already exists", name)
+	}
+	svc := &sdpb.Service{}
+	if req.GetService() != nil {
+		svc = proto.Clone(req.GetService()).(*sdpb.Service)
+	}
+	svc.Name = name
+	svc.Endpoints = nil
+	s.services[name] = svc
+	return proto.Clone(svc).(*sdpb.Service), nil
+}
+
+func (s *server) GetService(ctx context.Context, req *sdpb.GetServiceRequest) (*sdpb.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetName())
+	}
+	return proto.Clone(svc).(*sdpb.Service), nil
+}
+
+func (s *server) ListServices(ctx context.Context, req *sdpb.ListServicesRequest) (*sdpb.ListServicesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &sdpb.ListServicesResponse{}
+	for _, name := range sortedChildren(s.services, req.GetParent()) {
+		resp.Services = append(resp.Services, proto.Clone(s.services[name]).(*sdpb.Service))
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteService(ctx context.Context, req *sdpb.DeleteServiceRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.services[req.GetName()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetName())
+	}
+	for name := range s.endpoints {
+		if strings.HasPrefix(name, req.GetName()+"/") {
+			delete(s.endpoints, name)
+		}
+	}
+	delete(s.services, req.GetName())
+	return &emptypb.Empty{}, nil
+}
+
+func (s *server) CreateEndpoint(ctx context.Context, req *sdpb.CreateEndpointRequest) (*sdpb.Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.services[req.GetParent()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetParent())
+	}
+	name := fmt.Sprintf("%s/endpoints/%s", req.GetParent(), req.GetEndpointId())
+	if _, ok := s.endpoints[name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "endpoint %q already exists", name)
+	}
+	ep := &sdpb.Endpoint{}
+	if req.GetEndpoint() != nil {
+		ep = proto.Clone(req.GetEndpoint()).(*sdpb.Endpoint)
+	}
+	ep.Name = name
+	s.endpoints[name] = ep
+	return proto.Clone(ep).(*sdpb.Endpoint), nil
+}
+
+func (s *server) GetEndpoint(ctx context.Context, req *sdpb.GetEndpointRequest) (*sdpb.Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, ok := s.endpoints[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "endpoint %q not found", req.GetName())
+	}
+	return proto.Clone(ep).(*sdpb.Endpoint), nil
+}
+
+func (s *server) ListEndpoints(ctx context.Context, req *sdpb.ListEndpointsRequest) (*sdpb.ListEndpointsResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &sdpb.ListEndpointsResponse{}
+	for _, name := range sortedChildren(s.endpoints, req.GetParent()) {
+		resp.Endpoints = append(resp.Endpoints, proto.Clone(s.endpoints[name]).(*sdpb.Endpoint))
+	}
+	return resp, nil
+}
+
+func (s *server) DeleteEndpoint(ctx context.Context, req *sdpb.DeleteEndpointRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[req.GetName()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "endpoint %q not found", req.GetName())
+	}
+	delete(s.endpoints, req.GetName())
+	return &emptypb.Empty{}, nil
+}
+
+// lookupServer resolves services from the registration server's state.
+type lookupServer struct {
+	sdpb.UnimplementedLookupServiceServer
+	*server
+}
+
+func (l *lookupServer) ResolveService(ctx context.Context, req *sdpb.ResolveServiceRequest) (*sdpb.ResolveServiceResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	svc, ok := l.services[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "service %q not found", req.GetName())
+	}
+	resolved := proto.Clone(svc).(*sdpb.Service)
+	for _, name := range sortedChildren(l.endpoints, req.GetName()) {
+		if max := req.GetMaxEndpoints(); max > 0 && len(resolved.Endpoints) >= int(max) {
+			break
+		}
+		resolved.Endpoints = append(resolved.Endpoints, proto.Clone(l.endpoints[name]).(*sdpb.Endpoint))
+	}
+	return &sdpb.ResolveServiceResponse{Service: resolved}, nil
+}
+
+// sortedChildren returns the sorted keys of m that are direct children of
+// parent, i.e. parent/<collection>/<id>.
+func sortedChildren[V any](m map[string]V, parent string) []string {
+	var names []string
+	for name := range m {
+		rest, ok := strings.CutPrefix(name, parent+"/")
+		if ok && strings.Count(rest, "/") == 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
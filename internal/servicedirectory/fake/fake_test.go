@@ -0,0 +1,133 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	servicedirectory "cloud.google.com/go/servicedirectory/apiv1"
+	sdpb "cloud.google.com/go/servicedirectory/apiv1/servicedirectorypb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testLocation = "projects/p/locations/us-east4"
+
+func newTestClient(t *testing.T) *servicedirectory.RegistrationClient {
+	t.Helper()
+	client, err := servicedirectory.NewRegistrationClient(context.Background(), Options(t)...)
+	if err != nil {
+		t.Fatalf("servicedirectory.NewRegistrationClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func createNamespace(ctx context.Context, client *servicedirectory.RegistrationClient, id string) (*sdpb.Namespace, error) {
+	return client.CreateNamespace(ctx, &sdpb.CreateNamespaceRequest{
+		Parent:      testLocation,
+		NamespaceId: id,
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	_, err := client.GetNamespace(ctx, &sdpb.GetNamespaceRequest{Name: testLocation + "/namespaces/missing"})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("GetNamespace(missing): got code %v, want NotFound", got)
+	}
+
+	_, err = client.CreateService(ctx, &sdpb.CreateServiceRequest{
+		Parent:    testLocation + "/namespaces/missing",
+		ServiceId: "s",
+	})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("CreateService in missing namespace: got code %v, want NotFound", got)
+	}
+
+	ns, err := createNamespace(ctx, client, "ns")
+	if err != nil {
+		t.Fatalf("CreateNamespace: %v", err)
+	}
+	if err := client.DeleteNamespace(ctx, &sdpb.DeleteNamespaceRequest{Name: ns.Name}); err != nil {
+		t.Fatalf("DeleteNamespace: %v", err)
+	}
+	err = client.DeleteNamespace(ctx, &sdpb.DeleteNamespaceRequest{Name: ns.Name})
+	if got := status.Code(err); got != codes.NotFound {
+		t.Errorf("DeleteNamespace twice: got code %v, want NotFound", got)
+	}
+}
+
+func TestAlreadyExists(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	if _, err := createNamespace(ctx, client, "ns"); err != nil {
+		t.Fatalf("CreateNamespace: %v", err)
+	}
+	_, err := createNamespace(ctx, client, "ns")
+	if got := status.Code(err); got != codes.AlreadyExists {
+		t.Errorf("CreateNamespace twice: got code %v, want AlreadyExists", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	ns, err := createNamespace(ctx, client, "ns")
+	if err != nil {
+		t.Fatalf("CreateNamespace: %v", err)
+	}
+	for _, id := range []string{"b", "a"} {
+		if _, err := client.CreateService(ctx, &sdpb.CreateServiceRequest{
+			Parent:    ns.Name,
+			ServiceId: id,
+		}); err != nil {
+			t.Fatalf("CreateService(%q): %v", id, err)
+		}
+	}
+	other, err := createNamespace(ctx, client, "other")
+	if err != nil {
+		t.Fatalf("CreateNamespace: %v", err)
+	}
+	if _, err := client.CreateService(ctx, &sdpb.CreateServiceRequest{
+		Parent:    other.Name,
+		ServiceId: "c",
+	}); err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	var got []string
+	it := client.ListServices(ctx, &sdpb.ListServicesRequest{Parent: ns.Name})
+	for {
+		s, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ListServices: %v", err)
+		}
+		got = append(got, s.Name)
+	}
+	if want := []string{ns.Name + "/services/a", ns.Name + "/services/b"}; !slices.Equal(got, want) {
+		t.Errorf("ListServices = %v, want %v", got, want)
+	}
+}
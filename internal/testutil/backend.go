@@ -0,0 +1,166 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Backend selects which service implementation system tests talk to.
+type Backend string
+
+const (
+	// RealBackend runs tests against live Google Cloud APIs in
+	// GOLANG_SAMPLES_PROJECT_ID. It is the default.
+	RealBackend Backend = "real"
+	// FakeBackend runs tests against in-process gRPC fakes registered
+	// with RegisterFake. No project or credentials are required.
+	FakeBackend Backend = "fake"
+)
+
+// fakeProjectID is used as the project ID when running against fakes and
+// GOLANG_SAMPLES_PROJECT_ID is not set.
+const fakeProjectID = "golang-samples-fake"
+
+// CurrentBackend returns the backend selected by the
+// GOLANG_SAMPLES_BACKEND environment variable.
+func CurrentBackend() (Backend, error) {
+	switch b := Backend(os.Getenv("GOLANG_SAMPLES_BACKEND")); b {
+	case "", RealBackend:
+		return RealBackend, nil
+	case FakeBackend:
+		return FakeBackend, nil
+	default:
+		return "", fmt.Errorf("unknown GOLANG_SAMPLES_BACKEND %q, want %q or %q", b, RealBackend, FakeBackend)
+	}
+}
+
+// FakeServer starts a fake implementation of a service for the duration of t
+// and returns the client options needed to reach it.
+type FakeServer func(t *testing.T) []option.ClientOption
+
+var (
+	fakesMu sync.Mutex
+	fakes   = map[string]FakeServer{}
+)
+
+// RegisterFake makes a fake available for the named service, for example
+// "secretmanager". It is meant to be called from the init function of a fake
+// package. RegisterFake panics if a fake is registered twice for a service.
+func RegisterFake(service string, f FakeServer) {
+	fakesMu.Lock()
+	defer fakesMu.Unlock()
+	if _, ok := fakes[service]; ok {
+		panic(fmt.Sprintf("testutil: fake for %q registered twice", service))
+	}
+	fakes[service] = f
+}
+
+func lookupFake(service string) (FakeServer, bool) {
+	fakesMu.Lock()
+	defer fakesMu.Unlock()
+	f, ok := fakes[service]
+	return f, ok
+}
+
+// RegisteredFakes returns the sorted names of all registered fakes.
+func RegisteredFakes() []string {
+	fakesMu.Lock()
+	defer fakesMu.Unlock()
+	var names []string
+	for name := range fakes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BackendTest gets the test context for a test that can run against either
+// the real services or the fakes registered for services.
+//
+// With the real backend it behaves like SystemTest. With the fake backend the
+// test is never skipped for lack of a project, unless one of services has no
+// registered fake. Use tc.ClientOptions to create clients for the chosen
+// backend.
+func BackendTest(t *testing.T, services ...string) Context {
+	t.Helper()
+	b, err := CurrentBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == RealBackend {
+		return SystemTest(t)
+	}
+
+	for _, s := range services {
+		if _, ok := lookupFake(s); !ok {
+			t.Skipf("no fake registered for %q", s)
+		}
+	}
+	tc, err := testContext()
+	if err == errNoProjectID {
+		tc.ProjectID = fakeProjectID
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	tc.Backend = FakeBackend
+	return tc
+}
+
+// ClientOptions returns the client options to reach service on the test
+// context's backend. With the real backend no options are returned and clients
+// use their default endpoints and credentials. With the fake backend a fresh
+// fake server is started for t.
+func (tc Context) ClientOptions(t *testing.T, service string) []option.ClientOption {
+	t.Helper()
+	if tc.Backend != FakeBackend {
+		return nil
+	}
+	f, ok := lookupFake(service)
+	if !ok {
+		t.Fatalf("no fake registered for %q", service)
+	}
+	return f(t)
+}
+
+// ServeFake starts an in-process gRPC server on a local port, lets register
+// attach service implementations to it, and returns the client options
+// pointing at that server. The server is stopped when t completes.
+func ServeFake(t *testing.T, register func(*grpc.Server)) []option.ClientOption {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	gsrv := grpc.NewServer()
+	register(gsrv)
+	go gsrv.Serve(listener)
+	t.Cleanup(gsrv.Stop)
+
+	return []option.ClientOption{
+		option.WithEndpoint(listener.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/api/option"
+	gtransport "google.golang.org/api/transport/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func init() {
+	RegisterFake("testutil-health", func(t *testing.T) []option.ClientOption {
+		return ServeFake(t, func(s *grpc.Server) {
+			healthpb.RegisterHealthServer(s, health.NewServer())
+		})
+	})
+}
+
+func TestCurrentBackend(t *testing.T) {
+	tests := []struct {
+		env     string
+		want    Backend
+		wantErr bool
+	}{
+		{env: "", want: RealBackend},
+		{env: "real", want: RealBackend},
+		{env: "fake", want: FakeBackend},
+		{env: "emulator", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Setenv("GOLANG_SAMPLES_BACKEND", test.env)
+		got, err := CurrentBackend()
+		if (err != nil) != test.wantErr {
+			t.Errorf("CurrentBackend() with %q: err = %v, wantErr %v", test.env, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CurrentBackend() with %q = %q, want %q", test.env, got, test.want)
+		}
+	}
+}
+
+func TestBackendTestFake(t *testing.T) {
+	t.Setenv("GOLANG_SAMPLES_BACKEND", "fake")
+	t.Setenv("GOLANG_SAMPLES_PROJECT_ID", "")
+
+	tc := BackendTest(t, "testutil-health")
+	if tc.Backend != FakeBackend {
+		t.Fatalf("Backend = %q, want %q", tc.Backend, FakeBackend)
+	}
+	if tc.ProjectID != fakeProjectID {
+		t.Errorf("ProjectID = %q, want %q", tc.ProjectID, fakeProjectID)
+	}
+
+	ctx := context.Background()
+	conn, err := gtransport.Dial(ctx, tc.ClientOptions(t, "testutil-health")...)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Check status = %v, want SERVING", got)
+	}
+}
+
+func TestBackendTestRealNoOptions(t *testing.T) {
+	tc := Context{Backend: RealBackend}
+	if opts := tc.ClientOptions(t, "testutil-health"); len(opts) != 0 {
+		t.Errorf("ClientOptions on real backend returned %d options, want 0", len(opts))
+	}
+}
+
+func TestRegisteredFakes(t *testing.T) {
+	var found bool
+	for _, name := range RegisteredFakes() {
+		if name == "testutil-health" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("RegisteredFakes() = %v, missing %q", RegisteredFakes(), "testutil-health")
+	}
+}
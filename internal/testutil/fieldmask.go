@@ -0,0 +1,60 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ApplyFieldMask copies the fields named in paths from src to dst, the way
+// Update RPCs treat their update_mask. Paths may name nested fields, such as
+// "capacity_config.memory_bytes". Fields unset in src are cleared in dst.
+// Fakes use it to implement Update* calls; an unknown path yields an
+// InvalidArgument status like the real services return.
+func ApplyFieldMask(dst, src proto.Message, paths []string) error {
+	for _, path := range paths {
+		if err := applyPath(dst.ProtoReflect(), src.ProtoReflect(), strings.Split(path, ".")); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid update_mask path %q for %s: %v", path, dst.ProtoReflect().Descriptor().FullName(), err)
+		}
+	}
+	return nil
+}
+
+func applyPath(d, s protoreflect.Message, path []string) error {
+	fd := d.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil {
+		return fmt.Errorf("no field %q in %s", path[0], d.Descriptor().FullName())
+	}
+	if len(path) > 1 {
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("field %q is not a message", path[0])
+		}
+		// An unset message in src reads as an empty, read-only message,
+		// which clears the remaining path in dst.
+		return applyPath(d.Mutable(fd).Message(), s.Get(fd).Message(), path[1:])
+	}
+	if s.Has(fd) {
+		d.Set(fd, s.Get(fd))
+	} else {
+		d.Clear(fd)
+	}
+	return nil
+}
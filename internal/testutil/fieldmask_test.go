@@ -0,0 +1,77 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestApplyFieldMask(t *testing.T) {
+	dst := &healthpb.HealthCheckRequest{Service: "old"}
+	if err := ApplyFieldMask(dst, &healthpb.HealthCheckRequest{Service: "new"}, []string{"service"}); err != nil {
+		t.Fatalf("ApplyFieldMask: %v", err)
+	}
+	if dst.Service != "new" {
+		t.Errorf("Service = %q, want %q", dst.Service, "new")
+	}
+
+	if err := ApplyFieldMask(dst, &healthpb.HealthCheckRequest{}, []string{"service"}); err != nil {
+		t.Fatalf("ApplyFieldMask: %v", err)
+	}
+	if dst.Service != "" {
+		t.Errorf("Service = %q, want it cleared", dst.Service)
+	}
+
+	err := ApplyFieldMask(dst, &healthpb.HealthCheckRequest{}, []string{"nope"})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("ApplyFieldMask with unknown path: code = %v, want %v", got, codes.InvalidArgument)
+	}
+}
+
+func TestApplyFieldMaskNested(t *testing.T) {
+	dst := &descriptorpb.FileDescriptorProto{
+		Name: proto.String("a.proto"),
+		Options: &descriptorpb.FileOptions{
+			GoPackage:   proto.String("old"),
+			JavaPackage: proto.String("keep"),
+		},
+	}
+	src := &descriptorpb.FileDescriptorProto{
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("new")},
+	}
+	if err := ApplyFieldMask(dst, src, []string{"options.go_package"}); err != nil {
+		t.Fatalf("ApplyFieldMask: %v", err)
+	}
+	if got := dst.GetOptions().GetGoPackage(); got != "new" {
+		t.Errorf("GoPackage = %q, want %q", got, "new")
+	}
+	if got := dst.GetOptions().GetJavaPackage(); got != "keep" {
+		t.Errorf("JavaPackage = %q, want it unchanged", got)
+	}
+	if got := dst.GetName(); got != "a.proto" {
+		t.Errorf("Name = %q, want it unchanged", got)
+	}
+
+	err := ApplyFieldMask(dst, src, []string{"name.value"})
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("ApplyFieldMask through a scalar: code = %v, want %v", got, codes.InvalidArgument)
+	}
+}
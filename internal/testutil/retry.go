@@ -16,12 +16,22 @@ package testutil
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Retry runs function f for up to maxAttempts times until f returns successfully, and reports whether f was run successfully.
@@ -75,6 +85,187 @@ func RetryWithoutTest(maxAttempts int, sleep time.Duration, f func(r *R)) bool {
 	return false
 }
 
+// RetryOption configures RetryContext and RetryContextWithoutTest.
+type RetryOption func(*retryOptions)
+
+type retryOptions struct {
+	maxAttempts int
+	initial     time.Duration
+	max         time.Duration
+	multiplier  float64
+	jitter      float64
+	retryable   func(error) bool
+}
+
+func defaultRetryOptions() retryOptions {
+	return retryOptions{
+		maxAttempts: 10,
+		initial:     time.Second,
+		max:         30 * time.Second,
+		multiplier:  2,
+		jitter:      0.2,
+		retryable:   func(error) bool { return true },
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts. Zero means attempts
+// continue until the context is done. The default is 10.
+func WithMaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the second attempt, the cap on delays, and
+// the factor each delay grows by. The default is 1s, 30s and 2.
+func WithBackoff(initial, max time.Duration, multiplier float64) RetryOption {
+	return func(o *retryOptions) {
+		o.initial = initial
+		o.max = max
+		o.multiplier = multiplier
+	}
+}
+
+// WithJitter randomizes each delay by up to the given fraction in either
+// direction, so concurrent tests don't retry in lockstep. The default is 0.2.
+func WithJitter(fraction float64) RetryOption {
+	return func(o *retryOptions) {
+		o.jitter = fraction
+	}
+}
+
+// WithRetryableCodes limits retries to attempts whose error, recorded with
+// R.Check, has one of the given gRPC status codes. Any other error stops
+// retrying immediately. Attempts failed with Fail or Errorf are always
+// retried. By default every error is retryable.
+func WithRetryableCodes(c ...codes.Code) RetryOption {
+	return func(o *retryOptions) {
+		o.retryable = func(err error) bool {
+			return slices.Contains(c, status.Code(err))
+		}
+	}
+}
+
+// AttemptLog describes one attempt of RetryContext.
+type AttemptLog struct {
+	Attempt  int
+	Duration time.Duration
+	// Err is the error recorded with R.Check, if any.
+	Err error
+	// Delay is the wait before the next attempt, zero for the last one.
+	Delay time.Duration
+}
+
+func (a AttemptLog) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "attempt=%d duration=%s", a.Attempt, a.Duration.Round(time.Millisecond))
+	if a.Err != nil {
+		fmt.Fprintf(&b, " code=%s err=%q", status.Code(a.Err), a.Err.Error())
+	}
+	if a.Delay > 0 {
+		fmt.Fprintf(&b, " next_delay=%s", a.Delay.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// delay returns the jittered wait after the given attempt.
+func (o retryOptions) delay(attempt int) time.Duration {
+	d := float64(o.initial) * math.Pow(o.multiplier, float64(attempt-1))
+	if max := float64(o.max); o.max > 0 && d > max {
+		d = max
+	}
+	d *= 1 + o.jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// RetryContext runs function f until it succeeds, and reports whether it did.
+// Unlike Retry, it waits with exponential backoff and jitter between attempts,
+// stops when ctx is done, and stops early on errors that are not retryable.
+// Every attempt is summarized in the test log, followed by the log of the
+// final attempt.
+// Use the provided *testutil.R instead of a *testing.T from the function.
+func RetryContext(ctx context.Context, t *testing.T, f func(r *R), opts ...RetryOption) bool {
+	t.Helper()
+	r, history, err := retryContext(ctx, f, opts)
+	summary := summarize(history)
+	if err != nil {
+		t.Logf("FAILED after %d attempts: %v\n%s%s", r.Attempt, err, summary, r.log.String())
+		t.Fail()
+		return false
+	}
+	if r.log.Len() != 0 || r.Attempt > 1 {
+		t.Logf("Success after %d attempts:\n%s%s", r.Attempt, summary, r.log.String())
+	}
+	return true
+}
+
+// RetryContextWithoutTest is a variant of RetryContext that does not use a testing parameter.
+// It returns an error describing every attempt if f never succeeded.
+func RetryContextWithoutTest(ctx context.Context, f func(r *R), opts ...RetryOption) error {
+	r, history, err := retryContext(ctx, f, opts)
+	if err != nil {
+		return fmt.Errorf("failed after %d attempts: %w\n%s%s", r.Attempt, err, summarize(history), r.log.String())
+	}
+	return nil
+}
+
+func summarize(history []AttemptLog) string {
+	var b strings.Builder
+	for _, a := range history {
+		b.WriteString(a.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// errNotRetryable marks an attempt error that WithRetryableCodes rejected.
+var errNotRetryable = errors.New("error is not retryable")
+
+func retryContext(ctx context.Context, f func(r *R), opts []RetryOption) (*R, []AttemptLog, error) {
+	o := defaultRetryOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var history []AttemptLog
+	for attempt := 1; ; attempt++ {
+		r := &R{Attempt: attempt, log: &bytes.Buffer{}, ctx: ctx}
+		start := time.Now()
+		f(r)
+		a := AttemptLog{Attempt: attempt, Duration: time.Since(start), Err: r.err}
+
+		if !r.failed {
+			history = append(history, a)
+			return r, history, nil
+		}
+		if r.err != nil && !o.retryable(r.err) {
+			history = append(history, a)
+			return r, history, fmt.Errorf("%w: %w", errNotRetryable, r.err)
+		}
+		if o.maxAttempts > 0 && attempt >= o.maxAttempts {
+			history = append(history, a)
+			return r, history, lastError(r, "attempts exhausted")
+		}
+
+		a.Delay = o.delay(attempt)
+		history = append(history, a)
+		timer := time.NewTimer(a.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return r, history, fmt.Errorf("%w: %w", ctx.Err(), lastError(r, "last attempt failed"))
+		case <-timer.C:
+		}
+	}
+}
+
+func lastError(r *R, msg string) error {
+	if r.err != nil {
+		return fmt.Errorf("%s: %w", msg, r.err)
+	}
+	return errors.New(msg)
+}
+
 // R is passed to each run of a flaky test run, manages state and accumulates log statements.
 type R struct {
 	// The number of current attempt.
@@ -82,6 +273,46 @@ type R struct {
 
 	failed bool
 	log    *bytes.Buffer
+	ctx    context.Context
+	err    error
+}
+
+// Context returns the context passed to RetryContext, or context.Background
+// for the other Retry functions. Use it for calls made in the attempt so
+// they stop at the retry deadline.
+func (r *R) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// Check records a non-nil err as the attempt's error and marks the run as
+// failed. With RetryContext, the error decides whether the run is retried;
+// see WithRetryableCodes. Check reports whether err was non-nil.
+func (r *R) Check(err error) bool {
+	if err == nil {
+		return false
+	}
+	r.err = err
+	r.logf("%v", err)
+	r.Fail()
+	return true
+}
+
+// Logger returns a structured logger that writes to the attempt's log, with
+// every record tagged with the attempt number.
+func (r *R) Logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(r.log, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Times make the log harder to read and AttemptLog already
+			// records durations.
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})).With("attempt", r.Attempt)
 }
 
 // Fail marks the run as failed, and will retry once the function returns.
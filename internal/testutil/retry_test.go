@@ -15,8 +15,14 @@
 package testutil
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestRetry(t *testing.T) {
@@ -74,3 +80,100 @@ func TestRetryWithoutTestAttempts(t *testing.T) {
 		t.Errorf("attempts=%d; want %d", attempts, 5)
 	}
 }
+
+func TestRetryContext(t *testing.T) {
+	var attempts int
+	ok := RetryContext(context.Background(), t, func(r *R) {
+		attempts = r.Attempt
+		r.Logger().Info("checking", "want", 3)
+		if r.Attempt == 3 {
+			return
+		}
+		r.Check(status.Error(codes.Unavailable, "not yet"))
+	}, WithBackoff(time.Millisecond, 4*time.Millisecond, 2))
+
+	if !ok {
+		t.Errorf("RetryContext() = false, want true")
+	}
+	if attempts != 3 {
+		t.Errorf("attempts=%d; want %d", attempts, 3)
+	}
+}
+
+func TestRetryContextWithoutTestNotRetryable(t *testing.T) {
+	var attempts int
+	err := RetryContextWithoutTest(context.Background(), func(r *R) {
+		attempts = r.Attempt
+		code := codes.Unavailable
+		if r.Attempt == 2 {
+			code = codes.InvalidArgument
+		}
+		r.Check(status.Error(code, "boom"))
+	}, WithBackoff(time.Millisecond, time.Millisecond, 1), WithRetryableCodes(codes.Unavailable))
+
+	if !errors.Is(err, errNotRetryable) {
+		t.Errorf("RetryContextWithoutTest() = %v, want errNotRetryable", err)
+	}
+	if got := status.Code(err); got != codes.InvalidArgument {
+		t.Errorf("status.Code(err) = %v, want %v", got, codes.InvalidArgument)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts=%d; want %d", attempts, 2)
+	}
+}
+
+func TestRetryContextWithoutTestMaxAttempts(t *testing.T) {
+	var attempts int
+	err := RetryContextWithoutTest(context.Background(), func(r *R) {
+		attempts = r.Attempt
+		r.Errorf("attempt %d failed", r.Attempt)
+	}, WithMaxAttempts(4), WithBackoff(time.Millisecond, time.Millisecond, 1), WithJitter(0))
+
+	if err == nil {
+		t.Fatal("RetryContextWithoutTest() = nil, want error")
+	}
+	if attempts != 4 {
+		t.Errorf("attempts=%d; want %d", attempts, 4)
+	}
+	// Every attempt is summarized.
+	if got := strings.Count(err.Error(), "attempt="); got != 4 {
+		t.Errorf("error summarizes %d attempts, want 4:\n%v", got, err)
+	}
+}
+
+func TestRetryContextWithoutTestDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := RetryContextWithoutTest(ctx, func(r *R) {
+		r.Check(status.Error(codes.PermissionDenied, "policy not propagated"))
+	}, WithMaxAttempts(0), WithBackoff(10*time.Millisecond, 10*time.Millisecond, 1))
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RetryContextWithoutTest() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	o := retryOptions{initial: time.Second, max: 5 * time.Second, multiplier: 2}
+	for _, test := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+	} {
+		if got := o.delay(test.attempt); got != test.want {
+			t.Errorf("delay(%d) = %v, want %v", test.attempt, got, test.want)
+		}
+	}
+
+	o.jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := o.delay(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("delay(1) with jitter = %v, want within [500ms, 1.5s]", got)
+		}
+	}
+}
@@ -31,6 +31,8 @@ var errNoProjectID = errors.New("GOLANG_SAMPLES_PROJECT_ID not set")
 type Context struct {
 	ProjectID string
 	Dir       string
+	// Backend is the service implementation the test runs against.
+	Backend Backend
 }
 
 func (tc Context) Path(p ...string) string {
@@ -82,7 +84,7 @@ func EndToEndTest(t *testing.T) Context {
 }
 
 func testContext() (Context, error) {
-	tc := Context{}
+	tc := Context{Backend: RealBackend}
 
 	tc.ProjectID = os.Getenv("GOLANG_SAMPLES_PROJECT_ID")
 	if tc.ProjectID == "" {
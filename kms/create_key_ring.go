@@ -22,16 +22,17 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 )
 
 // createKeyRing creates a new ring to store keys on KMS.
-func createKeyRing(w io.Writer, parent, id string) error {
+func createKeyRing(w io.Writer, parent, id string, opts ...option.ClientOption) error {
 	// parent := "projects/PROJECT_ID/locations/global"
 	// id := "my-key-ring"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
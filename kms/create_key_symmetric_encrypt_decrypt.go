@@ -22,17 +22,18 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 )
 
 // createKeySymmetricEncryptDecrypt creates a new symmetric encrypt/decrypt key
 // on Cloud KMS.
-func createKeySymmetricEncryptDecrypt(w io.Writer, parent, id string) error {
+func createKeySymmetricEncryptDecrypt(w io.Writer, parent, id string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project/locations/us-east1/keyRings/my-key-ring"
 	// id := "my-symmetric-encryption-key"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
@@ -22,15 +22,16 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 )
 
 // createKeyVersion creates a new key version for the given key.
-func createKeyVersion(w io.Writer, parent string) error {
+func createKeyVersion(w io.Writer, parent string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project/locations/us-east1/keyRings/my-key-ring/cryptoKeys/my-key"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
@@ -23,17 +23,18 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // decryptSymmetric will decrypt the input ciphertext bytes using the specified symmetric key.
-func decryptSymmetric(w io.Writer, name string, ciphertext []byte) error {
+func decryptSymmetric(w io.Writer, name string, ciphertext []byte, opts ...option.ClientOption) error {
 	// name := "projects/my-project/locations/us-east1/keyRings/my-key-ring/cryptoKeys/my-key"
 	// ciphertext := []byte("...")  // result of a symmetric encryption call
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
@@ -22,16 +22,17 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 )
 
 // destroyKeyVersion marks a specified key version for deletion. The key can be
 // restored if requested within 24 hours.
-func destroyKeyVersion(w io.Writer, name string) error {
+func destroyKeyVersion(w io.Writer, name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/locations/us-east1/keyRings/my-key-ring/cryptoKeys/my-key/cryptoKeyVersions/123"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
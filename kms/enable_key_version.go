@@ -22,16 +22,17 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 	fieldmask "google.golang.org/genproto/protobuf/field_mask"
 )
 
 // enableKeyVersion disables the specified key version on Cloud KMS.
-func enableKeyVersion(w io.Writer, name string) error {
+func enableKeyVersion(w io.Writer, name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/locations/us-east1/keyRings/my-key-ring/cryptoKeys/my-key/cryptoKeyVersions/123"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
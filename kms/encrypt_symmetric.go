@@ -23,18 +23,19 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // encryptSymmetric encrypts the input plaintext with the specified symmetric
 // Cloud KMS key.
-func encryptSymmetric(w io.Writer, name string, message string) error {
+func encryptSymmetric(w io.Writer, name string, message string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/locations/us-east1/keyRings/my-key-ring/cryptoKeys/my-key"
 	// message := "Sample message"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
@@ -23,17 +23,18 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	"google.golang.org/api/option"
 )
 
 // generateRandomBytes generates random bytes with entropy sourced from the
 // given location.
-func generateRandomBytes(w io.Writer, location string, numBytes int32) error {
+func generateRandomBytes(w io.Writer, location string, numBytes int32, opts ...option.ClientOption) error {
 	// name := "projects/my-project/locations/us-east1"
 	// numBytes := 256
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := kms.NewKeyManagementClient(ctx)
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create kms client: %w", err)
 	}
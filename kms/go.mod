@@ -5,6 +5,7 @@ go 1.23.0
 require (
 	cloud.google.com/go/kms v1.20.5
 	github.com/GoogleCloudPlatform/golang-samples v0.0.0-20240724083556-7f760db013b7
+	github.com/GoogleCloudPlatform/golang-samples/internal/kms v0.0.0-00010101000000-000000000000
 	github.com/gofrs/uuid v4.4.0+incompatible
 	github.com/google/tink/go v1.7.0
 	google.golang.org/api v0.217.0
@@ -71,3 +72,7 @@ require (
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 )
+
+replace github.com/GoogleCloudPlatform/golang-samples/internal/kms => ../internal/kms/
+
+replace github.com/GoogleCloudPlatform/golang-samples => ../
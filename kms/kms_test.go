@@ -33,7 +33,9 @@ import (
 
 	kms "cloud.google.com/go/kms/apiv1"
 	"cloud.google.com/go/kms/apiv1/kmspb"
+	_ "github.com/GoogleCloudPlatform/golang-samples/internal/kms/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"github.com/gofrs/uuid"
 )
 
 var fixture *kmsFixture
@@ -41,8 +43,10 @@ var fixture *kmsFixture
 func TestMain(m *testing.M) {
 	tc, ok := testutil.ContextMain(m)
 	if !ok {
-		log.Print("skipping - unset GOLANG_SAMPLES_PROJECT_ID?")
-		return
+		// Without a project only the tests that can use the fake backend run;
+		// the fixture tests skip themselves.
+		log.Print("unset GOLANG_SAMPLES_PROJECT_ID, skipping fixture tests")
+		os.Exit(m.Run())
 	}
 
 	var err error
@@ -771,3 +775,138 @@ func TestVerifyMac(t *testing.T) {
 		t.Errorf("verifyAsymmetricRSA: expected %q to contain %q", got, want)
 	}
 }
+
+func TestSymmetricKeyLifecycle(t *testing.T) {
+	tc := testutil.BackendTest(t, "kms")
+	opts := tc.ClientOptions(t, "kms")
+
+	ctx := context.Background()
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	u, err := uuid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	location := fmt.Sprintf("projects/%s/locations/us-east1", tc.ProjectID)
+	keyRingName := fmt.Sprintf("%s/keyRings/%s", location, u)
+	keyName := fmt.Sprintf("%s/cryptoKeys/symmetric", keyRingName)
+	version1 := fmt.Sprintf("%s/cryptoKeyVersions/1", keyName)
+	version2 := fmt.Sprintf("%s/cryptoKeyVersions/2", keyName)
+
+	// Key rings cannot be deleted, so destroy the key material instead.
+	t.Cleanup(func() {
+		for _, name := range []string{version1, version2} {
+			client.DestroyCryptoKeyVersion(ctx, &kmspb.DestroyCryptoKeyVersionRequest{Name: name})
+		}
+	})
+
+	wantState := func(t *testing.T, name string, want kmspb.CryptoKeyVersion_CryptoKeyVersionState) {
+		t.Helper()
+		v, err := client.GetCryptoKeyVersion(ctx, &kmspb.GetCryptoKeyVersionRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.State; got != want {
+			t.Errorf("%s: got state %v, want %v", name, got, want)
+		}
+	}
+
+	t.Run("createKeyRing", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := createKeyRing(&b, location, u.String(), opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Created key ring: "+keyRingName; !strings.Contains(got, want) {
+			t.Errorf("createKeyRing: expected %q to contain %q", got, want)
+		}
+		if err := createKeyRing(&b, location, u.String(), opts...); err == nil {
+			t.Errorf("createKeyRing: expected an error creating %s twice", keyRingName)
+		}
+	})
+
+	t.Run("createKeySymmetricEncryptDecrypt", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := createKeySymmetricEncryptDecrypt(&b, keyRingName, "symmetric", opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Created key: "+keyName; !strings.Contains(got, want) {
+			t.Errorf("createKeySymmetricEncryptDecrypt: expected %q to contain %q", got, want)
+		}
+		wantState(t, version1, kmspb.CryptoKeyVersion_ENABLED)
+	})
+
+	t.Run("encryptDecryptSymmetric", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := encryptSymmetric(&b, keyName, "fruitloops", opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Encrypted ciphertext:"; !strings.Contains(got, want) {
+			t.Errorf("encryptSymmetric: expected %q to contain %q", got, want)
+		}
+
+		result, err := client.Encrypt(ctx, &kmspb.EncryptRequest{
+			Name:      keyName,
+			Plaintext: []byte("fruitloops"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Reset()
+		if err := decryptSymmetric(&b, keyName, result.Ciphertext, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Decrypted plaintext: fruitloops"; got != want {
+			t.Errorf("decryptSymmetric: got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("createKeyVersion", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := createKeyVersion(&b, keyName, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Created key version: "+version2; !strings.Contains(got, want) {
+			t.Errorf("createKeyVersion: expected %q to contain %q", got, want)
+		}
+	})
+
+	t.Run("disableEnableKeyVersion", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := disableKeyVersion(&b, version1, opts...); err != nil {
+			t.Fatal(err)
+		}
+		wantState(t, version1, kmspb.CryptoKeyVersion_DISABLED)
+
+		if err := enableKeyVersion(&b, version1, opts...); err != nil {
+			t.Fatal(err)
+		}
+		wantState(t, version1, kmspb.CryptoKeyVersion_ENABLED)
+	})
+
+	t.Run("destroyRestoreKeyVersion", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := destroyKeyVersion(&b, version2, opts...); err != nil {
+			t.Fatal(err)
+		}
+		wantState(t, version2, kmspb.CryptoKeyVersion_DESTROY_SCHEDULED)
+
+		if err := restoreKeyVersion(&b, version2, opts...); err != nil {
+			t.Fatal(err)
+		}
+		wantState(t, version2, kmspb.CryptoKeyVersion_DISABLED)
+	})
+
+	t.Run("generateRandomBytes", func(t *testing.T) {
+		var b bytes.Buffer
+		if err := generateRandomBytes(&b, location, 256, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := b.String(), "Random bytes:"; !strings.Contains(got, want) {
+			t.Errorf("generateRandomBytes: expected %q to contain %q", got, want)
+		}
+	})
+}
@@ -23,6 +23,8 @@ import (
 
 	"github.com/GoogleCloudPlatform/golang-samples/internal/managedkafka/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -48,6 +50,13 @@ func TestClusters(t *testing.T) {
 			t.Fatalf("createCluster() mismatch got: %s\nwant: %s", got, want)
 		}
 	})
+	t.Run("CreateClusterAlreadyExists", func(t *testing.T) {
+		subnet := fmt.Sprintf("projects/%s/regions/%s/subnetworks/default", tc.ProjectID, region)
+		err := createCluster(buf, tc.ProjectID, region, clusterID, subnet, 3, 3221225472, options...)
+		if got := status.Code(err); got != codes.AlreadyExists {
+			t.Fatalf("createCluster() of an existing cluster got code %v, want %v (err: %v)", got, codes.AlreadyExists, err)
+		}
+	})
 	t.Run("GetCluster", func(t *testing.T) {
 		if err := getCluster(buf, tc.ProjectID, region, clusterID, options...); err != nil {
 			t.Fatalf("failed to get cluster: %v", err)
@@ -89,4 +98,10 @@ func TestClusters(t *testing.T) {
 			t.Fatalf("deleteCluster() mismatch got: %s\nwant: %s", got, want)
 		}
 	})
+	t.Run("GetClusterAfterDelete", func(t *testing.T) {
+		err := getCluster(buf, tc.ProjectID, region, clusterID, options...)
+		if got := status.Code(err); got != codes.NotFound {
+			t.Fatalf("getCluster() of a deleted cluster got code %v, want %v (err: %v)", got, codes.NotFound, err)
+		}
+	})
 }
@@ -21,8 +21,11 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/managedkafka/apiv1/managedkafkapb"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/managedkafka/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -35,7 +38,13 @@ func TestConsumerGroups(t *testing.T) {
 	tc := testutil.SystemTest(t)
 	buf := new(bytes.Buffer)
 	consumerGroupID := fmt.Sprintf("%s-%d", consumerGroupPrefix, time.Now().UnixNano())
-	options := fake.Options(t)
+	clusterPath := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", tc.ProjectID, region, parentClusterID)
+	// Consumer groups are created by Kafka clients, not the API, so the
+	// fake starts with one.
+	options := fake.Options(t,
+		&managedkafkapb.Cluster{Name: clusterPath},
+		&managedkafkapb.ConsumerGroup{Name: fmt.Sprintf("%s/consumerGroups/%s", clusterPath, consumerGroupID)},
+	)
 	t.Run("GetConsumerGroup", func(t *testing.T) {
 		if err := getConsumerGroup(buf, tc.ProjectID, region, parentClusterID, consumerGroupID, options...); err != nil {
 			t.Fatalf("failed to get consumer group: %v", err)
@@ -80,4 +89,10 @@ func TestConsumerGroups(t *testing.T) {
 			t.Fatalf("deleteConsumerGroup() mismatch got: %s\nwant: %s", got, want)
 		}
 	})
+	t.Run("GetConsumerGroupAfterDelete", func(t *testing.T) {
+		err := getConsumerGroup(buf, tc.ProjectID, region, parentClusterID, consumerGroupID, options...)
+		if got := status.Code(err); got != codes.NotFound {
+			t.Fatalf("getConsumerGroup() of a deleted consumer group got code %v, want %v (err: %v)", got, codes.NotFound, err)
+		}
+	})
 }
@@ -21,8 +21,11 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/managedkafka/apiv1/managedkafkapb"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/managedkafka/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -35,7 +38,9 @@ func TestTopics(t *testing.T) {
 	tc := testutil.SystemTest(t)
 	buf := new(bytes.Buffer)
 	topicID := fmt.Sprintf("%s-%d", topicPrefix, time.Now().UnixNano())
-	options := fake.Options(t)
+	options := fake.Options(t, &managedkafkapb.Cluster{
+		Name: fmt.Sprintf("projects/%s/locations/%s/clusters/%s", tc.ProjectID, region, parentClusterID),
+	})
 	t.Run("CreateTopic", func(t *testing.T) {
 		partitionCount := 10
 		replicationFactor := 3
@@ -51,6 +56,18 @@ func TestTopics(t *testing.T) {
 			t.Fatalf("createTopic() mismatch got: %s\nwant: %s", got, want)
 		}
 	})
+	t.Run("CreateTopicAlreadyExists", func(t *testing.T) {
+		err := createTopic(buf, tc.ProjectID, region, parentClusterID, topicID, 10, 3, nil, options...)
+		if got := status.Code(err); got != codes.AlreadyExists {
+			t.Fatalf("createTopic() of an existing topic got code %v, want %v (err: %v)", got, codes.AlreadyExists, err)
+		}
+	})
+	t.Run("CreateTopicMissingCluster", func(t *testing.T) {
+		err := createTopic(buf, tc.ProjectID, region, "missing-cluster", topicID, 10, 3, nil, options...)
+		if got := status.Code(err); got != codes.NotFound {
+			t.Fatalf("createTopic() in a missing cluster got code %v, want %v (err: %v)", got, codes.NotFound, err)
+		}
+	})
 	t.Run("GetTopic", func(t *testing.T) {
 		if err := getTopic(buf, tc.ProjectID, region, parentClusterID, topicID, options...); err != nil {
 			t.Fatalf("failed to get topic: %v", err)
@@ -76,11 +93,12 @@ func TestTopics(t *testing.T) {
 		}
 	})
 	t.Run("ListTopics", func(t *testing.T) {
+		buf.Reset()
 		if err := listTopics(buf, tc.ProjectID, region, parentClusterID, options...); err != nil {
 			t.Fatalf("failed to list topics: %v", err)
 		}
 		got := buf.String()
-		want := "Got topic"
+		want := topicID
 		if !strings.Contains(got, want) {
 			t.Fatalf("listTopics() mismatch got: %s\nwant: %s", got, want)
 		}
@@ -95,4 +113,16 @@ func TestTopics(t *testing.T) {
 			t.Fatalf("deleteTopic() mismatch got: %s\nwant: %s", got, want)
 		}
 	})
+	t.Run("GetTopicAfterDelete", func(t *testing.T) {
+		err := getTopic(buf, tc.ProjectID, region, parentClusterID, topicID, options...)
+		if got := status.Code(err); got != codes.NotFound {
+			t.Fatalf("getTopic() of a deleted topic got code %v, want %v (err: %v)", got, codes.NotFound, err)
+		}
+	})
+	t.Run("DeleteTopicAfterDelete", func(t *testing.T) {
+		err := deleteTopic(buf, tc.ProjectID, region, parentClusterID, topicID, options...)
+		if got := status.Code(err); got != codes.NotFound {
+			t.Fatalf("deleteTopic() of a deleted topic got code %v, want %v (err: %v)", got, codes.NotFound, err)
+		}
+	})
 }
@@ -9,6 +9,7 @@ require (
 	cloud.google.com/go/storage v1.51.0
 	cloud.google.com/go/trace v1.11.5
 	github.com/GoogleCloudPlatform/golang-samples v0.0.0-20240820230436-761d0ae7aeff
+	github.com/GoogleCloudPlatform/golang-samples/internal/pubsub v0.0.0-00010101000000-000000000000
 	github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace v1.24.1
 	github.com/google/go-cmp v0.7.0
 	github.com/google/uuid v1.6.0
@@ -75,3 +76,7 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20250414145226-207652e42e2e // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250414145226-207652e42e2e // indirect
 )
+
+replace github.com/GoogleCloudPlatform/golang-samples/internal/pubsub => ../internal/pubsub/
+
+replace github.com/GoogleCloudPlatform/golang-samples => ../
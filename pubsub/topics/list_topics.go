@@ -21,12 +21,13 @@ import (
 
 	"cloud.google.com/go/pubsub"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
-func list(projectID string) ([]*pubsub.Topic, error) {
+func list(projectID string, opts ...option.ClientOption) ([]*pubsub.Topic, error) {
 	// projectID := "my-project-id"
 	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, projectID)
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("pubsub.NewClient: %w", err)
 	}
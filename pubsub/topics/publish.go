@@ -21,14 +21,15 @@ import (
 	"io"
 
 	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/option"
 )
 
-func publish(w io.Writer, projectID, topicID, msg string) error {
+func publish(w io.Writer, projectID, topicID, msg string, opts ...option.ClientOption) error {
 	// projectID := "my-project-id"
 	// topicID := "my-topic"
 	// msg := "Hello World"
 	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, projectID)
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return fmt.Errorf("pubsub: NewClient: %w", err)
 	}
@@ -32,6 +32,7 @@ import (
 	"cloud.google.com/go/pubsub/pstest"
 	trace "cloud.google.com/go/trace/apiv1"
 	"cloud.google.com/go/trace/apiv1/tracepb"
+	_ "github.com/GoogleCloudPlatform/golang-samples/internal/pubsub/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 	"google.golang.org/api/iterator"
 )
@@ -145,6 +146,111 @@ func TestPublish(t *testing.T) {
 	}
 }
 
+// TestTopicLifecycleFake runs the samples against the Pub/Sub fake. It only
+// runs with GOLANG_SAMPLES_BACKEND=fake; the tests above cover the real
+// service.
+func TestTopicLifecycleFake(t *testing.T) {
+	if b, err := testutil.CurrentBackend(); err != nil || b != testutil.FakeBackend {
+		t.Skip("GOLANG_SAMPLES_BACKEND=fake not set")
+	}
+	ctx := context.Background()
+	tc := testutil.BackendTest(t, "pubsub")
+	opts := tc.ClientOptions(t, "pubsub")
+
+	client, err := pubsub.NewClient(ctx, tc.ProjectID, opts...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	id := fmt.Sprintf("%s-%d", topicPrefix, time.Now().UnixNano())
+
+	t.Run("create", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := create(buf, tc.ProjectID, id, opts...); err != nil {
+			t.Fatalf("failed to create a topic: %v", err)
+		}
+		ok, err := client.Topic(id).Exists(ctx)
+		if err != nil {
+			t.Fatalf("failed to check if topic exists: %v", err)
+		}
+		if !ok {
+			t.Fatalf("got none; want topic = %q", id)
+		}
+		if err := create(buf, tc.ProjectID, id, opts...); err == nil {
+			t.Errorf("create(%q) twice: got nil error, want AlreadyExists", id)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		testutil.Retry(t, 10, time.Second, func(r *testutil.R) {
+			topics, err := list(tc.ProjectID, opts...)
+			if err != nil {
+				r.Errorf("failed to list topics: %v", err)
+			}
+
+			for _, t := range topics {
+				if t.ID() == id {
+					return // PASS
+				}
+			}
+
+			topicIDs := make([]string, len(topics))
+			for i, t := range topics {
+				topicIDs[i] = t.ID()
+			}
+			r.Errorf("got %+v; want a list with topic = %q", topicIDs, id)
+		})
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		sub, err := client.CreateSubscription(ctx, id, pubsub.SubscriptionConfig{
+			Topic: client.Topic(id),
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+		defer sub.Delete(ctx)
+
+		buf := new(bytes.Buffer)
+		if err := publish(buf, tc.ProjectID, id, "hello world", opts...); err != nil {
+			t.Fatalf("failed to publish message: %v", err)
+		}
+		if got, want := buf.String(), "Published a message; msg ID: "; !strings.Contains(got, want) {
+			t.Errorf("publish: got %q, want it to contain %q", got, want)
+		}
+
+		rctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		var got string
+		err = sub.Receive(rctx, func(_ context.Context, m *pubsub.Message) {
+			m.Ack()
+			got = string(m.Data)
+			cancel()
+		})
+		if err != nil {
+			t.Fatalf("Receive: %v", err)
+		}
+		if want := "hello world"; got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := delete(buf, tc.ProjectID, id, opts...); err != nil {
+			t.Fatalf("failed to delete topic (%q): %v", id, err)
+		}
+		ok, err := client.Topic(id).Exists(ctx)
+		if err != nil {
+			t.Fatalf("failed to check if topic exists: %v", err)
+		}
+		if ok {
+			t.Fatalf("got topic = %q; want none", id)
+		}
+	})
+}
+
 func TestPublishThatScales(t *testing.T) {
 	ctx := context.Background()
 	tc := testutil.SystemTest(t)
@@ -19,11 +19,11 @@ import (
 	"context"
 	"crypto/tls"
 	"flag"
+	"io"
 	"log"
 	"os"
 	"time"
 
-	ptypes "github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 
@@ -38,6 +38,8 @@ var (
 	skipVerify   = flag.Bool("skip-verify", false, "Skip server hostname verification in SSL validation [false]")
 	message      = flag.String("message", "Hi there", "The body of the content sent to server")
 	sendUpstream = flag.Bool("relay", false, "Direct ping to relay the request to a ping-upstream service [false]")
+	relays       = flag.Int("relays", 1, "Number of times to relay the request along the chain of upstream services, with -relay or -stream")
+	streamCount  = flag.Int("stream", 0, "Number of pings to send over a bidirectional stream, instead of a single request")
 )
 
 func main() {
@@ -62,6 +64,10 @@ func main() {
 	}
 	defer conn.Close()
 	client := pb.NewPingServiceClient(conn)
+	if *streamCount > 0 {
+		stream(client)
+		return
+	}
 	send(client)
 }
 
@@ -74,6 +80,7 @@ func send(client pb.PingServiceClient) {
 	if *sendUpstream {
 		resp, err = client.SendUpstream(ctx, &pb.Request{
 			Message: *message,
+			Relays:  int32(*relays),
 		})
 	} else {
 		resp, err = client.Send(ctx, &pb.Request{
@@ -85,9 +92,55 @@ func send(client pb.PingServiceClient) {
 		logger.Fatalf("Error while executing Send: %v", err)
 	}
 
-	respMessage := resp.Pong.GetMessage()
-	timestamp := ptypes.TimestampString(resp.Pong.GetReceivedOn())
 	logger.Println("Unary Request/Unary Response")
 	logger.Printf("  Sent Ping: %s", *message)
-	logger.Printf("  Received:\n    Pong: %s\n    Server Time: %s", respMessage, timestamp)
+	logPong(resp.GetPong())
+}
+
+// stream sends pings over a bidirectional stream, relayed if -relay is set.
+func stream(client pb.PingServiceClient) {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	stream, err := client.PingStream(ctx)
+	if err != nil {
+		logger.Fatalf("Error while executing PingStream: %v", err)
+	}
+
+	var r int32
+	if *sendUpstream {
+		r = int32(*relays)
+	}
+	go func() {
+		for i := 1; i <= *streamCount; i++ {
+			if err := stream.Send(&pb.Request{Message: *message, Relays: r, Sequence: int64(i)}); err != nil {
+				// The error is returned by Recv.
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	logger.Println("Bidirectional Stream")
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			logger.Fatalf("Error while receiving from PingStream: %v", err)
+		}
+		logger.Printf("  Sent Ping #%d: %s", resp.GetPong().GetSequence(), *message)
+		logPong(resp.GetPong())
+	}
+}
+
+// logPong logs the pong and its hops.
+func logPong(pong *pb.Pong) {
+	timestamp := pong.GetReceivedOn().AsTime().Format(time.RFC3339Nano)
+	logger.Printf("  Received:\n    Pong: %s\n    Server Time: %s\n    Answered By: service #%d", pong.GetMessage(), timestamp, pong.GetIndex())
+	for i := len(pong.GetHops()) - 1; i >= 0; i-- {
+		hop := pong.GetHops()[i]
+		logger.Printf("    Hop: %s (%v)", hop.GetService(), hop.GetLatency().AsDuration())
+	}
 }
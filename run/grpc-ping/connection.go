@@ -19,15 +19,36 @@ package main
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
+// keepaliveTime is how often connections ping the server when they are idle,
+// to detect broken connections.
+const keepaliveTime = 30 * time.Second
+
+// retryPolicy retries the ping service's requests when the server is
+// unavailable, such as while an instance starts or stops.
+const retryPolicy = `{
+	"methodConfig": [{
+		"name": [{"service": "ping.PingService"}],
+		"retryPolicy": {
+			"maxAttempts": 4,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "1s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
 // NewConn creates a new gRPC connection.
 // host should be of the form domain:port, e.g., example.com:443
-func NewConn(host string, insecure bool) (*grpc.ClientConn, error) {
-	var opts []grpc.DialOption
+// Requests are retried when the server is unavailable.
+func NewConn(host string, insecure bool, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
 	if host != "" {
 		opts = append(opts, grpc.WithAuthority(host))
 	}
@@ -47,6 +68,15 @@ func NewConn(host string, insecure bool) (*grpc.ClientConn, error) {
 		opts = append(opts, grpc.WithTransportCredentials(cred))
 	}
 
+	opts = append(opts,
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                keepaliveTime,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultServiceConfig(retryPolicy),
+	)
+
 	return grpc.Dial(host, opts...)
 }
 
@@ -16,11 +16,18 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 
 	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-ping/pkg/api/v1"
 )
@@ -40,8 +47,11 @@ func main() {
 		log.Fatalf("net.Listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
-	pb.RegisterPingServiceServer(grpcServer, &pingService{})
+	s, err := newPingServiceFromEnv()
+	if err != nil {
+		log.Fatalf("newPingServiceFromEnv: %v", err)
+	}
+	grpcServer := newServer(s)
 	if err = grpcServer.Serve(listener); err != nil {
 		log.Fatal(err)
 	}
@@ -49,17 +59,68 @@ func main() {
 
 // [END cloudrun_grpc_server]
 
-// conn holds an open connection to the ping service.
-var conn *grpc.ClientConn
+// newServer returns a gRPC server for s, with the standard health service
+// and server reflection, so tools such as grpcurl can list its methods.
+func newServer(s *pingService) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		// Allow the keepalive pings of clients created with NewConn.
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveTime / 2,
+			PermitWithoutStream: true,
+		}),
+	)
+	pb.RegisterPingServiceServer(grpcServer, s)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(pb.PingService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	reflection.Register(grpcServer)
+	return grpcServer
+}
 
-func init() {
-	if os.Getenv("GRPC_PING_HOST") != "" {
-		var err error
-		conn, err = NewConn(os.Getenv("GRPC_PING_HOST"), os.Getenv("GRPC_PING_INSECURE") != "")
-		if err != nil {
-			log.Fatal(err)
+// newPingServiceFromEnv returns a pingService configured by the environment.
+// Without GRPC_PING_HOST, it doesn't relay requests.
+func newPingServiceFromEnv() (*pingService, error) {
+	s := &pingService{
+		name:      serviceName(),
+		maxRelays: defaultMaxRelays,
+	}
+	if v := os.Getenv("GRPC_PING_MAX_RELAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("GRPC_PING_MAX_RELAYS: invalid number %q", v)
 		}
-	} else {
+		s.maxRelays = int32(n)
+	}
+
+	host := os.Getenv("GRPC_PING_HOST")
+	if host == "" {
 		log.Println("Starting without support for SendUpstream: configure with 'GRPC_PING_HOST' environment variable. E.g., example.com:443")
+		return s, nil
+	}
+	conn, err := NewConn(host, os.Getenv("GRPC_PING_INSECURE") != "")
+	if err != nil {
+		return nil, fmt.Errorf("NewConn: %w", err)
+	}
+	s.upstream = conn
+	if os.Getenv("GRPC_PING_UNAUTHENTICATED") == "" {
+		hostWithoutPort := strings.Split(host, ":")[0]
+		s.audience = "https://" + hostWithoutPort
+	}
+	return s, nil
+}
+
+// serviceName returns the name of this service in the hops of pongs: the
+// Cloud Run service, or the host name.
+func serviceName() string {
+	if name := os.Getenv("K_SERVICE"); name != "" {
+		return name
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return "grpc-ping"
 	}
+	return name
 }
@@ -16,43 +16,91 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io"
 	"log"
-	"os"
-	"strings"
+	"sync"
+	"time"
 
 	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-ping/pkg/api/v1"
-	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// defaultMaxRelays is the default maximum number of relays of a request.
+const defaultMaxRelays = 10
+
 type pingService struct {
 	pb.UnimplementedPingServiceServer
+
+	// name identifies the service in the hops of pongs.
+	name string
+	// upstream is the connection to the next service of the relay chain, or
+	// nil if the service doesn't relay requests.
+	upstream *grpc.ClientConn
+	// audience is the audience of the identity tokens of requests to
+	// upstream, or "" for unauthenticated requests.
+	audience string
+	// maxRelays is the maximum number of relays of a request.
+	maxRelays int32
 }
 
 func (s *pingService) Send(ctx context.Context, req *pb.Request) (*pb.Response, error) {
 	log.Print("sending ping response")
-	return &pb.Response{
-		Pong: &pb.Pong{
-			Index:      1,
-			Message:    req.GetMessage(),
-			ReceivedOn: ptypes.TimestampNow(),
-		},
-	}, nil
+	return &pb.Response{Pong: s.pong(req, time.Now())}, nil
 }
 
-func (s *pingService) SendUpstream(ctx context.Context, req *pb.Request) (*pb.Response, error) {
-	if conn == nil {
-		return nil, fmt.Errorf("no upstream connection configured")
+// pong returns the pong answering req, received at start.
+func (s *pingService) pong(req *pb.Request, start time.Time) *pb.Pong {
+	pong := &pb.Pong{
+		Index:      req.GetHop() + 1,
+		Message:    req.GetMessage(),
+		ReceivedOn: timestamppb.New(start),
+		Sequence:   req.GetSequence(),
 	}
+	s.addHop(pong, start)
+	return pong
+}
 
-	p := &pb.Request{
-		Message: req.GetMessage() + " (relayed)",
+// addHop adds this service to the hops of pong, with the latency since start.
+func (s *pingService) addHop(pong *pb.Pong, start time.Time) {
+	pong.Hops = append(pong.Hops, &pb.Hop{
+		Service: s.name,
+		Latency: durationpb.New(time.Since(start)),
+	})
+}
+
+// relayed returns the request to relay upstream for req.
+func (s *pingService) relayed(req *pb.Request) (*pb.Request, error) {
+	if s.upstream == nil {
+		return nil, status.Error(codes.FailedPrecondition, "no upstream connection configured")
+	}
+	relays := req.GetRelays()
+	if relays < 1 {
+		relays = 1
 	}
+	if relays > s.maxRelays {
+		return nil, status.Errorf(codes.InvalidArgument, "%d relays requested, want at most %d", relays, s.maxRelays)
+	}
+	return &pb.Request{
+		Message:  req.GetMessage() + " (relayed)",
+		Relays:   relays - 1,
+		Hop:      req.GetHop() + 1,
+		Sequence: req.GetSequence(),
+	}, nil
+}
 
-	hostWithoutPort := strings.Split(os.Getenv("GRPC_PING_HOST"), ":")[0]
-	tokenAudience := "https://" + hostWithoutPort
-	resp, err := PingRequest(conn, p, tokenAudience, os.Getenv("GRPC_PING_UNAUTHENTICATED") == "")
+func (s *pingService) SendUpstream(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	start := time.Now()
+	p, err := s.relayed(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := PingRequest(ctx, s.upstream, p, s.audience, s.audience != "")
 	if err != nil {
 		log.Printf("PingRequest: %q", err)
 		c := status.Code(err)
@@ -60,7 +108,147 @@ func (s *pingService) SendUpstream(ctx context.Context, req *pb.Request) (*pb.Re
 	}
 
 	log.Print("received upstream pong")
+	s.addHop(resp.GetPong(), start)
 	return &pb.Response{
 		Pong: resp.Pong,
 	}, nil
 }
+
+// PingStream answers the requests with relays of 0, and relays the others
+// upstream over a single stream, opened with the first of them.
+func (s *pingService) PingStream(stream pb.PingService_PingStreamServer) error {
+	// Responses are sent by this goroutine and the one receiving the
+	// upstream responses.
+	var mu sync.Mutex
+	send := func(resp *pb.Response) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.Send(resp)
+	}
+
+	var up *relayStream
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+
+		if req.GetRelays() == 0 {
+			if err := send(&pb.Response{Pong: s.pong(req, start)}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		p, err := s.relayed(req)
+		if err != nil {
+			return err
+		}
+		if up == nil {
+			if up, err = s.openRelay(stream.Context(), send); err != nil {
+				return err
+			}
+		}
+		if err := up.send(p, start); err != nil {
+			return err
+		}
+	}
+
+	if up == nil {
+		return nil
+	}
+	// Wait for the responses to the requests relayed upstream.
+	return up.close()
+}
+
+// relayStream is a stream relaying requests upstream, and their responses
+// downstream.
+type relayStream struct {
+	s      *pingService
+	stream pb.PingService_PingStreamClient
+
+	mu sync.Mutex
+	// started holds when the requests in flight were received, by sequence.
+	started map[int64][]time.Time
+	// done receives the result of receiving the upstream responses.
+	done chan error
+}
+
+// openRelay opens a relayStream, sending the upstream responses with send.
+func (s *pingService) openRelay(ctx context.Context, send func(*pb.Response) error) (*relayStream, error) {
+	stream, err := pingStream(ctx, s.upstream, s.audience, s.audience != "")
+	if err != nil {
+		log.Printf("pingStream: %q", err)
+		return nil, status.Errorf(status.Code(err), "Could not reach ping service: %s", status.Convert(err).Message())
+	}
+	r := &relayStream{
+		s:       s,
+		stream:  stream,
+		started: map[int64][]time.Time{},
+		done:    make(chan error, 1),
+	}
+	go func() {
+		r.done <- r.receive(send)
+	}()
+	return r, nil
+}
+
+// send relays p upstream. The request was received at start.
+func (r *relayStream) send(p *pb.Request, start time.Time) error {
+	r.mu.Lock()
+	r.started[p.GetSequence()] = append(r.started[p.GetSequence()], start)
+	r.mu.Unlock()
+
+	if err := r.stream.Send(p); err != nil {
+		if errors.Is(err, io.EOF) {
+			// The stream failed: the reason is returned by Recv.
+			return <-r.done
+		}
+		return err
+	}
+	return nil
+}
+
+// receive sends the upstream responses with send, until the upstream stream
+// ends.
+func (r *relayStream) receive(send func(*pb.Response) error) error {
+	for {
+		resp, err := r.stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(status.Code(err), "Could not reach ping service: %s", status.Convert(err).Message())
+		}
+
+		pong := resp.GetPong()
+		start := time.Now()
+		r.mu.Lock()
+		if started := r.started[pong.GetSequence()]; len(started) > 0 {
+			start = started[0]
+			if len(started) == 1 {
+				delete(r.started, pong.GetSequence())
+			} else {
+				r.started[pong.GetSequence()] = started[1:]
+			}
+		}
+		r.mu.Unlock()
+
+		r.s.addHop(pong, start)
+		if err := send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// close ends the upstream stream, and waits for its responses to be sent.
+func (r *relayStream) close() error {
+	if err := r.stream.CloseSend(); err != nil {
+		return err
+	}
+	return <-r.done
+}
@@ -0,0 +1,289 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-ping/pkg/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve serves s in memory, and returns a connection to it.
+func serve(t *testing.T, s *pingService) *grpc.ClientConn {
+	t.Helper()
+	return serveServer(t, newServer(s))
+}
+
+// serveServer serves grpcServer in memory, and returns a connection to it
+// created with NewConn.
+func serveServer(t *testing.T, grpcServer *grpc.Server) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := NewConn("bufnet", true, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	if err != nil {
+		t.Fatalf("NewConn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// chain starts a relay chain of n services, named ping-1 to ping-n, and
+// returns a connection to ping-1.
+func chain(t *testing.T, n int) *grpc.ClientConn {
+	t.Helper()
+	var conn *grpc.ClientConn
+	for i := n; i >= 1; i-- {
+		conn = serve(t, &pingService{
+			name:      fmt.Sprintf("ping-%d", i),
+			upstream:  conn,
+			maxRelays: defaultMaxRelays,
+		})
+	}
+	return conn
+}
+
+// hops returns the services of the hops of pong, and checks their latencies.
+func hops(t *testing.T, pong *pb.Pong) []string {
+	t.Helper()
+	var services []string
+	for i, hop := range pong.GetHops() {
+		services = append(services, hop.GetService())
+		if i > 0 && hop.GetLatency().AsDuration() < pong.GetHops()[i-1].GetLatency().AsDuration() {
+			t.Errorf("hop %s latency %v is less than the latency of the hop it relayed to", hop.GetService(), hop.GetLatency().AsDuration())
+		}
+	}
+	return services
+}
+
+func TestSend(t *testing.T) {
+	client := pb.NewPingServiceClient(chain(t, 1))
+	resp, err := client.Send(context.Background(), &pb.Request{Message: "hello"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	pong := resp.GetPong()
+	if pong.GetIndex() != 1 || pong.GetMessage() != "hello" || pong.GetReceivedOn() == nil {
+		t.Errorf("Send got %v, want index 1, message hello and a receive time", pong)
+	}
+	if got, want := hops(t, pong), []string{"ping-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Send hops = %q, want %q", got, want)
+	}
+}
+
+func TestSendUpstream(t *testing.T) {
+	client := pb.NewPingServiceClient(chain(t, 3))
+
+	tests := []struct {
+		relays      int32
+		wantIndex   int32
+		wantMessage string
+		wantHops    []string
+	}{
+		{
+			relays:      0,
+			wantIndex:   2,
+			wantMessage: "hello (relayed)",
+			wantHops:    []string{"ping-2", "ping-1"},
+		},
+		{
+			relays:      2,
+			wantIndex:   3,
+			wantMessage: "hello (relayed) (relayed)",
+			wantHops:    []string{"ping-3", "ping-2", "ping-1"},
+		},
+	}
+	for _, test := range tests {
+		resp, err := client.SendUpstream(context.Background(), &pb.Request{Message: "hello", Relays: test.relays})
+		if err != nil {
+			t.Fatalf("SendUpstream(relays %d): %v", test.relays, err)
+		}
+		pong := resp.GetPong()
+		if pong.GetIndex() != test.wantIndex || pong.GetMessage() != test.wantMessage {
+			t.Errorf("SendUpstream(relays %d) got index %d, message %q, want %d, %q", test.relays, pong.GetIndex(), pong.GetMessage(), test.wantIndex, test.wantMessage)
+		}
+		if got := hops(t, pong); !reflect.DeepEqual(got, test.wantHops) {
+			t.Errorf("SendUpstream(relays %d) hops = %q, want %q", test.relays, got, test.wantHops)
+		}
+	}
+}
+
+func TestSendUpstreamErrors(t *testing.T) {
+	client := pb.NewPingServiceClient(chain(t, 2))
+
+	tests := []struct {
+		relays int32
+		want   codes.Code
+	}{
+		// ping-2 has no upstream.
+		{relays: 2, want: codes.FailedPrecondition},
+		{relays: defaultMaxRelays + 1, want: codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		_, err := client.SendUpstream(context.Background(), &pb.Request{Message: "hello", Relays: test.relays})
+		if got := status.Code(err); got != test.want {
+			t.Errorf("SendUpstream(relays %d) = %v, want code %v", test.relays, err, test.want)
+		}
+	}
+}
+
+func TestPingStream(t *testing.T) {
+	client := pb.NewPingServiceClient(chain(t, 3))
+	stream, err := client.PingStream(context.Background())
+	if err != nil {
+		t.Fatalf("PingStream: %v", err)
+	}
+
+	// Relay each request one more time than the previous one.
+	const n = 3
+	for i := 0; i < n; i++ {
+		if err := stream.Send(&pb.Request{Message: "hello", Relays: int32(i), Sequence: int64(i)}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+
+	var pongs []*pb.Pong
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		pongs = append(pongs, resp.GetPong())
+	}
+	if len(pongs) != n {
+		t.Fatalf("got %d pongs, want %d", len(pongs), n)
+	}
+	sort.Slice(pongs, func(i, j int) bool { return pongs[i].GetSequence() < pongs[j].GetSequence() })
+
+	wantHops := [][]string{
+		{"ping-1"},
+		{"ping-2", "ping-1"},
+		{"ping-3", "ping-2", "ping-1"},
+	}
+	for i, pong := range pongs {
+		if pong.GetIndex() != int32(i+1) {
+			t.Errorf("pong %d: index = %d, want %d", i, pong.GetIndex(), i+1)
+		}
+		if got := hops(t, pong); !reflect.DeepEqual(got, wantHops[i]) {
+			t.Errorf("pong %d: hops = %q, want %q", i, got, wantHops[i])
+		}
+	}
+}
+
+func TestPingStreamWithoutUpstream(t *testing.T) {
+	client := pb.NewPingServiceClient(chain(t, 1))
+	stream, err := client.PingStream(context.Background())
+	if err != nil {
+		t.Fatalf("PingStream: %v", err)
+	}
+	if err := stream.Send(&pb.Request{Message: "hello", Relays: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Recv = %v, want code %v", err, codes.FailedPrecondition)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := healthpb.NewHealthClient(chain(t, 1))
+	for _, service := range []string{"", "ping.PingService"} {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", service, err)
+		}
+		if got := resp.GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, want %v", service, got, healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+}
+
+func TestReflection(t *testing.T) {
+	client := reflectionpb.NewServerReflectionClient(chain(t, 1))
+	stream, err := client.ServerReflectionInfo(context.Background())
+	if err != nil {
+		t.Fatalf("ServerReflectionInfo: %v", err)
+	}
+	req := &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}
+	if err := stream.Send(req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+
+	services := map[string]bool{}
+	for _, s := range resp.GetListServicesResponse().GetService() {
+		services[s.GetName()] = true
+	}
+	for _, want := range []string{"ping.PingService", "grpc.health.v1.Health"} {
+		if !services[want] {
+			t.Errorf("services = %v, want %s", services, want)
+		}
+	}
+}
+
+// flakyService fails its first Send as unavailable.
+type flakyService struct {
+	*pingService
+	calls int
+}
+
+func (s *flakyService) Send(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, status.Error(codes.Unavailable, "starting")
+	}
+	return s.pingService.Send(ctx, req)
+}
+
+func TestNewConnRetry(t *testing.T) {
+	s := &flakyService{pingService: &pingService{name: "flaky"}}
+	grpcServer := grpc.NewServer()
+	pb.RegisterPingServiceServer(grpcServer, s)
+	client := pb.NewPingServiceClient(serveServer(t, grpcServer))
+
+	if _, err := client.Send(context.Background(), &pb.Request{Message: "hello"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if s.calls != 2 {
+		t.Errorf("got %d calls, want 2", s.calls)
+	}
+}
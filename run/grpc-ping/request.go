@@ -25,20 +25,41 @@ import (
 )
 
 // pingRequest sends a new gRPC ping request to the server configured in the connection.
-func pingRequest(conn *grpc.ClientConn, p *pb.Request) (*pb.Response, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func pingRequest(ctx context.Context, conn *grpc.ClientConn, p *pb.Request) (*pb.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	client := pb.NewPingServiceClient(conn)
-	return client.Send(ctx, p)
+	return send(ctx, client, p)
 }
 
 // [END cloudrun_grpc_request]
 
+// send sends p with Send, or with SendUpstream if it's to be relayed
+// further.
+func send(ctx context.Context, client pb.PingServiceClient, p *pb.Request) (*pb.Response, error) {
+	if p.GetRelays() > 0 {
+		return client.SendUpstream(ctx, p)
+	}
+	return client.Send(ctx, p)
+}
+
 // PingRequest creates a new gRPC request to the upstream ping gRPC service.
-func PingRequest(conn *grpc.ClientConn, p *pb.Request, url string, authenticated bool) (*pb.Response, error) {
+func PingRequest(ctx context.Context, conn *grpc.ClientConn, p *pb.Request, url string, authenticated bool) (*pb.Response, error) {
 	if authenticated {
-		return pingRequestWithAuth(conn, p, url)
+		return pingRequestWithAuth(ctx, conn, p, url)
 	}
-	return pingRequest(conn, p)
+	return pingRequest(ctx, conn, p)
+}
+
+// pingStream opens a new PingStream to the upstream ping gRPC service.
+func pingStream(ctx context.Context, conn *grpc.ClientConn, url string, authenticated bool) (pb.PingService_PingStreamClient, error) {
+	if authenticated {
+		var err error
+		if ctx, err = withIDToken(ctx, url); err != nil {
+			return nil, err
+		}
+	}
+	client := pb.NewPingServiceClient(conn)
+	return client.PingStream(ctx)
 }
@@ -31,8 +31,8 @@ import (
 // pingRequestWithAuth mints a new Identity Token for each request.
 // This token has a 1 hour expiry and should be reused.
 // audience must be the auto-assigned URL of a Cloud Run service or HTTP Cloud Function without port number.
-func pingRequestWithAuth(conn *grpc.ClientConn, p *pb.Request, audience string) (*pb.Response, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func pingRequestWithAuth(ctx context.Context, conn *grpc.ClientConn, p *pb.Request, audience string) (*pb.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	// Create an identity token.
@@ -52,7 +52,21 @@ func pingRequestWithAuth(conn *grpc.ClientConn, p *pb.Request, audience string)
 
 	// Send the request.
 	client := pb.NewPingServiceClient(conn)
-	return client.Send(ctx, p)
+	return send(ctx, client, p)
 }
 
 // [END cloudrun_grpc_request_auth]
+
+// withIDToken returns ctx with a new Identity Token for audience, for the
+// requests of a stream.
+func withIDToken(ctx context.Context, audience string) (context.Context, error) {
+	tokenSource, err := idtoken.NewTokenSource(ctx, audience)
+	if err != nil {
+		return nil, fmt.Errorf("idtoken.NewTokenSource: %w", err)
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("TokenSource.Token: %w", err)
+	}
+	return grpcMetadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token.AccessToken), nil
+}
@@ -16,6 +16,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
 	"flag"
@@ -23,11 +24,14 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-server-streaming/pkg/api/v1"
 )
@@ -39,6 +43,9 @@ var (
 	insecure   = flag.Bool("insecure", false, "Skip SSL validation? [false]")
 	skipVerify = flag.Bool("skip-verify", false, "Skip server hostname verification in SSL validation [false]")
 	duration   = flag.Uint("duration", 10, "duration (in seconds) to stream the time from the server for")
+	subscribe  = flag.Bool("subscribe", false, "Use the bidirectional Subscribe rpc, and change the interval by typing a new one, such as 500ms [false]")
+	interval   = flag.Duration("interval", time.Second, "interval between messages, with -subscribe")
+	retries    = flag.Int("retries", 5, "number of times to resume an interrupted stream without receiving a message")
 )
 
 func init() {
@@ -70,23 +77,124 @@ func main() {
 	defer conn.Close()
 	client := pb.NewTimeServiceClient(conn)
 
-	if err := streamTime(client, *duration); err != nil {
+	if *subscribe {
+		err = subscribeTime(client, *duration, *interval)
+	} else {
+		err = streamTime(client, *duration)
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// resume calls stream until the stream ends, resuming it from the resume
+// token of the last message received when it fails with codes.Unavailable,
+// such as when the Cloud Run instance serving it shuts down.
+func resume(stream func(token *string) error) error {
+	var token string
+	failures := 0
+	for {
+		last := token
+		err := stream(&token)
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+		if token != last {
+			failures = 0
+		}
+		if failures >= *retries {
+			return err
+		}
+		backoff := 100 * time.Millisecond << failures
+		failures++
+		log.Printf("stream interrupted, resuming in %v: %v", backoff, err)
+		time.Sleep(backoff)
+	}
+}
+
 func streamTime(client pb.TimeServiceClient, duration uint) error {
 	ctx := context.Background()
 
-	resp, err := client.StreamTime(ctx, &pb.Request{
-		DurationSecs: uint32(duration)})
-	if err != nil {
-		return fmt.Errorf("StreamTime rpc failed: %w", err)
+	return resume(func(token *string) error {
+		resp, err := client.StreamTime(ctx, &pb.Request{
+			DurationSecs: uint32(duration),
+			ResumeToken:  *token,
+		})
+		if err != nil {
+			return fmt.Errorf("StreamTime rpc failed: %w", err)
+		}
+		log.Print("rpc established to timeserver, starting to stream")
+		return receive(resp.Recv, token)
+	})
+}
+
+// subscribeTime streams the time with the Subscribe rpc, sending the
+// intervals typed on the standard input to the server.
+func subscribeTime(client pb.TimeServiceClient, duration uint, interval time.Duration) error {
+	// The intervals are read for the whole session, across resumed streams.
+	intervals := make(chan time.Duration)
+	go readIntervals(os.Stdin, intervals)
+
+	return resume(func(token *string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.Subscribe(ctx)
+		if err != nil {
+			return fmt.Errorf("Subscribe rpc failed: %w", err)
+		}
+		req := &pb.SubscribeRequest{
+			DurationSecs: uint32(duration),
+			ResumeToken:  *token,
+		}
+		// A resumed stream keeps its interval.
+		if *token == "" {
+			req.Interval = durationpb.New(interval)
+		}
+		if err := stream.Send(req); err != nil {
+			return fmt.Errorf("error sending request: %w", err)
+		}
+		log.Print("rpc established to timeserver, starting to stream")
+
+		go func() {
+			for {
+				select {
+				case d := <-intervals:
+					if err := stream.Send(&pb.SubscribeRequest{Interval: durationpb.New(d)}); err != nil {
+						return
+					}
+					log.Printf("changing interval to %v", d)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return receive(stream.Recv, token)
+	})
+}
+
+// readIntervals sends the intervals read from r, one per line, to intervals.
+func readIntervals(r io.Reader, intervals chan<- time.Duration) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		d, err := time.ParseDuration(line)
+		if err != nil {
+			log.Printf("invalid interval %q: %v", line, err)
+			continue
+		}
+		intervals <- d
 	}
-	log.Print("rpc established to timeserver, starting to stream")
+}
 
+// receive prints the messages received with recv until the end of the
+// stream, keeping the resume token of the last one in token.
+func receive(recv func() (*pb.TimeResponse, error), token *string) error {
 	for {
-		msg, err := resp.Recv()
+		msg, err := recv()
 		if err == io.EOF {
 			log.Printf("end of stream")
 			return nil
@@ -94,10 +202,11 @@ func streamTime(client pb.TimeServiceClient, duration uint) error {
 			return fmt.Errorf("error receiving message: %w", err)
 		}
 
-		ts, err := ptypes.Timestamp(msg.GetCurrentTime())
-		if err != nil {
+		*token = msg.GetResumeToken()
+		if err := msg.GetCurrentTime().CheckValid(); err != nil {
 			return fmt.Errorf("failed to parse timestamp %v: %w", msg.GetCurrentTime(), err)
 		}
-		log.Printf("received message: current_timestamp: %v", ts.Format(time.RFC3339))
+		ts := msg.GetCurrentTime().AsTime()
+		log.Printf("received message %d: current_timestamp: %v", msg.GetSequence(), ts.Format(time.RFC3339))
 	}
 }
@@ -3,8 +3,8 @@ module github.com/GoogleCloudPlatform/golang-samples/run/grpc-server-streaming
 go 1.23.0
 
 require (
-	github.com/golang/protobuf v1.5.4
 	google.golang.org/grpc v1.69.4
+	google.golang.org/protobuf v1.36.3
 )
 
 require (
@@ -14,5 +14,4 @@ require (
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/protobuf v1.36.3 // indirect
 )
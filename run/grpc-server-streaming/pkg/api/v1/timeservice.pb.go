@@ -1,236 +1,331 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
 // Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.3
+// 	protoc        (unknown)
 // source: timeservice.proto
 
 package timeservice
 
 import (
-	context "context"
-	fmt "fmt"
-	math "math"
-
-	proto "github.com/golang/protobuf/proto"
-	timestamp "github.com/golang/protobuf/ptypes/timestamp"
-	grpc "google.golang.org/grpc"
-	codes "google.golang.org/grpc/codes"
-	status "google.golang.org/grpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
 )
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ = proto.Marshal
-var _ = fmt.Errorf
-var _ = math.Inf
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the proto package it is being compiled against.
-// A compilation error at this line likely means your copy of the
-// proto package needs to be updated.
-const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
 
 type Request struct {
-	DurationSecs         uint32   `protobuf:"varint,2,opt,name=duration_secs,json=durationSecs,proto3" json:"duration_secs,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	DurationSecs uint32                 `protobuf:"varint,2,opt,name=duration_secs,json=durationSecs,proto3" json:"duration_secs,omitempty"`
+	// Resumes a stream after the response with this token.
+	ResumeToken   string `protobuf:"bytes,3,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-func (m *Request) Reset()         { *m = Request{} }
-func (m *Request) String() string { return proto.CompactTextString(m) }
-func (*Request) ProtoMessage()    {}
-func (*Request) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c24d50486e4ed4c3, []int{0}
+func (x *Request) Reset() {
+	*x = Request{}
+	mi := &file_timeservice_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-func (m *Request) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_Request.Unmarshal(m, b)
-}
-func (m *Request) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_Request.Marshal(b, m, deterministic)
-}
-func (m *Request) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Request.Merge(m, src)
-}
-func (m *Request) XXX_Size() int {
-	return xxx_messageInfo_Request.Size(m)
-}
-func (m *Request) XXX_DiscardUnknown() {
-	xxx_messageInfo_Request.DiscardUnknown(m)
+func (x *Request) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var xxx_messageInfo_Request proto.InternalMessageInfo
+func (*Request) ProtoMessage() {}
 
-func (m *Request) GetDurationSecs() uint32 {
-	if m != nil {
-		return m.DurationSecs
+func (x *Request) ProtoReflect() protoreflect.Message {
+	mi := &file_timeservice_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return 0
-}
-
-type TimeResponse struct {
-	CurrentTime          *timestamp.Timestamp `protobuf:"bytes,1,opt,name=current_time,json=currentTime,proto3" json:"current_time,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
-	XXX_unrecognized     []byte               `json:"-"`
-	XXX_sizecache        int32                `json:"-"`
+	return mi.MessageOf(x)
 }
 
-func (m *TimeResponse) Reset()         { *m = TimeResponse{} }
-func (m *TimeResponse) String() string { return proto.CompactTextString(m) }
-func (*TimeResponse) ProtoMessage()    {}
-func (*TimeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_c24d50486e4ed4c3, []int{1}
-}
-
-func (m *TimeResponse) XXX_Unmarshal(b []byte) error {
-	return xxx_messageInfo_TimeResponse.Unmarshal(m, b)
-}
-func (m *TimeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	return xxx_messageInfo_TimeResponse.Marshal(b, m, deterministic)
-}
-func (m *TimeResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TimeResponse.Merge(m, src)
-}
-func (m *TimeResponse) XXX_Size() int {
-	return xxx_messageInfo_TimeResponse.Size(m)
-}
-func (m *TimeResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_TimeResponse.DiscardUnknown(m)
+// Deprecated: Use Request.ProtoReflect.Descriptor instead.
+func (*Request) Descriptor() ([]byte, []int) {
+	return file_timeservice_proto_rawDescGZIP(), []int{0}
 }
 
-var xxx_messageInfo_TimeResponse proto.InternalMessageInfo
-
-func (m *TimeResponse) GetCurrentTime() *timestamp.Timestamp {
-	if m != nil {
-		return m.CurrentTime
+func (x *Request) GetDurationSecs() uint32 {
+	if x != nil {
+		return x.DurationSecs
 	}
-	return nil
+	return 0
 }
 
-func init() {
-	proto.RegisterType((*Request)(nil), "timeservice.Request")
-	proto.RegisterType((*TimeResponse)(nil), "timeservice.TimeResponse")
+func (x *Request) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
 }
 
-func init() { proto.RegisterFile("timeservice.proto", fileDescriptor_c24d50486e4ed4c3) }
-
-var fileDescriptor_c24d50486e4ed4c3 = []byte{
-	// 205 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x8f, 0x3d, 0x4f, 0x86, 0x30,
-	0x14, 0x85, 0xad, 0x83, 0x26, 0xb7, 0x30, 0xd8, 0x38, 0x20, 0x8b, 0x04, 0x17, 0xa6, 0x62, 0x70,
-	0x76, 0xf0, 0x07, 0x98, 0x98, 0xc2, 0x4e, 0xa0, 0x5e, 0x49, 0x13, 0x4b, 0xb1, 0x1f, 0xfe, 0xfe,
-	0x37, 0xe5, 0x23, 0xe1, 0x9d, 0x9a, 0x3c, 0xe7, 0xa4, 0xcf, 0xb9, 0xf0, 0xe0, 0x95, 0x46, 0x87,
-	0xf6, 0x5f, 0x49, 0xe4, 0x8b, 0x35, 0xde, 0x30, 0x7a, 0x42, 0xf9, 0xf3, 0x64, 0xcc, 0xf4, 0x8b,
-	0xf5, 0x1a, 0x8d, 0xe1, 0xa7, 0x5e, 0x43, 0x3f, 0xe8, 0x65, 0x6b, 0x97, 0x1c, 0xee, 0x05, 0xfe,
-	0x05, 0x74, 0x9e, 0xbd, 0x40, 0xfa, 0x1d, 0xec, 0xe0, 0x95, 0x99, 0x7b, 0x87, 0xd2, 0x65, 0xb7,
-	0x05, 0xa9, 0x52, 0x91, 0x1c, 0xb0, 0x45, 0xe9, 0xca, 0x4f, 0x48, 0x3a, 0xa5, 0x51, 0xa0, 0x5b,
-	0xcc, 0xec, 0x90, 0xbd, 0x43, 0x22, 0x83, 0xb5, 0x38, 0xfb, 0x3e, 0x7e, 0x9d, 0x91, 0x82, 0x54,
-	0xb4, 0xc9, 0xf9, 0xe6, 0xe5, 0x87, 0x97, 0x77, 0x87, 0x57, 0xd0, 0xbd, 0x1f, 0x49, 0xf3, 0x05,
-	0x34, 0xbe, 0xed, 0x36, 0x97, 0x7d, 0x00, 0xb4, 0xde, 0xe2, 0xa0, 0x23, 0x64, 0x8f, 0xfc, 0x7c,
-	0xdd, 0x3e, 0x33, 0x7f, 0xba, 0xa2, 0xe7, 0x31, 0xe5, 0xcd, 0x2b, 0x19, 0xef, 0x56, 0xe5, 0xdb,
-	0x25, 0x00, 0x00, 0xff, 0xff, 0x54, 0x1e, 0x75, 0xcb, 0x1a, 0x01, 0x00, 0x00,
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Interval between responses. Defaults to 1 second, or the interval of the
+	// resumed subscription.
+	Interval *durationpb.Duration `protobuf:"bytes,1,opt,name=interval,proto3" json:"interval,omitempty"`
+	// Time to stream for, or 0 to stream until the client cancels. Only read
+	// from the first request.
+	DurationSecs uint32 `protobuf:"varint,2,opt,name=duration_secs,json=durationSecs,proto3" json:"duration_secs,omitempty"`
+	// Resumes a subscription after the response with this token. Only read
+	// from the first request.
+	ResumeToken   string `protobuf:"bytes,3,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// TimeServiceClient is the client API for TimeService service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type TimeServiceClient interface {
-	StreamTime(ctx context.Context, in *Request, opts ...grpc.CallOption) (TimeService_StreamTimeClient, error)
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_timeservice_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
 }
 
-type timeServiceClient struct {
-	cc *grpc.ClientConn
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-func NewTimeServiceClient(cc *grpc.ClientConn) TimeServiceClient {
-	return &timeServiceClient{cc}
-}
+func (*SubscribeRequest) ProtoMessage() {}
 
-func (c *timeServiceClient) StreamTime(ctx context.Context, in *Request, opts ...grpc.CallOption) (TimeService_StreamTimeClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_TimeService_serviceDesc.Streams[0], "/timeservice.TimeService/StreamTime", opts...)
-	if err != nil {
-		return nil, err
-	}
-	x := &timeServiceStreamTimeClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_timeservice_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	return x, nil
-}
-
-type TimeService_StreamTimeClient interface {
-	Recv() (*TimeResponse, error)
-	grpc.ClientStream
+	return mi.MessageOf(x)
 }
 
-type timeServiceStreamTimeClient struct {
-	grpc.ClientStream
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_timeservice_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *timeServiceStreamTimeClient) Recv() (*TimeResponse, error) {
-	m := new(TimeResponse)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
-		return nil, err
+func (x *SubscribeRequest) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
 	}
-	return m, nil
+	return nil
 }
 
-// TimeServiceServer is the server API for TimeService service.
-type TimeServiceServer interface {
-	StreamTime(*Request, TimeService_StreamTimeServer) error
+func (x *SubscribeRequest) GetDurationSecs() uint32 {
+	if x != nil {
+		return x.DurationSecs
+	}
+	return 0
 }
 
-// UnimplementedTimeServiceServer can be embedded to have forward compatible implementations.
-type UnimplementedTimeServiceServer struct {
+func (x *SubscribeRequest) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
 }
 
-func (*UnimplementedTimeServiceServer) StreamTime(req *Request, srv TimeService_StreamTimeServer) error {
-	return status.Errorf(codes.Unimplemented, "method StreamTime not implemented")
+type TimeResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	CurrentTime *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=current_time,json=currentTime,proto3" json:"current_time,omitempty"`
+	// Position of the response in the stream, from 1, including the responses
+	// before the stream was resumed.
+	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Token to resume the stream after this response, such as when the server
+	// shuts down.
+	ResumeToken   string `protobuf:"bytes,3,opt,name=resume_token,json=resumeToken,proto3" json:"resume_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeResponse) Reset() {
+	*x = TimeResponse{}
+	mi := &file_timeservice_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeResponse) ProtoMessage() {}
+
+func (x *TimeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_timeservice_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
 
-func RegisterTimeServiceServer(s *grpc.Server, srv TimeServiceServer) {
-	s.RegisterService(&_TimeService_serviceDesc, srv)
+// Deprecated: Use TimeResponse.ProtoReflect.Descriptor instead.
+func (*TimeResponse) Descriptor() ([]byte, []int) {
+	return file_timeservice_proto_rawDescGZIP(), []int{2}
 }
 
-func _TimeService_StreamTime_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Request)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
+func (x *TimeResponse) GetCurrentTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CurrentTime
 	}
-	return srv.(TimeServiceServer).StreamTime(m, &timeServiceStreamTimeServer{stream})
-}
-
-type TimeService_StreamTimeServer interface {
-	Send(*TimeResponse) error
-	grpc.ServerStream
+	return nil
 }
 
-type timeServiceStreamTimeServer struct {
-	grpc.ServerStream
+func (x *TimeResponse) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
 }
 
-func (x *timeServiceStreamTimeServer) Send(m *TimeResponse) error {
-	return x.ServerStream.SendMsg(m)
-}
+func (x *TimeResponse) GetResumeToken() string {
+	if x != nil {
+		return x.ResumeToken
+	}
+	return ""
+}
+
+var File_timeservice_proto protoreflect.FileDescriptor
+
+var file_timeservice_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65,
+	0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x22, 0x51, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x23, 0x0a, 0x0d,
+	0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x63, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0c, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x63,
+	0x73, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x5f, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x54,
+	0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x91, 0x01, 0x0a, 0x10, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69,
+	0x62, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x35, 0x0a, 0x08, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c,
+	0x12, 0x23, 0x0a, 0x0d, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x65, 0x63,
+	0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x53, 0x65, 0x63, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x5f,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x65, 0x73,
+	0x75, 0x6d, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x8c, 0x01, 0x0a, 0x0c, 0x54, 0x69, 0x6d,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3d, 0x0a, 0x0c, 0x63, 0x75, 0x72,
+	0x72, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x63, 0x75, 0x72,
+	0x72, 0x65, 0x6e, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x73, 0x65, 0x71, 0x75,
+	0x65, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x73, 0x65, 0x71, 0x75,
+	0x65, 0x6e, 0x63, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x73, 0x75, 0x6d, 0x65, 0x5f, 0x74,
+	0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x65, 0x73, 0x75,
+	0x6d, 0x65, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x32, 0x9d, 0x01, 0x0a, 0x0b, 0x54, 0x69, 0x6d, 0x65,
+	0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x41, 0x0a, 0x0a, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x14, 0x2e, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12, 0x4b, 0x0a, 0x09, 0x53, 0x75,
+	0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x12, 0x1d, 0x2e, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x2e, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x60, 0x5a, 0x5e, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x47, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x43, 0x6c, 0x6f, 0x75,
+	0x64, 0x50, 0x6c, 0x61, 0x74, 0x66, 0x6f, 0x72, 0x6d, 0x2f, 0x67, 0x6f, 0x6c, 0x61, 0x6e, 0x67,
+	0x2d, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x2f, 0x72, 0x75, 0x6e, 0x2f, 0x67, 0x72, 0x70,
+	0x63, 0x2d, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2d, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x69,
+	0x6e, 0x67, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x3b, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_timeservice_proto_rawDescOnce sync.Once
+	file_timeservice_proto_rawDescData = file_timeservice_proto_rawDesc
+)
 
-var _TimeService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "timeservice.TimeService",
-	HandlerType: (*TimeServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "StreamTime",
-			Handler:       _TimeService_StreamTime_Handler,
-			ServerStreams: true,
+func file_timeservice_proto_rawDescGZIP() []byte {
+	file_timeservice_proto_rawDescOnce.Do(func() {
+		file_timeservice_proto_rawDescData = protoimpl.X.CompressGZIP(file_timeservice_proto_rawDescData)
+	})
+	return file_timeservice_proto_rawDescData
+}
+
+var file_timeservice_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_timeservice_proto_goTypes = []any{
+	(*Request)(nil),               // 0: timeservice.Request
+	(*SubscribeRequest)(nil),      // 1: timeservice.SubscribeRequest
+	(*TimeResponse)(nil),          // 2: timeservice.TimeResponse
+	(*durationpb.Duration)(nil),   // 3: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_timeservice_proto_depIdxs = []int32{
+	3, // 0: timeservice.SubscribeRequest.interval:type_name -> google.protobuf.Duration
+	4, // 1: timeservice.TimeResponse.current_time:type_name -> google.protobuf.Timestamp
+	0, // 2: timeservice.TimeService.StreamTime:input_type -> timeservice.Request
+	1, // 3: timeservice.TimeService.Subscribe:input_type -> timeservice.SubscribeRequest
+	2, // 4: timeservice.TimeService.StreamTime:output_type -> timeservice.TimeResponse
+	2, // 5: timeservice.TimeService.Subscribe:output_type -> timeservice.TimeResponse
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_timeservice_proto_init() }
+func file_timeservice_proto_init() {
+	if File_timeservice_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_timeservice_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
 		},
-	},
-	Metadata: "timeservice.proto",
+		GoTypes:           file_timeservice_proto_goTypes,
+		DependencyIndexes: file_timeservice_proto_depIdxs,
+		MessageInfos:      file_timeservice_proto_msgTypes,
+	}.Build()
+	File_timeservice_proto = out.File
+	file_timeservice_proto_rawDesc = nil
+	file_timeservice_proto_goTypes = nil
+	file_timeservice_proto_depIdxs = nil
 }
@@ -16,19 +16,21 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
 
 	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-server-streaming/pkg/api/v1"
 )
 
-const responseInterval = time.Second
+// shutdownTimeout is how long to wait for streams to end after SIGTERM,
+// within the 10 seconds Cloud Run gives instances to shut down.
+const shutdownTimeout = 8 * time.Second
 
 func main() {
 	port := os.Getenv("PORT")
@@ -42,33 +44,37 @@ func main() {
 		log.Fatalf("net.Listen: %v", err)
 	}
 
-	svc := new(timeService)
+	svc := newTimeService()
 	server := grpc.NewServer()
 	pb.RegisterTimeServiceServer(server, svc)
-	if err = server.Serve(listener); err != nil {
-		log.Fatal(err)
-	}
-}
 
-type timeService struct{}
+	// SIGINT handles Ctrl+C locally.
+	// SIGTERM handles Cloud Run termination signal.
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
-func (timeService) StreamTime(req *pb.Request, resp pb.TimeService_StreamTimeServer) error {
-	durationSeconds := req.GetDurationSecs()
-	finish := time.Now().Add(time.Second * time.Duration(durationSeconds))
-
-	for time.Now().Before(finish) {
-		if err := resp.Send(&pb.TimeResponse{
-			CurrentTime: ptypes.TimestampNow()}); err != nil {
-			return fmt.Errorf("failed to send message: %w", err)
+	go func() {
+		if err = server.Serve(listener); err != nil {
+			log.Fatal(err)
 		}
+	}()
 
-		select {
-		case <-time.After(responseInterval):
-		case <-resp.Context().Done():
-			log.Printf("response context closed, exiting response")
-			return resp.Context().Err()
+	sig := <-signalChan
+	log.Printf("%s signal caught", sig)
 
-		}
+	// End the streams, so that clients resume them on another instance, and
+	// wait for them to finish.
+	svc.drain()
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		log.Print("streams didn't end in time, stopping")
+		server.Stop()
 	}
-	return nil
+	log.Print("server exited")
 }
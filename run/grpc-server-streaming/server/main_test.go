@@ -0,0 +1,191 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-server-streaming/pkg/api/v1"
+)
+
+// newTestClient serves svc in memory and returns a client of it.
+func newTestClient(t *testing.T, svc *timeService) pb.TimeServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterTimeServiceServer(server, svc)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewTimeServiceClient(conn)
+}
+
+func TestStreamTime(t *testing.T) {
+	client := newTestClient(t, newTimeService())
+	stream, err := client.StreamTime(context.Background(), &pb.Request{DurationSecs: 2})
+	if err != nil {
+		t.Fatalf("StreamTime: %v", err)
+	}
+
+	var sequences []uint64
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if resp.GetResumeToken() == "" {
+			t.Errorf("response %d has no resume token", resp.GetSequence())
+		}
+		sequences = append(sequences, resp.GetSequence())
+	}
+	if len(sequences) != 2 || sequences[0] != 1 || sequences[1] != 2 {
+		t.Errorf("got sequences %v, want [1 2]", sequences)
+	}
+}
+
+func TestResume(t *testing.T) {
+	client := newTestClient(t, newTimeService())
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.StreamTime(ctx, &pb.Request{DurationSecs: 60})
+	if err != nil {
+		t.Fatalf("StreamTime: %v", err)
+	}
+	first, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	cancel()
+
+	// The stream continues from the last response, even with another
+	// duration.
+	stream, err = client.StreamTime(context.Background(), &pb.Request{ResumeToken: first.GetResumeToken()})
+	if err != nil {
+		t.Fatalf("StreamTime: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if resp.GetSequence() != 2 {
+		t.Errorf("resumed stream sent sequence %d, want 2", resp.GetSequence())
+	}
+	if gap := resp.GetCurrentTime().AsTime().Sub(first.GetCurrentTime().AsTime()); gap < responseInterval-100*time.Millisecond {
+		t.Errorf("resumed stream sent a response %v after the last one, want about %v", gap, responseInterval)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	client := newTestClient(t, newTimeService())
+	stream, err := client.Subscribe(context.Background())
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := stream.Send(&pb.SubscribeRequest{
+		Interval:     durationpb.New(minInterval),
+		DurationSecs: 10,
+	}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	for range 2 {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+	}
+
+	// The new interval applies from the next response. Closing the client
+	// side of the stream doesn't end it.
+	if err := stream.Send(&pb.SubscribeRequest{Interval: durationpb.New(5 * minInterval)}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend: %v", err)
+	}
+	// The response following the change may have been scheduled before it.
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	start := time.Now()
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	if got := time.Since(start); got < 4*minInterval {
+		t.Errorf("got a response after %v, want about %v", got, 5*minInterval)
+	}
+}
+
+func TestSubscribeErrors(t *testing.T) {
+	client := newTestClient(t, newTimeService())
+	for _, req := range []*pb.SubscribeRequest{
+		{Interval: durationpb.New(time.Millisecond)},
+		{Interval: durationpb.New(2 * maxInterval)},
+		{ResumeToken: "not a token"},
+		{ResumeToken: (&schedule{Sequence: 1, Last: time.Now().UnixNano()}).token()},
+	} {
+		stream, err := client.Subscribe(context.Background())
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Subscribe(%v) got error %v, want InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	svc := newTimeService()
+	client := newTestClient(t, svc)
+	stream, err := client.Subscribe(context.Background())
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if err := stream.Send(&pb.SubscribeRequest{}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+
+	svc.drain()
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Recv after drain got error %v, want Unavailable", err)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "github.com/GoogleCloudPlatform/golang-samples/run/grpc-server-streaming/pkg/api/v1"
+)
+
+const (
+	// responseInterval is the interval of StreamTime, and the default
+	// interval of Subscribe.
+	responseInterval = time.Second
+	// minInterval and maxInterval bound the intervals of Subscribe.
+	minInterval = 100 * time.Millisecond
+	maxInterval = time.Hour
+)
+
+type timeService struct {
+	pb.UnimplementedTimeServiceServer
+
+	// draining is closed when the server shuts down, to end the streams.
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+func newTimeService() *timeService {
+	return &timeService{draining: make(chan struct{})}
+}
+
+// drain ends the streams with codes.Unavailable, so that clients resume them
+// on another instance.
+func (s *timeService) drain() {
+	s.drainOnce.Do(func() { close(s.draining) })
+}
+
+func (s *timeService) StreamTime(req *pb.Request, resp pb.TimeService_StreamTimeServer) error {
+	sched := &schedule{
+		Interval: int64(responseInterval),
+		End:      time.Now().Add(time.Second * time.Duration(req.GetDurationSecs())).UnixNano(),
+	}
+	if token := req.GetResumeToken(); token != "" {
+		var err error
+		if sched, err = parseResumeToken(token); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return s.stream(resp.Context(), sched, resp.Send, nil)
+}
+
+// Subscribe streams the time at the interval of the first request, changed
+// by the following ones.
+func (s *timeService) Subscribe(stream pb.TimeService_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sched := &schedule{Interval: int64(responseInterval)}
+	if d := req.GetDurationSecs(); d > 0 {
+		sched.End = time.Now().Add(time.Second * time.Duration(d)).UnixNano()
+	}
+	if token := req.GetResumeToken(); token != "" {
+		if sched, err = parseResumeToken(token); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if req.GetInterval() != nil {
+		d, err := interval(req.GetInterval())
+		if err != nil {
+			return err
+		}
+		sched.Interval = int64(d)
+	}
+
+	// Receive the interval changes until the client closes its side of the
+	// stream, which doesn't end the responses.
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	defer cancel(nil)
+	updates := make(chan time.Duration)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				cancel(err)
+				return
+			}
+			if req.GetInterval() == nil {
+				continue
+			}
+			d, err := interval(req.GetInterval())
+			if err != nil {
+				cancel(err)
+				return
+			}
+			select {
+			case updates <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err = s.stream(ctx, sched, stream.Send, updates)
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// interval returns the interval d, or an InvalidArgument error if it's out
+// of bounds.
+func interval(d *durationpb.Duration) (time.Duration, error) {
+	if err := d.CheckValid(); err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid interval: %v", err)
+	}
+	if i := d.AsDuration(); i < minInterval || i > maxInterval {
+		return 0, status.Errorf(codes.InvalidArgument, "interval %v isn't between %v and %v", i, minInterval, maxInterval)
+	}
+	return d.AsDuration(), nil
+}
+
+// stream sends the responses of sched until it ends, ctx is done or the
+// server drains. The intervals received from updates apply from the next
+// response.
+func (s *timeService) stream(ctx context.Context, sched *schedule, send func(*pb.TimeResponse) error, updates <-chan time.Duration) error {
+	// The first response is immediate, or follows the last response of the
+	// resumed stream.
+	next := time.Now()
+	if sched.Sequence > 0 {
+		next = sched.next()
+	}
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		if sched.End != 0 && !next.Before(time.Unix(0, sched.End)) {
+			return nil
+		}
+
+		select {
+		case <-timer.C:
+		case d := <-updates:
+			sched.Interval = int64(d)
+			if sched.Sequence > 0 {
+				next = sched.next()
+			}
+			timer.Reset(time.Until(next))
+			continue
+		case <-s.draining:
+			log.Printf("server draining, ending stream after response %d", sched.Sequence)
+			return status.Error(codes.Unavailable, "server is shutting down: resume the stream with the last resume_token")
+		case <-ctx.Done():
+			log.Printf("response context closed, exiting response")
+			return ctx.Err()
+		}
+
+		sched.Sequence++
+		sched.Last = next.UnixNano()
+		if err := send(&pb.TimeResponse{
+			CurrentTime: timestamppb.Now(),
+			Sequence:    sched.Sequence,
+			ResumeToken: sched.token(),
+		}); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+
+		next = sched.next()
+		timer.Reset(time.Until(next))
+	}
+}
+
+// schedule is the state of a stream, saved in resume tokens so that any
+// instance can resume the stream.
+type schedule struct {
+	// Sequence is the sequence of the last response.
+	Sequence uint64 `json:"s"`
+	// Last is when the last response was scheduled, in Unix nanoseconds.
+	Last int64 `json:"l"`
+	// Interval is the interval between responses.
+	Interval int64 `json:"i"`
+	// End is when the stream ends in Unix nanoseconds, or 0 if it doesn't.
+	End int64 `json:"e,omitempty"`
+}
+
+// next returns when the next response is due. Responses missed while the
+// stream was interrupted aren't sent: the next one is due now.
+func (s *schedule) next() time.Time {
+	next := time.Unix(0, s.Last).Add(time.Duration(s.Interval))
+	if now := time.Now(); next.Before(now) {
+		return now
+	}
+	return next
+}
+
+// token returns the resume token of the schedule.
+func (s *schedule) token() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		// A schedule only has integers.
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseResumeToken returns the schedule of a resume token.
+func parseResumeToken(token string) (*schedule, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid resume token: %w", err)
+	}
+	var s schedule
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("invalid resume token: %w", err)
+	}
+	if s.Sequence == 0 || s.Last <= 0 || s.End < 0 {
+		return nil, errors.New("invalid resume token")
+	}
+	if d := time.Duration(s.Interval); d < minInterval || d > maxInterval {
+		return nil, errors.New("invalid resume token: interval out of bounds")
+	}
+	return &s, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// renderedHTML is the HTML rendered from some markdown, and its ETag.
+type renderedHTML struct {
+	etag string
+	html []byte
+}
+
+// renderCache is a least-recently-used cache of rendered HTML, by the hash of
+// the markdown. A nil *renderCache caches nothing.
+type renderCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	// entries has the most recently used entries first.
+	entries *list.List
+	byKey   map[[32]byte]*list.Element
+}
+
+type renderCacheEntry struct {
+	key      [32]byte
+	rendered renderedHTML
+}
+
+// newRenderCache returns a cache of up to maxEntries rendered HTML.
+func newRenderCache(maxEntries int) *renderCache {
+	return &renderCache{
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		byKey:      map[[32]byte]*list.Element{},
+	}
+}
+
+// get returns the rendered HTML for key, if it's cached.
+func (c *renderCache) get(key [32]byte) (renderedHTML, bool) {
+	if c == nil {
+		return renderedHTML{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byKey[key]
+	if !ok {
+		return renderedHTML{}, false
+	}
+	c.entries.MoveToFront(e)
+	return e.Value.(*renderCacheEntry).rendered, true
+}
+
+// add caches rendered for key, removing the least recently used entry if the
+// cache is full.
+func (c *renderCache) add(key [32]byte, rendered renderedHTML) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.byKey[key]; ok {
+		e.Value.(*renderCacheEntry).rendered = rendered
+		c.entries.MoveToFront(e)
+		return
+	}
+	if c.entries.Len() >= c.maxEntries {
+		e := c.entries.Back()
+		delete(c.byKey, c.entries.Remove(e).(*renderCacheEntry).key)
+	}
+	c.byKey[key] = c.entries.PushFront(&renderCacheEntry{key: key, rendered: rendered})
+}
@@ -15,12 +15,15 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"regexp"
 	"strings"
 	"testing"
+
+	"golang.org/x/oauth2"
 )
 
 func init() {
@@ -95,3 +98,39 @@ func TestRenderHandlerErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderServiceReuse(t *testing.T) {
+	var requests, notModified int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got, want := r.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("Authorization: got %q, want %q", got, want)
+		}
+		w.Header().Set("ETag", `"etag"`)
+		if r.Header.Get("If-None-Match") == `"etag"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("<p>rendered</p>"))
+	}))
+	defer upstream.Close()
+
+	s := &RenderService{
+		URL:         upstream.URL,
+		tokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}),
+		cache:       newRenderCache(10),
+	}
+	for i := 0; i < 2; i++ {
+		got, err := s.Render(context.Background(), []byte("markdown"))
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if want := "<p>rendered</p>"; string(got) != want {
+			t.Errorf("Render #%d: got %q, want %q", i, got, want)
+		}
+	}
+	if requests != 2 || notModified != 1 {
+		t.Errorf("got %d requests, %d not modified, want 2 requests, 1 not modified", requests, notModified)
+	}
+}
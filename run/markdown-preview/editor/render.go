@@ -18,9 +18,11 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -31,8 +33,14 @@ import (
 type RenderService struct {
 	// URL is the render service address.
 	URL string
+
+	mu sync.Mutex
 	// tokenSource provides an identity token for requests to the Render Service.
 	tokenSource oauth2.TokenSource
+
+	// cache holds the rendered HTML of recent requests, to reuse it when the
+	// Render service reports that it hasn't changed.
+	cache *renderCache
 }
 
 // NewRequest creates a new HTTP request to the Render service.
@@ -47,15 +55,19 @@ func (s *RenderService) NewRequest(method string) (*http.Request, error) {
 	defer cancel()
 
 	// Create a TokenSource if none exists.
+	s.mu.Lock()
 	if s.tokenSource == nil {
 		s.tokenSource, err = idtoken.NewTokenSource(ctx, s.URL)
 		if err != nil {
+			s.mu.Unlock()
 			return nil, fmt.Errorf("idtoken.NewTokenSource: %w", err)
 		}
 	}
+	tokenSource := s.tokenSource
+	s.mu.Unlock()
 
 	// Retrieve an identity token. Will reuse tokens until refresh needed.
-	token, err := s.tokenSource.Token()
+	token, err := tokenSource.Token()
 	if err != nil {
 		return nil, fmt.Errorf("TokenSource.Token: %w", err)
 	}
@@ -70,12 +82,22 @@ func (s *RenderService) NewRequest(method string) (*http.Request, error) {
 
 var renderClient = &http.Client{Timeout: 30 * time.Second}
 
-// Render converts the Markdown plaintext to HTML.
-func (s *RenderService) Render(in []byte) ([]byte, error) {
+// Render converts the Markdown plaintext to HTML. Rendered HTML is reused
+// if the Render service replies that it's unchanged, and the request is
+// canceled with ctx, such as when a newer edit supersedes it.
+func (s *RenderService) Render(ctx context.Context, in []byte) ([]byte, error) {
 	req, err := s.NewRequest(http.MethodPost)
 	if err != nil {
 		return nil, fmt.Errorf("RenderService.NewRequest: %w", err)
 	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/markdown; charset=utf-8; variant=GFM")
+
+	key := sha256.Sum256(in)
+	cached, ok := s.cache.get(key)
+	if ok {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
 
 	req.Body = io.NopCloser(bytes.NewReader(in))
 	defer req.Body.Close()
@@ -84,16 +106,24 @@ func (s *RenderService) Render(in []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("http.Client.Do: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if ok && resp.StatusCode == http.StatusNotModified {
+		return cached.html, nil
+	}
 
 	out, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("ioutil.ReadAll: %w", err)
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		return out, fmt.Errorf("http.Client.Do: %s (%d): request not OK", http.StatusText(resp.StatusCode), resp.StatusCode)
 	}
 
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		s.cache.add(key, renderedHTML{etag: etag, html: out})
+	}
 	return out, nil
 }
 
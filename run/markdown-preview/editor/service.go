@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,7 +29,7 @@ import (
 
 // MarkdownRenderer defines an interface for rendering Markdown to HTML.
 type MarkdownRenderer interface {
-	Render([]byte) ([]byte, error)
+	Render(context.Context, []byte) ([]byte, error)
 }
 
 // Service manages centralized resources of the service
@@ -62,7 +63,8 @@ func NewServiceFromEnv() (*Service, error) {
 
 	return &Service{
 		Renderer: &RenderService{
-			URL: url,
+			URL:   url,
+			cache: newRenderCache(100),
 		},
 		parsedTemplate:  parsedTemplate,
 		markdownDefault: markdownDefault,
@@ -116,7 +118,7 @@ func (s *Service) renderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rendered, err := s.Renderer.Render([]byte(d.Data))
+	rendered, err := s.Renderer.Render(r.Context(), []byte(d.Data))
 	if err != nil {
 		log.Printf("MarkdownRenderer.Render: %v", err)
 		msg := http.StatusText(http.StatusInternalServerError)
@@ -0,0 +1,79 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cache is a least-recently-used cache of rendered HTML, by content hash.
+type cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	// entries has the most recently used entries first.
+	entries *list.List
+	byKey   map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key  string
+	html []byte
+}
+
+// newCache returns a cache holding up to maxBytes of HTML.
+func newCache(maxBytes int64) *cache {
+	return &cache{
+		maxBytes: maxBytes,
+		entries:  list.New(),
+		byKey:    map[string]*list.Element{},
+	}
+}
+
+// get returns the HTML for key, if it's cached.
+func (c *cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(e)
+	return e.Value.(*cacheEntry).html, true
+}
+
+// add caches html for key, removing the least recently used entries to make
+// room for it.
+func (c *cache) add(key string, html []byte) {
+	size := int64(len(html))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.byKey[key]; ok {
+		return
+	}
+	for c.bytes+size > c.maxBytes {
+		e := c.entries.Back()
+		entry := c.entries.Remove(e).(*cacheEntry)
+		delete(c.byKey, entry.key)
+		c.bytes -= int64(len(entry.html))
+	}
+	c.byKey[key] = c.entries.PushFront(&cacheEntry{key: key, html: html})
+	c.bytes += size
+}
@@ -12,21 +12,23 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Sample editor provides a frontend to a markdown rendering microservice.
+// Sample renderer is a markdown rendering microservice.
 package main
 
 import (
-	"io/ioutil"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-
-	"github.com/microcosm-cc/bluemonday"
-	"github.com/russross/blackfriday/v2"
+	"strconv"
 )
 
 func main() {
-	http.HandleFunc("/", markdownHandler)
+	rd, err := newRendererFromEnv()
+	if err != nil {
+		log.Fatalf("newRendererFromEnv: %v", err)
+	}
+	http.Handle("/", rd)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -35,22 +37,43 @@ func main() {
 	}
 
 	log.Printf("Listening on port %s", port)
-	err := http.ListenAndServe(":"+port, nil)
+	err = http.ListenAndServe(":"+port, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func markdownHandler(w http.ResponseWriter, r *http.Request) {
-	out, err := ioutil.ReadAll(r.Body)
+// newRendererFromEnv returns a renderer configured by the environment:
+// RENDERER_POLICY is the default sanitization policy, "ugc" or "strict",
+// RENDERER_MAX_BYTES the maximum size of the markdown, and
+// RENDERER_CACHE_BYTES the size of the cache of rendered HTML.
+func newRendererFromEnv() (*renderer, error) {
+	policy := os.Getenv("RENDERER_POLICY")
+	if policy == "" {
+		// This is a very basic content policy and tighter standards are recommended.
+		policy = "ugc"
+	}
+	maxBytes, err := bytesFromEnv("RENDERER_MAX_BYTES", 1<<20)
 	if err != nil {
-		log.Printf("ioutil.ReadAll: %v", err)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
+		return nil, err
 	}
+	cacheBytes, err := bytesFromEnv("RENDERER_CACHE_BYTES", 32<<20)
+	if err != nil {
+		return nil, err
+	}
+	return newRenderer(policy, maxBytes, cacheBytes)
+}
 
-	unsafe := blackfriday.Run(out)
-	// This is a very basic content policy and tighter standards are recommended.
-	output := bluemonday.UGCPolicy().SanitizeBytes(unsafe)
-	w.Write(output)
+// bytesFromEnv returns the size in the environment variable name, or def if
+// it isn't set.
+func bytesFromEnv(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s: invalid size %q", name, v)
+	}
+	return n, nil
 }
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -38,14 +39,170 @@ var tests = []struct {
 }
 
 func TestMarkdownHandler(t *testing.T) {
+	rd, err := newRenderer("ugc", 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for _, test := range tests {
 		req := httptest.NewRequest("POST", "/", strings.NewReader(test.input))
 
 		rr := httptest.NewRecorder()
-		markdownHandler(rr, req)
+		rd.ServeHTTP(rr, req)
 
 		if got := rr.Body.String(); got != test.want {
 			t.Errorf("%s: got %q, want %q", test.label, got, test.want)
 		}
 	}
 }
+
+func TestMarkdownHandlerFlavors(t *testing.T) {
+	rd, err := newRenderer("ugc", 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := "| a |\n|:--|\n| 1 |\n\n- [x] done\n\n~~gone~~\n\n```go\nfunc main() {}\n```\n"
+
+	tests := []struct {
+		contentType string
+		want        []string
+		wantNot     []string
+	}{
+		{
+			contentType: "",
+			want: []string{
+				`<td style="text-align: left">1</td>`,
+				`<input checked="" disabled="" type="checkbox"> done`,
+				`<del>gone</del>`,
+				`<span style="color: #000; font-weight: bold">func</span>`,
+			},
+		},
+		{
+			contentType: "text/markdown; variant=GFM",
+			want:        []string{"<table>"},
+		},
+		{
+			contentType: "text/markdown; variant=CommonMark",
+			want:        []string{"[x] done", "~~gone~~"},
+			wantNot:     []string{"<table>", "<input", "<del>", "<span"},
+		},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(in))
+		req.Header.Set("Content-Type", test.contentType)
+		rr := httptest.NewRecorder()
+		rd.ServeHTTP(rr, req)
+
+		got := rr.Body.String()
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("Content-Type %q: got %q, want it to contain %q", test.contentType, got, want)
+			}
+		}
+		for _, want := range test.wantNot {
+			if strings.Contains(got, want) {
+				t.Errorf("Content-Type %q: got %q, want it not to contain %q", test.contentType, got, want)
+			}
+		}
+	}
+}
+
+func TestMarkdownHandlerErrors(t *testing.T) {
+	rd, err := newRenderer("ugc", 10, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		label       string
+		method      string
+		target      string
+		contentType string
+		input       string
+		wantStatus  int
+	}{
+		{label: "method", method: "GET", target: "/", wantStatus: http.StatusMethodNotAllowed},
+		{label: "variant", method: "POST", target: "/", contentType: "text/markdown; variant=Pandoc", wantStatus: http.StatusUnsupportedMediaType},
+		{label: "policy", method: "POST", target: "/?policy=none", wantStatus: http.StatusBadRequest},
+		{label: "size", method: "POST", target: "/", input: "more than ten bytes", wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.input))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		rr := httptest.NewRecorder()
+		rd.ServeHTTP(rr, req)
+
+		if rr.Code != test.wantStatus {
+			t.Errorf("%s: status = %d, want %d", test.label, rr.Code, test.wantStatus)
+		}
+	}
+}
+
+func TestMarkdownHandlerPolicy(t *testing.T) {
+	rd, err := newRenderer("ugc", 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/?policy=strict", strings.NewReader("**strong** <b>bold</b>"))
+	rr := httptest.NewRecorder()
+	rd.ServeHTTP(rr, req)
+
+	if got, want := rr.Body.String(), "strong bold\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMarkdownHandlerCache(t *testing.T) {
+	rd, err := newRenderer("ugc", 1<<20, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(in, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(in))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		rd.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := render("**cached**", "")
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag")
+	}
+	if _, ok := rd.cache.get(strings.Trim(etag, `"`)); !ok {
+		t.Errorf("rendered HTML not cached")
+	}
+
+	second := render("**cached**", "")
+	if second.Header().Get("ETag") != etag || second.Body.String() != first.Body.String() {
+		t.Errorf("got %q (ETag %s), want %q (ETag %s)", second.Body, second.Header().Get("ETag"), first.Body, etag)
+	}
+
+	if rr := render("**cached**", etag); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("If-None-Match: got %d %q, want %d", rr.Code, rr.Body, http.StatusNotModified)
+	}
+	if rr := render("**changed**", etag); rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Errorf("changed markdown: got %d with ETag %s, want %d with a new ETag", rr.Code, rr.Header().Get("ETag"), http.StatusOK)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(10)
+	c.add("a", []byte("aaaa"))
+	c.add("b", []byte("bbbb"))
+	c.get("a")
+	// Evicts b, the least recently used.
+	c.add("c", []byte("cccc"))
+	// Too large to cache.
+	c.add("d", []byte("ddddddddddd"))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		if _, got := c.get(key); got != want {
+			t.Errorf("get(%q) cached = %v, want %v", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Flavors of markdown, selected with the variant parameter of the
+// text/markdown content type. See RFC 7763.
+const (
+	flavorGFM        = "gfm"
+	flavorCommonMark = "commonmark"
+)
+
+// newMarkdown returns the markdown converters for each flavor. Raw HTML is
+// kept, because the output is sanitized.
+func newMarkdown() map[string]goldmark.Markdown {
+	return map[string]goldmark.Markdown{
+		flavorCommonMark: goldmark.New(
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+		flavorGFM: goldmark.New(
+			goldmark.WithExtensions(
+				extension.GFM,
+				highlighting.NewHighlighting(highlighting.WithStyle("github")),
+			),
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+	}
+}
+
+// newPolicies returns the sanitization policies, by name.
+func newPolicies() map[string]*bluemonday.Policy {
+	// ugc allows the HTML of user-generated content, plus the task lists,
+	// table alignment and syntax highlighting of GitHub-flavored markdown.
+	ugc := bluemonday.UGCPolicy()
+	ugc.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	ugc.AllowAttrs("checked", "disabled").OnElements("input")
+	ugc.AllowStyles("text-align").OnElements("th", "td")
+	ugc.AllowStyles("color", "background-color", "font-weight", "font-style", "text-decoration").OnElements("pre", "span")
+
+	return map[string]*bluemonday.Policy{
+		"ugc": ugc,
+		// strict removes all HTML, leaving the text.
+		"strict": bluemonday.StrictPolicy(),
+	}
+}
+
+// renderer renders markdown to sanitized HTML.
+type renderer struct {
+	markdown map[string]goldmark.Markdown
+	policies map[string]*bluemonday.Policy
+	// policy is the name of the default policy.
+	policy string
+	// maxBytes is the maximum size of the markdown to render.
+	maxBytes int64
+	cache    *cache
+}
+
+// newRenderer returns a renderer using the policy named policy by default,
+// and caching up to cacheBytes of rendered HTML.
+func newRenderer(policy string, maxBytes, cacheBytes int64) (*renderer, error) {
+	rd := &renderer{
+		markdown: newMarkdown(),
+		policies: newPolicies(),
+		policy:   policy,
+		maxBytes: maxBytes,
+		cache:    newCache(cacheBytes),
+	}
+	if rd.policies[policy] == nil {
+		return nil, fmt.Errorf("unknown policy %q", policy)
+	}
+	return rd, nil
+}
+
+// flavorOf returns the flavor of markdown for contentType. Requests without
+// a markdown content type use GitHub-flavored markdown.
+func flavorOf(contentType string) (string, error) {
+	if contentType == "" {
+		return flavorGFM, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("mime.ParseMediaType: %w", err)
+	}
+	if mediaType != "text/markdown" {
+		return flavorGFM, nil
+	}
+	switch variant := strings.ToLower(params["variant"]); variant {
+	case "", flavorGFM:
+		return flavorGFM, nil
+	case flavorCommonMark:
+		return flavorCommonMark, nil
+	default:
+		return "", fmt.Errorf("unsupported variant %q", variant)
+	}
+}
+
+// ServeHTTP renders the markdown in the body of the request. The policy query
+// parameter selects the sanitization policy.
+//
+// The ETag of the response is a hash of the markdown, flavor and policy, so
+// clients can skip downloading HTML they already have with If-None-Match.
+func (rd *renderer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	flavor, err := flavorOf(r.Header.Get("Content-Type"))
+	if err != nil {
+		log.Printf("flavorOf: %v", err)
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	policyName := r.URL.Query().Get("policy")
+	if policyName == "" {
+		policyName = rd.policy
+	}
+	policy := rd.policies[policyName]
+	if policy == nil {
+		http.Error(w, fmt.Sprintf("Unknown policy %q", policyName), http.StatusBadRequest)
+		return
+	}
+
+	in, err := io.ReadAll(http.MaxBytesReader(w, r.Body, rd.maxBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Printf("io.ReadAll: %v", err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	key := cacheKey(flavor, policyName, in)
+	etag := `"` + key + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if out, ok := rd.cache.get(key); ok {
+		w.Write(out)
+		return
+	}
+
+	var out bytes.Buffer
+	if err := rd.render(io.MultiWriter(w, &out), rd.markdown[flavor], policy, in); err != nil {
+		// The response may be partially written: don't cache it.
+		log.Printf("render: %v", err)
+		return
+	}
+	rd.cache.add(key, out.Bytes())
+}
+
+// render writes the HTML of the markdown in to w, sanitized with policy. The
+// HTML is streamed to w as it's converted.
+func (rd *renderer) render(w io.Writer, md goldmark.Markdown, policy *bluemonday.Policy, in []byte) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(md.Convert(in, pw))
+	}()
+	if err := policy.SanitizeReaderToWriter(pr, w); err != nil {
+		// Stop the conversion.
+		pr.CloseWithError(err)
+		return fmt.Errorf("SanitizeReaderToWriter: %w", err)
+	}
+	return nil
+}
+
+// cacheKey returns the key of the HTML of the markdown in.
+func cacheKey(flavor, policy string, in []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", flavor, policy)
+	h.Write(in)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// etagMatches reports whether the If-None-Match header ifNoneMatch matches
+// etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
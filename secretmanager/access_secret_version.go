@@ -23,18 +23,19 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // accessSecretVersion accesses the payload for the given secret version if one
 // exists. The version can be a version number as a string (e.g. "5") or an
 // alias (e.g. "latest").
-func accessSecretVersion(w io.Writer, name string) error {
+func accessSecretVersion(w io.Writer, name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret/versions/5"
 	// name := "projects/my-project/secrets/my-secret/versions/latest"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
@@ -23,11 +23,12 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // addSecretVersion adds a new secret version to the given secret with the
 // provided payload.
-func addSecretVersion(w io.Writer, parent string) error {
+func addSecretVersion(w io.Writer, parent string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project/secrets/my-secret"
 
 	// Declare the payload to store.
@@ -39,7 +40,7 @@ func addSecretVersion(w io.Writer, parent string) error {
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
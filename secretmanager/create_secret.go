@@ -22,18 +22,19 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // createSecret creates a new secret with the given name. A secret is a logical
 // wrapper around a collection of secret versions. Secret versions hold the
 // actual secret material.
-func createSecret(w io.Writer, parent, id string) error {
+func createSecret(w io.Writer, parent, id string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project"
 	// id := "my-secret"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
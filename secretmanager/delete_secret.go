@@ -21,15 +21,16 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // deleteSecret deletes the secret with the given name and all of its versions.
-func deleteSecret(name string) error {
+func deleteSecret(name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
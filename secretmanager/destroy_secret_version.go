@@ -21,16 +21,17 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // destroySecretVersion destroys the given secret version, making the payload
 // irrecoverable. Other secrets versions are unaffected.
-func destroySecretVersion(name string) error {
+func destroySecretVersion(name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret/versions/5"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
@@ -21,17 +21,18 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // disableSecretVersion disables the given secret version. Future requests will
 // throw an error until the secret version is enabled. Other secrets versions
 // are unaffected.
-func disableSecretVersion(name string) error {
+func disableSecretVersion(name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret/versions/5"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
@@ -21,17 +21,18 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 )
 
 // enableSecretVersion enables the given secret version, enabling it to be
 // accessed after previously being disabled. Other secrets versions are
 // unaffected.
-func enableSecretVersion(name string) error {
+func enableSecretVersion(name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret/versions/5"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
@@ -5,6 +5,7 @@ go 1.23.0
 require (
 	cloud.google.com/go/secretmanager v1.14.3
 	github.com/GoogleCloudPlatform/golang-samples v0.0.0-20240724083556-7f760db013b7
+	github.com/GoogleCloudPlatform/golang-samples/internal/secretmanager v0.0.0-00010101000000-000000000000
 	github.com/gofrs/uuid v4.4.0+incompatible
 	google.golang.org/api v0.217.0
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f
@@ -56,3 +57,7 @@ require (
 	google.golang.org/genproto/googleapis/api v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
 )
+
+replace github.com/GoogleCloudPlatform/golang-samples/internal/secretmanager => ../internal/secretmanager/
+
+replace github.com/GoogleCloudPlatform/golang-samples => ../
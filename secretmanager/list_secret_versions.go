@@ -23,16 +23,17 @@ import (
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 // listSecretVersions lists all secret versions in the given secret and their
 // metadata.
-func listSecretVersions(w io.Writer, parent string) error {
+func listSecretVersions(w io.Writer, parent string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project/secrets/my-secret"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
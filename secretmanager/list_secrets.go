@@ -23,15 +23,16 @@ import (
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
 )
 
 // listSecrets lists all secrets in the given project.
-func listSecrets(w io.Writer, parent string) error {
+func listSecrets(w io.Writer, parent string, opts ...option.ClientOption) error {
 	// parent := "projects/my-project"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
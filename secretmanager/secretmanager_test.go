@@ -27,6 +27,7 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	_ "github.com/GoogleCloudPlatform/golang-samples/internal/secretmanager/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 	regional_secretmanager "github.com/GoogleCloudPlatform/golang-samples/secretmanager/regional_samples"
 	"github.com/gofrs/uuid"
@@ -46,11 +47,11 @@ func testLocation(tb testing.TB) string {
 	return v
 }
 
-func testClient(tb testing.TB) (*secretmanager.Client, context.Context) {
+func testClient(tb testing.TB, opts ...option.ClientOption) (*secretmanager.Client, context.Context) {
 	tb.Helper()
 
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		tb.Fatalf("testClient: failed to create client: %v", err)
 	}
@@ -84,12 +85,12 @@ func testName(tb testing.TB) string {
 	return u.String()
 }
 
-func testSecret(tb testing.TB, projectID string) *secretmanagerpb.Secret {
+func testSecret(tb testing.TB, projectID string, opts ...option.ClientOption) *secretmanagerpb.Secret {
 	tb.Helper()
 
 	secretID := testName(tb)
 
-	client, ctx := testClient(tb)
+	client, ctx := testClient(tb, opts...)
 	secret, err := client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
 		Parent:   fmt.Sprintf("projects/%s", projectID),
 		SecretId: secretID,
@@ -140,10 +141,10 @@ func testRegionalSecret(tb testing.TB, projectID string) (*secretmanagerpb.Secre
 	return secret, secretID
 }
 
-func testSecretVersion(tb testing.TB, parent string, payload []byte) *secretmanagerpb.SecretVersion {
+func testSecretVersion(tb testing.TB, parent string, payload []byte, opts ...option.ClientOption) *secretmanagerpb.SecretVersion {
 	tb.Helper()
 
-	client, ctx := testClient(tb)
+	client, ctx := testClient(tb, opts...)
 
 	version, err := client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
 		Parent: parent,
@@ -174,10 +175,10 @@ func testRegionalSecretVersion(tb testing.TB, parent string, payload []byte) *se
 	return version
 }
 
-func testCleanupSecret(tb testing.TB, name string) {
+func testCleanupSecret(tb testing.TB, name string, opts ...option.ClientOption) {
 	tb.Helper()
 
-	client, ctx := testClient(tb)
+	client, ctx := testClient(tb, opts...)
 
 	if err := client.DeleteSecret(ctx, &secretmanagerpb.DeleteSecretRequest{
 		Name: name,
@@ -214,16 +215,17 @@ func testIamUser(tb testing.TB) string {
 }
 
 func TestAccessSecretVersion(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	payload := []byte("my-secret")
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	version := testSecretVersion(t, secret.Name, payload)
+	version := testSecretVersion(t, secret.Name, payload, opts...)
 
 	var b bytes.Buffer
-	if err := accessSecretVersion(&b, version.Name); err != nil {
+	if err := accessSecretVersion(&b, version.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -254,13 +256,14 @@ func TestAccessRegionalSecretVersion(t *testing.T) {
 }
 
 func TestAddSecretVersion(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
 	var b bytes.Buffer
-	if err := addSecretVersion(&b, secret.Name); err != nil {
+	if err := addSecretVersion(&b, secret.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -305,17 +308,18 @@ func TestConsumeEventNotification(t *testing.T) {
 }
 
 func TestCreateSecret(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	secretID := "createSecret"
 
 	parent := fmt.Sprintf("projects/%s", tc.ProjectID)
 
 	var b bytes.Buffer
-	if err := createSecret(&b, parent, secretID); err != nil {
+	if err := createSecret(&b, parent, secretID, opts...); err != nil {
 		t.Fatal(err)
 	}
-	defer testCleanupSecret(t, fmt.Sprintf("projects/%s/secrets/%s", tc.ProjectID, secretID))
+	defer testCleanupSecret(t, fmt.Sprintf("projects/%s/secrets/%s", tc.ProjectID, secretID), opts...)
 
 	if got, want := b.String(), "Created secret:"; !strings.Contains(got, want) {
 		t.Errorf("createSecret: expected %q to contain %q", got, want)
@@ -415,16 +419,17 @@ func TestCreateUserManagedReplicationSecret(t *testing.T) {
 }
 
 func TestDeleteSecret(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	if err := deleteSecret(secret.Name); err != nil {
+	if err := deleteSecret(secret.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
-	client, ctx := testClient(t)
+	client, ctx := testClient(t, opts...)
 	_, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
 		Name: secret.Name,
 	})
@@ -520,19 +525,20 @@ func TestDeleteRegionalSecretWithEtag(t *testing.T) {
 }
 
 func TestDestroySecretVersion(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	payload := []byte("my-secret")
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	version := testSecretVersion(t, secret.Name, payload)
+	version := testSecretVersion(t, secret.Name, payload, opts...)
 
-	if err := destroySecretVersion(version.Name); err != nil {
+	if err := destroySecretVersion(version.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
-	client, ctx := testClient(t)
+	client, ctx := testClient(t, opts...)
 	v, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{
 		Name: version.Name,
 	})
@@ -622,19 +628,20 @@ func TestDestroyRegionalSecretVersionWithEtag(t *testing.T) {
 }
 
 func TestDisableEnableSecretVersion(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	payload := []byte("my-secret")
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	version := testSecretVersion(t, secret.Name, payload)
+	version := testSecretVersion(t, secret.Name, payload, opts...)
 
-	if err := disableSecretVersion(version.Name); err != nil {
+	if err := disableSecretVersion(version.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
-	client, ctx := testClient(t)
+	client, ctx := testClient(t, opts...)
 	v, err := client.GetSecretVersion(ctx, &secretmanagerpb.GetSecretVersionRequest{
 		Name: version.Name,
 	})
@@ -645,7 +652,7 @@ func TestDisableEnableSecretVersion(t *testing.T) {
 		t.Errorf("testSecretVersion: expected %v to be %v", got, want)
 	}
 
-	if err := enableSecretVersion(version.Name); err != nil {
+	if err := enableSecretVersion(version.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -782,16 +789,17 @@ func TestDisableEnableRegionalSecretVersionWithEtag(t *testing.T) {
 }
 
 func TestGetSecretVersion(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	payload := []byte("my-secret")
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	version := testSecretVersion(t, secret.Name, payload)
+	version := testSecretVersion(t, secret.Name, payload, opts...)
 
 	var b bytes.Buffer
-	if err := getSecretVersion(&b, version.Name); err != nil {
+	if err := getSecretVersion(&b, version.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -822,13 +830,14 @@ func TestGetRegionalSecretVersion(t *testing.T) {
 }
 
 func TestGetSecret(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
 	var b bytes.Buffer
-	if err := getSecret(&b, secret.Name); err != nil {
+	if err := getSecret(&b, secret.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -994,17 +1003,18 @@ func TestIamRevokeAccessWithRegionalSecret(t *testing.T) {
 }
 
 func TestListSecretVersions(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
 	payload := []byte("my-secret")
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
-	version1 := testSecretVersion(t, secret.Name, payload)
-	version2 := testSecretVersion(t, secret.Name, payload)
+	version1 := testSecretVersion(t, secret.Name, payload, opts...)
+	version2 := testSecretVersion(t, secret.Name, payload, opts...)
 
 	var b bytes.Buffer
-	if err := listSecretVersions(&b, secret.Name); err != nil {
+	if err := listSecretVersions(&b, secret.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1094,16 +1104,17 @@ func TestListRegionalSecretVersionsWithFilter(t *testing.T) {
 }
 
 func TestListSecrets(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
-	secret1 := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret1.Name)
+	secret1 := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret1.Name, opts...)
 
-	secret2 := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret2.Name)
+	secret2 := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret2.Name, opts...)
 
 	var b bytes.Buffer
-	if err := listSecrets(&b, fmt.Sprintf("projects/%s", tc.ProjectID)); err != nil {
+	if err := listSecrets(&b, fmt.Sprintf("projects/%s", tc.ProjectID), opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1246,13 +1257,14 @@ func TestListRegionalSecretsWithFilter(t *testing.T) {
 }
 
 func TestUpdateSecret(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "secretmanager")
+	opts := tc.ClientOptions(t, "secretmanager")
 
-	secret := testSecret(t, tc.ProjectID)
-	defer testCleanupSecret(t, secret.Name)
+	secret := testSecret(t, tc.ProjectID, opts...)
+	defer testCleanupSecret(t, secret.Name, opts...)
 
 	var b bytes.Buffer
-	if err := updateSecret(&b, secret.Name); err != nil {
+	if err := updateSecret(&b, secret.Name, opts...); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1260,7 +1272,7 @@ func TestUpdateSecret(t *testing.T) {
 		t.Errorf("updateSecret: expected %q to contain %q", got, want)
 	}
 
-	client, ctx := testClient(t)
+	client, ctx := testClient(t, opts...)
 	s, err := client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{
 		Name: secret.Name,
 	})
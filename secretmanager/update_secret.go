@@ -22,16 +22,17 @@ import (
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
 	"google.golang.org/genproto/protobuf/field_mask"
 )
 
 // updateSecret updates the metadata about an existing secret.
-func updateSecret(w io.Writer, name string) error {
+func updateSecret(w io.Writer, name string, opts ...option.ClientOption) error {
 	// name := "projects/my-project/secrets/my-secret"
 
 	// Create the client.
 	ctx := context.Background()
-	client, err := secretmanager.NewClient(ctx)
+	client, err := secretmanager.NewClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %w", err)
 	}
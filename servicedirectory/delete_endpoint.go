@@ -21,9 +21,10 @@ import (
 
 	servicedirectory "cloud.google.com/go/servicedirectory/apiv1"
 	sdpb "cloud.google.com/go/servicedirectory/apiv1/servicedirectorypb"
+	"google.golang.org/api/option"
 )
 
-func deleteEndpoint(projectID string) error {
+func deleteEndpoint(projectID string, opts ...option.ClientOption) error {
 	// projectID := "my-project"
 	location := "us-east4"
 	namespaceID := "golang-test-namespace"
@@ -32,7 +33,7 @@ func deleteEndpoint(projectID string) error {
 
 	ctx := context.Background()
 	// Create a registration client.
-	client, err := servicedirectory.NewRegistrationClient(ctx)
+	client, err := servicedirectory.NewRegistrationClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("ServiceDirectory.NewRegistrationClient: %w", err)
 	}
@@ -5,6 +5,8 @@ go 1.23.0
 require (
 	cloud.google.com/go/servicedirectory v1.12.3
 	github.com/GoogleCloudPlatform/golang-samples v0.0.0-20240724083556-7f760db013b7
+	github.com/GoogleCloudPlatform/golang-samples/internal/servicedirectory v0.0.0-00010101000000-000000000000
+	google.golang.org/api v0.217.0
 )
 
 require (
@@ -48,10 +50,13 @@ require (
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	golang.org/x/time v0.9.0 // indirect
-	google.golang.org/api v0.217.0 // indirect
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/grpc v1.69.4 // indirect
 	google.golang.org/protobuf v1.36.3 // indirect
 )
+
+replace github.com/GoogleCloudPlatform/golang-samples/internal/servicedirectory => ../internal/servicedirectory/
+
+replace github.com/GoogleCloudPlatform/golang-samples => ../
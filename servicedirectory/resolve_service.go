@@ -22,9 +22,10 @@ import (
 
 	servicedirectory "cloud.google.com/go/servicedirectory/apiv1"
 	sdpb "cloud.google.com/go/servicedirectory/apiv1/servicedirectorypb"
+	"google.golang.org/api/option"
 )
 
-func resolveService(w io.Writer, projectID string) error {
+func resolveService(w io.Writer, projectID string, opts ...option.ClientOption) error {
 	// projectID := "my-project"
 	location := "us-east4"
 	namespaceID := "golang-test-namespace"
@@ -32,7 +33,7 @@ func resolveService(w io.Writer, projectID string) error {
 
 	ctx := context.Background()
 	// Create a lookup client.
-	resolver, err := servicedirectory.NewLookupClient(ctx)
+	resolver, err := servicedirectory.NewLookupClient(ctx, opts...)
 	if err != nil {
 		return fmt.Errorf("ServiceDirectory.NewLookupClient: %w", err)
 	}
@@ -19,14 +19,16 @@ import (
 	"strings"
 	"testing"
 
+	_ "github.com/GoogleCloudPlatform/golang-samples/internal/servicedirectory/fake"
 	"github.com/GoogleCloudPlatform/golang-samples/internal/testutil"
 )
 
 func TestServiceDirectory(t *testing.T) {
-	tc := testutil.SystemTest(t)
+	tc := testutil.BackendTest(t, "servicedirectory")
+	opts := tc.ClientOptions(t, "servicedirectory")
 	t.Run("createNamespace", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		if err := createNamespace(buf, tc.ProjectID); err != nil {
+		if err := createNamespace(buf, tc.ProjectID, opts...); err != nil {
 			t.Fatalf("CreateNamespace: %v", err)
 		}
 		got := buf.String()
@@ -36,7 +38,7 @@ func TestServiceDirectory(t *testing.T) {
 	})
 	t.Run("createService", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		if err := createService(buf, tc.ProjectID); err != nil {
+		if err := createService(buf, tc.ProjectID, opts...); err != nil {
 			t.Fatalf("CreateService: %v", err)
 		}
 
@@ -47,7 +49,7 @@ func TestServiceDirectory(t *testing.T) {
 	})
 	t.Run("resolveService", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		if err := resolveService(buf, tc.ProjectID); err != nil {
+		if err := resolveService(buf, tc.ProjectID, opts...); err != nil {
 			t.Errorf("CreateService: %v", err)
 		}
 
@@ -58,7 +60,7 @@ func TestServiceDirectory(t *testing.T) {
 	})
 	t.Run("createEndpoint", func(t *testing.T) {
 		buf := new(bytes.Buffer)
-		if err := createEndpoint(buf, tc.ProjectID); err != nil {
+		if err := createEndpoint(buf, tc.ProjectID, opts...); err != nil {
 			t.Errorf("CreateEndpoint %v", err)
 		}
 
@@ -68,17 +70,17 @@ func TestServiceDirectory(t *testing.T) {
 		}
 	})
 	t.Run("deleteEndpoint", func(t *testing.T) {
-		if err := deleteEndpoint(tc.ProjectID); err != nil {
+		if err := deleteEndpoint(tc.ProjectID, opts...); err != nil {
 			t.Errorf("DeleteEndpoint: %v", err)
 		}
 	})
 	t.Run("deleteService", func(t *testing.T) {
-		if err := deleteService(tc.ProjectID); err != nil {
+		if err := deleteService(tc.ProjectID, opts...); err != nil {
 			t.Errorf("DeleteService: %v", err)
 		}
 	})
 	t.Run("deleteNamespace", func(t *testing.T) {
-		if err := deleteNamespace(tc.ProjectID); err != nil {
+		if err := deleteNamespace(tc.ProjectID, opts...); err != nil {
 			t.Errorf("DeleteNamespace: %v", err)
 		}
 	})
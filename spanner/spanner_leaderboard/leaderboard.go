@@ -13,6 +13,8 @@
 // limitations under the License.
 
 // Command spanner_leaderboard contains runnable snippet code for Cloud Spanner.
+// Its serve command serves the leaderboard as a JSON API, with score
+// submission, paginated rankings and the rank of a player. See server.go.
 package main
 
 import (
@@ -23,6 +25,7 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -74,19 +77,7 @@ func createDatabase(ctx context.Context, w io.Writer, adminClient *database.Data
 	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
 		Parent:          matches[1],
 		CreateStatement: "CREATE DATABASE `" + matches[2] + "`",
-		ExtraStatements: []string{
-			`CREATE TABLE Players(
-			    PlayerId INT64 NOT NULL,
-			    PlayerName STRING(2048) NOT NULL
-			) PRIMARY KEY(PlayerId)`,
-			`CREATE TABLE Scores(
-			    PlayerId INT64 NOT NULL,
-			    Score INT64 NOT NULL,
-			    Timestamp TIMESTAMP NOT NULL
-			    OPTIONS(allow_commit_timestamp=true)
-			) PRIMARY KEY(PlayerId, Timestamp),
-			INTERLEAVE IN PARENT Players ON DELETE NO ACTION`,
-		},
+		ExtraStatements: schemaStatements(),
 	})
 	if err != nil {
 		return err
@@ -316,6 +307,15 @@ func run(ctx context.Context, adminClient *database.DatabaseAdminClient, dataCli
 		return err
 	}
 
+	// updateschema command
+	if cmd == "updateschema" {
+		err := updateSchema(ctx, w, adminClient, db)
+		if err != nil {
+			fmt.Fprintf(w, "%s failed with %v", cmd, err)
+		}
+		return err
+	}
+
 	// querywithtimespan command
 	if cmd == "querywithtimespan" {
 		if timespan == 0 {
@@ -344,9 +344,9 @@ func run(ctx context.Context, adminClient *database.DatabaseAdminClient, dataCli
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: leaderboard <command> <database_name> [command_option]
+		fmt.Fprintf(os.Stderr, `Usage: leaderboard [-staleness duration] <command> <database_name> [command_option]
 
-	Command can be one of: createdatabase, insertplayers, insertscores, query, querywithtimespan
+	Command can be one of: createdatabase, updateschema, insertplayers, insertscores, query, querywithtimespan, serve
 
 Examples:
 	leaderboard createdatabase projects/my-project/instances/my-instance/databases/example-db
@@ -359,8 +359,16 @@ Examples:
 		- Query players with top ten scores of all time.
 	leaderboard querywithtimespan projects/my-project/instances/my-instance/databases/example-db 168
 		- Query players with top ten scores within a timespan specified in hours.
+	leaderboard updateschema projects/my-project/instances/my-instance/databases/example-db
+		- Create the tables and indexes missing from the database.
+	leaderboard serve projects/my-project/instances/my-instance/databases/example-db
+		- Update the schema, and serve the leaderboard as a JSON API on $PORT, or 8080.
+
+Flags:
 `)
+		flag.PrintDefaults()
 	}
+	staleness := flag.Duration("staleness", 15*time.Second, "The staleness of the rankings read by serve, or 0 for strong reads.")
 
 	flag.Parse()
 	flagCount := len(flag.Args())
@@ -381,6 +389,15 @@ Examples:
 		timespan = parsedTimespan
 	}
 
+	if cmd == "serve" {
+		ctx := context.Background()
+		adminClient, dataClient := createClients(ctx, db)
+		if err := serve(ctx, adminClient, dataClient, db, *staleness); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 	adminClient, dataClient := createClients(ctx, db)
@@ -388,3 +405,18 @@ Examples:
 		os.Exit(1)
 	}
 }
+
+// serve updates the schema of the database db, and serves the leaderboard
+// until it fails.
+func serve(ctx context.Context, adminClient *database.DatabaseAdminClient, dataClient *spanner.Client, db string, staleness time.Duration) error {
+	if err := updateSchema(ctx, os.Stdout, adminClient, db); err != nil {
+		return err
+	}
+	s := &server{client: dataClient, staleness: staleness, now: time.Now}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Listening on port %s", port)
+	return http.ListenAndServe(":"+port, s.handler())
+}
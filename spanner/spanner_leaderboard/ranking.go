@@ -0,0 +1,341 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	errNotFound         = errors.New("not found")
+	errInvalidPageToken = errors.New("invalid page token")
+)
+
+// An entry is a score in the rankings. Scores are ranked by score, highest
+// first, then by commit timestamp, so that of two equal scores the first
+// submitted ranks higher, then by player ID.
+type entry struct {
+	Rank       int64     `json:"rank"`
+	PlayerID   int64     `json:"playerId"`
+	PlayerName string    `json:"playerName"`
+	Score      int64     `json:"score"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// A position is the place of an entry in the ranking order.
+type position struct {
+	Score     int64     `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+	PlayerID  int64     `json:"playerId"`
+}
+
+func (e *entry) position() position {
+	return position{Score: e.Score, Timestamp: e.Timestamp, PlayerID: e.PlayerID}
+}
+
+const (
+	// selectEntries selects the entries of the scores since @since. It
+	// scans ScoresByScore, which is in the ranking order, and filters the
+	// timespan as it goes, so that queries with a LIMIT stop early. There is
+	// no index on Timestamp, because indexing a commit timestamp makes all
+	// the writes go to the same split.
+	selectEntries = `SELECT s.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		FROM Scores@{FORCE_INDEX=ScoresByScore} s
+		JOIN Players p ON p.PlayerId = s.PlayerId
+		WHERE s.Timestamp >= @since`
+	// selectPlayerEntries selects the entries of the player @playerId since
+	// @since, which the primary key of Scores finds directly.
+	selectPlayerEntries = `SELECT s.PlayerId, p.PlayerName, s.Score, s.Timestamp
+		FROM Scores s
+		JOIN Players p ON p.PlayerId = s.PlayerId
+		WHERE s.PlayerId = @playerId AND s.Timestamp >= @since`
+	// rankingOrder and reverseOrder order entries in the ranking order, and
+	// in the reverse order.
+	rankingOrder = ` ORDER BY s.Score DESC, s.Timestamp, s.PlayerId`
+	reverseOrder = ` ORDER BY s.Score, s.Timestamp DESC, s.PlayerId DESC`
+	// after and before match the entries ranked after and before the
+	// position of @score, @timestamp and @playerId.
+	after = ` AND (s.Score < @score OR (s.Score = @score AND
+		(s.Timestamp > @timestamp OR (s.Timestamp = @timestamp AND s.PlayerId > @playerId))))`
+	before = ` AND (s.Score > @score OR (s.Score = @score AND
+		(s.Timestamp < @timestamp OR (s.Timestamp = @timestamp AND s.PlayerId < @playerId))))`
+)
+
+// since returns the start of the timespan of hours ending at now, or the zero
+// time, which is before all the scores, if hours is 0.
+func since(now time.Time, hours int) time.Time {
+	if hours == 0 {
+		return time.Time{}
+	}
+	return now.Add(-time.Duration(hours) * time.Hour)
+}
+
+// staleRead returns the timestamp bound of reads with staleness, or of
+// strong reads if staleness is 0. Stale reads don't wait for the latest
+// writes, so they are faster, which suits rankings, which change all the time
+// anyway.
+func staleRead(staleness time.Duration) spanner.TimestampBound {
+	if staleness == 0 {
+		return spanner.StrongRead()
+	}
+	return spanner.ExactStaleness(staleness)
+}
+
+// params returns the parameters of the statements with since and p.
+func params(since time.Time, p position) map[string]interface{} {
+	return map[string]interface{}{
+		"since":     since,
+		"score":     p.Score,
+		"timestamp": p.Timestamp,
+		"playerId":  p.PlayerID,
+	}
+}
+
+// queryEntries returns the entries selected by stmt in txn, ranked from
+// rank.
+func queryEntries(ctx context.Context, txn *spanner.ReadOnlyTransaction, stmt spanner.Statement, rank int64) ([]entry, error) {
+	entries := []entry{}
+	err := txn.Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		e := entry{Rank: rank + int64(len(entries))}
+		if err := row.Columns(&e.PlayerID, &e.PlayerName, &e.Score, &e.Timestamp); err != nil {
+			return err
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// A rankingPage is a page of the top scores.
+type rankingPage struct {
+	Entries []entry `json:"entries"`
+	// NextPageToken is the token of the next page, or empty for the last
+	// page.
+	NextPageToken string `json:"nextPageToken,omitempty"`
+	// ReadTime is the time of the rankings.
+	ReadTime time.Time `json:"readTime"`
+}
+
+// A pageToken is the state of the rankings of a page after the first. The
+// next pages are read at the read time of the first page, so that the
+// rankings don't change between pages.
+type pageToken struct {
+	ReadTime time.Time `json:"readTime"`
+	Timespan int       `json:"timespan"`
+	Since    time.Time `json:"since"`
+	// Last is the last entry of the previous page.
+	Last position `json:"last"`
+	Rank int64    `json:"rank"`
+}
+
+func (t *pageToken) encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePageToken(s string) (*pageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidPageToken
+	}
+	t := &pageToken{}
+	if err := json.Unmarshal(b, t); err != nil || t.ReadTime.IsZero() || t.Rank < 1 {
+		return nil, errInvalidPageToken
+	}
+	return t, nil
+}
+
+// topScores returns a page of pageSize top scores of the last timespan hours,
+// or of all time if timespan is 0. The first page is read with staleness, and
+// the next pages, of token, at the same time.
+func topScores(ctx context.Context, client *spanner.Client, timespan, pageSize int, token string, staleness time.Duration, now time.Time) (*rankingPage, error) {
+	stmt := spanner.Statement{SQL: selectEntries}
+	bound := staleRead(staleness)
+	rank := int64(1)
+	if token == "" {
+		stmt.Params = map[string]interface{}{"since": since(now, timespan)}
+	} else {
+		t, err := decodePageToken(token)
+		if err != nil {
+			return nil, err
+		}
+		if t.Timespan != timespan {
+			return nil, fmt.Errorf("%w: the timespan of the token is %d", errInvalidPageToken, t.Timespan)
+		}
+		stmt.SQL += after
+		stmt.Params = params(t.Since, t.Last)
+		bound = spanner.ReadTimestamp(t.ReadTime)
+		rank = t.Rank + 1
+	}
+	// Read an extra entry, to know if there is a next page.
+	stmt.SQL += rankingOrder + ` LIMIT @limit`
+	stmt.Params["limit"] = pageSize + 1
+
+	txn := client.Single().WithTimestampBound(bound)
+	defer txn.Close()
+	entries, err := queryEntries(ctx, txn, stmt, rank)
+	if err != nil {
+		if token != "" && spanner.ErrCode(err) == codes.FailedPrecondition {
+			// The read time is older than the versions kept by Spanner.
+			return nil, fmt.Errorf("%w: the token expired", errInvalidPageToken)
+		}
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	readTime, err := txn.Timestamp()
+	if err != nil {
+		return nil, fmt.Errorf("Timestamp: %w", err)
+	}
+
+	page := &rankingPage{Entries: entries, ReadTime: readTime}
+	if len(entries) > pageSize {
+		page.Entries = entries[:pageSize]
+		last := page.Entries[pageSize-1]
+		next := &pageToken{
+			ReadTime: readTime,
+			Timespan: timespan,
+			Since:    stmt.Params["since"].(time.Time),
+			Last:     last.position(),
+			Rank:     last.Rank,
+		}
+		page.NextPageToken = next.encode()
+	}
+	return page, nil
+}
+
+// A playerRanking is the rank of the best score of a player, with the
+// scores ranked just above and below it.
+type playerRanking struct {
+	Player entry   `json:"player"`
+	Above  []entry `json:"above"`
+	Below  []entry `json:"below"`
+	// ReadTime is the time of the rankings.
+	ReadTime time.Time `json:"readTime"`
+}
+
+// playerRank returns the rank of the best score of the player playerID in
+// the last timespan hours, or all time if timespan is 0, with the neighbors
+// scores above and below it. It returns errNotFound if the player has no
+// score in the timespan.
+func playerRank(ctx context.Context, client *spanner.Client, playerID int64, timespan, neighbors int, staleness time.Duration, now time.Time) (*playerRanking, error) {
+	// Read all the entries at the same time.
+	txn := client.ReadOnlyTransaction().WithTimestampBound(staleRead(staleness))
+	defer txn.Close()
+	start := since(now, timespan)
+
+	best, err := queryEntries(ctx, txn, spanner.Statement{
+		SQL:    selectPlayerEntries + rankingOrder + ` LIMIT 1`,
+		Params: map[string]interface{}{"since": start, "playerId": playerID},
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	if len(best) == 0 {
+		return nil, errNotFound
+	}
+	r := &playerRanking{Player: best[0]}
+	p := params(start, r.Player.position())
+
+	var above int64
+	err = txn.Query(ctx, spanner.Statement{
+		SQL:    `SELECT COUNT(*) FROM Scores@{FORCE_INDEX=ScoresByScore} s WHERE s.Timestamp >= @since` + before,
+		Params: p,
+	}).Do(func(row *spanner.Row) error { return row.Column(0, &above) })
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	r.Player.Rank = above + 1
+
+	p["limit"] = neighbors
+	r.Above, err = queryEntries(ctx, txn, spanner.Statement{
+		SQL:    selectEntries + before + reverseOrder + ` LIMIT @limit`,
+		Params: p,
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	// The entries above are read nearest first.
+	slices.Reverse(r.Above)
+	for i := range r.Above {
+		r.Above[i].Rank = r.Player.Rank - int64(len(r.Above)-i)
+	}
+	r.Below, err = queryEntries(ctx, txn, spanner.Statement{
+		SQL:    selectEntries + after + rankingOrder + ` LIMIT @limit`,
+		Params: p,
+	}, r.Player.Rank+1)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+
+	if r.ReadTime, err = txn.Timestamp(); err != nil {
+		return nil, fmt.Errorf("Timestamp: %w", err)
+	}
+	return r, nil
+}
+
+// submitScore adds a score of the player playerID, at the commit timestamp,
+// which it returns. It returns errNotFound if the player doesn't exist.
+func submitScore(ctx context.Context, client *spanner.Client, playerID, score int64) (time.Time, error) {
+	ts, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		if _, err := txn.ReadRow(ctx, "Players", spanner.Key{playerID}, []string{"PlayerId"}); err != nil {
+			if spanner.ErrCode(err) == codes.NotFound {
+				return errNotFound
+			}
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{
+			spanner.Insert("Scores", []string{"PlayerId", "Score", "Timestamp"},
+				[]interface{}{playerID, score, spanner.CommitTimestamp}),
+		})
+	})
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return time.Time{}, errNotFound
+		}
+		return time.Time{}, fmt.Errorf("ReadWriteTransaction: %w", err)
+	}
+	return ts, nil
+}
+
+// addPlayer adds a player named name, with a random ID, which it returns.
+func addPlayer(ctx context.Context, client *spanner.Client, name string) (int64, error) {
+	for {
+		// Random IDs spread the players over the key space, as the
+		// insertplayers command does.
+		playerID := rand.Int63n(8000000000) + 1000000000
+		_, err := client.Apply(ctx, []*spanner.Mutation{
+			spanner.Insert("Players", []string{"PlayerId", "PlayerName"}, []interface{}{playerID, name}),
+		})
+		if spanner.ErrCode(err) == codes.AlreadyExists {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Apply: %w", err)
+		}
+		return playerID, nil
+	}
+}
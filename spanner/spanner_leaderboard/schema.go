@@ -0,0 +1,102 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+)
+
+// A schemaObject is a table or an index of the database.
+type schemaObject struct {
+	name string
+	ddl  string
+}
+
+// schema is the schema of the leaderboard database, in the order of creation.
+// To change the schema, add objects rather than editing the existing ones:
+// updateSchema only creates the objects which don't exist.
+var schema = []schemaObject{
+	{"Players", `CREATE TABLE Players(
+	    PlayerId INT64 NOT NULL,
+	    PlayerName STRING(2048) NOT NULL
+	) PRIMARY KEY(PlayerId)`},
+	// Timestamp is the commit timestamp of the score, for scores submitted
+	// to the service.
+	{"Scores", `CREATE TABLE Scores(
+	    PlayerId INT64 NOT NULL,
+	    Score INT64 NOT NULL,
+	    Timestamp TIMESTAMP NOT NULL
+	    OPTIONS(allow_commit_timestamp=true)
+	) PRIMARY KEY(PlayerId, Timestamp),
+	INTERLEAVE IN PARENT Players ON DELETE NO ACTION`},
+	// ScoresByScore is in the ranking order, for the top scores of all time
+	// and of a timespan.
+	{"ScoresByScore", `CREATE INDEX ScoresByScore ON Scores(Score DESC, Timestamp)`},
+}
+
+// schemaStatements returns the DDL statements of the objects of schema.
+func schemaStatements() []string {
+	var statements []string
+	for _, o := range schema {
+		statements = append(statements, o.ddl)
+	}
+	return statements
+}
+
+// ddlName matches the name of the table or index created by a DDL statement.
+var ddlName = regexp.MustCompile("^CREATE\\s+(?:UNIQUE\\s+)?(?:NULL_FILTERED\\s+)?(?:TABLE|INDEX)\\s+`?(\\w+)")
+
+// updateSchema creates the tables and indexes of schema which don't exist in
+// the database db.
+func updateSchema(ctx context.Context, w io.Writer, adminClient *database.DatabaseAdminClient, db string) error {
+	ddl, err := adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: db})
+	if err != nil {
+		return fmt.Errorf("GetDatabaseDdl: %w", err)
+	}
+	exists := make(map[string]bool)
+	for _, statement := range ddl.GetStatements() {
+		if m := ddlName.FindStringSubmatch(statement); m != nil {
+			exists[m[1]] = true
+		}
+	}
+
+	var statements []string
+	for _, o := range schema {
+		if !exists[o.name] {
+			statements = append(statements, o.ddl)
+			fmt.Fprintf(w, "Creating %s\n", o.name)
+		}
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: statements,
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateDatabaseDdl: %w", err)
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("UpdateDatabaseDdl: %w", err)
+	}
+	return nil
+}
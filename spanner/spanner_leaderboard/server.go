@@ -0,0 +1,180 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+const (
+	defaultPageSize  = 10
+	maxPageSize      = 100
+	defaultNeighbors = 2
+	maxNeighbors     = 10
+	// maxTimespan is the longest timespan, in hours, of the rankings.
+	maxTimespan = 10 * 365 * 24
+)
+
+// A server serves the leaderboard as a JSON API:
+//
+//	POST /players {"name": "..."} adds a player.
+//	POST /scores {"playerId": ..., "score": ...} adds a score of a player.
+//	GET /rankings?timespan=HOURS&pageSize=N&pageToken=T returns a page of
+//	the top scores of the last hours, or of all time without timespan.
+//	GET /players/{id}/rank?timespan=HOURS&neighbors=N returns the rank of
+//	the best score of a player, with the scores around it.
+type server struct {
+	client *spanner.Client
+	// staleness is the staleness of the reads of the rankings.
+	staleness time.Duration
+	// now returns the current time. It is replaced in tests.
+	now func() time.Time
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /players", s.handleAddPlayer)
+	mux.HandleFunc("POST /scores", s.handleSubmitScore)
+	mux.HandleFunc("GET /rankings", s.handleRankings)
+	mux.HandleFunc("GET /players/{id}/rank", s.handlePlayerRank)
+	return mux
+}
+
+func (s *server) handleAddPlayer(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || len(req.Name) > 2048 {
+		http.Error(w, "A name of up to 2048 bytes is required.", http.StatusBadRequest)
+		return
+	}
+	playerID, err := addPlayer(r.Context(), s.client, req.Name)
+	if err != nil {
+		log.Printf("addPlayer: %v", err)
+		http.Error(w, "Error adding player.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"playerId": playerID, "name": req.Name})
+}
+
+func (s *server) handleSubmitScore(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlayerID int64 `json:"playerId"`
+		Score    int64 `json:"score"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlayerID == 0 || req.Score < 0 {
+		http.Error(w, "A playerId and a score of at least 0 are required.", http.StatusBadRequest)
+		return
+	}
+	ts, err := submitScore(r.Context(), s.client, req.PlayerID, req.Score)
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "Player not found.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("submitScore: %v", err)
+		http.Error(w, "Error submitting score.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"playerId": req.PlayerID, "score": req.Score, "timestamp": ts})
+}
+
+func (s *server) handleRankings(w http.ResponseWriter, r *http.Request) {
+	timespan, err := intParam(r, "timespan", 0, 0, maxTimespan)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pageSize, err := intParam(r, "pageSize", defaultPageSize, 1, maxPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := topScores(r.Context(), s.client, timespan, pageSize, r.FormValue("pageToken"), s.staleness, s.now())
+	if errors.Is(err, errInvalidPageToken) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("topScores: %v", err)
+		http.Error(w, "Error reading rankings.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *server) handlePlayerRank(w http.ResponseWriter, r *http.Request) {
+	playerID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid player ID.", http.StatusBadRequest)
+		return
+	}
+	timespan, err := intParam(r, "timespan", 0, 0, maxTimespan)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	neighbors, err := intParam(r, "neighbors", defaultNeighbors, 0, maxNeighbors)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ranking, err := playerRank(r.Context(), s.client, playerID, timespan, neighbors, s.staleness, s.now())
+	if errors.Is(err, errNotFound) {
+		http.Error(w, "No score of the player in the timespan.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("playerRank: %v", err)
+		http.Error(w, "Error reading rank.", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ranking)
+}
+
+// intParam returns the value of the integer parameter name of r, between min
+// and max, or def if it isn't set.
+func intParam(r *http.Request, name string, def, min, max int) (int, error) {
+	s := r.FormValue(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a number between %d and %d", name, min, max)
+	}
+	return n, nil
+}
+
+// writeJSON writes v as the JSON response, with status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, "Error encoding response.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
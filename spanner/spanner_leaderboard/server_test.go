@@ -0,0 +1,374 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "cloud.google.com/go/spanner/admin/database/apiv1/databasepb"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	"cloud.google.com/go/spanner/admin/instance/apiv1/instancepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newEmulatorClient returns a client of a new, empty database in the Cloud
+// Spanner emulator, with the schema created by updateSchema. It skips the
+// test unless SPANNER_EMULATOR_HOST points at the emulator, e.g. one started
+// with docker run -p 9010:9010 gcr.io/cloud-spanner-emulator/emulator.
+func newEmulatorClient(t *testing.T) *spanner.Client {
+	t.Helper()
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("SPANNER_EMULATOR_HOST not set")
+	}
+	ctx := context.Background()
+	const (
+		projectID  = "leaderboard-test"
+		instanceID = "leaderboard-test"
+	)
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("instance.NewInstanceAdminClient: %v", err)
+	}
+	defer instanceAdmin.Close()
+	op, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + projectID,
+		InstanceId: instanceID,
+		Instance: &instancepb.Instance{
+			Config:      "projects/" + projectID + "/instanceConfigs/emulator-config",
+			DisplayName: instanceID,
+			NodeCount:   1,
+		},
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		if err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+		if _, err := op.Wait(ctx); err != nil {
+			t.Fatalf("CreateInstance: %v", err)
+		}
+	}
+
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatalf("database.NewDatabaseAdminClient: %v", err)
+	}
+	defer adminClient.Close()
+	databaseID := "lb-" + randomID()
+	dbOp, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+		Parent:          fmt.Sprintf("projects/%s/instances/%s", projectID, instanceID),
+		CreateStatement: "CREATE DATABASE `" + databaseID + "`",
+	})
+	if err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	if _, err := dbOp.Wait(ctx); err != nil {
+		t.Fatalf("CreateDatabase: %v", err)
+	}
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, databaseID)
+	if err := updateSchema(ctx, io.Discard, adminClient, db); err != nil {
+		t.Fatalf("updateSchema: %v", err)
+	}
+	// Updating an up-to-date schema must be a no-op.
+	var b bytes.Buffer
+	if err := updateSchema(ctx, &b, adminClient, db); err != nil || b.Len() != 0 {
+		t.Fatalf("updateSchema again: %q, %v, want no change", b.String(), err)
+	}
+
+	client, err := spanner.NewClient(ctx, db)
+	if err != nil {
+		t.Fatalf("spanner.NewClient: %v", err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// addTestScores adds the players of names, and their scores, each added
+// hoursAgo before now. It returns the IDs of the players, by name.
+func addTestScores(t *testing.T, client *spanner.Client, now time.Time, scores []testScore) map[string]int64 {
+	t.Helper()
+	ctx := context.Background()
+	ids := make(map[string]int64)
+	var muts []*spanner.Mutation
+	for _, s := range scores {
+		if _, ok := ids[s.name]; !ok {
+			id, err := addPlayer(ctx, client, s.name)
+			if err != nil {
+				t.Fatalf("addPlayer: %v", err)
+			}
+			ids[s.name] = id
+		}
+		muts = append(muts, spanner.Insert("Scores", []string{"PlayerId", "Score", "Timestamp"},
+			[]interface{}{ids[s.name], s.score, now.Add(-time.Duration(s.hoursAgo) * time.Hour)}))
+	}
+	if _, err := client.Apply(ctx, muts); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return ids
+}
+
+type testScore struct {
+	name     string
+	score    int64
+	hoursAgo int
+}
+
+// testScores rank b, d, c, a, then the old score of a. d ranks above c as its
+// equal score is older.
+var testScores = []testScore{
+	{name: "a", score: 100, hoursAgo: 3},
+	{name: "a", score: 50, hoursAgo: 48},
+	{name: "b", score: 300, hoursAgo: 2},
+	{name: "c", score: 200, hoursAgo: 1},
+	{name: "d", score: 200, hoursAgo: 2},
+}
+
+// summary returns entries as "rank name score" strings.
+func summary(entries []entry) []string {
+	s := []string{}
+	for _, e := range entries {
+		s = append(s, fmt.Sprintf("%d %s %d", e.Rank, e.PlayerName, e.Score))
+	}
+	return s
+}
+
+func TestTopScores(t *testing.T) {
+	ctx := context.Background()
+	client := newEmulatorClient(t)
+	now := time.Now()
+	ids := addTestScores(t, client, now, testScores)
+
+	for _, test := range []struct {
+		timespan int
+		want     [][]string
+	}{
+		{timespan: 0, want: [][]string{{"1 b 300", "2 d 200"}, {"3 c 200", "4 a 100"}, {"5 a 50"}}},
+		{timespan: 24, want: [][]string{{"1 b 300", "2 d 200"}, {"3 c 200", "4 a 100"}}},
+	} {
+		var got [][]string
+		token := ""
+		for {
+			page, err := topScores(ctx, client, test.timespan, 2, token, 0, now)
+			if err != nil {
+				t.Fatalf("topScores(%d): %v", test.timespan, err)
+			}
+			got = append(got, summary(page.Entries))
+			if token = page.NextPageToken; token == "" {
+				break
+			}
+		}
+		if !slices.EqualFunc(got, test.want, slices.Equal) {
+			t.Errorf("topScores(%d) got pages %q, want %q", test.timespan, got, test.want)
+		}
+	}
+
+	// The next pages are read at the time of the first one.
+	page, err := topScores(ctx, client, 0, 2, "", 0, now)
+	if err != nil {
+		t.Fatalf("topScores: %v", err)
+	}
+	if _, err := submitScore(ctx, client, ids["a"], 1000); err != nil {
+		t.Fatalf("submitScore: %v", err)
+	}
+	page, err = topScores(ctx, client, 0, 2, page.NextPageToken, 0, now)
+	if err != nil {
+		t.Fatalf("topScores of the next page: %v", err)
+	}
+	if got, want := summary(page.Entries), []string{"3 c 200", "4 a 100"}; !slices.Equal(got, want) {
+		t.Errorf("topScores of the next page got %q, want %q", got, want)
+	}
+	page, err = topScores(ctx, client, 0, 1, "", 0, now)
+	if err != nil {
+		t.Fatalf("topScores: %v", err)
+	}
+	if got := summary(page.Entries); !slices.Equal(got, []string{"1 a 1000"}) {
+		t.Errorf("topScores after submitScore got %q, want the submitted score first", got)
+	}
+
+	for _, token := range []string{"invalid", (&pageToken{}).encode()} {
+		if _, err := topScores(ctx, client, 0, 2, token, 0, now); !errors.Is(err, errInvalidPageToken) {
+			t.Errorf("topScores(%q) got error %v, want %v", token, err, errInvalidPageToken)
+		}
+	}
+	if _, err := topScores(ctx, client, 24, 2, page.NextPageToken, 0, now); !errors.Is(err, errInvalidPageToken) {
+		t.Errorf("topScores with the token of another timespan got error %v, want %v", err, errInvalidPageToken)
+	}
+}
+
+func TestPlayerRank(t *testing.T) {
+	ctx := context.Background()
+	client := newEmulatorClient(t)
+	now := time.Now()
+	ids := addTestScores(t, client, now, testScores)
+
+	for _, test := range []struct {
+		name      string
+		timespan  int
+		neighbors int
+		want      []string
+	}{
+		{name: "c", neighbors: 1, want: []string{"2 d 200", "3 c 200", "4 a 100"}},
+		{name: "d", neighbors: 2, want: []string{"1 b 300", "2 d 200", "3 c 200", "4 a 100"}},
+		{name: "a", timespan: 24, neighbors: 2, want: []string{"2 d 200", "3 c 200", "4 a 100"}},
+		{name: "b", neighbors: 0, want: []string{"1 b 300"}},
+	} {
+		r, err := playerRank(ctx, client, ids[test.name], test.timespan, test.neighbors, 0, now)
+		if err != nil {
+			t.Fatalf("playerRank(%s): %v", test.name, err)
+		}
+		got := summary(slices.Concat(r.Above, []entry{r.Player}, r.Below))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("playerRank(%s, %d, %d) got %q, want %q", test.name, test.timespan, test.neighbors, got, test.want)
+		}
+	}
+
+	// a has no score in the last 2 hours.
+	if _, err := playerRank(ctx, client, ids["a"], 2, 1, 0, now); !errors.Is(err, errNotFound) {
+		t.Errorf("playerRank without scores got error %v, want %v", err, errNotFound)
+	}
+}
+
+func TestSubmitScore(t *testing.T) {
+	ctx := context.Background()
+	client := newEmulatorClient(t)
+	playerID, err := addPlayer(ctx, client, "a")
+	if err != nil {
+		t.Fatalf("addPlayer: %v", err)
+	}
+	ts, err := submitScore(ctx, client, playerID, 10)
+	if err != nil {
+		t.Fatalf("submitScore: %v", err)
+	}
+	r, err := playerRank(ctx, client, playerID, 1, 0, 0, time.Now())
+	if err != nil {
+		t.Fatalf("playerRank: %v", err)
+	}
+	if !r.Player.Timestamp.Equal(ts) {
+		t.Errorf("playerRank got timestamp %v, want the commit timestamp %v", r.Player.Timestamp, ts)
+	}
+
+	if _, err := submitScore(ctx, client, playerID+1, 10); !errors.Is(err, errNotFound) {
+		t.Errorf("submitScore of an unknown player got error %v, want %v", err, errNotFound)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	client := newEmulatorClient(t)
+	s := &server{client: client, now: time.Now}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	do := func(method, path, body string, wantCode int, v interface{}) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("http.NewRequest: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantCode {
+			b, _ := io.ReadAll(resp.Body)
+			t.Fatalf("%s %s got status %d (%s), want %d", method, path, resp.StatusCode, b, wantCode)
+		}
+		if v != nil {
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Fatalf("%s %s: json.Decode: %v", method, path, err)
+			}
+		}
+	}
+
+	var player struct {
+		PlayerID int64 `json:"playerId"`
+	}
+	do("POST", "/players", `{"name": "a"}`, http.StatusCreated, &player)
+	do("POST", "/players", `{}`, http.StatusBadRequest, nil)
+	do("POST", "/scores", fmt.Sprintf(`{"playerId": %d, "score": 10}`, player.PlayerID), http.StatusCreated, nil)
+	do("POST", "/scores", fmt.Sprintf(`{"playerId": %d, "score": -1}`, player.PlayerID), http.StatusBadRequest, nil)
+	do("POST", "/scores", fmt.Sprintf(`{"playerId": %d, "score": 10}`, player.PlayerID+1), http.StatusNotFound, nil)
+
+	var page rankingPage
+	do("GET", "/rankings?timespan=1", "", http.StatusOK, &page)
+	if got := summary(page.Entries); !slices.Equal(got, []string{"1 a 10"}) || page.NextPageToken != "" {
+		t.Errorf("GET /rankings got %q, token %q, want the score of a", got, page.NextPageToken)
+	}
+	do("GET", "/rankings?pageSize=0", "", http.StatusBadRequest, nil)
+	do("GET", "/rankings?pageToken=invalid", "", http.StatusBadRequest, nil)
+
+	var rank playerRanking
+	do("GET", fmt.Sprintf("/players/%d/rank?neighbors=1", player.PlayerID), "", http.StatusOK, &rank)
+	if rank.Player.Rank != 1 || rank.Player.Score != 10 {
+		t.Errorf("GET /players/{id}/rank got %+v, want rank 1 with score 10", rank.Player)
+	}
+	do("GET", fmt.Sprintf("/players/%d/rank", player.PlayerID+1), "", http.StatusNotFound, nil)
+	do("GET", "/players/x/rank", "", http.StatusBadRequest, nil)
+}
+
+func TestDDLName(t *testing.T) {
+	for _, o := range schema {
+		if m := ddlName.FindStringSubmatch(o.ddl); m == nil || m[1] != o.name {
+			t.Errorf("ddlName of the DDL of %s got %q", o.name, m)
+		}
+	}
+	// GetDatabaseDdl returns the statements in a canonical form.
+	for statement, want := range map[string]string{
+		"CREATE TABLE Players (\n  PlayerId INT64 NOT NULL,\n) PRIMARY KEY(PlayerId)": "Players",
+		"CREATE INDEX ScoresByScore ON Scores(Score DESC, Timestamp)":                 "ScoresByScore",
+		"CREATE UNIQUE NULL_FILTERED INDEX `Names` ON Players(PlayerName)":            "Names",
+	} {
+		if m := ddlName.FindStringSubmatch(statement); m == nil || m[1] != want {
+			t.Errorf("ddlName of %q got %q, want %s", statement, m, want)
+		}
+	}
+}
+
+func TestDecodePageToken(t *testing.T) {
+	want := &pageToken{
+		ReadTime: time.Date(2025, 3, 1, 12, 0, 0, 123456789, time.UTC),
+		Timespan: 24,
+		Since:    time.Date(2025, 2, 28, 12, 0, 0, 0, time.UTC),
+		Last:     position{Score: 100, Timestamp: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), PlayerID: 7},
+		Rank:     10,
+	}
+	got, err := decodePageToken(want.encode())
+	if err != nil {
+		t.Fatalf("decodePageToken: %v", err)
+	}
+	if *got != *want {
+		t.Errorf("decodePageToken got %+v, want %+v", got, want)
+	}
+	for _, token := range []string{"", "!", "e30", (&pageToken{ReadTime: want.ReadTime}).encode()} {
+		if _, err := decodePageToken(token); !errors.Is(err, errInvalidPageToken) {
+			t.Errorf("decodePageToken(%q) got error %v, want %v", token, err, errInvalidPageToken)
+		}
+	}
+}
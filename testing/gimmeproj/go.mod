@@ -2,7 +2,11 @@ module github.com/GoogleCloudPlatform/golang-samples/testing/gimmeproj
 
 go 1.23.0
 
-require cloud.google.com/go/datastore v1.20.0
+require (
+	cloud.google.com/go/datastore v1.20.0
+	google.golang.org/api v0.217.0
+	google.golang.org/grpc v1.69.4
+)
 
 require (
 	cloud.google.com/go v0.118.0 // indirect
@@ -31,10 +35,8 @@ require (
 	golang.org/x/sys v0.29.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
 	golang.org/x/time v0.9.0 // indirect
-	google.golang.org/api v0.217.0 // indirect
 	google.golang.org/genproto v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250115164207-1a7da9e5054f // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/grpc v1.69.4 // indirect
 	google.golang.org/protobuf v1.36.3 // indirect
 )
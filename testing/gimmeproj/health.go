@@ -0,0 +1,35 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// checkProject reports whether a project is usable for tests: it must exist,
+// be visible to the caller and not be pending deletion.
+func checkProject(ctx context.Context, crm *cloudresourcemanager.Service, projectID string) error {
+	p, err := crm.Projects.Get(projectID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("Projects.Get: %w", err)
+	}
+	if p.LifecycleState != "ACTIVE" {
+		return fmt.Errorf("project is %s", p.LifecycleState)
+	}
+	return nil
+}
@@ -16,26 +16,41 @@
 //
 // The metadata about the project pool is stored in Cloud Datastore in a meta-project.
 // Projects are leased for a certain duration, and automatically returned to the pool when the lease expires.
-// Projects should be returned before the lease expires.
+// Projects should be returned before the lease expires, and long-running jobs
+// can renew their lease while they run.
+//
+// Callers waiting for a project are served in the order they arrived. Projects
+// can carry labels, such as "gpu", and lease requests can ask for projects with
+// particular labels. Broken projects are quarantined, either by hand or by the
+// health-check command, so they are not handed out.
 package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"strings"
 	"time"
 
 	ds "cloud.google.com/go/datastore"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
 var (
 	metaProject = flag.String("project", "", "Meta-project that manages the pool.")
-	format      = flag.String("output", "", "Output format for selected operations. Options include: list")
+	format      = flag.String("output", "", "Output format for selected operations. Options include: list, json")
 	waitTime    = flag.Duration("timeout", 30*time.Minute, "maximum wait time for leasing a project")
+	pollTime    = flag.Duration("poll", 15*time.Second, "how often to poll while waiting in the lease queue")
+	leaseLabels = flag.String("labels", "", "Comma-separated labels a leased project must have, e.g. gpu,spanner.")
+	holder      = flag.String("holder", defaultHolder(), "Lease holder, used to renew leases. Defaults to $GIMMEPROJ_HOLDER or the hostname.")
 	datastore   *ds.Client
 
 	version       = "dev"
@@ -44,56 +59,6 @@ var (
 	ErrNoProjects = errors.New("could not find a free project")
 )
 
-type Pool struct {
-	Projects []Project
-}
-
-type Project struct {
-	ID          string
-	LeaseExpiry time.Time
-}
-
-func (p *Pool) Get(projID string) (*Project, bool) {
-	for i := range p.Projects {
-		proj := &p.Projects[i]
-		if proj.ID == projID {
-			return proj, true
-		}
-	}
-	return nil, false
-}
-
-func (p *Pool) Add(proj string) (ok bool) {
-	if _, ok := p.Get(proj); ok {
-		return false
-	}
-	p.Projects = append(p.Projects, Project{ID: proj})
-	return true
-}
-
-func (p *Pool) Lease(d time.Duration) (*Project, bool) {
-	if len(p.Projects) == 0 {
-		return nil, false
-	}
-
-	oldest := &p.Projects[0]
-	for i := range p.Projects {
-		proj := &p.Projects[i]
-		if proj.LeaseExpiry.Before(oldest.LeaseExpiry) {
-			oldest = proj
-		}
-	}
-	if !oldest.Expired() {
-		return nil, false
-	}
-	oldest.LeaseExpiry = time.Now().Add(d)
-	return oldest, true
-}
-
-func (p *Project) Expired() bool {
-	return time.Now().After(p.LeaseExpiry)
-}
-
 func startup() {
 	// set version info from embedded details.
 	if bi, ok := debug.ReadBuildInfo(); ok {
@@ -120,6 +85,17 @@ func main() {
 	}
 }
 
+func defaultHolder() string {
+	if h := os.Getenv("GIMMEPROJ_HOLDER"); h != "" {
+		return h
+	}
+	h, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return h
+}
+
 func submain() error {
 	ctx := context.Background()
 
@@ -129,14 +105,24 @@ Usage:
 	gimmeproj -project=[meta project ID] -output=list status
 
 Commands:
-	lease [duration]    Leases a project for a given duration. Prints the project ID to stdout.
-	done [project ID]   Returns a project to the pool.
-	version             Prints the version of gimmeproj.
+	lease [duration]                   Leases a project for a given duration. Prints the project ID to stdout.
+	                                   Waits in a first-come, first-served queue for up to -timeout.
+	                                   Respects -labels and -holder.
+	renew [project ID] [duration]      Extends a lease to the given duration from now. Respects -holder.
+	heartbeat [project ID] [duration]  Renews a lease periodically until interrupted. Respects -holder.
+	done [project ID]                  Returns a project to the pool.
+	version                            Prints the version of gimmeproj.
 
 Administrative commands:
-	pool-add [project ID]       Adds a project to the pool.
-	pool-rm  [project ID]       Removes a project from the pool.
-	status                      Displays the current status of the meta project. Respects -output.
+	pool-add [project ID] [labels...]    Adds a project to the pool.
+	pool-rm  [project ID]                Removes a project from the pool.
+	pool-label [project ID] [labels...]  Replaces the labels of a project.
+	quarantine [project ID] [reason]     Stops a project from being leased.
+	unquarantine [project ID]            Returns a quarantined project to the pool.
+	health-check [project IDs...]        Checks projects that are not leased, quarantining broken ones.
+	                                     Checks all projects if none are given.
+	status                               Displays the current status of the meta project. Respects -output.
+	                                     -output=json includes queue, lease history and metrics.
 `)
 
 	if flag.Arg(0) == "version" {
@@ -165,25 +151,23 @@ Administrative commands:
 		fmt.Fprintln(os.Stderr, usage.Error())
 		return nil
 	case "lease":
-		// When leasing, keep trying until we reach our configured timeout
-		ctx, cancel := context.WithTimeout(ctx, *waitTime)
-		defer cancel()
-		for ctx.Err() == nil {
-			err := lease(ctx, flag.Arg(1))
-			if err == nil {
-				return err
-			} else if errors.Is(err, ErrNoProjects) {
-				log.Printf("Temporary error: %v\n", err)
-				time.Sleep(30 * time.Second)
-			} else {
-				return err
-			}
-		}
-		return ctx.Err()
+		return lease(ctx, flag.Arg(1))
+	case "renew":
+		return renew(ctx, flag.Arg(1), flag.Arg(2))
+	case "heartbeat":
+		return heartbeat(ctx, flag.Arg(1), flag.Arg(2))
 	case "pool-add":
-		return addToPool(ctx, flag.Arg(1))
+		return addToPool(ctx, flag.Arg(1), flag.Args()[min(2, flag.NArg()):])
 	case "pool-rm":
 		return removeFromPool(ctx, flag.Arg(1))
+	case "pool-label":
+		return labelProject(ctx, flag.Arg(1), flag.Args()[min(2, flag.NArg()):])
+	case "quarantine":
+		return quarantine(ctx, flag.Arg(1), strings.Join(flag.Args()[min(2, flag.NArg()):], " "))
+	case "unquarantine":
+		return unquarantine(ctx, flag.Arg(1))
+	case "health-check":
+		return healthCheck(ctx, flag.Args()[1:])
 	case "status":
 		return status(ctx)
 	case "done":
@@ -222,42 +206,153 @@ func withPool(ctx context.Context, f func(pool *Pool) error) error {
 	return nil
 }
 
-func lease(ctx context.Context, duration string) error {
+func parseDuration(duration string) (time.Duration, error) {
 	if duration == "" {
-		return errors.New("must provide a duration (e.g. 10m). See https://golang.org/pkg/time/#ParseDuration")
+		return 0, errors.New("must provide a duration (e.g. 10m). See https://golang.org/pkg/time/#ParseDuration")
 	}
 	d, err := time.ParseDuration(duration)
 	if err != nil {
-		return fmt.Errorf("Could not parse duration: %w", err)
+		return 0, fmt.Errorf("Could not parse duration: %w", err)
 	}
+	return d, nil
+}
+
+func lease(ctx context.Context, duration string) error {
+	d, err := parseDuration(duration)
+	if err != nil {
+		return err
+	}
+
+	req := LeaseRequest{
+		Ticket:   fmt.Sprintf("%s/%d/%d", *holder, os.Getpid(), time.Now().UnixNano()),
+		Holder:   *holder,
+		Labels:   splitLabels(*leaseLabels),
+		Duration: d,
+	}
+
+	// When leasing, keep trying until we reach our configured timeout
+	ctx, cancel := context.WithTimeout(ctx, *waitTime)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
 
 	var proj *Project
+	defer func() {
+		if proj != nil {
+			return
+		}
+		// Give up our place in the queue so we don't hold up others
+		// until the waiter expires.
+		dqCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := withPool(dqCtx, func(pool *Pool) error {
+			pool.Dequeue(req.Ticket)
+			return nil
+		}); err != nil {
+			log.Printf("Could not leave the queue: %v", err)
+		}
+	}()
+
+	for {
+		var pos int
+		err := withPool(ctx, func(pool *Pool) error {
+			var ok bool
+			proj, pos, ok = pool.Lease(req, time.Now())
+			if !ok {
+				// Returning an error would discard our place in the queue.
+				proj = nil
+			}
+			return nil
+		})
+		switch {
+		case err != nil && ctx.Err() == nil && transient(err):
+			// Assume the lease didn't commit. If it did, the project is
+			// returned to the pool when the lease expires.
+			proj = nil
+			log.Printf("Temporary error: %v; retrying\n", err)
+		case err != nil:
+			proj = nil
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		case proj != nil:
+			fmt.Fprintf(os.Stderr, "Leased! %s is yours for %s.\n", proj.ID, d)
+			fmt.Print(proj.ID)
+			return nil
+		default:
+			log.Printf("Temporary error: %v; position %d in queue\n", ErrNoProjects, pos)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*pollTime):
+		}
+	}
+}
+
+// transient reports whether err is worth retrying while waiting for a lease:
+// the pool was contended by other callers, or Datastore was briefly
+// unavailable.
+func transient(err error) bool {
+	return errors.Is(err, ds.ErrConcurrentTransaction) || grpcstatus.Code(err) == codes.Unavailable
+}
+
+func renew(ctx context.Context, projectID, duration string) error {
+	if projectID == "" {
+		return errors.New("must provide project id")
+	}
+	d, err := parseDuration(duration)
+	if err != nil {
+		return err
+	}
 	err = withPool(ctx, func(pool *Pool) error {
-		var ok bool
-		proj, ok = pool.Lease(d)
-		if !ok {
-			return ErrNoProjects
+		if err := pool.Renew(projectID, *holder, d, time.Now()); err != nil {
+			return fmt.Errorf("could not renew %s for %s: %w", projectID, *holder, err)
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(os.Stderr, "Leased! %s is yours for %s.\n", proj.ID, d)
-	fmt.Print(proj.ID)
+	fmt.Fprintf(os.Stderr, "Renewed %s for %s.\n", projectID, d)
 	return nil
 }
 
+// heartbeat renews a lease every third of its duration until interrupted,
+// so a job that runs longer than expected keeps its project.
+func heartbeat(ctx context.Context, projectID, duration string) error {
+	d, err := parseDuration(duration)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	for {
+		if err := renew(ctx, projectID, duration); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(d / 3):
+		}
+	}
+}
+
 func done(ctx context.Context, projectID string) error {
 	if projectID == "" {
 		return errors.New("must provide project id")
 	}
 	err := withPool(ctx, func(pool *Pool) error {
-		proj, ok := pool.Get(projectID)
-		if !ok {
+		if err := pool.Return(projectID, time.Now()); err != nil {
 			return fmt.Errorf("Could not find project %s in project pool.", projectID)
 		}
-		proj.LeaseExpiry = time.Now().Add(-10 * time.Second)
 		return nil
 	})
 	if err != nil {
@@ -269,34 +364,48 @@ func done(ctx context.Context, projectID string) error {
 
 func status(ctx context.Context) error {
 	return withPool(ctx, func(pool *Pool) error {
+		switch *format {
+		case "", "list", "json":
+		default:
+			return errors.New("output may be '', 'list', 'json'")
+		}
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(pool.Status(time.Now()))
+		}
+
 		if *format == "" {
-			fmt.Printf("%-8s %s\n", "LEASE", "PROJECT")
+			fmt.Printf("%-8s %-12s %s\n", "LEASE", "LABELS", "PROJECT")
 		}
 		for _, proj := range pool.Projects {
 			exp := ""
-			if !proj.Expired() {
+			if proj.Quarantined {
+				exp = "QUARANTINED"
+			} else if !proj.Expired() {
 				secs := time.Until(proj.LeaseExpiry)
 				exp = secs.String()
 			}
 			switch *format {
 			case "":
-				fmt.Printf("%-8s %s\n", exp, proj.ID)
+				fmt.Printf("%-8s %-12s %s\n", exp, strings.Join(proj.Labels, ","), proj.ID)
 			case "list":
 				fmt.Printf("%s\n", proj.ID)
-			default:
-				return errors.New("output may be '', 'list'")
 			}
 		}
+		if *format == "" && len(pool.Queue) > 0 {
+			fmt.Printf("\n%d waiting for a project.\n", len(pool.Status(time.Now()).Queue))
+		}
 		return nil
 	})
 }
 
-func addToPool(ctx context.Context, proj string) error {
+func addToPool(ctx context.Context, proj string, labels []string) error {
 	if proj == "" {
 		return errors.New("must provide project id")
 	}
 	return withPool(ctx, func(pool *Pool) error {
-		if !pool.Add(proj) {
+		if !pool.Add(proj, labels...) {
 			return fmt.Errorf("%s already in pool", proj)
 		}
 		return nil
@@ -323,3 +432,98 @@ func removeFromPool(ctx context.Context, projectID string) error {
 		return nil
 	})
 }
+
+func labelProject(ctx context.Context, projectID string, labels []string) error {
+	if projectID == "" {
+		return errors.New("must provide project id")
+	}
+	return withPool(ctx, func(pool *Pool) error {
+		proj, ok := pool.Get(projectID)
+		if !ok {
+			return fmt.Errorf("%s not in pool", projectID)
+		}
+		proj.Labels = labels
+		return nil
+	})
+}
+
+func quarantine(ctx context.Context, projectID, reason string) error {
+	if projectID == "" {
+		return errors.New("must provide project id")
+	}
+	if reason == "" {
+		return errors.New("must provide a reason")
+	}
+	return withPool(ctx, func(pool *Pool) error {
+		if err := pool.Quarantine(projectID, reason, time.Now()); err != nil {
+			return fmt.Errorf("%s: %w", projectID, err)
+		}
+		return nil
+	})
+}
+
+func unquarantine(ctx context.Context, projectID string) error {
+	if projectID == "" {
+		return errors.New("must provide project id")
+	}
+	return withPool(ctx, func(pool *Pool) error {
+		if err := pool.Unquarantine(projectID, time.Now()); err != nil {
+			return fmt.Errorf("%s: %w", projectID, err)
+		}
+		return nil
+	})
+}
+
+// healthCheck checks the given projects, or all projects, and records the
+// results. Leased projects are skipped so a check never races with a test.
+func healthCheck(ctx context.Context, projectIDs []string) error {
+	crm, err := cloudresourcemanager.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("cloudresourcemanager.NewService: %w", err)
+	}
+
+	if len(projectIDs) == 0 {
+		if err := withPool(ctx, func(pool *Pool) error {
+			for _, proj := range pool.Projects {
+				if proj.Expired() {
+					projectIDs = append(projectIDs, proj.ID)
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Check outside of the transaction, which would otherwise be held open
+	// for every API call.
+	results := map[string]error{}
+	for _, id := range projectIDs {
+		results[id] = checkProject(ctx, crm, id)
+		if err := results[id]; err != nil {
+			fmt.Fprintf(os.Stderr, "%s: FAILED: %v\n", id, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ok\n", id)
+		}
+	}
+
+	return withPool(ctx, func(pool *Pool) error {
+		now := time.Now()
+		for id, checkErr := range results {
+			if err := pool.RecordHealth(id, checkErr, now); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
+func splitLabels(s string) []string {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
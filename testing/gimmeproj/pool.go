@@ -0,0 +1,440 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// waiterTTL is how long a waiter stays in the queue without polling.
+	// Waiters that crash or are killed fall out of the queue after this.
+	waiterTTL = 3 * time.Minute
+
+	// healthCheckReason prefixes the quarantine reason of projects that
+	// failed a health check.
+	healthCheckReason = "health check: "
+
+	// maxHistory bounds the lease history kept in the pool entity, which
+	// must stay under the Datastore entity size limit.
+	maxHistory = 500
+)
+
+// Lease history events.
+const (
+	EventLease        = "lease"
+	EventRenew        = "renew"
+	EventDone         = "done"
+	EventQuarantine   = "quarantine"
+	EventUnquarantine = "unquarantine"
+)
+
+var (
+	ErrNotFound    = errors.New("project not in pool")
+	ErrNotLeased   = errors.New("project is not leased")
+	ErrLeaseHolder = errors.New("project is leased by someone else")
+)
+
+// Pool is the state of the project pool. It is stored as a single Datastore
+// entity and only modified in transactions.
+type Pool struct {
+	Projects []Project
+	// Queue holds the callers waiting for a project, oldest first.
+	Queue   []Waiter      `datastore:",noindex"`
+	History []LeaseRecord `datastore:",noindex"`
+}
+
+type Project struct {
+	ID          string
+	LeaseExpiry time.Time
+	// Labels describe capabilities of the project, such as "gpu" or
+	// "spanner". A lease request only matches projects with all its labels.
+	Labels []string
+	// Holder identifies who holds the current or last lease.
+	Holder   string
+	LeasedAt time.Time
+
+	// Quarantined projects are never leased. Reason says why, and is
+	// prefixed with healthCheckReason when set by a health check. It can
+	// hold a long error, so it is not indexed: indexed strings are limited
+	// to 1500 bytes.
+	Quarantined      bool
+	QuarantineReason string `datastore:",noindex"`
+	LastHealthCheck  time.Time
+}
+
+// Waiter is a caller waiting in the lease queue.
+type Waiter struct {
+	Ticket   string
+	Holder   string
+	Labels   []string
+	Enqueued time.Time
+	// LastSeen is updated every time the waiter polls.
+	LastSeen time.Time
+}
+
+// LeaseRecord is an entry in the lease history.
+type LeaseRecord struct {
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Project string    `json:"project"`
+	Holder  string    `json:"holder,omitempty"`
+	// DurationSeconds is the lease duration of a lease or renew event.
+	DurationSeconds int64 `json:"durationSeconds,omitempty"`
+	// WaitSeconds is how long the holder waited in the queue before a
+	// lease event.
+	WaitSeconds int64  `json:"waitSeconds,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// LeaseRequest asks for a project.
+type LeaseRequest struct {
+	// Ticket identifies the request across polls and keeps its place in the
+	// queue.
+	Ticket   string
+	Holder   string
+	Labels   []string
+	Duration time.Duration
+}
+
+func (p *Pool) Get(projID string) (*Project, bool) {
+	for i := range p.Projects {
+		proj := &p.Projects[i]
+		if proj.ID == projID {
+			return proj, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Pool) Add(proj string, labels ...string) (ok bool) {
+	if _, ok := p.Get(proj); ok {
+		return false
+	}
+	p.Projects = append(p.Projects, Project{ID: proj, Labels: labels})
+	return true
+}
+
+// Lease tries to lease a project for req, queueing req if it cannot be
+// served yet. Requests are served first come, first served: a free project
+// is only given to req if no request ahead of it in the queue can use it.
+// It returns the project and true on success, or the 1-based queue position
+// of req and false.
+func (p *Pool) Lease(req LeaseRequest, now time.Time) (*Project, int, bool) {
+	p.pruneQueue(now)
+	pos := p.queueIndex(req.Ticket)
+	if pos < 0 {
+		p.Queue = append(p.Queue, Waiter{
+			Ticket:   req.Ticket,
+			Holder:   req.Holder,
+			Labels:   req.Labels,
+			Enqueued: now,
+		})
+		pos = len(p.Queue) - 1
+	}
+	p.Queue[pos].LastSeen = now
+
+	// Hand out free projects, least recently used first, to the waiters in
+	// queue order until req is reached.
+	free := p.free(now)
+	for i := 0; i <= pos; i++ {
+		j := pickProject(free, p.Queue[i].Labels)
+		if j < 0 {
+			continue
+		}
+		if i < pos {
+			// Reserved for an earlier waiter.
+			free = append(free[:j], free[j+1:]...)
+			continue
+		}
+
+		w := p.Queue[pos]
+		p.Queue = append(p.Queue[:pos], p.Queue[pos+1:]...)
+		proj := free[j]
+		proj.LeaseExpiry = now.Add(req.Duration)
+		proj.LeasedAt = now
+		proj.Holder = req.Holder
+		p.record(LeaseRecord{
+			Time:            now,
+			Event:           EventLease,
+			Project:         proj.ID,
+			Holder:          req.Holder,
+			DurationSeconds: int64(req.Duration.Seconds()),
+			WaitSeconds:     int64(now.Sub(w.Enqueued).Seconds()),
+		})
+		return proj, 0, true
+	}
+	return nil, pos + 1, false
+}
+
+// Dequeue removes a waiter that gave up.
+func (p *Pool) Dequeue(ticket string) {
+	if i := p.queueIndex(ticket); i >= 0 {
+		p.Queue = append(p.Queue[:i], p.Queue[i+1:]...)
+	}
+}
+
+// Renew extends an active lease held by holder to d from now.
+func (p *Pool) Renew(projID, holder string, d time.Duration, now time.Time) error {
+	proj, ok := p.Get(projID)
+	if !ok {
+		return ErrNotFound
+	}
+	if proj.Holder != holder {
+		return ErrLeaseHolder
+	}
+	if !proj.LeaseExpiry.After(now) {
+		// The project may already be leased to a waiter; a holder whose
+		// lease expired has to lease again.
+		return ErrNotLeased
+	}
+	proj.LeaseExpiry = now.Add(d)
+	p.record(LeaseRecord{Time: now, Event: EventRenew, Project: projID, Holder: holder, DurationSeconds: int64(d.Seconds())})
+	return nil
+}
+
+// Return ends the lease on a project.
+func (p *Pool) Return(projID string, now time.Time) error {
+	proj, ok := p.Get(projID)
+	if !ok {
+		return ErrNotFound
+	}
+	proj.LeaseExpiry = now.Add(-10 * time.Second)
+	p.record(LeaseRecord{Time: now, Event: EventDone, Project: projID, Holder: proj.Holder})
+	return nil
+}
+
+// Quarantine takes a project out of rotation. An active lease is not
+// revoked, but the project is not leased again until it is unquarantined.
+func (p *Pool) Quarantine(projID, reason string, now time.Time) error {
+	proj, ok := p.Get(projID)
+	if !ok {
+		return ErrNotFound
+	}
+	proj.Quarantined = true
+	proj.QuarantineReason = reason
+	p.record(LeaseRecord{Time: now, Event: EventQuarantine, Project: projID, Reason: reason})
+	return nil
+}
+
+// Unquarantine returns a quarantined project to rotation.
+func (p *Pool) Unquarantine(projID string, now time.Time) error {
+	proj, ok := p.Get(projID)
+	if !ok {
+		return ErrNotFound
+	}
+	if !proj.Quarantined {
+		return fmt.Errorf("%s is not quarantined", projID)
+	}
+	proj.Quarantined = false
+	proj.QuarantineReason = ""
+	p.record(LeaseRecord{Time: now, Event: EventUnquarantine, Project: projID})
+	return nil
+}
+
+// RecordHealth records the result of a health check of a project. A failing
+// project is quarantined, and a project quarantined by an earlier health
+// check is returned to rotation once it passes. Projects quarantined by hand
+// stay quarantined.
+func (p *Pool) RecordHealth(projID string, checkErr error, now time.Time) error {
+	proj, ok := p.Get(projID)
+	if !ok {
+		return ErrNotFound
+	}
+	proj.LastHealthCheck = now
+	switch {
+	case checkErr != nil && !proj.Quarantined:
+		return p.Quarantine(projID, healthCheckReason+checkErr.Error(), now)
+	case checkErr == nil && proj.Quarantined && strings.HasPrefix(proj.QuarantineReason, healthCheckReason):
+		return p.Unquarantine(projID, now)
+	}
+	return nil
+}
+
+// free returns the projects that can be leased, least recently leased first.
+func (p *Pool) free(now time.Time) []*Project {
+	var free []*Project
+	for i := range p.Projects {
+		proj := &p.Projects[i]
+		if !proj.Quarantined && !proj.LeaseExpiry.After(now) {
+			free = append(free, proj)
+		}
+	}
+	sort.SliceStable(free, func(i, j int) bool {
+		return free[i].LeaseExpiry.Before(free[j].LeaseExpiry)
+	})
+	return free
+}
+
+// pickProject returns the index of the first project in projs with all of
+// labels, or -1.
+func pickProject(projs []*Project, labels []string) int {
+	for i, proj := range projs {
+		if proj.HasLabels(labels) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Pool) queueIndex(ticket string) int {
+	for i, w := range p.Queue {
+		if w.Ticket == ticket {
+			return i
+		}
+	}
+	return -1
+}
+
+// pruneQueue drops waiters that stopped polling.
+func (p *Pool) pruneQueue(now time.Time) {
+	queue := p.Queue[:0]
+	for _, w := range p.Queue {
+		if now.Sub(w.LastSeen) < waiterTTL {
+			queue = append(queue, w)
+		}
+	}
+	p.Queue = queue
+}
+
+func (p *Pool) record(r LeaseRecord) {
+	p.History = append(p.History, r)
+	if n := len(p.History); n > maxHistory {
+		p.History = append([]LeaseRecord(nil), p.History[n-maxHistory:]...)
+	}
+}
+
+func (p *Project) Expired() bool {
+	return time.Now().After(p.LeaseExpiry)
+}
+
+// HasLabels reports whether the project has all of labels.
+func (p *Project) HasLabels(labels []string) bool {
+	for _, want := range labels {
+		var found bool
+		for _, l := range p.Labels {
+			if l == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Status is a snapshot of the pool, printed by the status command.
+type Status struct {
+	Time     time.Time       `json:"time"`
+	Projects []ProjectStatus `json:"projects"`
+	Queue    []WaiterStatus  `json:"queue"`
+	History  []LeaseRecord   `json:"history"`
+	Summary  Summary         `json:"summary"`
+}
+
+type ProjectStatus struct {
+	ID               string     `json:"id"`
+	Labels           []string   `json:"labels,omitempty"`
+	Leased           bool       `json:"leased"`
+	Holder           string     `json:"holder,omitempty"`
+	LeaseExpiry      *time.Time `json:"leaseExpiry,omitempty"`
+	Quarantined      bool       `json:"quarantined,omitempty"`
+	QuarantineReason string     `json:"quarantineReason,omitempty"`
+	LastHealthCheck  *time.Time `json:"lastHealthCheck,omitempty"`
+}
+
+type WaiterStatus struct {
+	Holder      string   `json:"holder,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	WaitSeconds int64    `json:"waitSeconds"`
+}
+
+// Summary aggregates pool metrics.
+type Summary struct {
+	Total       int `json:"total"`
+	Leased      int `json:"leased"`
+	Free        int `json:"free"`
+	Quarantined int `json:"quarantined"`
+	Waiting     int `json:"waiting"`
+	// Leases and MeanWaitSeconds cover the lease events in the history.
+	Leases          int     `json:"leases"`
+	MeanWaitSeconds float64 `json:"meanWaitSeconds"`
+	MaxWaitSeconds  int64   `json:"maxWaitSeconds"`
+}
+
+// Status returns a snapshot of the pool at now.
+func (p *Pool) Status(now time.Time) Status {
+	s := Status{Time: now, History: p.History}
+	for _, proj := range p.Projects {
+		ps := ProjectStatus{
+			ID:               proj.ID,
+			Labels:           proj.Labels,
+			Leased:           proj.LeaseExpiry.After(now),
+			Quarantined:      proj.Quarantined,
+			QuarantineReason: proj.QuarantineReason,
+		}
+		if ps.Leased {
+			ps.Holder = proj.Holder
+			ps.LeaseExpiry = &proj.LeaseExpiry
+		}
+		if !proj.LastHealthCheck.IsZero() {
+			ps.LastHealthCheck = &proj.LastHealthCheck
+		}
+		s.Projects = append(s.Projects, ps)
+
+		s.Summary.Total++
+		switch {
+		case ps.Leased:
+			s.Summary.Leased++
+		case ps.Quarantined:
+			s.Summary.Quarantined++
+		default:
+			s.Summary.Free++
+		}
+	}
+	for _, w := range p.Queue {
+		if now.Sub(w.LastSeen) >= waiterTTL {
+			continue
+		}
+		s.Queue = append(s.Queue, WaiterStatus{
+			Holder:      w.Holder,
+			Labels:      w.Labels,
+			WaitSeconds: int64(now.Sub(w.Enqueued).Seconds()),
+		})
+	}
+	s.Summary.Waiting = len(s.Queue)
+
+	var totalWait int64
+	for _, r := range p.History {
+		if r.Event != EventLease {
+			continue
+		}
+		s.Summary.Leases++
+		totalWait += r.WaitSeconds
+		if r.WaitSeconds > s.Summary.MaxWaitSeconds {
+			s.Summary.MaxWaitSeconds = r.WaitSeconds
+		}
+	}
+	if s.Summary.Leases > 0 {
+		s.Summary.MeanWaitSeconds = float64(totalWait) / float64(s.Summary.Leases)
+	}
+	return s
+}
@@ -0,0 +1,174 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func req(ticket string, labels ...string) LeaseRequest {
+	return LeaseRequest{Ticket: ticket, Holder: ticket, Labels: labels, Duration: time.Hour}
+}
+
+func TestLeaseFIFO(t *testing.T) {
+	var pool Pool
+	pool.Add("p1")
+
+	if proj, _, ok := pool.Lease(req("a"), t0); !ok || proj.ID != "p1" {
+		t.Fatalf("Lease(a) = %v, %v, want p1", proj, ok)
+	}
+	// b and c queue up behind the lease.
+	if _, pos, ok := pool.Lease(req("b"), t0.Add(time.Second)); ok || pos != 1 {
+		t.Fatalf("Lease(b) = pos %d, %v, want pos 1, false", pos, ok)
+	}
+	if _, pos, ok := pool.Lease(req("c"), t0.Add(2*time.Second)); ok || pos != 2 {
+		t.Fatalf("Lease(c) = pos %d, %v, want pos 2, false", pos, ok)
+	}
+
+	if err := pool.Return("p1", t0.Add(time.Minute)); err != nil {
+		t.Fatalf("Return: %v", err)
+	}
+	// c polls first, but b is ahead of it.
+	if _, pos, ok := pool.Lease(req("c"), t0.Add(time.Minute)); ok || pos != 2 {
+		t.Errorf("Lease(c) after return = pos %d, %v, want pos 2, false", pos, ok)
+	}
+	proj, _, ok := pool.Lease(req("b"), t0.Add(time.Minute))
+	if !ok || proj.ID != "p1" || proj.Holder != "b" {
+		t.Fatalf("Lease(b) after return = %+v, %v, want p1 held by b", proj, ok)
+	}
+	if _, pos, _ := pool.Lease(req("c"), t0.Add(time.Minute)); pos != 1 {
+		t.Errorf("c position = %d, want 1", pos)
+	}
+
+	var last LeaseRecord
+	for _, r := range pool.History {
+		if r.Event == EventLease {
+			last = r
+		}
+	}
+	if last.Holder != "b" || last.WaitSeconds != 59 {
+		t.Errorf("last lease record = %+v, want holder b waiting 59s", last)
+	}
+}
+
+func TestLeaseLabels(t *testing.T) {
+	var pool Pool
+	pool.Add("plain")
+	pool.Add("gpu", "gpu")
+
+	// A waiter for a GPU project at the head of the queue does not block a
+	// waiter that can use the plain project, and vice versa.
+	pool.Lease(req("x"), t0)
+	if _, _, ok := pool.Lease(req("spanner", "spanner"), t0); ok {
+		t.Fatalf("Lease(spanner) succeeded, want no project with the label")
+	}
+	proj, _, ok := pool.Lease(req("gpu", "gpu"), t0)
+	if !ok || proj.ID != "gpu" {
+		t.Fatalf("Lease(gpu) = %+v, %v, want gpu", proj, ok)
+	}
+	if _, pos, _ := pool.Lease(req("spanner", "spanner"), t0); pos != 1 {
+		t.Errorf("spanner position = %d, want 1", pos)
+	}
+}
+
+func TestLeaseWaiterExpires(t *testing.T) {
+	var pool Pool
+	pool.Add("p1")
+	pool.Lease(req("a"), t0)
+	pool.Lease(req("gone"), t0)
+	pool.Return("p1", t0.Add(time.Minute))
+
+	// "gone" stopped polling, so "b" gets the project once it has expired.
+	if _, _, ok := pool.Lease(req("b"), t0.Add(time.Minute)); ok {
+		t.Fatalf("Lease(b) succeeded while an earlier waiter is still live")
+	}
+	if proj, _, ok := pool.Lease(req("b"), t0.Add(waiterTTL+time.Minute)); !ok || proj.ID != "p1" {
+		t.Fatalf("Lease(b) after waiter expiry = %+v, %v, want p1", proj, ok)
+	}
+}
+
+func TestRenew(t *testing.T) {
+	var pool Pool
+	pool.Add("p1")
+	pool.Lease(req("a"), t0)
+
+	if err := pool.Renew("p1", "a", 2*time.Hour, t0.Add(30*time.Minute)); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	proj, _ := pool.Get("p1")
+	if want := t0.Add(150 * time.Minute); !proj.LeaseExpiry.Equal(want) {
+		t.Errorf("LeaseExpiry = %v, want %v", proj.LeaseExpiry, want)
+	}
+	if err := pool.Renew("p1", "b", time.Hour, t0); !errors.Is(err, ErrLeaseHolder) {
+		t.Errorf("Renew by another holder: err = %v, want %v", err, ErrLeaseHolder)
+	}
+	if err := pool.Renew("p1", "a", time.Hour, t0.Add(3*time.Hour)); !errors.Is(err, ErrNotLeased) {
+		t.Errorf("Renew after expiry: err = %v, want %v", err, ErrNotLeased)
+	}
+	if err := pool.Renew("nope", "a", time.Hour, t0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Renew unknown project: err = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	var pool Pool
+	pool.Add("p1")
+
+	if err := pool.RecordHealth("p1", errors.New("project is DELETE_REQUESTED"), t0); err != nil {
+		t.Fatalf("RecordHealth: %v", err)
+	}
+	if _, _, ok := pool.Lease(req("a"), t0); ok {
+		t.Fatalf("leased a quarantined project")
+	}
+	if s := pool.Status(t0).Summary; s.Quarantined != 1 || s.Waiting != 1 {
+		t.Errorf("Summary = %+v, want 1 quarantined and 1 waiting", s)
+	}
+
+	// A passing health check undoes its own quarantine...
+	if err := pool.RecordHealth("p1", nil, t0); err != nil {
+		t.Fatalf("RecordHealth: %v", err)
+	}
+	if proj, _, ok := pool.Lease(req("a"), t0); !ok || proj.ID != "p1" {
+		t.Fatalf("Lease after recovery = %+v, %v, want p1", proj, ok)
+	}
+
+	// ...but not a manual one.
+	pool.Quarantine("p1", "billing disabled", t0)
+	pool.RecordHealth("p1", nil, t0)
+	if proj, _ := pool.Get("p1"); !proj.Quarantined {
+		t.Errorf("health check lifted a manual quarantine")
+	}
+	if err := pool.Unquarantine("p1", t0); err != nil {
+		t.Errorf("Unquarantine: %v", err)
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	var pool Pool
+	pool.Add("p1")
+	for i := 0; i < maxHistory+10; i++ {
+		pool.Return("p1", t0.Add(time.Duration(i)*time.Second))
+	}
+	if got := len(pool.History); got != maxHistory {
+		t.Errorf("len(History) = %d, want %d", got, maxHistory)
+	}
+	if got, want := pool.History[0].Time, t0.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("oldest record at %v, want %v", got, want)
+	}
+}